@@ -223,7 +223,7 @@ func (c *NetConn) doClose(err error) anet.Status {
 	}
 
 	c.wbuf.Clear()
-	c.SetStatus(status)
+	c.SetStatus(anet.CLOSED)
 	if c.sock != nil {
 		_ = c.sock.Close()
 		c.sock = nil
@@ -274,6 +274,7 @@ func (c *NetConn) doWrite() {
 		status := c.Status()
 		for (status == anet.CONNECTING) || (status == anet.OPEN && c.wbuf.Empty()) {
 			c.cond.Wait()
+			status = c.Status()
 		}
 
 		buffer.Swap(c.wbuf, b)
@@ -294,6 +295,11 @@ func (c *NetConn) doWrite() {
 			break
 		}
 
+		// 读协程出错已经关闭
+		if status == anet.CLOSED {
+			break
+		}
+
 		b.Clear()
 	}
 }
@@ -0,0 +1,50 @@
+package tcp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/anet/base"
+)
+
+type nopFilter struct {
+	base.Filter
+}
+
+func (f *nopFilter) Name() string {
+	return "nop"
+}
+
+func TestRemoteClose(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		if sock, err := l.Accept(); err == nil {
+			_ = sock.Close()
+		}
+	}()
+
+	tran := New()
+	tran.AddFilters(&nopFilter{})
+	defer tran.Close()
+	conn, err := tran.Dial(l.Addr().String(), anet.WithBlocking(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 对端关闭后,读协程出错需要将连接置为CLOSED
+	deadline := time.Now().Add(time.Second * 5)
+	for conn.IsActive() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if conn.Status() != anet.CLOSED {
+		t.Fatalf("bad status, %v", conn.Status())
+	}
+}
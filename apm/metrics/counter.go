@@ -1 +1,30 @@
 package metrics
+
+import "sync/atomic"
+
+// Counter 计数器,通常只增不减,比如请求次数
+type Counter interface {
+	Inc()
+	Add(delta int64)
+	Count() int64
+}
+
+func NewCounter() Counter {
+	return &counter{}
+}
+
+type counter struct {
+	count int64
+}
+
+func (c *counter) Inc() {
+	atomic.AddInt64(&c.count, 1)
+}
+
+func (c *counter) Add(delta int64) {
+	atomic.AddInt64(&c.count, delta)
+}
+
+func (c *counter) Count() int64 {
+	return atomic.LoadInt64(&c.count)
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+)
+
+// RegisterExecutor 将执行器的统计信息注册到Registry中,名字为exec.<name>.queued等
+// 使用GaugeFunc,读取时才会调用Stats
+func RegisterExecutor(r Registry, name string, e exec.Executor) {
+	if r == nil {
+		r = Default()
+	}
+
+	prefix := "exec." + name + "."
+	r.Register(prefix+"queued", NewGaugeFunc(func() int64 { return e.Stats().Queued }))
+	r.Register(prefix+"running", NewGaugeFunc(func() int64 { return e.Stats().Running }))
+	r.Register(prefix+"completed", NewGaugeFunc(func() int64 { return e.Stats().Completed }))
+	r.Register(prefix+"rejected", NewGaugeFunc(func() int64 { return e.Stats().Rejected }))
+	r.Register(prefix+"max_latency_ms", NewGaugeFunc(func() int64 {
+		return int64(e.Stats().MaxLatency / time.Millisecond)
+	}))
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/jeckbjy/gsk/exec"
+	"github.com/jeckbjy/gsk/exec/pooled"
+)
+
+type taskFunc func() error
+
+func (f taskFunc) Run() error {
+	return f()
+}
+
+func TestRegisterExecutor(t *testing.T) {
+	e := pooled.New(1, exec.QueueSize(1, exec.PolicyDropNewest))
+	r := NewRegistry()
+	RegisterExecutor(r, "pool", e)
+
+	value := func(name string) int64 {
+		g, ok := r.Get("exec.pool." + name).(Gauge)
+		if !ok {
+			t.Fatalf("metric not registered, %s", name)
+		}
+		return g.Value()
+	}
+
+	// 第一个任务阻塞,第二个任务排队,第三个任务被拒绝
+	var started, block sync.WaitGroup
+	started.Add(1)
+	block.Add(1)
+	_ = e.Post(taskFunc(func() error {
+		started.Done()
+		block.Wait()
+		return nil
+	}))
+	started.Wait()
+	_ = e.Post(taskFunc(func() error { return nil }))
+	if err := e.Post(taskFunc(func() error { return nil })); err != exec.ErrRejected {
+		t.Fatalf("expect rejected, %+v", err)
+	}
+
+	if value("running") != 1 || value("queued") != 1 || value("rejected") != 1 {
+		t.Fatalf("bad stats, %+v", e.Stats())
+	}
+
+	block.Done()
+	_ = e.Stop()
+	e.Wait()
+	if value("completed") != 2 || value("queued") != 0 || value("running") != 0 {
+		t.Fatalf("bad stats, %+v", e.Stats())
+	}
+	value("max_latency_ms")
+}
@@ -1 +1,39 @@
 package metrics
+
+import "sync/atomic"
+
+// Gauge 瞬时值,比如队列长度,内存使用
+type Gauge interface {
+	Update(v int64)
+	Value() int64
+}
+
+func NewGauge() Gauge {
+	return &gauge{}
+}
+
+type gauge struct {
+	value int64
+}
+
+func (g *gauge) Update(v int64) {
+	atomic.StoreInt64(&g.value, v)
+}
+
+func (g *gauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+// NewGaugeFunc 读取时调用fn计算,用于导出已有的统计数据,Update无效
+func NewGaugeFunc(fn func() int64) Gauge {
+	return gaugeFunc(fn)
+}
+
+type gaugeFunc func() int64
+
+func (g gaugeFunc) Update(v int64) {
+}
+
+func (g gaugeFunc) Value() int64 {
+	return g()
+}
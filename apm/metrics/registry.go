@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+var gRegistry = NewRegistry()
+
+// Default 返回全局默认的Registry
+func Default() Registry {
+	return gRegistry
+}
+
+// Registry 按照名字管理所有指标,导出到Prometheus,InfluxDB等时通过Each遍历
+type Registry interface {
+	// Counter 获取或者创建Counter
+	Counter(name string) Counter
+	// Gauge 获取或者创建Gauge
+	Gauge(name string) Gauge
+	// Register 注册指标,已经存在时覆盖
+	Register(name string, metric interface{})
+	// Unregister 删除指标
+	Unregister(name string)
+	// Get 查询指标,不存在返回nil
+	Get(name string) interface{}
+	// Each 按照名字顺序遍历
+	Each(fn func(name string, metric interface{}))
+}
+
+func NewRegistry() Registry {
+	return &registry{metrics: make(map[string]interface{})}
+}
+
+type registry struct {
+	mux     sync.RWMutex
+	metrics map[string]interface{}
+}
+
+func (r *registry) Counter(name string) Counter {
+	return r.getOrRegister(name, func() interface{} { return NewCounter() }).(Counter)
+}
+
+func (r *registry) Gauge(name string) Gauge {
+	return r.getOrRegister(name, func() interface{} { return NewGauge() }).(Gauge)
+}
+
+func (r *registry) getOrRegister(name string, create func() interface{}) interface{} {
+	r.mux.RLock()
+	m, ok := r.metrics[name]
+	r.mux.RUnlock()
+	if ok {
+		return m
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		return m
+	}
+
+	m = create()
+	r.metrics[name] = m
+	return m
+}
+
+func (r *registry) Register(name string, metric interface{}) {
+	r.mux.Lock()
+	r.metrics[name] = metric
+	r.mux.Unlock()
+}
+
+func (r *registry) Unregister(name string) {
+	r.mux.Lock()
+	delete(r.metrics, name)
+	r.mux.Unlock()
+}
+
+func (r *registry) Get(name string) interface{} {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.metrics[name]
+}
+
+func (r *registry) Each(fn func(name string, metric interface{})) {
+	r.mux.RLock()
+	names := make([]string, 0, len(r.metrics))
+	metrics := make(map[string]interface{}, len(r.metrics))
+	for name, m := range r.metrics {
+		names = append(names, name)
+		metrics[name] = m
+	}
+	r.mux.RUnlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		fn(name, metrics[name])
+	}
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.Counter("requests")
+	c.Inc()
+	c.Add(2)
+	if r.Counter("requests") != c || c.Count() != 3 {
+		t.Fatalf("bad counter, %d", c.Count())
+	}
+
+	g := r.Gauge("queue")
+	g.Update(5)
+	if r.Gauge("queue") != g || g.Value() != 5 {
+		t.Fatalf("bad gauge, %d", g.Value())
+	}
+
+	// Register覆盖已有的指标
+	r.Register("queue", NewGaugeFunc(func() int64 { return 7 }))
+	if v := r.Get("queue").(Gauge).Value(); v != 7 {
+		t.Fatalf("register not override, %d", v)
+	}
+
+	r.Register("alive", NewGauge())
+	var names []string
+	r.Each(func(name string, metric interface{}) {
+		names = append(names, name)
+	})
+	if !reflect.DeepEqual(names, []string{"alive", "queue", "requests"}) {
+		t.Fatalf("bad order, %+v", names)
+	}
+
+	r.Unregister("alive")
+	if r.Get("alive") != nil {
+		t.Fatal("unregister fail")
+	}
+}
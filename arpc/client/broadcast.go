@@ -0,0 +1,99 @@
+package client
+
+import (
+	"reflect"
+
+	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/selector"
+)
+
+// Broadcast 向服务的所有节点并行发送请求,并汇总每个节点的结果
+// 代理模式下无法获取真实节点,因此不支持广播
+func (c *_Client) Broadcast(service string, msg interface{}, rsp interface{}, opts ...arpc.MiscOption) (map[string]*arpc.Reply, error) {
+	if len(c.opts.Proxy) > 0 {
+		return nil, arpc.ErrNotSupport
+	}
+
+	if _, ok := msg.(arpc.Packet); ok {
+		return nil, arpc.ErrInvalidParam
+	}
+
+	var rspType reflect.Type
+	if rsp != nil {
+		rspType = reflect.TypeOf(rsp)
+		if rspType.Kind() != reflect.Ptr || rspType.Elem().Kind() != reflect.Struct {
+			return nil, arpc.ErrInvalidResponse
+		}
+	}
+
+	o := &arpc.MiscOptions{}
+	o.Init(opts...)
+
+	nodes, err := selector.Nodes(c.opts.Selector, service, &o.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	// 缓冲足够大,提前返回时也不会阻塞未完成的协程
+	replyCh := make(chan *arpc.Reply, len(nodes))
+	for _, node := range nodes {
+		go func(node selector.Node) {
+			reply := &arpc.Reply{Node: node.Id()}
+			if rspType == nil {
+				reply.Error = c.invoke(node, c.newRequest(msg, o), o, c.send)
+			} else {
+				reply.Response, reply.Error = c.callNode(node, msg, rspType, o)
+			}
+			replyCh <- reply
+		}(node)
+	}
+
+	quorum := o.Quorum
+	if quorum <= 0 || quorum > len(nodes) {
+		quorum = len(nodes)
+	}
+
+	results := make(map[string]*arpc.Reply, len(nodes))
+	success := 0
+	for i := 0; i < len(nodes); i++ {
+		reply := <-replyCh
+		results[reply.Node] = reply
+		if reply.Error == nil {
+			success++
+			if success >= quorum {
+				return results, nil
+			}
+		} else if o.FailFast {
+			return results, reply.Error
+		}
+	}
+
+	return results, arpc.ErrPartialFailure
+}
+
+// callNode 向指定节点同步调用,每个节点使用独立的Packet,Future和应答消息
+func (c *_Client) callNode(node selector.Node, msg interface{}, rspType reflect.Type, opts *arpc.MiscOptions) (interface{}, error) {
+	o := *opts
+	o.Response = reflect.New(rspType.Elem()).Interface()
+	o.Future = NewFuture()
+	o.RetryCB = nil
+
+	req := c.newRequest(msg, &o)
+	req.SetInternal(&o)
+	req.SetSeqID(arpc.NewSequenceID())
+
+	err := c.invoke(node, req, &o, func(node selector.Node, req arpc.Packet, o *arpc.MiscOptions) error {
+		done := track(node, o)
+		if err := c.send(node, req, o); err != nil {
+			done(err)
+			return err
+		}
+		return o.Future.Wait()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return o.Response, nil
+}
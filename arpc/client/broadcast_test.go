@@ -0,0 +1,177 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/arpc/packet"
+	"github.com/jeckbjy/gsk/selector"
+)
+
+func init() {
+	arpc.SetPacketFactory(packet.New)
+}
+
+type echoReq struct {
+	Text string
+}
+
+type echoRsp struct {
+	Node string
+	Text string
+}
+
+var errDown = errors.New("node down")
+
+// testNode 模拟节点,发送请求后延迟delay应答,err不为空时连接失败
+type testNode struct {
+	id    string
+	delay time.Duration
+	err   error
+	stats selector.Stats
+}
+
+func (n *testNode) Id() string             { return n.id }
+func (n *testNode) Addr() string           { return n.id }
+func (n *testNode) Stats() *selector.Stats { return &n.stats }
+func (n *testNode) Conn(tran anet.Tran) (anet.Conn, error) {
+	if n.err != nil {
+		return nil, n.err
+	}
+
+	return &testConn{node: n}, nil
+}
+
+// testConn 只实现了Send,直接模拟RpcRouter收到应答
+type testConn struct {
+	anet.Conn
+	node *testNode
+}
+
+func (c *testConn) Send(msg interface{}) error {
+	req := msg.(arpc.Packet)
+	o, ok := req.Internal().(*arpc.MiscOptions)
+	if !ok {
+		return nil
+	}
+
+	if o.Future != nil {
+		o.Future.Add()
+	}
+	go func() {
+		time.Sleep(c.node.delay)
+		if o.OnDone != nil {
+			o.OnDone(nil)
+		}
+		switch rsp := o.Response.(type) {
+		case *echoRsp:
+			rsp.Node = c.node.id
+			rsp.Text = req.Body().(*echoReq).Text
+		case func(rsp *echoRsp) error:
+			_ = rsp(&echoRsp{Node: c.node.id})
+		}
+		if o.Future != nil {
+			o.Future.Done(nil)
+		}
+	}()
+
+	return nil
+}
+
+type testSelector struct {
+	nodes []selector.Node
+}
+
+func (s *testSelector) Name() string { return "test" }
+func (s *testSelector) Close() error { return nil }
+func (s *testSelector) Select(service string, opts *selector.Options) (selector.Next, error) {
+	return opts.GetNext(s.nodes), nil
+}
+
+func (s *testSelector) List(service string, opts *selector.Options) ([]selector.Node, error) {
+	return s.nodes, nil
+}
+
+func newTestClient(nodes ...*testNode) arpc.Client {
+	s := &testSelector{}
+	for _, n := range nodes {
+		s.nodes = append(s.nodes, n)
+	}
+
+	return New(Selector(s))
+}
+
+func TestBroadcast(t *testing.T) {
+	c := newTestClient(&testNode{id: "a"}, &testNode{id: "b"}, &testNode{id: "c"})
+	results, err := c.Broadcast("echo", &echoReq{Text: "hello"}, &echoRsp{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("bad results, %+v", results)
+	}
+	for id, reply := range results {
+		rsp := reply.Response.(*echoRsp)
+		if reply.Error != nil || rsp.Node != id || rsp.Text != "hello" {
+			t.Fatalf("bad reply, %s, %+v", id, reply)
+		}
+	}
+
+	// 仅发送
+	results, err = c.Broadcast("echo", &echoReq{Text: "hello"}, nil)
+	if err != nil || len(results) != 3 {
+		t.Fatalf("send fail, %+v, %v", results, err)
+	}
+}
+
+func TestBroadcastQuorum(t *testing.T) {
+	c := newTestClient(&testNode{id: "a"}, &testNode{id: "b"}, &testNode{id: "slow", delay: time.Second})
+
+	start := time.Now()
+	results, err := c.Broadcast("echo", &echoReq{}, &echoRsp{}, arpc.WithQuorum(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > time.Millisecond*500 || results["slow"] != nil || len(results) != 2 {
+		t.Fatalf("should not wait slow node, %+v", results)
+	}
+}
+
+func TestBroadcastFailure(t *testing.T) {
+	c := newTestClient(&testNode{id: "a"}, &testNode{id: "down", err: errDown}, &testNode{id: "slow", delay: time.Second})
+
+	// FailFast时任意节点失败立即返回
+	start := time.Now()
+	results, err := c.Broadcast("echo", &echoReq{}, &echoRsp{}, arpc.WithFailFast())
+	if err != errDown || time.Since(start) > time.Millisecond*500 {
+		t.Fatalf("should fail fast, %v", err)
+	}
+	if reply := results["down"]; reply == nil || reply.Error != errDown {
+		t.Fatalf("bad reply, %+v", results)
+	}
+
+	// 成功数不足时返回ErrPartialFailure,结果中包含所有节点
+	results, err = c.Broadcast("echo", &echoReq{}, &echoRsp{})
+	if err != arpc.ErrPartialFailure || len(results) != 3 {
+		t.Fatalf("should partial failure, %+v, %v", results, err)
+	}
+	if results["down"].Error != errDown || results["slow"].Error != nil || results["a"].Error != nil {
+		t.Fatalf("bad results, %+v", results)
+	}
+}
+
+func TestBroadcastNotSupport(t *testing.T) {
+	c := New(Selector(&struct{ selector.Selector }{}))
+	if _, err := c.Broadcast("echo", &echoReq{}, nil); err == nil {
+		t.Fatal("selector without Lister should fail")
+	}
+
+	c = New(Proxy("gateway"))
+	if _, err := c.Broadcast("echo", &echoReq{}, nil); err != arpc.ErrNotSupport {
+		t.Fatalf("proxy should not support, %v", err)
+	}
+}
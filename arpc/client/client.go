@@ -2,9 +2,9 @@ package client
 
 import (
 	"reflect"
+	"sync"
 	"time"
 
-	"github.com/jeckbjy/gsk/anet"
 	"github.com/jeckbjy/gsk/arpc"
 	"github.com/jeckbjy/gsk/selector"
 )
@@ -15,11 +15,12 @@ func New(opts ...arpc.Option) arpc.Client {
 		fn(o)
 	}
 
-	return &_Client{opts: o}
+	return &_Client{opts: o, interceptor: arpc.ChainInterceptor(o.Interceptors...)}
 }
 
 type _Client struct {
-	opts *arpc.Options
+	opts        *arpc.Options
+	interceptor arpc.Interceptor
 }
 
 func (c *_Client) Init(opts ...arpc.Option) error {
@@ -27,6 +28,7 @@ func (c *_Client) Init(opts ...arpc.Option) error {
 		fn(c.opts)
 	}
 
+	c.interceptor = arpc.ChainInterceptor(c.opts.Interceptors...)
 	return nil
 }
 
@@ -40,13 +42,19 @@ func (c *_Client) Send(service string, msg interface{}, opts ...arpc.MiscOption)
 		return err
 	}
 
-	pkg := c.newRequest(msg, &o)
-	conn, err := c.getConn(next)
+	node, err := next()
 	if err != nil {
 		return err
 	}
 
-	return conn.Send(pkg)
+	pkg := c.newRequest(msg, &o)
+	return c.invoke(node, pkg, &o, func(node selector.Node, req arpc.Packet, o *arpc.MiscOptions) error {
+		// 不需要等待应答,发送完成即结束统计
+		end := selector.Begin(node)
+		err := c.send(node, req, o)
+		end(err)
+		return err
+	})
 }
 
 // Call - 异步RPC调用
@@ -75,7 +83,12 @@ func (c *_Client) Call(service string, msg interface{}, rsp interface{}, opts ..
 		o.RetryCB = func(req arpc.Packet) time.Duration {
 			// 防止重复接收,使用新的seqID
 			req.SetSeqID(arpc.NewSequenceID())
-			if err := c.sendMsg(next, req); err != nil {
+			node, err := next()
+			if err != nil {
+				return 0
+			}
+			// 重试同样需要经过拦截器
+			if err := c.invoke(node, req, o, c.send); err != nil {
 				return 0
 			}
 			// TODO:可以使用backoff方法计算ttl
@@ -83,36 +96,44 @@ func (c *_Client) Call(service string, msg interface{}, rsp interface{}, opts ..
 		}
 	}
 
+	node, err := next()
+	if err != nil {
+		return err
+	}
+
 	req := c.newRequest(msg, o)
 	req.SetInternal(o)
 	req.SetSeqID(arpc.NewSequenceID())
 
-	err = c.sendMsg(next, req)
-	if err != nil {
-		return err
-	}
-	if autoWait {
-		return o.Future.Wait()
-	}
-	return nil
+	return c.invoke(node, req, o, func(node selector.Node, req arpc.Packet, o *arpc.MiscOptions) error {
+		done := track(node, o)
+		if err := c.send(node, req, o); err != nil {
+			done(err)
+			return err
+		}
+		if autoWait {
+			return o.Future.Wait()
+		}
+		return nil
+	})
 }
 
-func (c *_Client) sendMsg(next selector.Next, pkg arpc.Packet) error {
-	conn, err := c.getConn(next)
-	if err == nil {
-		return conn.Send(pkg)
+// invoke 经过拦截器调用invoker
+func (c *_Client) invoke(node selector.Node, req arpc.Packet, o *arpc.MiscOptions, invoker arpc.Invoker) error {
+	if c.interceptor != nil {
+		return c.interceptor(node, req, o, invoker)
 	}
 
-	return err
+	return invoker(node, req, o)
 }
 
-func (c *_Client) getConn(next selector.Next) (anet.Conn, error) {
-	node, err := next()
-	if err == nil {
-		return node.Conn(c.opts.Tran)
-	} else {
-		return nil, err
+func (c *_Client) send(node selector.Node, req arpc.Packet, o *arpc.MiscOptions) error {
+	conn, err := node.Conn(c.opts.Tran)
+	if err != nil {
+		return err
 	}
+
+	return conn.Send(req)
 }
 
 func (c *_Client) getNext(service string, opts *arpc.MiscOptions) (selector.Next, error) {
@@ -121,6 +142,10 @@ func (c *_Client) getNext(service string, opts *arpc.MiscOptions) (selector.Next
 		service = o.Proxy
 	}
 
+	if opts.Zone == "" {
+		opts.Zone = o.Zone
+	}
+
 	return o.Selector.Select(service, &opts.Options)
 }
 
@@ -146,3 +171,23 @@ func (c *_Client) newRequest(req interface{}, o *arpc.MiscOptions) arpc.Packet {
 		return pkg
 	}
 }
+
+// track 统计节点负载,用于selector中的LeastLoaded,P2C等策略
+// 通过OnDone在收到应答或者超时后结束统计,同步和异步回调方式的调用都会被统计
+// 返回的函数用于发送失败时提前结束统计,多次调用只有第一次生效
+func track(node selector.Node, o *arpc.MiscOptions) func(err error) {
+	end := selector.Begin(node)
+	var once sync.Once
+	done := func(err error) {
+		once.Do(func() { end(err) })
+	}
+
+	prev := o.OnDone
+	o.OnDone = func(err error) {
+		done(err)
+		if prev != nil {
+			prev(err)
+		}
+	}
+	return done
+}
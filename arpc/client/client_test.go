@@ -0,0 +1,105 @@
+package client
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/selector"
+)
+
+// TestTrack 同步,异步以及仅发送的调用都需要统计节点负载
+func TestTrack(t *testing.T) {
+	node := &testNode{id: "a", delay: time.Millisecond * 100}
+	c := newTestClient(node)
+
+	if err := c.Send("echo", &echoReq{}); err != nil {
+		t.Fatal(err)
+	}
+	if node.stats.Success() != 1 || node.stats.Inflight() != 0 {
+		t.Fatalf("send not tracked, %d", node.stats.Success())
+	}
+
+	if err := c.Call("echo", &echoReq{}, &echoRsp{}); err != nil {
+		t.Fatal(err)
+	}
+	if node.stats.Success() != 2 || node.stats.Inflight() != 0 {
+		t.Fatalf("call not tracked, %d", node.stats.Success())
+	}
+
+	done := make(chan struct{})
+	err := c.Call("echo", &echoReq{}, func(rsp *echoRsp) error {
+		close(done)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.stats.Inflight() != 1 {
+		t.Fatalf("async call not tracked, %d", node.stats.Inflight())
+	}
+
+	<-done
+	if node.stats.Success() != 3 || node.stats.Inflight() != 0 {
+		t.Fatalf("async call not finished, %d", node.stats.Success())
+	}
+}
+
+// recorder 记录经过拦截器的调用
+type recorder struct {
+	mux   sync.Mutex
+	calls []string
+	seqs  []uint64
+	last  arpc.Packet
+	opts  *arpc.MiscOptions
+}
+
+func (r *recorder) intercept(node selector.Node, req arpc.Packet, o *arpc.MiscOptions, invoker arpc.Invoker) error {
+	r.mux.Lock()
+	r.calls = append(r.calls, node.Id())
+	r.seqs = append(r.seqs, req.SeqID())
+	r.last = req
+	r.opts = o
+	r.mux.Unlock()
+	return invoker(node, req, o)
+}
+
+// TestInterceptor Send,Call以及重试都需要经过拦截器
+func TestInterceptor(t *testing.T) {
+	r := &recorder{}
+	s := &testSelector{nodes: []selector.Node{&testNode{id: "a"}, &testNode{id: "b"}}}
+	c := New(Selector(s), Interceptor(r.intercept))
+
+	if err := c.Send("echo", &echoReq{}); err != nil {
+		t.Fatal(err)
+	}
+	rsp := &echoRsp{}
+	if err := c.Call("echo", &echoReq{Text: "hello"}, rsp); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.calls) != 2 || rsp.Text != "hello" {
+		t.Fatalf("interceptor not called, %+v", r.calls)
+	}
+
+	// 模拟超时后重试,需要使用新的seqID并且重新经过拦截器
+	retry := func(o *arpc.MiscOptions) { o.RetryNum = 1 }
+	done := make(chan struct{}, 2)
+	if err := c.Call("echo", &echoReq{}, func(rsp *echoRsp) error {
+		done <- struct{}{}
+		return nil
+	}, retry, arpc.WithTTL(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	r.mux.Lock()
+	req, o := r.last, r.opts
+	r.mux.Unlock()
+	if o.RetryCB == nil || o.RetryCB(req) == 0 {
+		t.Fatal("retry fail")
+	}
+	if len(r.calls) != 4 || r.seqs[3] == r.seqs[2] {
+		t.Fatalf("retry not intercepted, %+v, %+v", r.calls, r.seqs)
+	}
+}
@@ -30,3 +30,17 @@ func Proxy(p string) arpc.Option {
 		o.Proxy = p
 	}
 }
+
+// Interceptor 添加客户端拦截器,按照添加顺序执行
+func Interceptor(interceptors ...arpc.Interceptor) arpc.Option {
+	return func(o *arpc.Options) {
+		o.Interceptors = append(o.Interceptors, interceptors...)
+	}
+}
+
+// Zone 客户端所在可用区,选择节点时优先选择相同可用区的节点
+func Zone(zone string) arpc.Option {
+	return func(o *arpc.Options) {
+		o.Zone = zone
+	}
+}
@@ -0,0 +1,206 @@
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"sync"
+
+	"github.com/jeckbjy/gsk/util/buffer"
+	"github.com/jeckbjy/gsk/util/lz4"
+)
+
+// 压缩算法ID,用于消息中标识压缩算法,不能修改
+const (
+	IDLz4     = 1
+	IDGzip    = 2
+	IDDeflate = 3
+)
+
+// Codec 压缩算法
+// Encode:压缩src中全部数据,并追加到dst中
+// Decode:从src当前位置开始解压,并追加到dst中,size为解压后的长度
+type Codec interface {
+	ID() byte
+	Name() string
+	Encode(dst *buffer.Buffer, src *buffer.Buffer) error
+	Decode(dst *buffer.Buffer, src *buffer.Buffer, size int) error
+}
+
+// Lz4 速度优先,适合频繁同步的数据
+func Lz4() Codec {
+	return &lz4Codec{}
+}
+
+// Gzip 压缩率优先,level取值参考compress/flate
+func Gzip(level int) Codec {
+	return &flateCodec{id: IDGzip, name: "gzip", level: level}
+}
+
+// Deflate 与gzip相比没有头部和校验信息,更加紧凑
+func Deflate(level int) Codec {
+	return &flateCodec{id: IDDeflate, name: "deflate", level: level}
+}
+
+type lz4Codec struct {
+}
+
+func (c *lz4Codec) ID() byte {
+	return IDLz4
+}
+
+func (c *lz4Codec) Name() string {
+	return "lz4"
+}
+
+func (c *lz4Codec) Encode(dst *buffer.Buffer, src *buffer.Buffer) error {
+	// block格式需要连续内存,只有一个节点时不会拷贝
+	data := src.Bytes()
+	out := make([]byte, lz4.CompressBound(len(data)))
+	n, err := lz4.Compress(out, data)
+	if err != nil {
+		return err
+	}
+
+	dst.Append(out[:n])
+	return nil
+}
+
+func (c *lz4Codec) Decode(dst *buffer.Buffer, src *buffer.Buffer, size int) error {
+	pos := src.Pos()
+	data := src.Bytes()[pos:]
+	out := make([]byte, size)
+	n, err := lz4.Decompress(out, data)
+	if err != nil {
+		return err
+	}
+
+	if n != size {
+		return lz4.ErrCorrupt
+	}
+
+	dst.Append(out)
+	return nil
+}
+
+// flateCodec 支持gzip和deflate,writer通过pool复用
+type flateCodec struct {
+	id    byte
+	name  string
+	level int
+	pool  sync.Pool
+}
+
+func (c *flateCodec) ID() byte {
+	return c.id
+}
+
+func (c *flateCodec) Name() string {
+	return c.name
+}
+
+func (c *flateCodec) newWriter(w io.Writer) (io.WriteCloser, error) {
+	if c.id == IDGzip {
+		return gzip.NewWriterLevel(w, c.level)
+	}
+
+	return flate.NewWriter(w, c.level)
+}
+
+func (c *flateCodec) Encode(dst *buffer.Buffer, src *buffer.Buffer) error {
+	out := &bytes.Buffer{}
+	out.Grow(src.Len() / 2)
+
+	var w io.WriteCloser
+	if v := c.pool.Get(); v != nil {
+		w = v.(io.WriteCloser)
+		switch x := w.(type) {
+		case *gzip.Writer:
+			x.Reset(out)
+		case *flate.Writer:
+			x.Reset(out)
+		}
+	} else {
+		var err error
+		if w, err = c.newWriter(out); err != nil {
+			return err
+		}
+	}
+
+	// 直接遍历所有节点,不需要合并内存
+	var err error
+	src.Visit(func(data []byte) bool {
+		_, err = w.Write(data)
+		return err == nil
+	})
+
+	if err == nil {
+		err = w.Close()
+	}
+	c.pool.Put(w)
+	if err != nil {
+		return err
+	}
+
+	dst.Append(out.Bytes())
+	return nil
+}
+
+func (c *flateCodec) Decode(dst *buffer.Buffer, src *buffer.Buffer, size int) error {
+	var r io.ReadCloser
+	if c.id == IDGzip {
+		gr, err := gzip.NewReader(&reader{buff: src})
+		if err != nil {
+			return err
+		}
+		r = gr
+	} else {
+		r = flate.NewReader(&reader{buff: src})
+	}
+
+	out := make([]byte, size)
+	if _, err := io.ReadFull(r, out); err != nil {
+		_ = r.Close()
+		return err
+	}
+
+	// 数据长度必须完全一致
+	var tail [1]byte
+	if n, _ := r.Read(tail[:]); n != 0 {
+		_ = r.Close()
+		return ErrBadSize
+	}
+
+	if err := r.Close(); err != nil {
+		return err
+	}
+
+	dst.Append(out)
+	return nil
+}
+
+// reader 适配buffer.Buffer,结束时返回io.EOF
+type reader struct {
+	buff *buffer.Buffer
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.buff.Eof() {
+		return 0, io.EOF
+	}
+
+	n, err := r.buff.Read(p)
+	if err == buffer.ErrNoEnoughData {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *reader) ReadByte() (byte, error) {
+	if r.buff.Eof() {
+		return 0, io.EOF
+	}
+
+	return r.buff.ReadByte()
+}
@@ -0,0 +1,321 @@
+package compress
+
+import (
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/anet/base"
+	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/util/buffer"
+)
+
+var (
+	ErrBadSize      = errors.New("compress: bad size")
+	ErrUnknownCodec = errors.New("compress: unknown codec")
+)
+
+const (
+	DefaultThreshold = 1024             // 默认超过1KB才压缩
+	DefaultMaxSize   = 16 * 1024 * 1024 // 默认解压后最大16MB
+	headKey          = "compress"       // 握手消息中使用的head
+	connKey          = "compress"       // 连接中保存协商状态
+	probeMax         = 16               // 最多检测多少个消息,超过后认为对方不支持压缩
+)
+
+type Option func(f *compressFilter)
+
+// Threshold 消息长度超过n时才压缩
+func Threshold(n int) Option {
+	return func(f *compressFilter) {
+		f.threshold = n
+	}
+}
+
+// MaxSize 解压后消息最大长度,防止恶意数据
+func MaxSize(n int) Option {
+	return func(f *compressFilter) {
+		f.maxSize = n
+	}
+}
+
+// Codecs 支持的压缩算法,按照优先级排序
+func Codecs(codecs ...Codec) Option {
+	return func(f *compressFilter) {
+		f.codecs = codecs
+	}
+}
+
+// New 创建压缩Filter,需要放在FrameFilter与ExecFilter之间
+// 默认支持lz4,gzip,deflate,优先使用lz4
+func New(opts ...Option) anet.Filter {
+	f := &compressFilter{
+		threshold: DefaultThreshold,
+		maxSize:   DefaultMaxSize,
+		codecs:    []Codec{Lz4(), Gzip(flate.BestSpeed), Deflate(flate.BestSpeed)},
+	}
+
+	for _, fn := range opts {
+		fn(f)
+	}
+
+	return f
+}
+
+// compressFilter 消息压缩,超过阈值的消息会被压缩
+//
+// 兼容性:连接建立后,需要先协商压缩算法,只有协商成功后才会发送压缩消息,因此老的客户端不受影响
+//	1:Dial端连接成功后发送握手消息,MsgID为arpc.SysIDCompress,Head中携带支持的压缩算法列表
+//	2:Accept端收到后选择一个双方都支持的算法,并使用相同的MsgID应答,此后Accept端可以发送压缩消息
+//	3:Dial端收到应答后,此后也可以发送压缩消息
+//	老的服务器会忽略握手消息,因此Dial端永远不会发送压缩消息
+//
+// 压缩格式:Flag[2byte]+Codec[1byte]+Size[varint]+Data
+//	Flag与arpc.Packet的头部标识兼容,设置了arpc.HFCompress位,正常的消息不会使用此位
+//	Codec为压缩算法ID,Size为解压后长度,Data为压缩后的完整消息
+type compressFilter struct {
+	base.Filter
+	threshold int
+	maxSize   int
+	codecs    []Codec
+}
+
+// 连接协商状态
+type connState struct {
+	mux    sync.Mutex
+	codec  Codec // 协商后的压缩算法,nil表示不压缩
+	probes int   // 已经检测的消息个数
+	done   bool  // 是否已经完成协商
+}
+
+func (s *connState) Codec() Codec {
+	s.mux.Lock()
+	c := s.codec
+	s.mux.Unlock()
+	return c
+}
+
+func (s *connState) SetCodec(c Codec) {
+	s.mux.Lock()
+	s.codec = c
+	s.done = true
+	s.mux.Unlock()
+}
+
+func (f *compressFilter) Name() string {
+	return "compress"
+}
+
+func (f *compressFilter) getState(conn anet.Conn) *connState {
+	if s, ok := conn.Get(connKey).(*connState); ok {
+		return s
+	}
+
+	return nil
+}
+
+func (f *compressFilter) findByID(id byte) Codec {
+	for _, c := range f.codecs {
+		if c.ID() == id {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func (f *compressFilter) findByName(name string) Codec {
+	for _, c := range f.codecs {
+		if c.Name() == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func (f *compressFilter) names() string {
+	names := make([]string, 0, len(f.codecs))
+	for _, c := range f.codecs {
+		names = append(names, c.Name())
+	}
+
+	return strings.Join(names, ",")
+}
+
+func (f *compressFilter) HandleOpen(ctx anet.FilterCtx) error {
+	conn := ctx.Conn()
+	conn.Set(connKey, &connState{})
+	if conn.IsDial() && len(f.codecs) > 0 {
+		pkt := arpc.NewPacket()
+		pkt.SetMsgID(arpc.SysIDCompress)
+		pkt.SetHead(headKey, f.names())
+		return conn.Send(pkt)
+	}
+
+	return nil
+}
+
+func (f *compressFilter) HandleRead(ctx anet.FilterCtx) error {
+	buff, ok := ctx.Data().(*buffer.Buffer)
+	if !ok {
+		return nil
+	}
+
+	pos := buff.Pos()
+	var flag [2]byte
+	if n, _ := buff.Read(flag[:]); n != len(flag) {
+		_, _ = buff.Seek(int64(pos), io.SeekStart)
+		return nil
+	}
+
+	if binary.LittleEndian.Uint16(flag[:])&(1<<arpc.HFCompress) != 0 {
+		data, err := f.decode(buff)
+		if err != nil {
+			return err
+		}
+		ctx.SetData(data)
+		return nil
+	}
+
+	_, _ = buff.Seek(int64(pos), io.SeekStart)
+	if s := f.getState(ctx.Conn()); s != nil && f.probe(ctx.Conn(), s, buff) {
+		// 握手消息,不需要继续处理
+		ctx.Abort()
+	}
+
+	return nil
+}
+
+func (f *compressFilter) HandleWrite(ctx anet.FilterCtx) error {
+	buff, ok := ctx.Data().(*buffer.Buffer)
+	if !ok || buff.Len() < f.threshold {
+		return nil
+	}
+
+	s := f.getState(ctx.Conn())
+	if s == nil {
+		return nil
+	}
+
+	codec := s.Codec()
+	if codec == nil {
+		return nil
+	}
+
+	out, err := f.encode(codec, buff)
+	if err != nil {
+		return err
+	}
+
+	// 压缩后反而更大则不压缩
+	if out.Len() < buff.Len() {
+		ctx.SetData(out)
+	}
+
+	return nil
+}
+
+// encode 压缩消息,并添加Flag,Codec和Size
+func (f *compressFilter) encode(codec Codec, buff *buffer.Buffer) (*buffer.Buffer, error) {
+	out := buffer.New()
+	head := make([]byte, 3+binary.MaxVarintLen64)
+	binary.LittleEndian.PutUint16(head, 1<<arpc.HFCompress)
+	head[2] = codec.ID()
+	n := binary.PutUvarint(head[3:], uint64(buff.Len()))
+	out.Append(head[:3+n])
+	if err := codec.Encode(out, buff); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// decode 解压消息,调用前已经读取了Flag
+func (f *compressFilter) decode(buff *buffer.Buffer) (*buffer.Buffer, error) {
+	id, err := buff.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	codec := f.findByID(id)
+	if codec == nil {
+		return nil, ErrUnknownCodec
+	}
+
+	size, err := binary.ReadUvarint(buff)
+	if err != nil {
+		return nil, err
+	}
+
+	if size == 0 || (f.maxSize > 0 && size > uint64(f.maxSize)) {
+		return nil, ErrBadSize
+	}
+
+	out := buffer.New()
+	if err := codec.Decode(out, buff, int(size)); err != nil {
+		return nil, err
+	}
+
+	_, _ = out.Seek(0, io.SeekStart)
+	return out, nil
+}
+
+// probe 协商阶段检测是否是握手消息,返回true表示是握手消息
+func (f *compressFilter) probe(conn anet.Conn, s *connState, buff *buffer.Buffer) bool {
+	s.mux.Lock()
+	if s.done {
+		s.mux.Unlock()
+		return false
+	}
+	s.probes++
+	if s.probes >= probeMax {
+		s.done = true
+	}
+	s.mux.Unlock()
+
+	pos := buff.Pos()
+	pkt := arpc.NewPacket()
+	err := pkt.Decode(buff)
+	_, _ = buff.Seek(int64(pos), io.SeekStart)
+	if err != nil || pkt.MsgID() != arpc.SysIDCompress {
+		return false
+	}
+
+	if conn.IsDial() {
+		// 握手应答,空表示没有共同支持的算法
+		s.SetCodec(f.findByName(pkt.Head(headKey)))
+		return true
+	}
+
+	// 按照本地优先级选择算法
+	var codec Codec
+	remote := strings.Split(pkt.Head(headKey), ",")
+	for _, c := range f.codecs {
+		for _, name := range remote {
+			if c.Name() == name {
+				codec = c
+				break
+			}
+		}
+		if codec != nil {
+			break
+		}
+	}
+
+	s.SetCodec(codec)
+
+	rsp := arpc.NewPacket()
+	rsp.SetAck(true)
+	rsp.SetMsgID(arpc.SysIDCompress)
+	if codec != nil {
+		rsp.SetHead(headKey, codec.Name())
+	}
+	_ = conn.Send(rsp)
+
+	return true
+}
@@ -0,0 +1,315 @@
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/anet/base"
+	"github.com/jeckbjy/gsk/anet/tcp"
+	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/arpc/filter/fframe"
+	"github.com/jeckbjy/gsk/arpc/packet"
+	"github.com/jeckbjy/gsk/frame"
+	"github.com/jeckbjy/gsk/frame/varint"
+	"github.com/jeckbjy/gsk/util/buffer"
+)
+
+func newData() *buffer.Buffer {
+	b := buffer.New()
+	// 使用多个节点,模拟网络分段读取
+	for i := 0; i < 100; i++ {
+		b.Append(bytes.Repeat([]byte("item=1001,count=20;"), 10))
+	}
+	_, _ = b.Seek(0, io.SeekStart)
+	return b
+}
+
+func TestCodecs(t *testing.T) {
+	f := New().(*compressFilter)
+	for _, c := range []Codec{Lz4(), Gzip(flate.BestSpeed), Deflate(flate.DefaultCompression)} {
+		src := newData()
+		raw := src.String()
+		out, err := f.encode(c, src)
+		if err != nil {
+			t.Fatal(c.Name(), err)
+		}
+
+		t.Logf("%s: %+v => %+v", c.Name(), len(raw), out.Len())
+		_, _ = out.Seek(0, io.SeekStart)
+		var flag [2]byte
+		_, _ = out.Read(flag[:])
+		if binary.LittleEndian.Uint16(flag[:])&(1<<arpc.HFCompress) == 0 {
+			t.Fatal("no compress flag")
+		}
+
+		data, err := f.decode(out)
+		if err != nil {
+			t.Fatal(c.Name(), err)
+		}
+
+		if data.String() != raw {
+			t.Fatalf("%s: data not equal", c.Name())
+		}
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	f := New(MaxSize(100)).(*compressFilter)
+	out, err := f.encode(Lz4(), newData())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = out.Seek(2, io.SeekStart)
+	if _, err := f.decode(out); err != ErrBadSize {
+		t.Fatalf("expect bad size, %+v", err)
+	}
+}
+
+func init() {
+	arpc.SetPacketFactory(packet.New)
+	frame.SetDefault(varint.New())
+}
+
+// wireFilter 位于compress之前,记录收到的原始消息是否被压缩
+type wireFilter struct {
+	base.Filter
+	compressed chan bool
+}
+
+func (f *wireFilter) Name() string {
+	return "wire"
+}
+
+func (f *wireFilter) HandleRead(ctx anet.FilterCtx) error {
+	buff := ctx.Data().(*buffer.Buffer)
+	pos := buff.Pos()
+	var flag [2]byte
+	_, _ = buff.Read(flag[:])
+	_, _ = buff.Seek(int64(pos), io.SeekStart)
+	f.compressed <- binary.LittleEndian.Uint16(flag[:])&(1<<arpc.HFCompress) != 0
+	return nil
+}
+
+type message struct {
+	conn anet.Conn
+	pkt  arpc.Packet
+}
+
+// appFilter 编解码Packet,收到的非握手消息写入channel
+type appFilter struct {
+	base.Filter
+	msgs chan message
+}
+
+func (f *appFilter) Name() string {
+	return "app"
+}
+
+func (f *appFilter) HandleRead(ctx anet.FilterCtx) error {
+	pkt := arpc.NewPacket()
+	if err := pkt.Decode(ctx.Data().(*buffer.Buffer)); err != nil {
+		return err
+	}
+
+	// 老版本会把握手消息当做未知消息忽略
+	if pkt.MsgID() != arpc.SysIDCompress {
+		f.msgs <- message{conn: ctx.Conn(), pkt: pkt}
+	}
+
+	return nil
+}
+
+func (f *appFilter) HandleWrite(ctx anet.FilterCtx) error {
+	if pkt, ok := ctx.Data().(arpc.Packet); ok {
+		buff := buffer.New()
+		if err := pkt.Encode(buff); err != nil {
+			return err
+		}
+		ctx.SetData(buff)
+	}
+
+	return nil
+}
+
+type peer struct {
+	tran anet.Tran
+	wire *wireFilter
+	app  *appFilter
+}
+
+// newPeer 创建一端,compress为false时模拟不支持压缩的老版本
+func newPeer(compress bool) *peer {
+	p := &peer{
+		tran: tcp.New(),
+		wire: &wireFilter{compressed: make(chan bool, 100)},
+		app:  &appFilter{msgs: make(chan message, 100)},
+	}
+
+	filters := []anet.Filter{fframe.New(), p.wire}
+	if compress {
+		filters = append(filters, New(Threshold(64)))
+	}
+	filters = append(filters, p.app)
+	p.tran.AddFilters(filters...)
+	return p
+}
+
+func newBig(id int) arpc.Packet {
+	pkt := arpc.NewPacket()
+	pkt.SetMsgID(id)
+	pkt.SetHead("data", strings.Repeat("item=1001,count=20;", 100))
+	return pkt
+}
+
+// recv 读取下一个业务消息,同时返回线路上是否压缩,握手消息会被跳过
+func (p *peer) recv(t *testing.T) (message, bool) {
+	var compressed []bool
+	for {
+		select {
+		case c := <-p.wire.compressed:
+			compressed = append(compressed, c)
+		case msg := <-p.app.msgs:
+			// 业务消息是最后一个到达的
+			for len(p.wire.compressed) > 0 {
+				compressed = append(compressed, <-p.wire.compressed)
+			}
+			return msg, compressed[len(compressed)-1]
+		case <-time.After(time.Second * 5):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func dial(t *testing.T, server, client *peer) (anet.Conn, func()) {
+	l, err := server.tran.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := client.tran.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn, func() {
+		_ = conn.Close()
+		_ = l.Close()
+	}
+}
+
+// waitNegotiated 等待协商完成
+func waitNegotiated(t *testing.T, conn anet.Conn) Codec {
+	for i := 0; i < 500; i++ {
+		// Dial是异步的,HandleOpen后才会有协商状态
+		s, ok := conn.Get(connKey).(*connState)
+		if !ok {
+			time.Sleep(time.Millisecond * 10)
+			continue
+		}
+		s.mux.Lock()
+		done, codec := s.done, s.codec
+		s.mux.Unlock()
+		if done {
+			return codec
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	t.Fatal("negotiate timeout")
+	return nil
+}
+
+func TestNegotiate(t *testing.T) {
+	server, client := newPeer(true), newPeer(true)
+	conn, closer := dial(t, server, client)
+	defer closer()
+
+	if codec := waitNegotiated(t, conn); codec == nil || codec.Name() != Lz4().Name() {
+		t.Fatalf("bad codec, %+v", codec)
+	}
+
+	// Dial端发送的大消息被压缩,并且能够正确解压
+	want := newBig(1)
+	_ = conn.Send(want)
+	msg, compressed := server.recv(t)
+	if !compressed || msg.pkt.MsgID() != 1 || msg.pkt.Head("data") != want.Head("data") {
+		t.Fatalf("bad message, %v, %+v", compressed, msg.pkt.MsgID())
+	}
+
+	// Accept端应答同样压缩
+	_ = msg.conn.Send(newBig(2))
+	msg, compressed = client.recv(t)
+	if !compressed || msg.pkt.MsgID() != 2 || msg.pkt.Head("data") != want.Head("data") {
+		t.Fatalf("bad response, %v, %+v", compressed, msg.pkt.MsgID())
+	}
+
+	// 小于阈值的消息不压缩
+	small := arpc.NewPacket()
+	small.SetMsgID(3)
+	_ = conn.Send(small)
+	if msg, compressed = server.recv(t); compressed || msg.pkt.MsgID() != 3 {
+		t.Fatalf("small message should not compress, %v", compressed)
+	}
+}
+
+func TestOldServer(t *testing.T) {
+	server, client := newPeer(false), newPeer(true)
+	conn, closer := dial(t, server, client)
+	defer closer()
+
+	// 老的服务器不会应答握手,Dial端永远不压缩
+	for i := 1; i <= 3; i++ {
+		_ = conn.Send(newBig(i))
+		msg, compressed := server.recv(t)
+		if compressed || msg.pkt.MsgID() != i {
+			t.Fatalf("should not compress, %v, %d", compressed, msg.pkt.MsgID())
+		}
+
+		_ = msg.conn.Send(newBig(i))
+		if msg, compressed = client.recv(t); compressed || msg.pkt.MsgID() != i {
+			t.Fatalf("bad response, %v, %d", compressed, msg.pkt.MsgID())
+		}
+	}
+
+	if s := conn.Get(connKey).(*connState); s.Codec() != nil {
+		t.Fatal("should not negotiate")
+	}
+}
+
+func TestOldClient(t *testing.T) {
+	server, client := newPeer(true), newPeer(false)
+	conn, closer := dial(t, server, client)
+	defer closer()
+
+	// 老的客户端不会发送握手,超过probeMax个消息后Accept端不再检测
+	var accepted anet.Conn
+	for i := 1; i <= probeMax+1; i++ {
+		_ = conn.Send(newBig(i))
+		msg, compressed := server.recv(t)
+		if compressed || msg.pkt.MsgID() != i {
+			t.Fatalf("should not compress, %v, %d", compressed, msg.pkt.MsgID())
+		}
+		accepted = msg.conn
+	}
+
+	s := accepted.Get(connKey).(*connState)
+	s.mux.Lock()
+	done, probes, codec := s.done, s.probes, s.codec
+	s.mux.Unlock()
+	if !done || probes != probeMax || codec != nil {
+		t.Fatalf("bad probe state, %v, %d, %+v", done, probes, codec)
+	}
+
+	// Accept端也不会发送压缩消息
+	_ = accepted.Send(newBig(100))
+	if msg, compressed := client.recv(t); compressed || msg.pkt.MsgID() != 100 {
+		t.Fatalf("bad response, %v, %d", compressed, msg.pkt.MsgID())
+	}
+}
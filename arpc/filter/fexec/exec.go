@@ -5,6 +5,7 @@ import (
 	"github.com/jeckbjy/gsk/anet/base"
 	"github.com/jeckbjy/gsk/arpc"
 	"github.com/jeckbjy/gsk/exec"
+	"github.com/jeckbjy/gsk/exec/actor"
 	"github.com/jeckbjy/gsk/util/buffer"
 )
 
@@ -40,11 +41,19 @@ func Executor(executor exec.Executor) Option {
 	}
 }
 
+// Actors 带有HFExtraUserID的消息投递到对应用户的actor中执行,同一个用户的消息串行处理
+func Actors(s *actor.System) Option {
+	return func(f *execFilter) {
+		f.actors = s
+	}
+}
+
 // execFilter 用于注册消息回调,或执行回调
 type execFilter struct {
 	base.Filter
 	router   arpc.Router
 	executor exec.Executor
+	actors   *actor.System
 }
 
 func (f *execFilter) Name() string {
@@ -52,16 +61,20 @@ func (f *execFilter) Name() string {
 }
 
 func (f *execFilter) HandleRead(ctx anet.FilterCtx) error {
-	data, ok := ctx.Data().(*buffer.Buffer)
-	if !ok {
+	var msg arpc.Packet
+	switch data := ctx.Data().(type) {
+	case arpc.Packet:
+		// 已经被前边的Filter解码,比如sign
+		msg = data
+	case *buffer.Buffer:
+		msg = arpc.NewPacket()
+		if err := msg.Decode(data); err != nil {
+			return err
+		}
+	default:
 		return nil
 	}
 
-	msg := arpc.NewPacket()
-	if err := msg.Decode(data); err != nil {
-		return err
-	}
-
 	//log.Printf("recv msg,%+v,%+v,%+v,%+v\n", msg.IsAck(), msg.MsgID(), msg.Name(), msg.SeqID())
 
 	taskCtx := arpc.NewContext()
@@ -69,6 +82,12 @@ func (f *execFilter) HandleRead(ctx anet.FilterCtx) error {
 	task := newTask(taskCtx, f.router)
 	task.Init(taskCtx, f.router)
 
+	if f.actors != nil {
+		if uid := msg.Extra(arpc.HFExtraUserID); uid != "" {
+			return f.actors.Post(uid, task)
+		}
+	}
+
 	return f.executor.Post(task)
 }
 
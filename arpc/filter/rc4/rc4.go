@@ -8,8 +8,11 @@ import (
 	"github.com/jeckbjy/gsk/util/buffer"
 )
 
+// Deprecated: rc4没有完整性校验,且每个包使用相同的密钥流,请使用secure.New
+// 注意:旧版本会忽略key,导致rc4.NewCipher总是失败,所有读写都会返回错误,现在会使用传入的key加解密,
+// 因此双方必须使用相同的key
 func New(key []byte) anet.Filter {
-	return &rc4Filter{}
+	return &rc4Filter{key: key}
 }
 
 type rc4Filter struct {
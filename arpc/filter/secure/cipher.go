@@ -0,0 +1,116 @@
+package secure
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+
+	"github.com/jeckbjy/gsk/util/crypto"
+)
+
+const (
+	keySize   = 32 // AES-256
+	nonceSize = 12
+)
+
+var (
+	infoKey    = []byte("gsk secure key")
+	infoIV     = []byte("gsk secure iv")
+	infoUpdate = []byte("gsk secure key update")
+)
+
+// cipherState 单向加解密状态,收发各自独立
+// nonce = iv ^ seq,seq不在网络上传输,双方各自递增
+// 因此任何重放,丢弃,乱序的数据都会导致解密失败
+type cipherState struct {
+	aead  cipher.AEAD
+	key   []byte
+	iv    [nonceSize]byte
+	nonce [nonceSize]byte
+	seq   uint64
+	bytes int64 // 当前密钥已经处理的数据量
+	limit int64 // 超过后更换密钥
+}
+
+func newCipherState(prk []byte, label string, limit int64) (*cipherState, error) {
+	key, err := crypto.HKDFExpand(prk, append([]byte(label+" "), infoKey...), keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	iv, err := crypto.HKDFExpand(prk, append([]byte(label+" "), infoIV...), nonceSize)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &cipherState{limit: limit}
+	copy(s.iv[:], iv)
+	if err := s.setKey(key); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *cipherState) setKey(key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	s.aead = aead
+	s.key = key
+	s.seq = 0
+	s.bytes = 0
+	return nil
+}
+
+func (s *cipherState) nextNonce() []byte {
+	s.nonce = s.iv
+	var seq [8]byte
+	binary.BigEndian.PutUint64(seq[:], s.seq)
+	for i := 0; i < len(seq); i++ {
+		s.nonce[nonceSize-8+i] ^= seq[i]
+	}
+	s.seq++
+	return s.nonce[:]
+}
+
+// update 处理完一个record后检测是否需要更换密钥,收发双方处理的数据完全一致,因此会在同一时刻更换
+func (s *cipherState) update(n int) error {
+	s.bytes += int64(n)
+	if (s.limit <= 0 || s.bytes < s.limit) && s.seq < 1<<32 {
+		return nil
+	}
+
+	key, err := crypto.HKDFExpand(s.key, infoUpdate, keySize)
+	if err != nil {
+		return err
+	}
+
+	return s.setKey(key)
+}
+
+// seal 加密一个record,格式为:Length[4byte]+Ciphertext+Tag, Length作为附加数据参与校验
+func (s *cipherState) seal(plaintext []byte) ([]byte, error) {
+	size := len(plaintext) + s.aead.Overhead()
+	out := make([]byte, recordHeadSize, recordHeadSize+size)
+	binary.LittleEndian.PutUint32(out, uint32(size))
+	out = s.aead.Seal(out, s.nextNonce(), plaintext, out[:recordHeadSize])
+	return out, s.update(len(plaintext))
+}
+
+// open 解密一个record,会复用ciphertext的内存
+func (s *cipherState) open(head []byte, ciphertext []byte) ([]byte, error) {
+	plaintext, err := s.aead.Open(ciphertext[:0], s.nextNonce(), ciphertext, head)
+	if err != nil {
+		return nil, ErrAuthFailed
+	}
+
+	return plaintext, s.update(len(plaintext))
+}
@@ -0,0 +1,377 @@
+package secure
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/anet/base"
+	"github.com/jeckbjy/gsk/util/buffer"
+	"github.com/jeckbjy/gsk/util/crypto"
+	"golang.org/x/crypto/curve25519"
+)
+
+var (
+	ErrBadHandshake = errors.New("secure: bad handshake")
+	ErrAuthFailed   = errors.New("secure: message authentication failed")
+	ErrTooLarge     = errors.New("secure: record too large")
+)
+
+const (
+	DefaultRekeyBytes = 1 << 30 // 默认每1GB更换一次密钥
+	version           = 1
+	randomSize        = 32
+	handshakeSize     = 4 + 1 + 32 + randomSize // Magic+Version+PublicKey+Random
+	recordHeadSize    = 4
+	maxRecordSize     = 16 * 1024 // 单个record明文最大长度
+	connKey           = "secure"
+)
+
+var magic = []byte("GSKS")
+
+type Option func(f *secureFilter)
+
+// PSK 预共享密钥,参与密钥派生,用于防止中间人攻击,双方必须一致
+func PSK(key []byte) Option {
+	return func(f *secureFilter) {
+		f.psk = key
+	}
+}
+
+// RekeyBytes 每个方向传输n字节后更换密钥,<=0表示不更换
+func RekeyBytes(n int64) Option {
+	return func(f *secureFilter) {
+		f.rekey = n
+	}
+}
+
+// New 创建加密Filter,用于替代rc4和xor,需要放在FilterChain的最前边,即紧挨着TransferFilter
+// 与上层协议无关,因此可以用于任意anet.Tran
+func New(opts ...Option) anet.Filter {
+	f := &secureFilter{rekey: DefaultRekeyBytes}
+	for _, fn := range opts {
+		fn(f)
+	}
+
+	return f
+}
+
+// secureFilter 基于ECDH(X25519)密钥交换和AES-256-GCM的加密传输
+//
+// 握手:连接建立后双方同时发送握手数据,格式为:Magic[4byte]+Version[1byte]+PublicKey[32byte]+Random[32byte]
+//	收到对方握手数据后,通过ECDH计算共享密钥,并使用HKDF派生出收发两个方向独立的密钥和IV
+//	握手完成前发送的数据会被缓存,握手完成后按顺序加密发送
+//
+// 数据:作用于字节流,与上层的粘包处理无关,格式为:Length[4byte]+Ciphertext+Tag
+//	nonce由IV和递增的序列号生成,序列号不在网络上传输,因此重放或篡改的数据都无法通过校验
+//	每个方向传输的数据超过RekeyBytes后,双方会同时派生新的密钥
+//
+// 注意:没有设置PSK时,只能防止被动窃听,无法防止中间人攻击
+type secureFilter struct {
+	base.Filter
+	mux   sync.Mutex
+	psk   []byte
+	rekey int64
+}
+
+type connState struct {
+	mux     sync.Mutex
+	priv    []byte // X25519私钥
+	pub     []byte // X25519公钥
+	random  []byte
+	hello   bool // 是否已经发送握手数据
+	ready   bool // 是否已经完成握手
+	send    *cipherState
+	recv    *cipherState
+	pending []anet.FilterCtx // 握手完成前需要发送的数据
+	plain   *buffer.Buffer   // 解密后的数据,交给上层粘包处理
+}
+
+func (f *secureFilter) Name() string {
+	return "secure"
+}
+
+// getState 读写可能在不同的协程中,需要保证只创建一次
+func (f *secureFilter) getState(conn anet.Conn) (*connState, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if s, ok := conn.Get(connKey).(*connState); ok {
+		return s, nil
+	}
+
+	priv := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(priv); err != nil {
+		return nil, err
+	}
+
+	pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+
+	random := make([]byte, randomSize)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	s := &connState{priv: priv, pub: pub, random: random, plain: buffer.New()}
+	conn.Set(connKey, s)
+	return s, nil
+}
+
+// sendHello 发送握手数据,需要在加锁状态下调用,保证握手数据在所有加密数据之前发送
+// 握手数据不需要经过其他Filter,直接写入Conn
+func (f *secureFilter) sendHello(conn anet.Conn, s *connState) error {
+	if s.hello {
+		return nil
+	}
+	s.hello = true
+
+	data := make([]byte, 0, handshakeSize)
+	data = append(data, magic...)
+	data = append(data, version)
+	data = append(data, s.pub...)
+	data = append(data, s.random...)
+
+	b := buffer.New()
+	b.Append(data)
+	return conn.Write(b)
+}
+
+func (f *secureFilter) HandleOpen(ctx anet.FilterCtx) error {
+	conn := ctx.Conn()
+	s, err := f.getState(conn)
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	err = f.sendHello(conn, s)
+	s.mux.Unlock()
+	return err
+}
+
+func (f *secureFilter) HandleClose(ctx anet.FilterCtx) error {
+	s, err := f.getState(ctx.Conn())
+	if err != nil {
+		return nil
+	}
+
+	s.mux.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mux.Unlock()
+	for _, p := range pending {
+		p.Abort()
+		_ = p.Call()
+	}
+
+	return nil
+}
+
+func (f *secureFilter) HandleWrite(ctx anet.FilterCtx) error {
+	buff, ok := ctx.Data().(*buffer.Buffer)
+	if !ok {
+		return nil
+	}
+
+	s, err := f.getState(ctx.Conn())
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if !s.ready {
+		s.pending = append(s.pending, ctx.Clone())
+		ctx.Abort()
+		return nil
+	}
+
+	// 加锁期间继续执行后续Filter,保证数据发送的顺序与序列号一致
+	return f.sealAndNext(ctx, s, buff)
+}
+
+func (f *secureFilter) sealAndNext(ctx anet.FilterCtx, s *connState, buff *buffer.Buffer) error {
+	out, err := f.seal(s, buff)
+	if err != nil {
+		ctx.Abort()
+		return err
+	}
+
+	ctx.SetData(out)
+	return ctx.Next()
+}
+
+// seal 按照节点分段加密,不需要合并内存
+func (f *secureFilter) seal(s *connState, buff *buffer.Buffer) (*buffer.Buffer, error) {
+	out := buffer.New()
+	var err error
+	buff.Visit(func(data []byte) bool {
+		for len(data) > 0 && err == nil {
+			n := len(data)
+			if n > maxRecordSize {
+				n = maxRecordSize
+			}
+			var record []byte
+			if record, err = s.send.seal(data[:n]); err == nil {
+				out.Append(record)
+			}
+			data = data[n:]
+		}
+		return err == nil
+	})
+
+	return out, err
+}
+
+func (f *secureFilter) HandleRead(ctx anet.FilterCtx) error {
+	buff, ok := ctx.Data().(*buffer.Buffer)
+	if !ok {
+		return nil
+	}
+
+	conn := ctx.Conn()
+	s, err := f.getState(conn)
+	if err == nil {
+		err = f.read(conn, s, buff)
+	}
+
+	if err != nil {
+		ctx.Abort()
+		_ = conn.Close()
+		return err
+	}
+
+	if s.plain.Len() == 0 {
+		ctx.Abort()
+		return nil
+	}
+
+	_, _ = s.plain.Seek(0, io.SeekStart)
+	ctx.SetData(s.plain)
+	return nil
+}
+
+// read 解析所有完整的数据,不完整的数据保留到下次处理
+func (f *secureFilter) read(conn anet.Conn, s *connState, buff *buffer.Buffer) error {
+	_, _ = buff.Seek(0, io.SeekStart)
+	defer buff.Discard()
+
+	s.mux.Lock()
+	ready := s.ready
+	s.mux.Unlock()
+
+	if !ready {
+		if buff.Len() < handshakeSize {
+			return nil
+		}
+
+		data := make([]byte, handshakeSize)
+		_, _ = buff.Read(data)
+		if err := f.handshake(conn, s, data); err != nil {
+			return err
+		}
+	}
+
+	// 解密数据只在读协程中处理,不需要加锁
+	head := make([]byte, recordHeadSize)
+	for buff.Len()-buff.Pos() >= recordHeadSize {
+		pos := buff.Pos()
+		_, _ = buff.Read(head)
+		size := int(binary.LittleEndian.Uint32(head))
+		if size > maxRecordSize+s.recv.aead.Overhead() {
+			return ErrTooLarge
+		}
+
+		if buff.Len()-buff.Pos() < size {
+			_, _ = buff.Seek(int64(pos), io.SeekStart)
+			break
+		}
+
+		ciphertext := make([]byte, size)
+		_, _ = buff.Read(ciphertext)
+		plaintext, err := s.recv.open(head, ciphertext)
+		if err != nil {
+			return err
+		}
+
+		s.plain.Append(plaintext)
+	}
+
+	return nil
+}
+
+// handshake 计算共享密钥,并发送缓存的数据
+func (f *secureFilter) handshake(conn anet.Conn, s *connState, data []byte) error {
+	if !bytes.Equal(data[:4], magic) || data[4] != version {
+		return ErrBadHandshake
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.ready {
+		return ErrBadHandshake
+	}
+
+	// 对方的握手数据可能先于HandleOpen到达
+	if err := f.sendHello(conn, s); err != nil {
+		return err
+	}
+
+	remotePub := data[5 : 5+32]
+	remoteRandom := data[5+32:]
+	// 对方公钥为低阶点时返回错误
+	secret, err := curve25519.X25519(s.priv, remotePub)
+	if err != nil {
+		return err
+	}
+
+	// 双方使用相同顺序的transcript,公钥较小的一方在前
+	localPub := s.pub
+	localLabel, remoteLabel := "a", "b"
+	transcript := make([]byte, 0, 2*(32+randomSize))
+	if bytes.Compare(localPub, remotePub) < 0 {
+		transcript = append(transcript, localPub...)
+		transcript = append(transcript, s.random...)
+		transcript = append(transcript, remotePub...)
+		transcript = append(transcript, remoteRandom...)
+	} else {
+		localLabel, remoteLabel = remoteLabel, localLabel
+		transcript = append(transcript, remotePub...)
+		transcript = append(transcript, remoteRandom...)
+		transcript = append(transcript, localPub...)
+		transcript = append(transcript, s.random...)
+	}
+
+	prk := crypto.HKDFExtract(append(secret, transcript...), f.psk)
+	if s.send, err = newCipherState(prk, localLabel, f.rekey); err != nil {
+		return err
+	}
+	if s.recv, err = newCipherState(prk, remoteLabel, f.rekey); err != nil {
+		return err
+	}
+
+	s.ready = true
+
+	// 按顺序发送握手前缓存的数据
+	pending := s.pending
+	s.pending = nil
+	for _, ctx := range pending {
+		if buff, ok := ctx.Data().(*buffer.Buffer); ok {
+			if out, err := f.seal(s, buff); err == nil {
+				ctx.SetData(out)
+			} else {
+				ctx.Abort()
+			}
+		}
+		_ = ctx.Call()
+	}
+
+	return nil
+}
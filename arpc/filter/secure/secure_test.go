@@ -0,0 +1,111 @@
+package secure
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/anet/base"
+	"github.com/jeckbjy/gsk/anet/tcp"
+	"github.com/jeckbjy/gsk/arpc/filter/fframe"
+	"github.com/jeckbjy/gsk/frame"
+	"github.com/jeckbjy/gsk/frame/varint"
+	"github.com/jeckbjy/gsk/util/buffer"
+	"github.com/jeckbjy/gsk/util/crypto"
+)
+
+// echoFilter 服务器原样返回,客户端将数据写入channel
+type echoFilter struct {
+	base.Filter
+	ch chan string
+}
+
+func (f *echoFilter) Name() string {
+	return "echo"
+}
+
+func (f *echoFilter) HandleRead(ctx anet.FilterCtx) error {
+	data := ctx.Data().(*buffer.Buffer)
+	if f.ch != nil {
+		f.ch <- data.String()
+		return nil
+	}
+
+	b := buffer.New()
+	b.Append([]byte(data.String()))
+	return ctx.Conn().Send(b)
+}
+
+func init() {
+	frame.SetDefault(varint.New())
+}
+
+func newTran(ch chan string, opts ...Option) anet.Tran {
+	tran := tcp.New()
+	tran.AddFilters(New(opts...), fframe.New(), &echoFilter{ch: ch})
+	return tran
+}
+
+func TestEcho(t *testing.T) {
+	opts := []Option{PSK([]byte("secret")), RekeyBytes(64)}
+	l, err := newTran(nil, opts...).Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ch := make(chan string, 10)
+	conn, err := newTran(ch, opts...).Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 第一条消息在握手完成前发送,会被缓存
+	// 每次读取只会处理一个frame,因此逐条收发
+	msgs := []string{"hello", string(bytes.Repeat([]byte("x"), 100)), "world"}
+	for _, msg := range msgs {
+		b := buffer.New()
+		b.Append([]byte(msg))
+		_ = conn.Send(b)
+
+		select {
+		case rsp := <-ch:
+			if rsp != msg {
+				t.Fatalf("not equal, %+v", rsp)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	prk := crypto.HKDFExtract([]byte("shared"), nil)
+	sender, _ := newCipherState(prk, "a", 0)
+	receiver, _ := newCipherState(prk, "a", 0)
+
+	record, err := sender.seal([]byte("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext := append([]byte(nil), record[recordHeadSize:]...)
+	if _, err := receiver.open(record[:recordHeadSize], ciphertext); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重放
+	ciphertext = append([]byte(nil), record[recordHeadSize:]...)
+	if _, err := receiver.open(record[:recordHeadSize], ciphertext); err != ErrAuthFailed {
+		t.Fatal("replay should fail")
+	}
+
+	// 篡改
+	record, _ = sender.seal([]byte("pong"))
+	record[recordHeadSize] ^= 1
+	if _, err := receiver.open(record[:recordHeadSize], record[recordHeadSize:]); err != ErrAuthFailed {
+		t.Fatal("tamper should fail")
+	}
+}
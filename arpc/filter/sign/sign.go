@@ -0,0 +1,370 @@
+package sign
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/anet/base"
+	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/util/buffer"
+	"github.com/jeckbjy/gsk/util/crypto"
+)
+
+var (
+	ErrNoSignature  = errors.New("sign: no signature")
+	ErrTampered     = errors.New("sign: signature mismatch")
+	ErrReplayed     = errors.New("sign: replayed packet")
+	ErrBadHandshake = errors.New("sign: bad handshake")
+)
+
+const (
+	nonceSize   = 16
+	counterSize = 8
+	signSize    = 64 // HMAC-SHA256的hex编码长度
+	trailerSize = counterSize + signSize
+	connKey     = "sign"
+	headKey     = "nonce"
+)
+
+type Option func(f *signFilter)
+
+// Stamp 校验通过的消息会通过Packet.SetInternal附带Stamp,Middleware据此判断消息是否经过签名校验
+type Stamp struct {
+	Counter uint64
+}
+
+// New 创建签名Filter,需要放在FrameFilter(或CompressFilter)与ExecFilter之间,通信双方都需要配置
+// secret为签名密钥,不能为空
+func New(secret string, opts ...Option) anet.Filter {
+	signer, err := crypto.NewSum(crypto.HashSha256, secret)
+	if err != nil {
+		panic(err)
+	}
+
+	f := &signFilter{signer: signer}
+	for _, fn := range opts {
+		fn(f)
+	}
+
+	return f
+}
+
+// signFilter 消息签名和防重放,通常用于客户端与服务器之间的通信
+//
+// 握手:连接建立后双方各自生成随机的nonce,并通过MsgID为arpc.SysIDSign的消息发送给对方
+//	握手消息的计数器为0,签名时不包含nonce,握手完成前发送的消息会被缓存,握手完成后按顺序发送
+//
+// 格式:Packet+Counter[8byte]+Sign[64byte]
+//	Counter为连接内从1开始单调递增的计数器
+//	Sign为HMAC-SHA256(Packet+Counter+Nonce)的hex编码,Nonce为接收方生成的nonce,不在网络上传输
+//
+// 校验:签名不一致时认为消息被篡改,计数器没有递增时认为是重放
+//	由于每个连接的nonce都不同,截获的消息无法在其他连接上重放,也不依赖双方的时钟
+//	校验失败的请求会使用arpc.StatusBadSignature或arpc.StatusReplayed应答,并丢弃消息
+//	校验通过的消息会被解码成arpc.Packet,并附带Stamp,交给ExecFilter处理
+type signFilter struct {
+	base.Filter
+	mux    sync.Mutex
+	signer *crypto.SummarySignature
+}
+
+type connState struct {
+	mux     sync.Mutex
+	local   []byte           // 本地生成的nonce,对方签名时使用
+	remote  []byte           // 对方的nonce,本地签名时使用,nil表示还没有完成握手
+	hello   bool             // 是否已经发送握手消息
+	sealed  bool             // 握手消息是否已经签名,之后的消息不再需要解析
+	send    uint64           // 最后发送的计数
+	recv    uint64           // 最后接收的计数,只在读协程中使用
+	pending []anet.FilterCtx // 握手完成前需要发送的数据
+}
+
+func (f *signFilter) Name() string {
+	return "sign"
+}
+
+func (f *signFilter) getState(conn anet.Conn) (*connState, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if s, ok := conn.Get(connKey).(*connState); ok {
+		return s, nil
+	}
+
+	s, err := newState()
+	if err != nil {
+		return nil, err
+	}
+
+	conn.Set(connKey, s)
+	return s, nil
+}
+
+func newState() (*connState, error) {
+	local := make([]byte, nonceSize)
+	if _, err := rand.Read(local); err != nil {
+		return nil, err
+	}
+
+	return &connState{local: local}, nil
+}
+
+func (f *signFilter) HandleOpen(ctx anet.FilterCtx) error {
+	conn := ctx.Conn()
+	s, err := f.getState(conn)
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	if s.hello {
+		s.mux.Unlock()
+		return nil
+	}
+	s.hello = true
+	s.mux.Unlock()
+
+	return conn.Send(newHello(s))
+}
+
+func newHello(s *connState) arpc.Packet {
+	pkt := arpc.NewPacket()
+	pkt.SetMsgID(arpc.SysIDSign)
+	pkt.SetHead(headKey, hex.EncodeToString(s.local))
+	return pkt
+}
+
+func (f *signFilter) HandleClose(ctx anet.FilterCtx) error {
+	s, err := f.getState(ctx.Conn())
+	if err != nil {
+		return nil
+	}
+
+	s.mux.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mux.Unlock()
+	for _, p := range pending {
+		p.Abort()
+		_ = p.Call()
+	}
+
+	return nil
+}
+
+func (f *signFilter) HandleWrite(ctx anet.FilterCtx) error {
+	buff, ok := ctx.Data().(*buffer.Buffer)
+	if !ok {
+		return nil
+	}
+
+	s, err := f.getState(ctx.Conn())
+	if err != nil {
+		return err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	hello := false
+	if !s.sealed {
+		// 只在握手消息发送前解析消息
+		if pkt, err := decode(buff.Bytes()); err == nil && pkt.MsgID() == arpc.SysIDSign {
+			hello = true
+			s.sealed = true
+		}
+	}
+
+	if !hello && s.remote == nil {
+		// 握手完成前只能发送握手消息
+		s.pending = append(s.pending, ctx.Clone())
+		ctx.Abort()
+		return nil
+	}
+
+	if err := f.seal(s, buff, hello); err != nil {
+		ctx.Abort()
+		return err
+	}
+
+	// 加锁期间继续执行后续Filter,保证发送的顺序与计数器一致
+	return ctx.Next()
+}
+
+func (f *signFilter) HandleRead(ctx anet.FilterCtx) error {
+	buff, ok := ctx.Data().(*buffer.Buffer)
+	if !ok {
+		return nil
+	}
+
+	conn := ctx.Conn()
+	s, err := f.getState(conn)
+	if err != nil {
+		return err
+	}
+
+	data := buff.Bytes()
+	counter, err := f.open(s, data)
+	if err != nil {
+		ctx.Abort()
+		f.reject(conn, data, err)
+		return nil
+	}
+
+	pkt, err := decode(data[:len(data)-trailerSize])
+	if err != nil {
+		return err
+	}
+
+	if counter == 0 {
+		ctx.Abort()
+		if err := f.handshake(s, pkt); err != nil {
+			_ = conn.Close()
+			return err
+		}
+		return nil
+	}
+
+	pkt.SetInternal(&Stamp{Counter: counter})
+	ctx.SetData(pkt)
+	return nil
+}
+
+// handshake 收到对方的nonce,并按顺序发送握手前缓存的数据
+func (f *signFilter) handshake(s *connState, pkt arpc.Packet) error {
+	if pkt.MsgID() != arpc.SysIDSign {
+		return ErrBadHandshake
+	}
+
+	remote, err := hex.DecodeString(pkt.Head(headKey))
+	if err != nil || len(remote) != nonceSize {
+		return ErrBadHandshake
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.remote != nil {
+		return ErrBadHandshake
+	}
+	s.remote = remote
+
+	pending := s.pending
+	s.pending = nil
+	for _, ctx := range pending {
+		if buff, ok := ctx.Data().(*buffer.Buffer); ok {
+			if err := f.seal(s, buff, false); err != nil {
+				ctx.Abort()
+			}
+		}
+		_ = ctx.Call()
+	}
+
+	return nil
+}
+
+// sum 计算签名,nonce为空时用于握手消息
+func (f *signFilter) sum(data string, nonce []byte) (string, error) {
+	return f.signer.Sign(data + string(nonce))
+}
+
+// seal 追加计数器和签名,握手消息的计数器为0,并且签名时不包含nonce
+func (f *signFilter) seal(s *connState, buff *buffer.Buffer, hello bool) error {
+	var counter uint64
+	var nonce []byte
+	if !hello {
+		s.send++
+		counter = s.send
+		nonce = s.remote
+	}
+
+	var head [counterSize]byte
+	binary.BigEndian.PutUint64(head[:], counter)
+	buff.Append(head[:])
+	sign, err := f.sum(buff.String(), nonce)
+	if err != nil {
+		return err
+	}
+
+	buff.Append([]byte(sign))
+	return nil
+}
+
+// open 校验签名和计数器,返回计数器,0表示握手消息
+func (f *signFilter) open(s *connState, data []byte) (uint64, error) {
+	if len(data) < trailerSize {
+		return 0, ErrNoSignature
+	}
+
+	pos := len(data) - signSize
+	counter := binary.BigEndian.Uint64(data[pos-counterSize : pos])
+	var nonce []byte
+	if counter != 0 {
+		nonce = s.local
+	}
+
+	expect, err := f.sum(string(data[:pos]), nonce)
+	if err != nil {
+		return 0, err
+	}
+
+	if !hmac.Equal([]byte(expect), data[pos:]) {
+		return 0, ErrTampered
+	}
+
+	if counter == 0 {
+		return 0, nil
+	}
+
+	if counter <= s.recv {
+		return 0, ErrReplayed
+	}
+
+	s.recv = counter
+	return counter, nil
+}
+
+// reject 尽量解析消息,如果是请求则返回错误码
+// 篡改后的消息头也不可信,这里只用于告知对方失败原因
+func (f *signFilter) reject(conn anet.Conn, data []byte, err error) {
+	if len(data) >= trailerSize {
+		data = data[:len(data)-trailerSize]
+	}
+
+	req, derr := decode(data)
+	if derr != nil || req.IsAck() || req.SeqID() == 0 {
+		return
+	}
+
+	rsp := arpc.NewPacket()
+	rsp.SetAck(true)
+	rsp.SetSeqID(req.SeqID())
+	rsp.SetMsgID(req.MsgID())
+	SetStatus(rsp, err)
+	_ = conn.Send(rsp)
+}
+
+// SetStatus 根据校验错误设置应答状态码
+func SetStatus(rsp arpc.Packet, err error) {
+	if err == ErrReplayed {
+		rsp.SetStatus(arpc.StatusReplayed, err.Error())
+	} else {
+		rsp.SetStatus(arpc.StatusBadSignature, err.Error())
+	}
+}
+
+func decode(data []byte) (arpc.Packet, error) {
+	b := buffer.New()
+	b.Append(data)
+	_, _ = b.Seek(0, io.SeekStart)
+	pkt := arpc.NewPacket()
+	if err := pkt.Decode(b); err != nil {
+		return nil, err
+	}
+
+	return pkt, nil
+}
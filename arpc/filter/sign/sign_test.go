@@ -0,0 +1,155 @@
+package sign
+
+import (
+	"testing"
+
+	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/arpc/packet"
+	"github.com/jeckbjy/gsk/util/buffer"
+)
+
+func init() {
+	arpc.SetPacketFactory(packet.New)
+}
+
+func newData(t *testing.T, f *signFilter, s *connState) []byte {
+	pkt := arpc.NewPacket()
+	pkt.SetMsgID(1)
+	pkt.SetSeqID(10)
+	pkt.SetName("login")
+	b := buffer.New()
+	if err := pkt.Encode(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.seal(s, b, false); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+// newPair 模拟握手完成后的两端
+func newPair(t *testing.T) (*connState, *connState) {
+	sender, err := newState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, _ := newState()
+	sender.remote = receiver.local
+	receiver.remote = sender.local
+	return sender, receiver
+}
+
+func TestSign(t *testing.T) {
+	f := New("secret").(*signFilter)
+	sender, receiver := newPair(t)
+	for i := 0; i < 3; i++ {
+		data := newData(t, f, sender)
+		counter, err := f.open(receiver, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counter != uint64(i+1) {
+			t.Fatalf("bad counter, %v", counter)
+		}
+
+		pkt, err := decode(data[:len(data)-trailerSize])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pkt.MsgID() != 1 || pkt.SeqID() != 10 || pkt.Name() != "login" {
+			t.Fatalf("bad packet, %+v", pkt)
+		}
+	}
+
+	// 密钥不一致
+	if _, err := New("other").(*signFilter).open(receiver, newData(t, f, sender)); err != ErrTampered {
+		t.Fatalf("expect tampered, %+v", err)
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	f := New("secret").(*signFilter)
+	sender, _ := newState()
+	receiver, _ := newState()
+
+	b := buffer.New()
+	if err := newHello(sender).Encode(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.seal(sender, b, true); err != nil {
+		t.Fatal(err)
+	}
+
+	data := b.Bytes()
+	counter, err := f.open(receiver, data)
+	if err != nil || counter != 0 {
+		t.Fatalf("bad hello, %v, %v", counter, err)
+	}
+
+	pkt, _ := decode(data[:len(data)-trailerSize])
+	if err := f.handshake(receiver, pkt); err != nil {
+		t.Fatal(err)
+	}
+	if string(receiver.remote) != string(sender.local) {
+		t.Fatal("bad remote nonce")
+	}
+
+	// 不允许重复握手
+	if err := f.handshake(receiver, pkt); err != ErrBadHandshake {
+		t.Fatalf("expect bad handshake, %v", err)
+	}
+}
+
+func TestTamper(t *testing.T) {
+	f := New("secret").(*signFilter)
+	sender, receiver := newPair(t)
+	data := newData(t, f, sender)
+	data[2] ^= 1
+	if _, err := f.open(receiver, data); err != ErrTampered {
+		t.Fatalf("expect tampered, %+v", err)
+	}
+
+	if _, err := f.open(receiver, data[:10]); err != ErrNoSignature {
+		t.Fatalf("expect no signature, %+v", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	f := New("secret").(*signFilter)
+	sender, receiver := newPair(t)
+	data := newData(t, f, sender)
+	if _, err := f.open(receiver, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.open(receiver, data); err != ErrReplayed {
+		t.Fatalf("expect replayed, %+v", err)
+	}
+
+	// 在新的连接上重放,nonce不同,签名校验失败
+	other, _ := newState()
+	if _, err := f.open(other, data); err != ErrTampered {
+		t.Fatalf("expect tampered, %+v", err)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	rsp := arpc.NewPacket()
+	SetStatus(rsp, ErrReplayed)
+	b := buffer.New()
+	if err := rsp.Encode(b); err != nil {
+		t.Fatal(err)
+	}
+
+	pkt, err := decode(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pkt.Code() != arpc.StatusReplayed {
+		t.Fatalf("bad code, %+v", pkt.Code())
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/jeckbjy/gsk/util/buffer"
 )
 
+// Deprecated: xor只能简单混淆数据,不能用于加密,请使用secure.New
 func New(key []byte) anet.Filter {
 	return &xorFilter{key: key}
 }
@@ -0,0 +1,45 @@
+package health
+
+import (
+	"context"
+	"time"
+
+	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/registry"
+)
+
+// HealthCheckReq 健康检查请求
+type HealthCheckReq struct {
+}
+
+// HealthCheckRsp 健康检查应答
+type HealthCheckRsp struct {
+	Time int64 `json:"time"` // 服务器时间戳,单位毫秒
+}
+
+// Handler 响应健康检查,需要注册到服务中
+//	srv.Register(health.Handler)
+func Handler(ctx arpc.Context, req *HealthCheckReq, rsp *HealthCheckRsp) error {
+	rsp.Time = time.Now().UnixNano() / int64(time.Millisecond)
+	return nil
+}
+
+// Register 注册健康检查回调
+func Register(r arpc.Router) error {
+	return r.Register(Handler)
+}
+
+// Checker 通过arpc向指定节点发送健康检查请求,服务端需要注册Handler
+// 与TCP检查相比,能够检测出服务卡死或者消息处理异常的情况
+//	kv.New(s, registry.WithChecker(health.Checker(client), time.Second*5))
+func Checker(c arpc.Client) registry.Checker {
+	return func(ctx context.Context, srv *registry.Service) error {
+		ttl := arpc.DefaultTTL
+		if deadline, ok := ctx.Deadline(); ok {
+			ttl = time.Until(deadline)
+		}
+
+		rsp := &HealthCheckRsp{}
+		return c.Call(srv.Name, &HealthCheckReq{}, rsp, arpc.WithNode(srv.Id), arpc.WithTTL(ttl))
+	}
+}
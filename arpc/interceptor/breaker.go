@@ -0,0 +1,28 @@
+package interceptor
+
+import (
+	"github.com/jeckbjy/gsk/apm/breaker"
+	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/selector"
+)
+
+// Breaker 熔断拦截器,以节点ID作为key,每个节点单独统计
+// group为nil则使用breaker默认分组
+func Breaker(group breaker.Group) arpc.Interceptor {
+	return func(node selector.Node, req arpc.Packet, opts *arpc.MiscOptions, invoker arpc.Invoker) error {
+		var b breaker.Breaker
+		if group != nil {
+			b = group.Get(node.Id())
+		} else {
+			b = breaker.Get(node.Id())
+		}
+
+		if err := b.Allow(); err != nil {
+			return err
+		}
+
+		err := invoker(node, req, opts)
+		b.Mark(err)
+		return err
+	}
+}
@@ -0,0 +1,78 @@
+package interceptor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/apm/breaker"
+	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/selector"
+)
+
+type testNode struct {
+	id string
+}
+
+func (n *testNode) Id() string {
+	return n.id
+}
+
+func (n *testNode) Addr() string {
+	return ""
+}
+
+func (n *testNode) Conn(tran anet.Tran) (anet.Conn, error) {
+	return nil, arpc.ErrNotSupport
+}
+
+func TestChain(t *testing.T) {
+	var trace []string
+	newInterceptor := func(name string) arpc.Interceptor {
+		return func(node selector.Node, req arpc.Packet, opts *arpc.MiscOptions, invoker arpc.Invoker) error {
+			trace = append(trace, name)
+			return invoker(node, req, opts)
+		}
+	}
+
+	chain := arpc.ChainInterceptor(newInterceptor("a"), newInterceptor("b"), newInterceptor("c"))
+	err := chain(&testNode{id: "1"}, nil, &arpc.MiscOptions{}, func(node selector.Node, req arpc.Packet, opts *arpc.MiscOptions) error {
+		trace = append(trace, "invoke")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trace) != 4 || trace[0] != "a" || trace[1] != "b" || trace[2] != "c" || trace[3] != "invoke" {
+		t.Fatalf("bad order, %+v", trace)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	chain := arpc.ChainInterceptor(Recover())
+	err := chain(&testNode{id: "1"}, nil, &arpc.MiscOptions{}, func(node selector.Node, req arpc.Packet, opts *arpc.MiscOptions) error {
+		panic("boom")
+	})
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("bad error, %+v", err)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	group := breaker.NewGroup(&breaker.Config{RequestThreshold: 5})
+	chain := arpc.ChainInterceptor(Breaker(group))
+	errFail := errors.New("fail")
+	node := &testNode{id: "1"}
+	invoked := 0
+	for i := 0; i < 20; i++ {
+		_ = chain(node, nil, &arpc.MiscOptions{}, func(node selector.Node, req arpc.Packet, opts *arpc.MiscOptions) error {
+			invoked++
+			return errFail
+		})
+	}
+
+	if invoked == 20 {
+		t.Fatal("breaker not open")
+	}
+}
@@ -0,0 +1,24 @@
+package interceptor
+
+import (
+	"fmt"
+
+	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/selector"
+)
+
+// Recover 用于拦截panic,转换为错误返回
+func Recover() arpc.Interceptor {
+	return func(node selector.Node, req arpc.Packet, opts *arpc.MiscOptions, invoker arpc.Invoker) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				var ok bool
+				if err, ok = r.(error); !ok {
+					err = fmt.Errorf("%v", r)
+				}
+			}
+		}()
+
+		return invoker(node, req, opts)
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/arpc/filter/sign"
+)
+
+// Signed 拒绝没有经过sign.Filter校验的消息,与sign.New配合使用
+// 用于面向客户端的Router,防止漏配Filter或者其他连接绕过签名校验
+func Signed() arpc.HandlerFunc {
+	return func(ctx arpc.Context) error {
+		msg := ctx.Message()
+		if _, ok := msg.Internal().(*sign.Stamp); ok {
+			return ctx.Next()
+		}
+
+		if !msg.IsAck() && msg.SeqID() != 0 {
+			rsp := arpc.NewPacket()
+			rsp.SetAck(true)
+			rsp.SetSeqID(msg.SeqID())
+			rsp.SetMsgID(msg.MsgID())
+			sign.SetStatus(rsp, sign.ErrNoSignature)
+			_ = ctx.Send(rsp)
+		}
+
+		ctx.Abort(sign.ErrNoSignature)
+		return nil
+	}
+}
@@ -47,3 +47,23 @@ func TestEncode(t *testing.T) {
 	t.Log(pkgd)
 	t.Log(bodyd)
 }
+
+func TestHeadMap(t *testing.T) {
+	pkg := New()
+	pkg.SetSeqID(1)
+	pkg.SetHead("k", "v")
+	buf := buffer.New()
+	if err := pkg.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = buf.Seek(0, io.SeekStart)
+	pkgd := New()
+	if err := pkgd.Decode(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if pkgd.Head("k") != "v" {
+		t.Fatalf("bad head, %+v", pkgd)
+	}
+}
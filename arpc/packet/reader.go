@@ -74,6 +74,10 @@ func (r *Reader) ReadMap(m *map[string]string, mask uint64) error {
 			return err
 		}
 
+		if *m == nil {
+			*m = make(map[string]string, l)
+		}
+
 		for i := 0; i < int(l); i++ {
 			s, err := r.ReadStringDirect()
 			if err != nil {
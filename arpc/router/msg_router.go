@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/registry"
 )
 
 type _MsgInfo struct {
@@ -15,9 +16,10 @@ type _MsgInfo struct {
 }
 
 type MsgRouter struct {
-	mux  sync.RWMutex         //
-	list []*_MsgInfo          // ID列表
-	dict map[string]*_MsgInfo // (name/method)=>MsgInfo
+	mux       sync.RWMutex         //
+	list      []*_MsgInfo          // ID列表
+	dict      map[string]*_MsgInfo // (name/method)=>MsgInfo
+	endpoints []*registry.Endpoint // 注册的所有消息,用于服务注册
 }
 
 func (r *MsgRouter) Init() {
@@ -69,6 +71,10 @@ func (r *MsgRouter) Register(cb interface{}, o *arpc.MiscOptions) error {
 	defer r.mux.Unlock()
 
 	info := &_MsgInfo{Handler: handler, Extra: o.Extra}
+	ep := &registry.Endpoint{ID: o.ID, Method: o.Method}
+	if t := v.Type(); t.NumIn() > 1 {
+		ep.Name = t.In(1).Elem().Name()
+	}
 
 	// ID和Method可以共存,因为可以支持多种协议,比如tcp为了高效可以使用ID,http为了简单方便可以使用Method
 	// 但Name则不是必须的,只有在测试的环境下才需要使用,因为使用Name完全可以用ID的方式代替
@@ -105,9 +111,24 @@ func (r *MsgRouter) Register(cb interface{}, o *arpc.MiscOptions) error {
 		}
 	}
 
+	r.endpoints = append(r.endpoints, ep)
 	return err
 }
 
+// Endpoints 返回所有注册的消息
+func (r *MsgRouter) Endpoints() []*registry.Endpoint {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	result := make([]*registry.Endpoint, 0, len(r.endpoints))
+	for _, ep := range r.endpoints {
+		cp := *ep
+		result = append(result, &cp)
+	}
+
+	return result
+}
+
 func toHandler(v reflect.Value, cb interface{}) (arpc.HandlerFunc, error) {
 	// func(ctx Context) error
 	if handler, ok := cb.(arpc.HandlerFunc); ok {
@@ -38,6 +38,9 @@ func (r *RpcRouter) Handle(ctx arpc.Context) arpc.HandlerFunc {
 
 	if info != nil {
 		ctx.SetData(info.Data)
+		if opts, ok := info.Request.Internal().(*arpc.MiscOptions); ok && opts.OnDone != nil {
+			opts.OnDone(nil)
+		}
 		return info.Handler
 	} else { // 不存在也不需要报错
 		return nil
@@ -182,6 +185,9 @@ func (r *RpcRouter) onTimeout(seqID uint64) {
 			delete(r.infos, seqID)
 			// TODO:notify timeout for async callback, add onError callback?
 			opts := info.Request.Internal().(*arpc.MiscOptions)
+			if opts.OnDone != nil {
+				opts.OnDone(arpc.ErrTimeout)
+			}
 			if opts.Future != nil {
 				opts.Future.Done(arpc.ErrTimeout)
 			}
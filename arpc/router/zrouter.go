@@ -2,6 +2,7 @@ package router
 
 import (
 	"github.com/jeckbjy/gsk/arpc"
+	"github.com/jeckbjy/gsk/registry"
 )
 
 func New() arpc.Router {
@@ -46,3 +47,8 @@ func (r *Router) Register(cb interface{}, opts ...arpc.MiscOption) error {
 		return r.msg.Register(cb, &o)
 	}
 }
+
+// Endpoints 实现arpc.EndpointLister,只包含静态注册的消息,不包括RPC应答
+func (r *Router) Endpoints() []*registry.Endpoint {
+	return r.msg.Endpoints()
+}
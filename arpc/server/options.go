@@ -41,3 +41,41 @@ func Advertise(addr string) arpc.Option {
 		o.Advertise = addr
 	}
 }
+
+func Version(v string) arpc.Option {
+	return func(o *arpc.Options) {
+		o.Version = v
+	}
+}
+
+func Namespace(ns string) arpc.Option {
+	return func(o *arpc.Options) {
+		o.Namespace = ns
+	}
+}
+
+func Zone(zone string) arpc.Option {
+	return func(o *arpc.Options) {
+		o.Zone = zone
+	}
+}
+
+func Weight(w int) arpc.Option {
+	return func(o *arpc.Options) {
+		o.Weight = w
+	}
+}
+
+// Tags 注册服务时附带的元数据,可用于Query时过滤
+func Tags(tags map[string]string) arpc.Option {
+	return func(o *arpc.Options) {
+		o.Tags = tags
+	}
+}
+
+// Router 用于注册服务时获取Endpoints,默认使用全局Router
+func Router(r arpc.Router) arpc.Option {
+	return func(o *arpc.Options) {
+		o.Router = r
+	}
+}
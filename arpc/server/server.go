@@ -99,8 +99,12 @@ func (s *_Server) register() error {
 	}
 
 	address = net.JoinHostPort(host, port)
-	srv := registry.NewService(o.Name, s.serviceID(), address, nil)
-	// 解析endpoint
+	srv := registry.NewService(o.Name, s.serviceID(), address, o.Tags)
+	srv.Version = o.Version
+	srv.Namespace = o.Namespace
+	srv.Zone = o.Zone
+	srv.Weight = o.Weight
+	srv.Endpoints = s.endpoints()
 	if err := o.Registry.Register(srv); err != nil {
 		return err
 	}
@@ -108,6 +112,20 @@ func (s *_Server) register() error {
 	return nil
 }
 
+// endpoints 从Router中获取所有注册的消息
+func (s *_Server) endpoints() []*registry.Endpoint {
+	r := s.opts.Router
+	if r == nil {
+		r = arpc.GetRouter()
+	}
+
+	if l, ok := r.(arpc.EndpointLister); ok {
+		return l.Endpoints()
+	}
+
+	return nil
+}
+
 func (s *_Server) deregister() error {
 	o := s.opts
 	if o.Registry == nil {
@@ -0,0 +1,40 @@
+package arpc
+
+import (
+	"github.com/jeckbjy/gsk/selector"
+)
+
+// Invoker 客户端真正发送消息的函数
+// Send时opts.Response为nil,Call时不为nil
+// 同步Call会在Invoker中等待应答,因此返回的是最终调用结果,异步Call则只能返回发送结果
+type Invoker func(node selector.Node, req Packet, opts *MiscOptions) error
+
+// Interceptor 客户端拦截器,类似于grpc的UnaryClientInterceptor
+// 作用于每一次Send和Call,可以在调用invoker前后做一些统一处理
+// 比如注入TraceID,鉴权信息,熔断,日志,故障注入等
+// 拦截器可以不调用invoker而直接返回错误,从而终止本次调用
+type Interceptor func(node selector.Node, req Packet, opts *MiscOptions, invoker Invoker) error
+
+// ChainInterceptor 将多个拦截器合并成一个,按照顺序执行,第一个在最外层
+func ChainInterceptor(interceptors ...Interceptor) Interceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+
+	return func(node selector.Node, req Packet, opts *MiscOptions, invoker Invoker) error {
+		return interceptors[0](node, req, opts, chainInvoker(interceptors, 0, invoker))
+	}
+}
+
+func chainInvoker(interceptors []Interceptor, index int, final Invoker) Invoker {
+	if index == len(interceptors)-1 {
+		return final
+	}
+
+	return func(node selector.Node, req Packet, opts *MiscOptions) error {
+		return interceptors[index+1](node, req, opts, chainInvoker(interceptors, index+1, final))
+	}
+}
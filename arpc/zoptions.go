@@ -16,16 +16,22 @@ const (
 // 用于创建Server和Client
 type Option func(o *Options)
 type Options struct {
-	Context   context.Context   //
-	Registry  registry.Registry //
-	Tran      anet.Tran         //
-	Name      string            // 服务名
-	Id        string            // 服务ID
-	Version   string            // 服务版本
-	Address   string            // Listen使用
-	Advertise string            // 注册服务使用
-	Selector  selector.Selector // client load balance
-	Proxy     string            // 代理服务名,空代表不使用代理
+	Context      context.Context   //
+	Registry     registry.Registry //
+	Tran         anet.Tran         //
+	Name         string            // 服务名
+	Id           string            // 服务ID
+	Version      string            // 服务版本
+	Namespace    string            // 命名空间
+	Zone         string            // 可用区
+	Weight       int               // 权重
+	Tags         map[string]string // 注册服务时附带的元数据
+	Router       Router            // 注册服务时用于获取Endpoints,nil则使用全局Router
+	Address      string            // Listen使用
+	Advertise    string            // 注册服务使用
+	Selector     selector.Selector // client load balance
+	Proxy        string            // 代理服务名,空代表不使用代理
+	Interceptors []Interceptor     // client拦截器,按照顺序执行
 }
 
 // 重试回调函数,每次需要返回新的TTL,小于等于0则终止重试
@@ -41,8 +47,11 @@ type MiscOptions struct {
 	RetryCB          RetryFunc     // 重试回调函数
 	TTL              time.Duration // 超时时间
 	Future           Future        // 异步等待
+	OnDone           func(error)   // 收到应答或者超时后回调,超时返回ErrTimeout,用于统计节点负载
 	Response         interface{}   // callback
 	Extra            interface{}   // 自定义扩展数据
+	Quorum           int           // 广播时,成功数达到Quorum则立即返回,0表示需要全部成功
+	FailFast         bool          // 广播时,任意节点失败则立即返回
 }
 
 func (o *MiscOptions) Init(opts ...MiscOption) {
@@ -52,6 +61,65 @@ func (o *MiscOptions) Init(opts ...MiscOption) {
 	}
 }
 
+// WithQuorum 广播时,成功数达到n则立即返回,不再等待其他节点
+func WithQuorum(n int) MiscOption {
+	return func(o *MiscOptions) {
+		o.Quorum = n
+	}
+}
+
+// WithFailFast 广播时,任意节点失败则立即返回
+func WithFailFast() MiscOption {
+	return func(o *MiscOptions) {
+		o.FailFast = true
+	}
+}
+
+// WithTTL 设置超时时间,广播时作用于每个节点
+func WithTTL(ttl time.Duration) MiscOption {
+	return func(o *MiscOptions) {
+		o.TTL = ttl
+	}
+}
+
+// WithNode 指定节点ID,不经过负载均衡,会忽略节点的健康状态
+func WithNode(id string) MiscOption {
+	return func(o *MiscOptions) {
+		o.Node = id
+	}
+}
+
+// WithStrategy 指定负载均衡策略,比如selector.P2C,selector.LeastLoaded
+func WithStrategy(s selector.Strategy) MiscOption {
+	return func(o *MiscOptions) {
+		o.Strategy = s
+	}
+}
+
+// WithHash 根据key选择节点,相同的key总是选择相同的节点,比如按照uid分配游戏服
+// 默认使用取模,节点变化时大部分key会重新映射,可使用WithHashMode指定一致性hash
+func WithHash(key int64) MiscOption {
+	return func(o *MiscOptions) {
+		o.Hash = key
+	}
+}
+
+// WithHashMode 指定hash算法,比如selector.HashRing,selector.HashMaglev
+// factor大于0时启用有界负载,节点负载超过平均负载的factor倍时顺延到下一个节点,通常取1.25
+func WithHashMode(mode selector.HashMode, factor float64) MiscOption {
+	return func(o *MiscOptions) {
+		o.HashMode = mode
+		o.LoadFactor = factor
+	}
+}
+
+// WithZone 优先选择指定可用区的节点,默认使用Client所在的可用区
+func WithZone(zone string) MiscOption {
+	return func(o *MiscOptions) {
+		o.Zone = zone
+	}
+}
+
 //type CallOption func(o *CallOptions)
 //type CallOptions struct {
 //	selector.Options
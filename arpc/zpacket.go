@@ -3,6 +3,7 @@ package arpc
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"sync/atomic"
 
 	"github.com/jeckbjy/gsk/codec"
@@ -50,6 +51,19 @@ const (
 	HFHeadMap              = 7  // 自定义消息头,map[string]string
 	HFExtra                = 8  // 扩展字段,key<16
 	HFMax                  = 15 // 最大可用位数
+	HFCompress             = 15 // 压缩标识,置位时后续数据为压缩后的完整消息,仅在连接协商后才会使用
+)
+
+// 系统消息ID,使用负数,取值范围[IDMin,0)
+const (
+	SysIDCompress = -1 // 压缩算法协商
+	SysIDSign     = -2 // 签名握手,交换防重放的nonce
+)
+
+// 预定义状态码,与http状态码保持一致
+const (
+	StatusBadSignature = 401 // 签名校验失败,消息被篡改或者没有签名
+	StatusReplayed     = 409 // 重复或者过期的消息,可能是重放攻击
 )
 
 // 预定义extra枚举,外部可以自行定义
@@ -61,11 +75,12 @@ const (
 	HFExtraProjectID = 4
 )
 
-// 使用2个字节作为Flag标识,目前系统已经使用了9个,还剩7个可以自定义,取值范围[0-6]
+// 使用2个字节作为Flag标识,0-7位为系统字段,15位为压缩标识HFCompress,
+// 8-14位为extra字段,其中可以自定义的key取值范围[0,HFExtraMax)
 // 服务器集群内经常使用的有TraceID，SpanID,RemoteIP,UserID,ProjectID等
 // 客户端与服务器通信经常使用的有,Auth,Checksum
 const HFExtraMax = 6
-const HFExtraMask = ^uint16(1<<HFExtra - 1)
+const HFExtraMask = ^uint16(1<<HFExtra-1) &^ (1 << HFCompress)
 
 // 私有通信协议
 // 编码格式:Flag[2byte]+Head+Body
@@ -169,12 +184,16 @@ func (s *Status) Decode(text string) {
 				s.Code = 0
 				s.Info = text
 			} else {
-				s.Code, _ = strconv.Atoi(text[:i-1])
-				s.Info = text[i:]
+				s.Code, _ = strconv.Atoi(text[:i])
+				s.Info = strings.TrimPrefix(text[i:], " ")
 			}
-			break
+			return
 		}
 	}
+
+	// 只有状态码
+	s.Code, _ = strconv.Atoi(text)
+	s.Info = ""
 }
 
 func IsValidID(id int) bool {
@@ -0,0 +1,31 @@
+package arpc
+
+import "testing"
+
+func TestStatus(t *testing.T) {
+	cases := []struct {
+		text string
+		code int
+		info string
+	}{
+		{"", 0, ""},
+		{"401 bad signature", 401, "bad signature"},
+		{"409", 409, ""},
+		{"not found", 0, "not found"},
+	}
+
+	for _, c := range cases {
+		s := Status{}
+		s.Decode(c.text)
+		if s.Code != c.code || s.Info != c.info {
+			t.Fatalf("bad status, %q, %+v", c.text, s)
+		}
+	}
+
+	s := Status{Code: 401, Info: "bad signature"}
+	d := Status{}
+	d.Decode(s.Encode())
+	if d != s {
+		t.Fatalf("bad decode, %+v", d)
+	}
+}
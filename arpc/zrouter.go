@@ -4,6 +4,7 @@ import (
 	"sync/atomic"
 
 	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/registry"
 )
 
 var gContextFactory ContextFactory
@@ -23,9 +24,10 @@ func SetRouter(r Router) {
 	gRouter.Store(r)
 }
 
-// GetRouter 获取全局Router
+// GetRouter 获取全局Router,没有设置则返回nil
 func GetRouter() Router {
-	return gRouter.Load().(Router)
+	r, _ := gRouter.Load().(Router)
+	return r
 }
 
 // Use 设置全局Middleware
@@ -84,6 +86,11 @@ type Router interface {
 	Register(cb interface{}, opts ...MiscOption) error
 }
 
+// EndpointLister 可选接口,Router实现后,Server注册服务时会自动填充Endpoints
+type EndpointLister interface {
+	Endpoints() []*registry.Endpoint
+}
+
 // MessageID 用于通过反射识别消息是否提供了消息ID,从而避免通过Name映射查询ID
 // 接口函数可以使用工具自动生成代码
 type MessageID interface {
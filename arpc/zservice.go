@@ -15,6 +15,7 @@ var (
 	ErrTimeout         = errors.New("timeout")
 	ErrNotFoundID      = errors.New("not found id")
 	ErrInvalidParam    = errors.New("invalid param")
+	ErrPartialFailure  = errors.New("partial failure")
 )
 
 type Server interface {
@@ -42,10 +43,26 @@ type Server interface {
 //	b:分别请求A,B,C协议,并使用f作为参数,底层会自动为Future调用Add
 //	c:调用f.Wait()方法,可以同步也可以异步
 //  需要特别注意:如果外部创建Future,则必须自己手动托管Wait调用
+//
+// Broadcast函数: 向服务的所有节点并行发送相同的请求,通常用于GM工具或者管理后台
+// 1:rsp为nil时,仅发送消息,不等待应答
+// 2:rsp为结构体指针时,作为原型为每个节点创建新的应答消息,并同步等待
+// 3:返回结果以节点ID为key,仅包含返回前已经完成的节点
+// 4:WithQuorum可指定成功数达到N后立即返回,WithFailFast可指定任意失败立即返回,TTL作用于每个节点
+// 5:成功数不满足要求时,返回ErrPartialFailure,此时结果中依然包含各个节点的应答和错误
+// 注意:req不能是Packet,因为每个节点都需要创建新的Packet
 type Client interface {
 	Init(opts ...Option) error
 	Send(service string, req interface{}, opts ...MiscOption) error
 	Call(service string, req interface{}, rsp interface{}, opts ...MiscOption) error
+	Broadcast(service string, req interface{}, rsp interface{}, opts ...MiscOption) (map[string]*Reply, error)
+}
+
+// Reply 广播时单个节点的调用结果
+type Reply struct {
+	Node     string      // 节点ID
+	Response interface{} // 应答消息,发送失败或仅发送时为nil
+	Error    error       // 错误信息
 }
 
 // Future 用于异步RPC调用时,阻塞当前调用
@@ -0,0 +1,147 @@
+// Package actor 基于exec实现的actor模型
+// 每个key对应一个actor,拥有独立的mailbox,消息按照顺序串行处理,不同actor之间并行执行
+// actor在第一次收到消息时通过Producer创建,空闲超过IdleTimeout后自动停止,panic后由Supervisor决定是否重启
+// mailbox并不独占协程,有消息时才投递到exec.Executor中执行,因此可以创建大量的actor,比如每个玩家一个
+package actor
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jeckbjy/gsk/exec"
+)
+
+var (
+	ErrStopped    = errors.New("actor stopped")
+	ErrNoResponse = errors.New("actor no response")
+	ErrPanic      = errors.New("actor panic")
+)
+
+// Actor 处理消息,同一个actor的Receive不会并发调用
+// 除了业务消息外,还会收到*Started和*Stopped生命周期消息
+type Actor interface {
+	Receive(ctx *Context)
+}
+
+// Producer 创建actor,重启时也会调用
+type Producer func(key string) Actor
+
+// Func 将函数转换为Actor
+type Func func(ctx *Context)
+
+func (f Func) Receive(ctx *Context) {
+	f(ctx)
+}
+
+// Started actor创建或者重启后收到的第一个消息,可以在此加载数据
+type Started struct {
+	Restart bool
+}
+
+// Stopped actor停止前收到的最后一个消息,可以在此保存数据
+type Stopped struct {
+}
+
+// Context 消息上下文,只能在Receive中使用
+type Context struct {
+	sys     *System
+	proc    *process
+	env     *envelope
+	replied bool
+}
+
+func (c *Context) Key() string {
+	return c.proc.key
+}
+
+// Self 当前actor的引用,actor在停止过程中(包括处理Stopped时)通过它给自己发送消息会返回ErrStopped
+func (c *Context) Self() *Ref {
+	return &Ref{sys: c.sys, key: c.proc.key, proc: c.proc}
+}
+
+func (c *Context) System() *System {
+	return c.sys
+}
+
+func (c *Context) Message() interface{} {
+	return c.env.msg
+}
+
+// Sender 通过Ref.TellFrom发送时的发送者,可能为nil
+func (c *Context) Sender() *Ref {
+	return c.env.sender
+}
+
+// Respond 应答Request,需要在Receive返回前调用,没有应答时Request返回ErrNoResponse
+func (c *Context) Respond(value interface{}) {
+	c.reply(value, nil)
+}
+
+// RespondError 应答错误
+func (c *Context) RespondError(err error) {
+	c.reply(nil, err)
+}
+
+func (c *Context) reply(value interface{}, err error) {
+	if c.replied || c.env.reply == nil {
+		return
+	}
+
+	c.replied = true
+	c.env.reply <- &response{value: value, err: err}
+}
+
+// Request 向其他actor发送请求并等待应答,会阻塞当前actor,注意不要循环请求导致死锁
+func (c *Context) Request(ctx context.Context, key string, msg interface{}) (interface{}, error) {
+	return c.sys.request(ctx, key, msg, c.proc)
+}
+
+// Ref actor的引用,actor停止后依然可以使用,发送消息时会创建新的actor
+type Ref struct {
+	sys  *System
+	key  string
+	proc *process // 通过Context.Self创建时为当前actor,用于检测给自己发消息
+}
+
+func (r *Ref) Key() string {
+	return r.key
+}
+
+func (r *Ref) Tell(msg interface{}) error {
+	return r.sys.deliver(r.key, &envelope{msg: msg}, r.proc)
+}
+
+// TellFrom 发送消息并指定发送者,接收者可以通过Context.Sender回复
+func (r *Ref) TellFrom(msg interface{}, sender *Ref) error {
+	return r.sys.deliver(r.key, &envelope{msg: msg, sender: sender}, r.proc)
+}
+
+func (r *Ref) Request(ctx context.Context, msg interface{}) (interface{}, error) {
+	return r.sys.request(ctx, r.key, msg, r.proc)
+}
+
+func (r *Ref) Post(task exec.Task) error {
+	return r.sys.deliver(r.key, &envelope{task: task}, r.proc)
+}
+
+func (r *Ref) Stop() {
+	r.sys.Stop(r.key)
+}
+
+type response struct {
+	value interface{}
+	err   error
+}
+
+type envelope struct {
+	msg    interface{}
+	task   exec.Task
+	sender *Ref
+	reply  chan *response
+}
+
+func (e *envelope) fail(err error) {
+	if e.reply != nil {
+		e.reply <- &response{err: err}
+	}
+}
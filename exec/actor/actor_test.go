@@ -0,0 +1,233 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+	"github.com/jeckbjy/gsk/exec/pooled"
+)
+
+type incr struct{}
+type get struct{}
+
+// counter 每个key一个计数器
+type counter struct {
+	count   int
+	started *int32
+	stopped *int32
+}
+
+func (c *counter) Receive(ctx *Context) {
+	switch msg := ctx.Message().(type) {
+	case *Started:
+		atomic.AddInt32(c.started, 1)
+	case *Stopped:
+		atomic.AddInt32(c.stopped, 1)
+	case incr:
+		c.count++
+	case get:
+		ctx.Respond(c.count)
+	case string:
+		if msg == "panic" {
+			panic("boom")
+		}
+	}
+}
+
+func newCounter(started, stopped *int32) Producer {
+	return func(key string) Actor {
+		return &counter{started: started, stopped: stopped}
+	}
+}
+
+func TestSerial(t *testing.T) {
+	var started, stopped int32
+	s := NewSystem(newCounter(&started, &stopped), Throughput(10))
+
+	// 同一个actor的消息串行处理,不需要加锁
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = s.Tell("player-1", incr{})
+				_ = s.Tell("player-2", incr{})
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, key := range []string{"player-1", "player-2"} {
+		v, err := s.Request(context.Background(), key, get{})
+		if err != nil || v.(int) != 1000 {
+			t.Fatalf("bad count, %s, %v, %+v", key, v, err)
+		}
+	}
+
+	if _, err := s.Request(context.Background(), "player-1", incr{}); err != ErrNoResponse {
+		t.Fatalf("should no response, %+v", err)
+	}
+
+	s.Shutdown()
+	if started != 2 || stopped != 2 || s.Len() != 0 {
+		t.Fatalf("bad lifecycle, %d, %d, %d", started, stopped, s.Len())
+	}
+
+	if err := s.Tell("player-1", incr{}); err == nil {
+		t.Fatal("tell after shutdown")
+	}
+}
+
+// TestBoundedExecutor Executor队列满时被拒绝或者丢弃的actor会重新投递,消息不会丢失
+func TestBoundedExecutor(t *testing.T) {
+	policies := []exec.Policy{exec.PolicyBlock, exec.PolicyDropNewest, exec.PolicyDropOldest}
+	for _, policy := range policies {
+		var started, stopped int32
+		e := pooled.New(2, exec.QueueSize(1, policy))
+		s := NewSystem(newCounter(&started, &stopped), Executor(e), Throughput(1))
+
+		const keys = 8
+		var wg sync.WaitGroup
+		for i := 0; i < keys; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					_ = s.Tell(fmt.Sprintf("key-%d", i), incr{})
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < keys; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			v, err := s.Request(ctx, fmt.Sprintf("key-%d", i), get{})
+			cancel()
+			if err != nil || v.(int) != 50 {
+				t.Fatalf("policy %d, bad count, %v, %+v", policy, v, err)
+			}
+		}
+
+		s.Shutdown()
+		_ = e.Stop()
+		e.Wait()
+		if started != keys || stopped != keys {
+			t.Fatalf("policy %d, bad lifecycle, %d, %d", policy, started, stopped)
+		}
+	}
+}
+
+func TestSupervisor(t *testing.T) {
+	var started, stopped int32
+	s := NewSystem(newCounter(&started, &stopped))
+	defer s.Shutdown()
+
+	_ = s.Tell("p", incr{})
+	if _, err := s.Request(context.Background(), "p", "panic"); !errors.Is(err, ErrPanic) {
+		t.Fatalf("should panic, %+v", err)
+	}
+
+	// 重启后状态重置
+	v, _ := s.Request(context.Background(), "p", get{})
+	if v.(int) != 0 || atomic.LoadInt32(&started) != 2 {
+		t.Fatalf("not restarted, %v, %d", v, started)
+	}
+
+	// Resume保留状态
+	r := NewSystem(newCounter(&started, &stopped), WithSupervisor(func(key string, reason interface{}) Directive {
+		return Resume
+	}))
+	defer r.Shutdown()
+	_ = r.Tell("p", incr{})
+	_ = r.Tell("p", "panic")
+	if v, _ := r.Request(context.Background(), "p", get{}); v.(int) != 1 {
+		t.Fatalf("not resumed, %v", v)
+	}
+}
+
+func TestPassivation(t *testing.T) {
+	var started, stopped int32
+	s := NewSystem(newCounter(&started, &stopped), IdleTimeout(time.Millisecond*50))
+	defer s.Shutdown()
+
+	_ = s.Tell("p", incr{})
+	time.Sleep(time.Millisecond * 200)
+	if s.Len() != 0 || atomic.LoadInt32(&stopped) != 1 {
+		t.Fatalf("not passivated, %d, %d", s.Len(), stopped)
+	}
+
+	// 再次发送消息时重新创建
+	v, _ := s.Request(context.Background(), "p", get{})
+	if v.(int) != 0 || atomic.LoadInt32(&started) != 2 {
+		t.Fatalf("not respawned, %v, %d", v, started)
+	}
+}
+
+type taskFunc func() error
+
+func (f taskFunc) Run() error {
+	return f()
+}
+
+func TestPost(t *testing.T) {
+	s := NewSystem(nil, MailboxSize(1000))
+	defer s.Shutdown()
+
+	// 没有Producer时作为按key串行的执行器
+	count := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		_ = s.Post("uid", taskFunc(func() error {
+			count++
+			wg.Done()
+			return nil
+		}))
+	}
+	wg.Wait()
+	if count != 100 {
+		t.Fatalf("bad count, %d", count)
+	}
+}
+
+func TestTellSelfWhenStopped(t *testing.T) {
+	result := make(chan error, 1)
+	s := NewSystem(func(key string) Actor {
+		return Func(func(ctx *Context) {
+			switch ctx.Message().(type) {
+			case *Stopped:
+				result <- ctx.Self().Tell(incr{})
+			case get:
+				ctx.Respond(nil)
+			}
+		})
+	})
+	defer s.Shutdown()
+
+	if _, err := s.Request(context.Background(), "p", get{}); err != nil {
+		t.Fatal(err)
+	}
+	s.Stop("p")
+
+	// 在Stopped中给自己发消息不能阻塞
+	select {
+	case err := <-result:
+		if err != ErrStopped {
+			t.Fatalf("expect ErrStopped, %+v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("tell self blocked")
+	}
+
+	// 其他协程发送的消息依然会重新创建actor
+	if err := s.Tell("p", incr{}); err != nil {
+		t.Fatal(err)
+	}
+}
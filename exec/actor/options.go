@@ -0,0 +1,75 @@
+package actor
+
+import (
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+)
+
+const (
+	DefaultThroughput    = 100
+	DefaultMaxRestarts   = 10
+	DefaultRestartWindow = time.Minute
+)
+
+// Directive 处理panic的方式
+type Directive int
+
+const (
+	Restart Directive = iota // 使用Producer重新创建actor,默认
+	Resume                   // 忽略错误,继续处理后续消息
+	Stop                     // 停止actor,后续消息会创建新的actor
+)
+
+// Supervisor 决定actor panic后如何处理,reason为recover的返回值
+type Supervisor func(key string, reason interface{}) Directive
+
+type Option func(o *Options)
+
+type Options struct {
+	Executor      exec.Executor // 执行mailbox,默认pooled,不限制协程数
+	MailboxSize   int           // mailbox容量,<=0不限制,超过后Tell返回exec.ErrRejected
+	Throughput    int           // 每次调度最多处理的消息数,之后让出执行器,防止某个actor独占
+	IdleTimeout   time.Duration // 超过时间没有消息则停止actor(passivation),0表示不停止
+	Supervisor    Supervisor    // 默认Restart
+	MaxRestarts   int           // RestartWindow时间内最多重启次数,超过后停止actor
+	RestartWindow time.Duration //
+}
+
+func Executor(e exec.Executor) Option {
+	return func(o *Options) {
+		o.Executor = e
+	}
+}
+
+func MailboxSize(n int) Option {
+	return func(o *Options) {
+		o.MailboxSize = n
+	}
+}
+
+func Throughput(n int) Option {
+	return func(o *Options) {
+		o.Throughput = n
+	}
+}
+
+func IdleTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.IdleTimeout = d
+	}
+}
+
+func WithSupervisor(s Supervisor) Option {
+	return func(o *Options) {
+		o.Supervisor = s
+	}
+}
+
+// MaxRestarts window时间内最多重启n次
+func MaxRestarts(n int, window time.Duration) Option {
+	return func(o *Options) {
+		o.MaxRestarts = n
+		o.RestartWindow = window
+	}
+}
@@ -0,0 +1,478 @@
+package actor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+	"github.com/jeckbjy/gsk/exec/pooled"
+)
+
+// System 管理所有actor,key相同的消息由同一个actor串行处理
+type System struct {
+	opts     *Options
+	producer Producer
+	owned    bool // Executor是否由System创建
+	mux      sync.Mutex
+	procs    map[string]*process
+	closed   bool
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSystem 创建actor系统,producer为nil时actor只用于串行执行Post的任务
+func NewSystem(producer Producer, opts ...Option) *System {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	s := &System{opts: o, producer: producer, procs: make(map[string]*process), quit: make(chan struct{})}
+	if o.Executor == nil {
+		o.Executor = pooled.New(0)
+		s.owned = true
+	}
+	if o.Throughput <= 0 {
+		o.Throughput = DefaultThroughput
+	}
+	if o.Supervisor == nil {
+		o.Supervisor = func(key string, reason interface{}) Directive { return Restart }
+	}
+	if o.MaxRestarts <= 0 {
+		o.MaxRestarts = DefaultMaxRestarts
+	}
+	if o.RestartWindow <= 0 {
+		o.RestartWindow = DefaultRestartWindow
+	}
+
+	if o.IdleTimeout > 0 {
+		s.wg.Add(1)
+		go s.passivate()
+	}
+
+	return s
+}
+
+// Ref 返回actor的引用,不会创建actor
+func (s *System) Ref(key string) *Ref {
+	return &Ref{sys: s, key: key}
+}
+
+// Len 当前存活的actor个数
+func (s *System) Len() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.procs)
+}
+
+// Tell 发送消息,不等待处理,actor在停止过程中会等待停止完成后发送给新的actor,
+// actor给自己发送消息需要使用Context.Self,否则停止过程中会死锁
+func (s *System) Tell(key string, msg interface{}) error {
+	return s.deliver(key, &envelope{msg: msg}, nil)
+}
+
+// Request 发送消息并等待actor调用Respond
+func (s *System) Request(ctx context.Context, key string, msg interface{}) (interface{}, error) {
+	return s.request(ctx, key, msg, nil)
+}
+
+func (s *System) request(ctx context.Context, key string, msg interface{}, from *process) (interface{}, error) {
+	env := &envelope{msg: msg, reply: make(chan *response, 1)}
+	if err := s.deliver(key, env, from); err != nil {
+		return nil, err
+	}
+
+	select {
+	case rsp := <-env.reply:
+		return rsp.value, rsp.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Post 在actor中执行任务,与actor的消息串行执行,但不会调用Receive
+func (s *System) Post(key string, task exec.Task) error {
+	return s.deliver(key, &envelope{task: task}, nil)
+}
+
+// Stop 停止actor,mailbox中未处理的消息会被丢弃
+func (s *System) Stop(key string) {
+	s.mux.Lock()
+	p := s.procs[key]
+	s.mux.Unlock()
+	if p != nil {
+		p.stop(false)
+	}
+}
+
+// Shutdown 停止所有actor,并等待Stopped处理完成
+func (s *System) Shutdown() {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.quit)
+	procs := make([]*process, 0, len(s.procs))
+	for _, p := range s.procs {
+		procs = append(procs, p)
+	}
+	s.mux.Unlock()
+
+	for _, p := range procs {
+		p.stop(false)
+	}
+	for _, p := range procs {
+		<-p.done
+	}
+
+	s.wg.Wait()
+	if s.owned {
+		_ = s.opts.Executor.Stop()
+		s.opts.Executor.Wait()
+	}
+}
+
+// deliver 投递到key对应的actor,from为通过Context.Self发送时的actor
+func (s *System) deliver(key string, env *envelope, from *process) error {
+	for {
+		p, err := s.spawn(key)
+		if err != nil {
+			return err
+		}
+
+		err = p.push(env)
+		if err != errStopping {
+			return err
+		}
+
+		// actor在停止过程中给自己发消息,等待会导致死锁
+		if p == from {
+			return ErrStopped
+		}
+
+		// 等待旧的actor处理完Stopped,保证同一个key不会同时存在两个actor
+		<-p.done
+	}
+}
+
+func (s *System) spawn(key string) (*process, error) {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return nil, exec.ErrAlreadyStop
+	}
+
+	p := s.procs[key]
+	if p != nil {
+		s.mux.Unlock()
+		return p, nil
+	}
+
+	p = newProcess(s, key)
+	s.procs[key] = p
+	s.mux.Unlock()
+
+	p.schedule()
+	return p, nil
+}
+
+func (s *System) remove(p *process) {
+	s.mux.Lock()
+	if s.procs[p.key] == p {
+		delete(s.procs, p.key)
+	}
+	s.mux.Unlock()
+}
+
+// passivate 定时停止空闲的actor
+func (s *System) passivate() {
+	defer s.wg.Done()
+
+	interval := s.opts.IdleTimeout / 2
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mux.Lock()
+			procs := make([]*process, 0, len(s.procs))
+			for _, p := range s.procs {
+				procs = append(procs, p)
+			}
+			s.mux.Unlock()
+
+			for _, p := range procs {
+				p.stop(true)
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+const (
+	stateRunning = iota
+	stateStopping
+	stateStopped
+)
+
+var errStopping = fmt.Errorf("actor stopping")
+
+// retryInterval 被Executor拒绝后重新投递的间隔
+const retryInterval = time.Millisecond * 10
+
+// process actor的运行状态,mailbox中有消息时投递到Executor中处理
+type process struct {
+	sys       *System
+	key       string
+	actor     Actor
+	mux       sync.Mutex
+	mailbox   []*envelope
+	scheduled bool // 是否已经投递到Executor中
+	state     int
+	last      time.Time // 最后处理消息的时间
+	restarts  []time.Time
+	done      chan struct{} // 停止后关闭
+	rejects   int64         // 被Executor拒绝或者丢弃的次数
+}
+
+func newProcess(s *System, key string) *process {
+	p := &process{sys: s, key: key, done: make(chan struct{}), last: time.Now()}
+	p.mailbox = append(p.mailbox, &envelope{msg: &Started{}})
+	return p
+}
+
+func (p *process) push(env *envelope) error {
+	p.mux.Lock()
+	if p.state != stateRunning {
+		p.mux.Unlock()
+		return errStopping
+	}
+
+	if size := p.sys.opts.MailboxSize; size > 0 && len(p.mailbox) >= size {
+		p.mux.Unlock()
+		return exec.ErrRejected
+	}
+
+	p.mailbox = append(p.mailbox, env)
+	post := !p.scheduled
+	p.scheduled = true
+	p.mux.Unlock()
+
+	if post {
+		p.post()
+	}
+
+	return nil
+}
+
+// schedule 新建process时mailbox中已经有Started消息
+func (p *process) schedule() {
+	p.mux.Lock()
+	post := !p.scheduled
+	p.scheduled = true
+	p.mux.Unlock()
+
+	if post {
+		p.post()
+	}
+}
+
+// post 投递到Executor,scheduled为true时调用,因此同一时刻只有一个投递
+func (p *process) post() {
+	rejects := atomic.LoadInt64(&p.rejects)
+	err := p.sys.opts.Executor.Post(p)
+	switch {
+	case err == nil:
+	case err == exec.ErrAlreadyStop:
+		// 执行器已经停止,直接在当前协程中停止actor
+		p.mux.Lock()
+		p.state = stateStopping
+		p.mux.Unlock()
+		p.terminate()
+	case atomic.LoadInt64(&p.rejects) == rejects:
+		// 执行器没有调用Options.Reject,同样延迟重新投递
+		p.Rejected()
+	}
+}
+
+// Rejected 队列满时被Executor拒绝或者丢弃(PolicyDropOldest),延迟后重新投递,
+// 期间scheduled保持为true,新的消息不会重复投递,实现exec.Rejectable
+func (p *process) Rejected() {
+	atomic.AddInt64(&p.rejects, 1)
+	time.AfterFunc(retryInterval, p.post)
+}
+
+// stop 停止actor,idle为true时只有空闲超时才停止
+func (p *process) stop(idle bool) {
+	p.mux.Lock()
+	if p.state != stateRunning {
+		p.mux.Unlock()
+		return
+	}
+
+	if idle && (p.scheduled || len(p.mailbox) > 0 || time.Since(p.last) < p.sys.opts.IdleTimeout) {
+		p.mux.Unlock()
+		return
+	}
+
+	p.state = stateStopping
+	post := !p.scheduled
+	p.scheduled = true
+	p.mux.Unlock()
+
+	if post {
+		p.post()
+	}
+}
+
+// Run 处理mailbox中的消息,最多处理Throughput个,之后重新投递,实现exec.Task
+func (p *process) Run() error {
+	for i := 0; ; i++ {
+		p.mux.Lock()
+		if p.state != stateRunning {
+			p.mux.Unlock()
+			p.terminate()
+			return nil
+		}
+
+		if len(p.mailbox) == 0 {
+			p.scheduled = false
+			p.last = time.Now()
+			p.mux.Unlock()
+			return nil
+		}
+
+		if i >= p.sys.opts.Throughput {
+			// 在新的协程中投递,PolicyBlock队列满时不会阻塞当前的执行协程,否则所有协程都可能在这里死锁
+			p.mux.Unlock()
+			go p.post()
+			return nil
+		}
+
+		env := p.mailbox[0]
+		p.mailbox[0] = nil
+		p.mailbox = p.mailbox[1:]
+		p.mux.Unlock()
+
+		p.invoke(env)
+	}
+}
+
+func (p *process) invoke(env *envelope) {
+	if env.task != nil {
+		if err := p.safeRun(env.task); err != nil {
+			p.failure(err)
+		}
+		return
+	}
+
+	if _, ok := env.msg.(*Started); ok && p.actor == nil && p.sys.producer != nil {
+		p.actor = p.sys.producer(p.key)
+	}
+
+	ctx := &Context{sys: p.sys, proc: p, env: env}
+	reason := p.receive(ctx)
+	if !ctx.replied {
+		if reason != nil {
+			env.fail(fmt.Errorf("%w: %v", ErrPanic, reason))
+		} else {
+			env.fail(ErrNoResponse)
+		}
+	}
+
+	if reason != nil {
+		p.failure(reason)
+	}
+}
+
+func (p *process) receive(ctx *Context) (reason interface{}) {
+	if p.actor == nil {
+		return nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			reason = r
+		}
+	}()
+
+	p.actor.Receive(ctx)
+	return nil
+}
+
+// safeRun 执行Post的任务,panic时返回原因
+func (p *process) safeRun(task exec.Task) (reason interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			reason = r
+		}
+	}()
+
+	_ = task.Run()
+	return nil
+}
+
+// failure 处理panic,根据Supervisor的决定恢复,重启或者停止
+func (p *process) failure(reason interface{}) {
+	buf := make([]byte, 4096)
+	buf = buf[:runtime.Stack(buf, false)]
+	log.Printf("[actor] %s panic, %v\n%s", p.key, reason, buf)
+
+	switch p.sys.opts.Supervisor(p.key, reason) {
+	case Resume:
+		return
+	case Restart:
+		now := time.Now()
+		restarts := p.restarts[:0]
+		for _, t := range p.restarts {
+			if now.Sub(t) < p.sys.opts.RestartWindow {
+				restarts = append(restarts, t)
+			}
+		}
+		p.restarts = append(restarts, now)
+		if len(p.restarts) <= p.sys.opts.MaxRestarts {
+			p.actor = nil
+			if p.sys.producer != nil {
+				p.actor = p.sys.producer(p.key)
+			}
+			if r := p.receive(&Context{sys: p.sys, proc: p, env: &envelope{msg: &Started{Restart: true}}}); r == nil {
+				return
+			}
+		}
+	}
+
+	// 停止,由Run调用terminate
+	p.mux.Lock()
+	p.state = stateStopping
+	p.mux.Unlock()
+}
+
+// terminate 丢弃未处理的消息,通知actor停止,并从System中删除
+func (p *process) terminate() {
+	p.mux.Lock()
+	if p.state == stateStopped {
+		p.mux.Unlock()
+		return
+	}
+	p.state = stateStopped
+	pending := p.mailbox
+	p.mailbox = nil
+	p.mux.Unlock()
+
+	for _, env := range pending {
+		env.fail(ErrStopped)
+	}
+
+	p.receive(&Context{sys: p.sys, proc: p, env: &envelope{msg: &Stopped{}}})
+	p.sys.remove(p)
+	close(p.done)
+}
@@ -1,19 +1,23 @@
 package base
 
 import (
+	"time"
+
 	"github.com/jeckbjy/gsk/exec"
 )
 
 type Node struct {
-	prev *Node
-	next *Node
-	task exec.Task
+	prev  *Node
+	next  *Node
+	task  exec.Task
+	stamp time.Time // 入队时间,用于统计延迟
 }
 
 // 双向非循环队列
 type Queue struct {
 	head *Node
 	tail *Node
+	size int
 }
 
 func (q *Queue) Swap(o *Queue) {
@@ -24,21 +28,40 @@ func (q *Queue) Empty() bool {
 	return q.head == nil
 }
 
+func (q *Queue) Len() int {
+	return q.size
+}
+
 func (q *Queue) Push(task exec.Task) {
+	q.PushAt(task, time.Now())
+}
+
+// PushAt 入队并指定入队时间
+func (q *Queue) PushAt(task exec.Task, stamp time.Time) {
 	n := gPool.Obtain()
 	n.task = task
+	n.stamp = stamp
 	q.pushNode(n)
+	q.size++
 }
 
 func (q *Queue) Pop() exec.Task {
+	t, _ := q.PopAt()
+	return t
+}
+
+// PopAt 出队并返回入队时间
+func (q *Queue) PopAt() (exec.Task, time.Time) {
 	n := q.popNode()
 	if n != nil {
-		t := n.task
+		q.size--
+		t, stamp := n.task, n.stamp
+		n.task = nil
 		gPool.Free(n)
-		return t
+		return t, stamp
 	}
 
-	return nil
+	return nil, time.Time{}
 }
 
 func (q *Queue) pushNode(n *Node) {
@@ -0,0 +1,64 @@
+package base
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+)
+
+// Stats 执行器统计,原子操作,可以被多个worker共享
+type Stats struct {
+	queued     int64
+	running    int64
+	completed  int64
+	rejected   int64
+	maxLatency int64
+}
+
+func (s *Stats) Queue() {
+	atomic.AddInt64(&s.queued, 1)
+}
+
+// Dequeue 任务没有执行就出队,比如被丢弃
+func (s *Stats) Dequeue() {
+	atomic.AddInt64(&s.queued, -1)
+}
+
+func (s *Stats) Reject() {
+	atomic.AddInt64(&s.rejected, 1)
+}
+
+// Run 执行已经入队的任务,stamp为入队时间
+func (s *Stats) Run(task exec.Task, stamp time.Time) error {
+	atomic.AddInt64(&s.queued, -1)
+	return s.Exec(task, stamp)
+}
+
+// Exec 执行没有入队的任务,比如runner或者CallerRuns
+func (s *Stats) Exec(task exec.Task, stamp time.Time) error {
+	atomic.AddInt64(&s.running, 1)
+	defer func() {
+		atomic.AddInt64(&s.running, -1)
+		atomic.AddInt64(&s.completed, 1)
+		latency := int64(time.Since(stamp))
+		for {
+			old := atomic.LoadInt64(&s.maxLatency)
+			if latency <= old || atomic.CompareAndSwapInt64(&s.maxLatency, old, latency) {
+				break
+			}
+		}
+	}()
+
+	return task.Run()
+}
+
+func (s *Stats) Snapshot() exec.Stats {
+	return exec.Stats{
+		Queued:     atomic.LoadInt64(&s.queued),
+		Running:    atomic.LoadInt64(&s.running),
+		Completed:  atomic.LoadInt64(&s.completed),
+		Rejected:   atomic.LoadInt64(&s.rejected),
+		MaxLatency: time.Duration(atomic.LoadInt64(&s.maxLatency)),
+	}
+}
@@ -0,0 +1,149 @@
+package base
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+)
+
+// TaskQueue 线程安全的任务队列,支持容量限制和拒绝策略
+type TaskQueue struct {
+	mux      sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	queue    Queue
+	opts     *exec.Options
+	stats    *Stats
+	closed   bool
+}
+
+func NewTaskQueue(opts *exec.Options, stats *Stats) *TaskQueue {
+	q := &TaskQueue{}
+	q.Init(opts, stats)
+	return q
+}
+
+func (q *TaskQueue) Init(opts *exec.Options, stats *Stats) {
+	if opts == nil {
+		opts = exec.NewOptions()
+	}
+	if stats == nil {
+		stats = &Stats{}
+	}
+
+	q.opts = opts
+	q.stats = stats
+	q.notEmpty = sync.NewCond(&q.mux)
+	q.notFull = sync.NewCond(&q.mux)
+}
+
+func (q *TaskQueue) Stats() *Stats {
+	return q.stats
+}
+
+func (q *TaskQueue) Len() int {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	return q.queue.Len()
+}
+
+func (q *TaskQueue) full() bool {
+	return q.opts.QueueSize > 0 && q.queue.Len() >= q.opts.QueueSize
+}
+
+// Push 入队,队列满时根据策略处理,accepted非空时在入队后持有锁的情况下调用
+func (q *TaskQueue) Push(task exec.Task, accepted func()) error {
+	stamp := time.Now()
+	var dropped exec.Task
+
+	q.mux.Lock()
+	if q.closed {
+		q.mux.Unlock()
+		return exec.ErrAlreadyStop
+	}
+
+	if q.full() {
+		switch q.opts.Policy {
+		case exec.PolicyBlock:
+			for q.full() && !q.closed {
+				q.notFull.Wait()
+			}
+			if q.closed {
+				q.mux.Unlock()
+				return exec.ErrAlreadyStop
+			}
+		case exec.PolicyDropOldest:
+			dropped = q.queue.Pop()
+			q.stats.Dequeue()
+			q.stats.Reject()
+		case exec.PolicyCallerRuns:
+			q.mux.Unlock()
+			_ = q.stats.Exec(task, stamp)
+			return nil
+		default:
+			q.mux.Unlock()
+			q.stats.Reject()
+			q.opts.Reject(task)
+			return exec.ErrRejected
+		}
+	}
+
+	q.queue.PushAt(task, stamp)
+	q.stats.Queue()
+	if accepted != nil {
+		accepted()
+	}
+	q.mux.Unlock()
+	q.notEmpty.Signal()
+
+	if dropped != nil {
+		q.opts.Reject(dropped)
+	}
+
+	return nil
+}
+
+// Pop 出队,wait为true时等待直到有任务或者关闭,队列为空时返回nil,
+// empty非空时在队列为空时持有锁的情况下调用
+func (q *TaskQueue) Pop(wait bool, empty func()) (exec.Task, time.Time) {
+	q.mux.Lock()
+	for wait && !q.closed && q.queue.Empty() {
+		q.notEmpty.Wait()
+	}
+
+	task, stamp := q.queue.PopAt()
+	if task == nil && empty != nil {
+		empty()
+	}
+	q.mux.Unlock()
+
+	if task != nil {
+		q.notFull.Signal()
+	}
+
+	return task, stamp
+}
+
+// Discard 清空队列,返回被丢弃的任务
+func (q *TaskQueue) Discard() []exec.Task {
+	var tasks []exec.Task
+	q.mux.Lock()
+	for !q.queue.Empty() {
+		tasks = append(tasks, q.queue.Pop())
+		q.stats.Dequeue()
+	}
+	q.mux.Unlock()
+	q.notFull.Broadcast()
+
+	return tasks
+}
+
+// Close 关闭队列,不再接受新的任务,已经入队的任务依然可以Pop
+func (q *TaskQueue) Close() {
+	q.mux.Lock()
+	q.closed = true
+	q.mux.Unlock()
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
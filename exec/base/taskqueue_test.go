@@ -0,0 +1,111 @@
+package base
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+)
+
+type funcTask func() error
+
+func (f funcTask) Run() error {
+	return f()
+}
+
+func TestPolicy(t *testing.T) {
+	var rejected []exec.Task
+	newQueue := func(policy exec.Policy) *TaskQueue {
+		rejected = nil
+		return NewTaskQueue(exec.NewOptions(
+			exec.QueueSize(2, policy),
+			exec.OnReject(func(task exec.Task) { rejected = append(rejected, task) }),
+		), nil)
+	}
+
+	var order []int
+	task := func(i int) exec.Task {
+		return funcTask(func() error {
+			order = append(order, i)
+			return nil
+		})
+	}
+
+	// 丢弃新任务
+	q := newQueue(exec.PolicyDropNewest)
+	_ = q.Push(task(1), nil)
+	_ = q.Push(task(2), nil)
+	if err := q.Push(task(3), nil); err != exec.ErrRejected || len(rejected) != 1 {
+		t.Fatalf("drop newest fail, %v", err)
+	}
+
+	// 丢弃旧任务
+	q = newQueue(exec.PolicyDropOldest)
+	_ = q.Push(task(1), nil)
+	_ = q.Push(task(2), nil)
+	if err := q.Push(task(3), nil); err != nil || len(rejected) != 1 {
+		t.Fatalf("drop oldest fail, %v", err)
+	}
+	order = nil
+	for task, stamp := q.Pop(false, nil); task != nil; task, stamp = q.Pop(false, nil) {
+		_ = q.Stats().Run(task, stamp)
+	}
+	if len(order) != 2 || order[0] != 2 || order[1] != 3 {
+		t.Fatalf("bad order, %+v", order)
+	}
+
+	// 调用者执行
+	q = newQueue(exec.PolicyCallerRuns)
+	_ = q.Push(task(1), nil)
+	_ = q.Push(task(2), nil)
+	order = nil
+	_ = q.Push(task(3), nil)
+	if len(order) != 1 || order[0] != 3 || q.Len() != 2 {
+		t.Fatalf("caller runs fail, %+v", order)
+	}
+
+	s := q.Stats().Snapshot()
+	if s.Queued != 2 || s.Completed != 1 {
+		t.Fatalf("bad stats, %+v", s)
+	}
+}
+
+func TestBlock(t *testing.T) {
+	var wg sync.WaitGroup
+	w := NewWorker()
+	w.Init(exec.NewOptions(exec.QueueSize(1, exec.PolicyBlock)), nil)
+	w.Start(&wg)
+
+	release := make(chan struct{})
+	_ = w.Post(funcTask(func() error {
+		<-release
+		return nil
+	}))
+	// 等待第一个任务开始执行,然后填满队列
+	for w.Stats().Running == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	_ = w.Post(funcTask(func() error { return nil }))
+
+	posted := make(chan struct{})
+	go func() {
+		_ = w.Post(funcTask(func() error { return nil }))
+		close(posted)
+	}()
+
+	select {
+	case <-posted:
+		t.Fatal("post should block when queue is full")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	close(release)
+	<-posted
+	w.Stop()
+	wg.Wait()
+
+	if s := w.Stats(); s.Completed != 3 || s.Queued != 0 || s.Running != 0 || s.MaxLatency < time.Millisecond*50 {
+		t.Fatalf("bad stats, %+v", s)
+	}
+}
@@ -10,56 +10,54 @@ func NewWorker() *Worker {
 	return &Worker{}
 }
 
+// Worker 单独的协程,按照顺序执行队列中的任务
 type Worker struct {
-	queue Queue
-	mux   *sync.Mutex
-	cond  *sync.Cond
+	queue TaskQueue
 	wg    *sync.WaitGroup
-	quit  bool
+}
+
+// Init 设置队列参数,多个worker可以共享同一个stats,需要在Start之前调用
+func (w *Worker) Init(opts *exec.Options, stats *Stats) {
+	w.queue.Init(opts, stats)
 }
 
 func (w *Worker) Start(wg *sync.WaitGroup) {
-	w.mux = &sync.Mutex{}
-	w.cond = sync.NewCond(w.mux)
-	w.quit = false
+	if w.queue.opts == nil {
+		w.queue.Init(nil, nil)
+	}
+
 	w.wg = wg
+	w.wg.Add(1)
 	go w.run()
 }
 
+// Stop 停止接受新任务,队列中的任务执行完后退出
 func (w *Worker) Stop() {
-	w.mux.Lock()
-	w.quit = true
-	w.mux.Unlock()
-	w.cond.Signal()
+	w.queue.Close()
+}
+
+// Discard 丢弃队列中还未执行的任务
+func (w *Worker) Discard() []exec.Task {
+	return w.queue.Discard()
 }
 
-func (w *Worker) Post(task exec.Task) {
-	w.mux.Lock()
-	w.queue.Push(task)
-	w.mux.Unlock()
-	w.cond.Signal()
+func (w *Worker) Post(task exec.Task) error {
+	return w.queue.Push(task, nil)
+}
+
+func (w *Worker) Stats() exec.Stats {
+	return w.queue.stats.Snapshot()
 }
 
 func (w *Worker) run() {
-	w.wg.Add(1)
 	defer w.wg.Done()
 
-	var queue Queue
-	var quit bool
 	for {
-		quit = false
-		w.mux.Lock()
-		for !w.quit && w.queue.Empty() {
-			w.cond.Wait()
-		}
-		quit = w.quit
-		queue.Swap(&w.queue)
-		w.mux.Unlock()
-
-		queue.Process()
-
-		if quit {
+		task, stamp := w.queue.Pop(true, nil)
+		if task == nil {
 			break
 		}
+
+		_ = w.queue.stats.Run(task, stamp)
 	}
 }
@@ -1,6 +1,7 @@
 package exec
 
 import (
+	"context"
 	"errors"
 	"sync/atomic"
 )
@@ -25,14 +26,21 @@ type Task interface {
 }
 
 // 线程/协程模型
-//	1:单线程
-//	2:根据ID hash到某个线程
-//	3:线程池,不超过最大线程数
-//  4:每个消息起一个go routine?
-// TODO:消息延迟处理或重新投递
-// 比如收到消息后,需要先验证数据是否加载成功
+//
+//		1:单线程
+//		2:根据ID hash到某个线程
+//		3:线程池,不超过最大线程数
+//	 4:每个消息起一个go routine?
+//
+// 消息延迟处理或重新投递见exec/taskq,支持延迟,失败重试,死信以及持久化
+// 可以通过QueueSize限制队列长度,并指定队列满时的拒绝策略,Stats返回统计信息
+// PostContext返回Future用于等待执行结果,执行前ctx已经结束的任务会被跳过
+// Shutdown停止并等待队列中的任务执行完成,直到ctx结束,超时后丢弃队列中剩余的任务
 type Executor interface {
 	Post(task Task) error
+	PostContext(ctx context.Context, task Task) (*Future, error)
 	Stop() error
+	Shutdown(ctx context.Context) error
 	Wait()
+	Stats() Stats
 }
@@ -0,0 +1,140 @@
+package exec
+
+import (
+	"context"
+	"sync"
+)
+
+// Future 异步任务的执行结果
+type Future struct {
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func NewFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+// Done 任务执行完成,被跳过或者被丢弃时关闭
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err 返回任务的执行结果,未完成时返回nil
+func (f *Future) Err() error {
+	select {
+	case <-f.done:
+		return f.err
+	default:
+		return nil
+	}
+}
+
+// Wait 等待任务完成并返回执行结果,ctx结束时返回ctx.Err(),ctx可以为nil
+func (f *Future) Wait(ctx context.Context) error {
+	if ctx == nil {
+		<-f.done
+		return f.err
+	}
+
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Complete 设置执行结果,只有第一次调用有效
+func (f *Future) Complete(err error) {
+	f.once.Do(func() {
+		f.err = err
+		close(f.done)
+	})
+}
+
+// contextTask 执行前检查ctx,已经结束的任务直接跳过
+type contextTask struct {
+	ctx    context.Context
+	task   Task
+	future *Future
+}
+
+func (t *contextTask) Run() error {
+	if err := t.ctx.Err(); err != nil {
+		t.future.Complete(err)
+		return err
+	}
+
+	err := t.task.Run()
+	t.future.Complete(err)
+	return err
+}
+
+// WithContext 包装task,执行时如果ctx已经结束则跳过,执行结果通过Future返回
+func WithContext(ctx context.Context, task Task) (Task, *Future) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	t := &contextTask{ctx: ctx, task: task, future: NewFuture()}
+	return t, t.future
+}
+
+// Unwrap 返回WithContext包装前的原始task
+func Unwrap(task Task) Task {
+	if t, ok := task.(*contextTask); ok {
+		return t.task
+	}
+
+	return task
+}
+
+// PostContext Executor实现PostContext的通用方法,ctx已经结束时不再投递
+func PostContext(e Executor, ctx context.Context, task Task) (*Future, error) {
+	if ctx != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	t, f := WithContext(ctx, task)
+	if err := e.Post(t); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Discarder 可以丢弃队列中还未执行的任务,Shutdown超时后调用
+type Discarder interface {
+	Discard() []Task
+}
+
+// Shutdown Executor实现Shutdown的通用方法,停止后等待队列中的任务执行完成,
+// ctx结束时返回ctx.Err(),如果Executor实现了Discarder,队列中剩余的任务会被丢弃,
+// PostContext返回的Future以ctx.Err()完成,正在执行的任务无法中断
+func Shutdown(e Executor, ctx context.Context) error {
+	if err := e.Stop(); err != nil && err != ErrAlreadyStop {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		e.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		if d, ok := e.(Discarder); ok {
+			for _, task := range d.Discard() {
+				if t, ok := task.(*contextTask); ok {
+					t.future.Complete(ctx.Err())
+				}
+			}
+		}
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,126 @@
+package exec_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+	"github.com/jeckbjy/gsk/exec/hashing"
+	"github.com/jeckbjy/gsk/exec/pooled"
+	"github.com/jeckbjy/gsk/exec/simple"
+	"github.com/jeckbjy/gsk/exec/stealing"
+)
+
+type funcTask func() error
+
+func (f funcTask) Run() error {
+	return f()
+}
+
+// 阻塞执行器,直到release关闭
+func block(t *testing.T, e exec.Executor) chan struct{} {
+	release := make(chan struct{})
+	_ = e.Post(funcTask(func() error {
+		<-release
+		return nil
+	}))
+	for e.Stats().Running == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	return release
+}
+
+func TestPostContext(t *testing.T) {
+	e := simple.New()
+	errTask := errors.New("task error")
+	f, err := e.PostContext(context.Background(), funcTask(func() error { return errTask }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Wait(nil); err != errTask {
+		t.Fatalf("bad result, %v", err)
+	}
+
+	// 排队期间ctx被取消,任务会被跳过
+	release := block(t, e)
+	ctx, cancel := context.WithCancel(context.Background())
+	executed := false
+	f, _ = e.PostContext(ctx, funcTask(func() error {
+		executed = true
+		return nil
+	}))
+	cancel()
+	close(release)
+	if err := f.Wait(nil); err != context.Canceled || executed {
+		t.Fatalf("should skip, %v", err)
+	}
+
+	if _, err := e.PostContext(ctx, funcTask(func() error { return nil })); err != context.Canceled {
+		t.Fatalf("should not post, %v", err)
+	}
+
+	_ = e.Stop()
+	e.Wait()
+}
+
+func TestFutureReject(t *testing.T) {
+	var rejected exec.Task
+	noop := funcTask(func() error { return nil })
+	e := simple.New(exec.QueueSize(1, exec.PolicyDropOldest), exec.OnReject(func(task exec.Task) {
+		rejected = task
+	}))
+
+	release := block(t, e)
+	f, _ := e.PostContext(context.Background(), noop)
+	_ = e.Post(noop)
+	if err := f.Wait(nil); err != exec.ErrRejected || rejected == nil {
+		t.Fatalf("should reject, %v", err)
+	}
+
+	close(release)
+	_ = e.Stop()
+	e.Wait()
+}
+
+func TestShutdown(t *testing.T) {
+	executors := map[string]exec.Executor{
+		"simple":   simple.New(),
+		"pooled":   pooled.New(1),
+		"hashing":  hashing.New(func(task exec.Task) int { return 0 }, 1),
+		"stealing": stealing.New(1),
+	}
+
+	for name, e := range executors {
+		release := block(t, e)
+		var executed int32
+		f, _ := e.PostContext(context.Background(), funcTask(func() error {
+			atomic.StoreInt32(&executed, 1)
+			return nil
+		}))
+
+		// 超时后丢弃队列中的任务,Future以ctx.Err()完成
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		if err := e.Shutdown(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("%s should timeout, %v", name, err)
+		}
+		cancel()
+		if err := f.Err(); err != context.DeadlineExceeded {
+			t.Fatalf("%s should discard, %v", name, err)
+		}
+
+		close(release)
+		if err := e.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if atomic.LoadInt32(&executed) != 0 {
+			t.Fatalf("%s run discarded task", name)
+		}
+		if s := e.Stats(); s.Queued != 0 {
+			t.Fatalf("%s bad stats, %+v", name, s)
+		}
+	}
+}
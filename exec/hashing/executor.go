@@ -1,14 +1,20 @@
 package hashing
 
 import (
+	"context"
 	"sync"
 
 	"github.com/jeckbjy/gsk/exec"
 	"github.com/jeckbjy/gsk/exec/base"
 )
 
-func New(strategy Strategy, maxWorker int) exec.Executor {
-	e := &executor{strategy: strategy}
+// New 创建hash执行器,同一个index的任务按照投递顺序执行
+// PolicyCallerRuns会在调用者协程中提前执行任务,破坏同一个index的执行顺序,因此按照PolicyBlock处理
+func New(strategy Strategy, maxWorker int, opts ...exec.Option) exec.Executor {
+	e := &executor{strategy: strategy, opts: exec.NewOptions(opts...)}
+	if e.opts.Policy == exec.PolicyCallerRuns {
+		e.opts.Policy = exec.PolicyBlock
+	}
 	if maxWorker > 0 {
 		e.workers = make([]*base.Worker, maxWorker)
 	}
@@ -17,10 +23,12 @@ func New(strategy Strategy, maxWorker int) exec.Executor {
 
 type Strategy func(task exec.Task) int
 
-// 根据hash的方式投递到某个线程中执行
+// 根据hash的方式投递到某个线程中执行,QueueSize限制的是每个线程的队列长度
 type executor struct {
 	workers  []*base.Worker
 	strategy Strategy
+	opts     *exec.Options
+	stats    base.Stats // 所有worker共享
 	mux      sync.Mutex
 	quit     bool
 	wg       sync.WaitGroup
@@ -30,40 +38,72 @@ func (e *executor) Stop() error {
 	e.mux.Lock()
 	e.quit = true
 	for _, w := range e.workers {
-		w.Stop()
+		if w != nil {
+			w.Stop()
+		}
 	}
 	e.mux.Unlock()
 
 	return nil
 }
 
+func (e *executor) PostContext(ctx context.Context, task exec.Task) (*exec.Future, error) {
+	return exec.PostContext(e, ctx, task)
+}
+
+func (e *executor) Shutdown(ctx context.Context) error {
+	return exec.Shutdown(e, ctx)
+}
+
+func (e *executor) Discard() []exec.Task {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	var tasks []exec.Task
+	for _, w := range e.workers {
+		if w != nil {
+			tasks = append(tasks, w.Discard()...)
+		}
+	}
+
+	return tasks
+}
+
 func (e *executor) Wait() {
 	e.wg.Wait()
 }
 
+func (e *executor) Stats() exec.Stats {
+	return e.stats.Snapshot()
+}
+
 func (e *executor) Post(task exec.Task) error {
-	if e.quit {
+	index := e.strategy(exec.Unwrap(task))
+	worker := e.obtain(index)
+	if worker == nil {
 		return exec.ErrAlreadyStop
 	}
 
-	index := e.strategy(task)
-	worker := e.obtain(index)
-	worker.Post(task)
-
-	return nil
+	return worker.Post(task)
 }
 
 func (e *executor) obtain(index int) *base.Worker {
 	var worker *base.Worker
 
 	e.mux.Lock()
+	if e.quit {
+		e.mux.Unlock()
+		return nil
+	}
+
 	if index >= len(e.workers) {
 		n := make([]*base.Worker, index+1)
 		copy(n, e.workers)
+		e.workers = n
 	}
 
 	if e.workers[index] == nil {
 		worker := base.NewWorker()
+		worker.Init(e.opts, &e.stats)
 		worker.Start(&e.wg)
 		e.workers[index] = worker
 	}
@@ -0,0 +1,93 @@
+package hashing
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+)
+
+type taskFunc func() error
+
+func (f taskFunc) Run() error {
+	return f()
+}
+
+func TestCallerRunsOrder(t *testing.T) {
+	e := New(func(task exec.Task) int { return 0 }, 1, exec.QueueSize(1, exec.PolicyCallerRuns))
+
+	var mux sync.Mutex
+	var order []int
+	block := make(chan struct{})
+	started := make(chan struct{})
+	push := func(i int) exec.Task {
+		return taskFunc(func() error {
+			if i == 0 {
+				close(started)
+				<-block
+			}
+			mux.Lock()
+			order = append(order, i)
+			mux.Unlock()
+			return nil
+		})
+	}
+
+	_ = e.Post(push(0))
+	<-started
+	_ = e.Post(push(1))
+
+	// 队列已满,第三个任务不能在调用者协程中提前执行
+	posted := make(chan struct{})
+	go func() {
+		_ = e.Post(push(2))
+		close(posted)
+	}()
+
+	time.Sleep(time.Millisecond * 50)
+	mux.Lock()
+	n := len(order)
+	mux.Unlock()
+	if n != 0 {
+		t.Fatalf("task run before queued ones, %+v", order)
+	}
+
+	close(block)
+	<-posted
+	_ = e.Stop()
+	e.Wait()
+
+	if len(order) != 3 || order[0] != 0 || order[1] != 1 || order[2] != 2 {
+		t.Fatalf("bad order, %+v", order)
+	}
+}
+
+type keyTask struct {
+	key  int
+	done chan int
+}
+
+func (t *keyTask) Run() error {
+	t.done <- t.key
+	return nil
+}
+
+func TestPostContext(t *testing.T) {
+	// Strategy收到的是原始task,而不是PostContext的包装
+	e := New(func(task exec.Task) int { return task.(*keyTask).key }, 0)
+	defer e.Stop()
+
+	done := make(chan int, 1)
+	f, err := e.PostContext(context.Background(), &keyTask{key: 3, done: done})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if key := <-done; key != 3 {
+		t.Fatalf("bad key, %d", key)
+	}
+}
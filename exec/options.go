@@ -0,0 +1,69 @@
+package exec
+
+import "errors"
+
+// ErrRejected 队列已满,任务被拒绝
+var ErrRejected = errors.New("task rejected")
+
+// Policy 队列满时的拒绝策略
+type Policy int
+
+const (
+	PolicyBlock      Policy = iota // 阻塞Post直到队列有空间
+	PolicyDropNewest               // 丢弃新任务,Post返回ErrRejected
+	PolicyDropOldest               // 丢弃队列中最旧的任务,新任务入队
+	PolicyCallerRuns               // 在调用Post的协程中直接执行,hashing中按照PolicyBlock处理以保证顺序
+)
+
+type Option func(o *Options)
+
+// Options Executor的通用参数
+type Options struct {
+	QueueSize int        // 队列容量,<=0表示不限制
+	Policy    Policy     // 队列满时的拒绝策略
+	OnReject  func(Task) // 任务被丢弃时回调
+}
+
+func NewOptions(opts ...Option) *Options {
+	o := &Options{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return o
+}
+
+// QueueSize 设置队列容量以及队列满时的拒绝策略
+func QueueSize(size int, policy Policy) Option {
+	return func(o *Options) {
+		o.QueueSize = size
+		o.Policy = policy
+	}
+}
+
+// OnReject 任务被丢弃时回调,用于记录日志或者通知调用者
+func OnReject(fn func(Task)) Option {
+	return func(o *Options) {
+		o.OnReject = fn
+	}
+}
+
+// Rejectable 任务被拒绝或者丢弃时收到通知,比如需要重新投递的任务
+type Rejectable interface {
+	Rejected()
+}
+
+// Reject 任务被丢弃时由Executor调用,通知PostContext返回的Future以及Rejectable,并回调OnReject
+func (o *Options) Reject(task Task) {
+	if t, ok := task.(*contextTask); ok {
+		t.future.Complete(ErrRejected)
+	}
+
+	if r, ok := task.(Rejectable); ok {
+		r.Rejected()
+	}
+
+	if o.OnReject != nil {
+		o.OnReject(Unwrap(task))
+	}
+}
@@ -1,6 +1,7 @@
 package pooled
 
 import (
+	"context"
 	"math"
 	"sync"
 
@@ -8,69 +9,78 @@ import (
 	"github.com/jeckbjy/gsk/exec/base"
 )
 
-func New(max int) exec.Executor {
+func New(max int, opts ...exec.Option) exec.Executor {
 	if max == 0 {
 		max = math.MaxInt32
 	}
 
-	return &executor{max: int32(max), quit: false}
+	e := &executor{max: int32(max)}
+	e.tasks.Init(exec.NewOptions(opts...), nil)
+	return e
 }
 
 // 将task投递到多个线程中执行,执行顺序不确定
 type executor struct {
-	tasks base.Queue
-	mux   sync.Mutex
+	tasks base.TaskQueue
 	wg    sync.WaitGroup
 	max   int32 // 协程数不超过max
-	num   int32 // 当前线程数
-	quit  bool
+	num   int32 // 当前线程数,由tasks的锁保护
 }
 
 func (e *executor) Stop() error {
-	e.quit = true
+	e.tasks.Close()
 	return nil
 }
 
+func (e *executor) PostContext(ctx context.Context, task exec.Task) (*exec.Future, error) {
+	return exec.PostContext(e, ctx, task)
+}
+
+func (e *executor) Shutdown(ctx context.Context) error {
+	return exec.Shutdown(e, ctx)
+}
+
+func (e *executor) Discard() []exec.Task {
+	return e.tasks.Discard()
+}
+
 func (e *executor) Wait() {
 	e.wg.Wait()
 }
 
-func (e *executor) Post(task exec.Task) error {
-	if e.quit {
-		return exec.ErrAlreadyStop
-	}
+func (e *executor) Stats() exec.Stats {
+	return e.tasks.Stats().Snapshot()
+}
 
+func (e *executor) Post(task exec.Task) error {
 	create := false
-	e.mux.Lock()
-	e.tasks.Push(task)
-	if e.num < e.max {
-		e.num++
-		create = true
-	}
-	e.mux.Unlock()
+	err := e.tasks.Push(task, func() {
+		if e.num < e.max {
+			e.num++
+			create = true
+		}
+	})
 
 	if create {
+		e.wg.Add(1)
 		go e.run()
 	}
-	return nil
+
+	return err
 }
 
 func (e *executor) run() {
-	e.wg.Add(1)
 	defer e.wg.Done()
 
-	var task exec.Task
+	stats := e.tasks.Stats()
 	for {
-		e.mux.Lock()
-		task = e.tasks.Pop()
-		if task == nil {
+		task, stamp := e.tasks.Pop(false, func() {
 			e.num--
-		}
-		e.mux.Unlock()
+		})
 		if task == nil {
 			break
 		}
 
-		_ = task.Run()
+		_ = stats.Run(task, stamp)
 	}
 }
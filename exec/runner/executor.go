@@ -1,6 +1,12 @@
 package runner
 
-import "github.com/jeckbjy/gsk/exec"
+import (
+	"context"
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+	"github.com/jeckbjy/gsk/exec/base"
+)
 
 func New() exec.Executor {
 	e := &executor{}
@@ -9,10 +15,26 @@ func New() exec.Executor {
 
 // 直接运行task
 type executor struct {
+	stats base.Stats
 }
 
 func (e *executor) Post(task exec.Task) error {
-	return task.Run()
+	return e.stats.Exec(task, time.Now())
+}
+
+// PostContext 直接执行,返回的Future已经完成,错误通过Future返回
+func (e *executor) PostContext(ctx context.Context, task exec.Task) (*exec.Future, error) {
+	if ctx != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	t, f := exec.WithContext(ctx, task)
+	_ = e.Post(t)
+	return f, nil
+}
+
+func (e *executor) Shutdown(ctx context.Context) error {
+	return nil
 }
 
 func (e *executor) Stop() error {
@@ -21,3 +43,7 @@ func (e *executor) Stop() error {
 
 func (e *executor) Wait() {
 }
+
+func (e *executor) Stats() exec.Stats {
+	return e.stats.Snapshot()
+}
@@ -1,15 +1,16 @@
 package simple
 
 import (
+	"context"
 	"sync"
 
 	"github.com/jeckbjy/gsk/exec"
 	"github.com/jeckbjy/gsk/exec/base"
 )
 
-func New() exec.Executor {
+func New(opts ...exec.Option) exec.Executor {
 	e := &executor{}
-	e.Start()
+	e.Start(exec.NewOptions(opts...))
 	return e
 }
 
@@ -19,8 +20,9 @@ type executor struct {
 	wg     sync.WaitGroup
 }
 
-func (e *executor) Start() {
+func (e *executor) Start(opts *exec.Options) {
 	e.worker = base.NewWorker()
+	e.worker.Init(opts, nil)
 	e.worker.Start(&e.wg)
 }
 
@@ -29,11 +31,26 @@ func (e *executor) Stop() error {
 	return nil
 }
 
+func (e *executor) PostContext(ctx context.Context, task exec.Task) (*exec.Future, error) {
+	return exec.PostContext(e, ctx, task)
+}
+
+func (e *executor) Shutdown(ctx context.Context) error {
+	return exec.Shutdown(e, ctx)
+}
+
+func (e *executor) Discard() []exec.Task {
+	return e.worker.Discard()
+}
+
 func (e *executor) Wait() {
 	e.wg.Wait()
 }
 
 func (e *executor) Post(task exec.Task) error {
-	e.worker.Post(task)
-	return nil
+	return e.worker.Post(task)
+}
+
+func (e *executor) Stats() exec.Stats {
+	return e.worker.Stats()
 }
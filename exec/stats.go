@@ -0,0 +1,12 @@
+package exec
+
+import "time"
+
+// Stats 执行器统计信息
+type Stats struct {
+	Queued     int64         // 等待执行的任务数
+	Running    int64         // 正在执行的任务数
+	Completed  int64         // 已经执行完成的任务数
+	Rejected   int64         // 被拒绝或者丢弃的任务数
+	MaxLatency time.Duration // 从Post到执行完成的最大耗时
+}
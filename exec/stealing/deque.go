@@ -0,0 +1,72 @@
+package stealing
+
+import (
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+)
+
+type item struct {
+	task  exec.Task
+	stamp time.Time // 入队时间,用于统计延迟
+}
+
+// deque 基于环形数组的双端队列,不是线程安全的,由worker的锁保护
+type deque struct {
+	items []item
+	head  int
+	size  int
+}
+
+func (d *deque) Len() int {
+	return d.size
+}
+
+func (d *deque) grow() {
+	n := len(d.items) * 2
+	if n == 0 {
+		n = 16
+	}
+
+	items := make([]item, n)
+	for i := 0; i < d.size; i++ {
+		items[i] = d.items[(d.head+i)%len(d.items)]
+	}
+	d.items = items
+	d.head = 0
+}
+
+func (d *deque) PushBack(it item) {
+	if d.size == len(d.items) {
+		d.grow()
+	}
+
+	d.items[(d.head+d.size)%len(d.items)] = it
+	d.size++
+}
+
+// PopFront 从头部取出,owner使用,保证同一个worker内先进先出
+func (d *deque) PopFront() (item, bool) {
+	if d.size == 0 {
+		return item{}, false
+	}
+
+	it := d.items[d.head]
+	d.items[d.head] = item{}
+	d.head = (d.head + 1) % len(d.items)
+	d.size--
+	return it, true
+}
+
+// PopBack 从尾部取出,窃取时使用,尽量不与owner争抢头部的任务
+func (d *deque) PopBack() (item, bool) {
+	if d.size == 0 {
+		return item{}, false
+	}
+
+	index := (d.head + d.size - 1) % len(d.items)
+	it := d.items[index]
+	d.items[index] = item{}
+	d.size--
+	return it, true
+}
@@ -0,0 +1,378 @@
+package stealing
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+	"github.com/jeckbjy/gsk/exec/base"
+)
+
+// New 创建固定数量worker的执行器,workers<=0时使用CPU核数
+// QueueSize限制的是所有worker队列的总长度
+func New(workers int, opts ...exec.Option) exec.Executor {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	e := &executor{opts: exec.NewOptions(opts...), quit: make(chan struct{})}
+	if e.opts.QueueSize > 0 {
+		e.slots = make(chan struct{}, e.opts.QueueSize)
+	}
+
+	e.workers = make([]*worker, workers)
+	for i := range e.workers {
+		e.workers[i] = &worker{owner: e, wake: make(chan struct{}, 1), seed: uint32(i)*2654435761 + 1}
+	}
+
+	e.wg.Add(workers)
+	for _, w := range e.workers {
+		go w.run()
+	}
+
+	return e
+}
+
+// 适用于大量CPU密集型的小任务,执行顺序不确定
+// 每个worker有独立的队列,Post时轮询投递到某个worker,避免所有协程争抢同一把锁,
+// worker自己的队列为空时,从其他worker的队列尾部窃取一半的任务,
+// 每个worker按照优先级分成多个队列,总是先执行高优先级的任务(包括窃取)
+type executor struct {
+	workers []*worker
+	opts    *exec.Options
+	stats   base.Stats
+	slots   chan struct{} // 限制队列总长度,nil表示不限制
+	next    uint32        // 轮询投递
+	pending int64         // 已经投递但还没有被取出的任务数
+	closed  int32
+	quit    chan struct{}
+	idleMux sync.Mutex
+	idle    []*worker // 等待中的worker
+	nidle   int32     // len(idle),用于无锁判断是否需要唤醒
+	wg      sync.WaitGroup
+}
+
+func (e *executor) Stop() error {
+	if atomic.CompareAndSwapInt32(&e.closed, 0, 1) {
+		close(e.quit)
+	}
+
+	return nil
+}
+
+func (e *executor) PostContext(ctx context.Context, task exec.Task) (*exec.Future, error) {
+	return exec.PostContext(e, ctx, task)
+}
+
+func (e *executor) Shutdown(ctx context.Context) error {
+	return exec.Shutdown(e, ctx)
+}
+
+func (e *executor) Wait() {
+	e.wg.Wait()
+}
+
+func (e *executor) Stats() exec.Stats {
+	return e.stats.Snapshot()
+}
+
+func (e *executor) Post(task exec.Task) error {
+	if atomic.LoadInt32(&e.closed) != 0 {
+		return exec.ErrAlreadyStop
+	}
+
+	stamp := time.Now()
+	if e.slots != nil {
+		if handled, err := e.acquire(task, stamp); handled {
+			return err
+		}
+	}
+
+	// 先增加pending再判断是否关闭,保证Stop之后worker不会在任务入队前退出
+	atomic.AddInt64(&e.pending, 1)
+	if atomic.LoadInt32(&e.closed) != 0 {
+		atomic.AddInt64(&e.pending, -1)
+		e.release()
+		return exec.ErrAlreadyStop
+	}
+
+	w := e.workers[atomic.AddUint32(&e.next, 1)%uint32(len(e.workers))]
+	e.stats.Queue()
+	w.push(priorityOf(task), item{task: task, stamp: stamp})
+	e.wake(w)
+	return nil
+}
+
+// acquire 获取队列名额,队列满时根据策略处理,返回true表示已经处理完,不需要入队
+func (e *executor) acquire(task exec.Task, stamp time.Time) (bool, error) {
+	select {
+	case e.slots <- struct{}{}:
+		return false, nil
+	default:
+	}
+
+	switch e.opts.Policy {
+	case exec.PolicyBlock:
+	case exec.PolicyDropOldest:
+		// 名额直接转给新任务
+		if e.dropOldest() {
+			return false, nil
+		}
+	case exec.PolicyCallerRuns:
+		_ = e.stats.Exec(task, stamp)
+		return true, nil
+	default:
+		e.stats.Reject()
+		e.opts.Reject(task)
+		return true, exec.ErrRejected
+	}
+
+	select {
+	case e.slots <- struct{}{}:
+		return false, nil
+	case <-e.quit:
+		return true, exec.ErrAlreadyStop
+	}
+}
+
+func (e *executor) release() {
+	if e.slots != nil {
+		<-e.slots
+	}
+}
+
+// dropOldest 从最低优先级开始丢弃队列头部的任务
+func (e *executor) dropOldest() bool {
+	for p := numPriority - 1; p >= PriorityHigh; p-- {
+		for _, w := range e.workers {
+			if it, ok := w.pop(p); ok {
+				atomic.AddInt64(&e.pending, -1)
+				e.stats.Dequeue()
+				e.stats.Reject()
+				e.opts.Reject(it.task)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Discard 丢弃所有worker队列中还未执行的任务,
+// 窃取中的任务暂时不在任何队列中,因此需要循环直到pending为0
+func (e *executor) Discard() []exec.Task {
+	var tasks []exec.Task
+	for atomic.LoadInt64(&e.pending) > 0 {
+		n := len(tasks)
+		for p := PriorityHigh; p < numPriority; p++ {
+			for _, w := range e.workers {
+				for {
+					it, ok := w.pop(p)
+					if !ok {
+						break
+					}
+					e.taken()
+					e.stats.Dequeue()
+					tasks = append(tasks, it.task)
+				}
+			}
+		}
+
+		if len(tasks) == n {
+			runtime.Gosched()
+		}
+	}
+
+	return tasks
+}
+
+// taken 任务被worker取出
+func (e *executor) taken() {
+	atomic.AddInt64(&e.pending, -1)
+	e.release()
+}
+
+// steal 从其他worker的队列尾部窃取一半的任务,返回最早入队的一个,其余的放入自己的队列
+func (e *executor) steal(w *worker, p Priority) (item, bool) {
+	n := len(e.workers)
+	start := int(w.rand() % uint32(n))
+	for i := 0; i < n; i++ {
+		victim := e.workers[(start+i)%n]
+		if victim == w || atomic.LoadInt32(&victim.size[p]) == 0 {
+			continue
+		}
+
+		batch := victim.stealHalf(p)
+		if len(batch) == 0 {
+			continue
+		}
+
+		// batch从新到旧排列
+		if len(batch) > 1 {
+			w.mux.Lock()
+			for j := len(batch) - 2; j >= 0; j-- {
+				w.queues[p].PushBack(batch[j])
+			}
+			atomic.StoreInt32(&w.size[p], int32(w.queues[p].Len()))
+			w.mux.Unlock()
+		}
+
+		return batch[len(batch)-1], true
+	}
+
+	return item{}, false
+}
+
+// park 没有任务时等待,返回false表示已经关闭并且所有任务都已经取出,worker可以退出
+func (e *executor) park(w *worker) bool {
+	e.idleMux.Lock()
+	e.idle = append(e.idle, w)
+	atomic.AddInt32(&e.nidle, 1)
+	e.idleMux.Unlock()
+
+	// 加入idle之后再次检查,避免与Post之间丢失唤醒
+	if atomic.LoadInt64(&e.pending) == 0 && atomic.LoadInt32(&e.closed) == 0 {
+		select {
+		case <-w.wake:
+		case <-e.quit:
+		}
+	}
+
+	e.unpark(w)
+	return atomic.LoadInt32(&e.closed) == 0 || atomic.LoadInt64(&e.pending) > 0
+}
+
+func (e *executor) unpark(w *worker) {
+	e.idleMux.Lock()
+	e.removeIdle(w)
+	e.idleMux.Unlock()
+}
+
+func (e *executor) removeIdle(w *worker) bool {
+	for i, x := range e.idle {
+		if x == w {
+			last := len(e.idle) - 1
+			e.idle[i] = e.idle[last]
+			e.idle[last] = nil
+			e.idle = e.idle[:last]
+			atomic.AddInt32(&e.nidle, -1)
+			return true
+		}
+	}
+
+	return false
+}
+
+// wake 优先唤醒目标worker,否则唤醒任意一个空闲的worker去窃取
+func (e *executor) wake(target *worker) {
+	if atomic.LoadInt32(&e.nidle) == 0 {
+		return
+	}
+
+	var w *worker
+	e.idleMux.Lock()
+	if e.removeIdle(target) {
+		w = target
+	} else if n := len(e.idle); n > 0 {
+		w = e.idle[n-1]
+		e.removeIdle(w)
+	}
+	e.idleMux.Unlock()
+
+	if w != nil {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+type worker struct {
+	owner  *executor
+	mux    sync.Mutex
+	queues [numPriority]deque
+	size   [numPriority]int32 // 队列长度,用于无锁判断是否为空
+	wake   chan struct{}
+	seed   uint32 // 随机选择窃取对象,只在worker协程中使用
+}
+
+func (w *worker) rand() uint32 {
+	w.seed ^= w.seed << 13
+	w.seed ^= w.seed >> 17
+	w.seed ^= w.seed << 5
+	return w.seed
+}
+
+func (w *worker) push(p Priority, it item) {
+	w.mux.Lock()
+	w.queues[p].PushBack(it)
+	atomic.StoreInt32(&w.size[p], int32(w.queues[p].Len()))
+	w.mux.Unlock()
+}
+
+func (w *worker) pop(p Priority) (item, bool) {
+	if atomic.LoadInt32(&w.size[p]) == 0 {
+		return item{}, false
+	}
+
+	w.mux.Lock()
+	it, ok := w.queues[p].PopFront()
+	atomic.StoreInt32(&w.size[p], int32(w.queues[p].Len()))
+	w.mux.Unlock()
+	return it, ok
+}
+
+func (w *worker) stealHalf(p Priority) []item {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	q := &w.queues[p]
+	n := (q.Len() + 1) / 2
+	if n == 0 {
+		return nil
+	}
+
+	batch := make([]item, 0, n)
+	for i := 0; i < n; i++ {
+		it, _ := q.PopBack()
+		batch = append(batch, it)
+	}
+	atomic.StoreInt32(&w.size[p], int32(q.Len()))
+	return batch
+}
+
+// next 按照优先级获取任务,同一优先级先取自己的,再去窃取
+func (w *worker) next() (item, bool) {
+	e := w.owner
+	for p := PriorityHigh; p < numPriority; p++ {
+		if it, ok := w.pop(p); ok {
+			return it, true
+		}
+
+		if it, ok := e.steal(w, p); ok {
+			return it, true
+		}
+	}
+
+	return item{}, false
+}
+
+func (w *worker) run() {
+	e := w.owner
+	defer e.wg.Done()
+
+	for {
+		if it, ok := w.next(); ok {
+			e.taken()
+			_ = e.stats.Run(it.task, it.stamp)
+			continue
+		}
+
+		if !e.park(w) {
+			return
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package stealing
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+	"github.com/jeckbjy/gsk/exec/hashing"
+	"github.com/jeckbjy/gsk/exec/pooled"
+)
+
+type funcTask func() error
+
+func (f funcTask) Run() error {
+	return f()
+}
+
+func TestExecutor(t *testing.T) {
+	e := New(4)
+	var count int64
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = e.Post(funcTask(func() error {
+					atomic.AddInt64(&count, 1)
+					return nil
+				}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	_ = e.Stop()
+	e.Wait()
+	if count != 10000 {
+		t.Fatalf("bad count, %v", count)
+	}
+
+	if err := e.Post(funcTask(func() error { return nil })); err != exec.ErrAlreadyStop {
+		t.Fatalf("post after stop, %v", err)
+	}
+
+	if s := e.Stats(); s.Completed != 10000 || s.Queued != 0 || s.Running != 0 {
+		t.Fatalf("bad stats, %+v", s)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	e := New(1)
+	release := make(chan struct{})
+	_ = e.Post(funcTask(func() error {
+		<-release
+		return nil
+	}))
+	for e.Stats().Running == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	var order []Priority
+	for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh, PriorityLow, PriorityHigh} {
+		p := p
+		task := WithPriority(funcTask(func() error {
+			order = append(order, p)
+			return nil
+		}), p)
+		if p == PriorityHigh {
+			// PostContext包装后依然保留优先级
+			_, _ = e.PostContext(context.Background(), task)
+		} else {
+			_ = e.Post(task)
+		}
+	}
+
+	close(release)
+	_ = e.Stop()
+	e.Wait()
+
+	expect := []Priority{PriorityHigh, PriorityHigh, PriorityNormal, PriorityLow, PriorityLow}
+	for i, p := range expect {
+		if i >= len(order) || order[i] != p {
+			t.Fatalf("bad order, %+v", order)
+		}
+	}
+}
+
+func TestQueueSize(t *testing.T) {
+	var rejected int64
+	e := New(1, exec.QueueSize(2, exec.PolicyDropNewest), exec.OnReject(func(exec.Task) {
+		atomic.AddInt64(&rejected, 1)
+	}))
+
+	release := make(chan struct{})
+	_ = e.Post(funcTask(func() error {
+		<-release
+		return nil
+	}))
+	for e.Stats().Running == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	noop := funcTask(func() error { return nil })
+	_ = e.Post(noop)
+	_ = e.Post(noop)
+	if err := e.Post(noop); err != exec.ErrRejected || rejected != 1 {
+		t.Fatalf("should reject, %v", err)
+	}
+
+	close(release)
+	_ = e.Stop()
+	e.Wait()
+	if s := e.Stats(); s.Completed != 3 || s.Rejected != 1 {
+		t.Fatalf("bad stats, %+v", s)
+	}
+}
+
+// 模拟CPU密集型的小任务
+func work() {
+	x := uint32(1)
+	for i := 0; i < 200; i++ {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+	}
+	if x == 0 {
+		panic("unreachable")
+	}
+}
+
+// 多个协程同时投递,等待所有任务执行完成
+func benchmark(b *testing.B, e exec.Executor) {
+	var wg sync.WaitGroup
+	wg.Add(b.N)
+	task := funcTask(func() error {
+		work()
+		wg.Done()
+		return nil
+	})
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = e.Post(task)
+		}
+	})
+	wg.Wait()
+	b.StopTimer()
+
+	_ = e.Stop()
+	e.Wait()
+}
+
+func BenchmarkStealing(b *testing.B) {
+	benchmark(b, New(runtime.NumCPU()))
+}
+
+func BenchmarkPooled(b *testing.B) {
+	benchmark(b, pooled.New(runtime.NumCPU()))
+}
+
+func BenchmarkHashing(b *testing.B) {
+	var next uint32
+	n := uint32(runtime.NumCPU())
+	benchmark(b, hashing.New(func(task exec.Task) int {
+		return int(atomic.AddUint32(&next, 1) % n)
+	}, int(n)))
+}
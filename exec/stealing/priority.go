@@ -0,0 +1,44 @@
+package stealing
+
+import "github.com/jeckbjy/gsk/exec"
+
+// Priority 任务优先级,数值越小优先级越高,高优先级的任务总是先被执行
+type Priority int
+
+const (
+	PriorityHigh Priority = iota
+	PriorityNormal
+	PriorityLow
+	numPriority
+)
+
+// Prioritized 实现了此接口的Task会按照Priority投递,否则为PriorityNormal
+type Prioritized interface {
+	exec.Task
+	Priority() Priority
+}
+
+// WithPriority 为Task指定优先级
+func WithPriority(task exec.Task, p Priority) exec.Task {
+	return &priorityTask{Task: task, priority: p}
+}
+
+type priorityTask struct {
+	exec.Task
+	priority Priority
+}
+
+func (t *priorityTask) Priority() Priority {
+	return t.priority
+}
+
+func priorityOf(task exec.Task) Priority {
+	// PostContext包装后的task需要取原始task的优先级
+	if t, ok := exec.Unwrap(task).(Prioritized); ok {
+		if p := t.Priority(); p >= PriorityHigh && p < numPriority {
+			return p
+		}
+	}
+
+	return PriorityNormal
+}
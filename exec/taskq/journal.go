@@ -0,0 +1,156 @@
+package taskq
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+const journalName = "taskq.log"
+
+const (
+	opAdd    = "add"    // 新增任务
+	opRetry  = "retry"  // 执行失败,等待重试
+	opDead   = "dead"   // 进入死信队列
+	opDone   = "done"   // 执行成功
+	opCancel = "cancel" // 取消
+)
+
+type record struct {
+	Op   string `json:"op"`
+	Task *Task  `json:"task"`
+}
+
+// journal 追加日志,每行一条json记录,add,retry,dead保存任务的完整状态,done,cancel只保存ID
+// 启动时重放日志恢复任务,并重写日志只保留有效任务,运行中记录数过多时也会重写
+type journal struct {
+	path  string
+	file  *os.File
+	sync  bool
+	count int // 当前日志中的记录数
+}
+
+// openJournal 打开日志并恢复未完成的任务,崩溃时最后一条记录可能不完整,解析失败的记录会被忽略
+func openJournal(dir string, sync bool) (*journal, map[string]*Task, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, err
+	}
+
+	j := &journal{path: filepath.Join(dir, journalName), sync: sync}
+	tasks := make(map[string]*Task)
+	if f, err := os.Open(j.path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+		for scanner.Scan() {
+			r := record{}
+			if err := json.Unmarshal(scanner.Bytes(), &r); err != nil || r.Task == nil {
+				continue
+			}
+
+			switch r.Op {
+			case opAdd, opRetry, opDead:
+				tasks[r.Task.ID] = r.Task
+			case opDone, opCancel:
+				delete(tasks, r.Task.ID)
+			}
+		}
+		err = scanner.Err()
+		_ = f.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	if err := j.compact(tasks); err != nil {
+		return nil, nil, err
+	}
+
+	return j, tasks, nil
+}
+
+func (j *journal) append(op string, t *Task) error {
+	if op == opDone || op == opCancel {
+		t = &Task{ID: t.ID}
+	}
+
+	data, err := json.Marshal(&record{Op: op, Task: t})
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+	if _, err := j.file.Write(data); err != nil {
+		return err
+	}
+
+	j.count++
+	if j.sync {
+		return j.file.Sync()
+	}
+
+	return nil
+}
+
+// compact 将有效任务写入临时文件,然后替换原日志
+func (j *journal) compact(tasks map[string]*Task) error {
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, t := range tasks {
+		op := opAdd
+		if t.Dead {
+			op = opDead
+		}
+		if err := enc.Encode(&record{Op: op, Task: t}); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	_ = f.Close()
+
+	if j.file != nil {
+		_ = j.file.Close()
+		j.file = nil
+	}
+
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	j.file = file
+	j.count = len(tasks)
+	return nil
+}
+
+func (j *journal) Close() error {
+	if j.file == nil {
+		return nil
+	}
+
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
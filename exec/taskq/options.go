@@ -0,0 +1,100 @@
+package taskq
+
+import (
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+	"github.com/jeckbjy/gsk/util/backoff"
+	"github.com/jeckbjy/gsk/util/timex/timingwheel"
+)
+
+const (
+	DefaultMaxAttempts = 5
+	DefaultCompact     = 10000
+)
+
+type Option func(o *Options)
+
+type Options struct {
+	Executor    exec.Executor            // 执行任务,默认pooled,最多NumCPU个协程
+	Wheel       *timingwheel.TimingWheel // 延迟调度,默认全局的timingwheel
+	MaxAttempts int                      // 最多执行次数,超过后进入死信队列
+	Backoff     func() backoff.BackOff   // 失败后的重试间隔,第n次重试使用第n次Next的结果
+	DeadLetter  func(t *Task)            // 进入死信队列时回调
+	Dir         string                   // 持久化目录,为空则不持久化
+	Sync        bool                     // 每次写日志后是否Sync,更安全但是更慢
+	Compact     int                      // 日志记录数超过Compact并且超过有效任务数的2倍时压缩日志
+}
+
+func (o *Options) Build(opts ...Option) {
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.Wheel == nil {
+		o.Wheel = timingwheel.Get()
+	}
+
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultMaxAttempts
+	}
+
+	if o.Backoff == nil {
+		o.Backoff = func() backoff.BackOff {
+			return backoff.NewExponential(backoff.WithMin(time.Second), backoff.WithMax(time.Minute*5))
+		}
+	}
+
+	if o.Compact <= 0 {
+		o.Compact = DefaultCompact
+	}
+}
+
+func Executor(e exec.Executor) Option {
+	return func(o *Options) {
+		o.Executor = e
+	}
+}
+
+func Wheel(w *timingwheel.TimingWheel) Option {
+	return func(o *Options) {
+		o.Wheel = w
+	}
+}
+
+func MaxAttempts(n int) Option {
+	return func(o *Options) {
+		o.MaxAttempts = n
+	}
+}
+
+func Backoff(fn func() backoff.BackOff) Option {
+	return func(o *Options) {
+		o.Backoff = fn
+	}
+}
+
+func DeadLetter(fn func(t *Task)) Option {
+	return func(o *Options) {
+		o.DeadLetter = fn
+	}
+}
+
+// Dir 持久化到dir目录中的追加日志,重启后恢复未完成的任务和死信
+func Dir(dir string) Option {
+	return func(o *Options) {
+		o.Dir = dir
+	}
+}
+
+func Sync(sync bool) Option {
+	return func(o *Options) {
+		o.Sync = sync
+	}
+}
+
+func Compact(n int) Option {
+	return func(o *Options) {
+		o.Compact = n
+	}
+}
@@ -0,0 +1,386 @@
+// Package taskq 延迟任务队列
+// 任务可以延迟执行或者在指定时间执行,延迟调度使用timingwheel,到期后投递到exec.Executor中执行
+// 执行失败后按照backoff重试,超过MaxAttempts后进入死信队列,可以通过Retry重新投递
+// 设置Dir后任务会记录在本地追加日志中,重启后恢复未完成的任务
+// 为了能够持久化,任务使用Type和Payload表示,通过Handle注册每种Type的处理函数
+package taskq
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/jeckbjy/gsk/exec"
+	"github.com/jeckbjy/gsk/exec/pooled"
+	"github.com/jeckbjy/gsk/util/backoff"
+	"github.com/jeckbjy/gsk/util/idgen/xid"
+	"github.com/jeckbjy/gsk/util/timex/timingwheel"
+)
+
+var (
+	ErrNoHandler = errors.New("no handler")
+	ErrNotFound  = errors.New("task not found")
+)
+
+// Handler 任务处理函数,返回错误时会重试
+type Handler func(t *Task) error
+
+// Task 任务,Handler中不要修改Task
+type Task struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Payload  []byte    `json:"payload,omitempty"`
+	RunAt    time.Time `json:"run_at"`             // 下次执行时间
+	Attempts int       `json:"attempts,omitempty"` // 失败次数
+	Error    string    `json:"error,omitempty"`    // 最后一次失败原因
+	Dead     bool      `json:"dead,omitempty"`     // 是否在死信队列中
+	timer    *timingwheel.Timer
+}
+
+// Queue 延迟任务队列
+type Queue struct {
+	opts     *Options
+	mux      sync.Mutex
+	handlers map[string]Handler
+	tasks    map[string]*Task // 等待中,执行中以及死信任务
+	journal  *journal
+	started  bool
+	quit     bool
+	owned    bool // Executor是否由Queue创建
+	wg       sync.WaitGroup
+}
+
+// New 创建队列,设置了Dir时会恢复之前未完成的任务,需要注册Handler后调用Start开始调度
+func New(opts ...Option) (*Queue, error) {
+	o := &Options{}
+	o.Build(opts...)
+
+	q := &Queue{opts: o, handlers: make(map[string]Handler), tasks: make(map[string]*Task)}
+	if o.Dir != "" {
+		j, tasks, err := openJournal(o.Dir, o.Sync)
+		if err != nil {
+			return nil, err
+		}
+		q.journal = j
+		q.tasks = tasks
+	}
+
+	if o.Executor == nil {
+		o.Executor = pooled.New(runtime.NumCPU())
+		q.owned = true
+	}
+
+	return q, nil
+}
+
+// Handle 注册任务处理函数
+func (q *Queue) Handle(typ string, h Handler) {
+	q.mux.Lock()
+	q.handlers[typ] = h
+	q.mux.Unlock()
+}
+
+// Start 开始调度,包括从日志中恢复的任务
+func (q *Queue) Start() error {
+	q.mux.Lock()
+	if q.quit {
+		q.mux.Unlock()
+		return exec.ErrAlreadyStop
+	}
+
+	var ready []*Task
+	if !q.started {
+		q.started = true
+		for _, t := range q.tasks {
+			if !t.Dead && q.schedule(t) {
+				ready = append(ready, t)
+			}
+		}
+	}
+	q.mux.Unlock()
+
+	q.run(ready...)
+	return nil
+}
+
+// Post 立即执行
+func (q *Queue) Post(typ string, payload []byte) (*Task, error) {
+	return q.PostAt(typ, payload, time.Now())
+}
+
+// PostDelay 延迟d之后执行
+func (q *Queue) PostDelay(typ string, payload []byte, d time.Duration) (*Task, error) {
+	return q.PostAt(typ, payload, time.Now().Add(d))
+}
+
+// PostAt 在指定时间执行,返回提交时任务的副本
+func (q *Queue) PostAt(typ string, payload []byte, at time.Time) (*Task, error) {
+	t := &Task{ID: xid.New().String(), Type: typ, Payload: payload, RunAt: at}
+
+	q.mux.Lock()
+	if q.quit {
+		q.mux.Unlock()
+		return nil, exec.ErrAlreadyStop
+	}
+
+	if err := q.log(opAdd, t); err != nil {
+		q.mux.Unlock()
+		return nil, err
+	}
+
+	q.tasks[t.ID] = t
+	ready := q.started && q.schedule(t)
+	// 返回副本,执行和重试时会在锁内修改t
+	c := *t
+	c.timer = nil
+	q.mux.Unlock()
+
+	if ready {
+		q.run(t)
+	}
+
+	return &c, nil
+}
+
+// Cancel 取消等待中的任务或者删除死信,正在执行的任务无法中断,但不会再重试
+func (q *Queue) Cancel(id string) bool {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	t, ok := q.tasks[id]
+	if !ok {
+		return false
+	}
+
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	delete(q.tasks, id)
+	_ = q.log(opCancel, t)
+	return true
+}
+
+// Retry 将死信重新投递,重置失败次数
+func (q *Queue) Retry(id string) error {
+	q.mux.Lock()
+	t, ok := q.tasks[id]
+	if !ok || !t.Dead {
+		q.mux.Unlock()
+		return ErrNotFound
+	}
+
+	t.Dead = false
+	t.Attempts = 0
+	t.RunAt = time.Now()
+	err := q.log(opAdd, t)
+	ready := q.started && !q.quit && q.schedule(t)
+	q.mux.Unlock()
+
+	if ready {
+		q.run(t)
+	}
+
+	return err
+}
+
+// Pending 等待中和执行中的任务
+func (q *Queue) Pending() []Task {
+	return q.list(false)
+}
+
+// DeadLetters 死信队列中的任务
+func (q *Queue) DeadLetters() []Task {
+	return q.list(true)
+}
+
+func (q *Queue) list(dead bool) []Task {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	var result []Task
+	for _, t := range q.tasks {
+		if t.Dead == dead {
+			c := *t
+			c.timer = nil
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
+
+// Stop 停止调度,等待正在执行的任务结束,未完成的任务保留在日志中,重启后继续执行
+func (q *Queue) Stop() error {
+	q.mux.Lock()
+	if q.quit {
+		q.mux.Unlock()
+		return exec.ErrAlreadyStop
+	}
+	q.quit = true
+	for _, t := range q.tasks {
+		if t.timer != nil {
+			t.timer.Stop()
+			t.timer = nil
+		}
+	}
+	q.mux.Unlock()
+
+	q.wg.Wait()
+	if q.owned {
+		_ = q.opts.Executor.Stop()
+		q.opts.Executor.Wait()
+	}
+
+	if q.journal == nil {
+		return nil
+	}
+
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if err := q.journal.compact(q.tasks); err != nil {
+		_ = q.journal.Close()
+		return err
+	}
+
+	return q.journal.Close()
+}
+
+func (q *Queue) log(op string, t *Task) error {
+	if q.journal == nil {
+		return nil
+	}
+
+	return q.journal.append(op, t)
+}
+
+// schedule 添加定时器,已经到期时返回true,需要在解锁后调用run
+func (q *Queue) schedule(t *Task) bool {
+	expired := (t.RunAt.UnixNano() + int64(time.Millisecond) - 1) / int64(time.Millisecond)
+	if expired <= time.Now().UnixNano()/int64(time.Millisecond) {
+		return true
+	}
+
+	id := t.ID
+	t.timer = q.opts.Wheel.NewTimer(expired, func() { q.fire(id) })
+	return false
+}
+
+func (q *Queue) fire(id string) {
+	q.mux.Lock()
+	t, ok := q.tasks[id]
+	if !ok || q.quit || t.Dead || t.timer == nil {
+		q.mux.Unlock()
+		return
+	}
+	t.timer = nil
+	q.mux.Unlock()
+
+	q.run(t)
+}
+
+func (q *Queue) run(tasks ...*Task) {
+	for _, t := range tasks {
+		q.wg.Add(1)
+		r := &runner{q: q, t: t}
+		if err := q.opts.Executor.Post(r); err != nil {
+			q.wg.Done()
+			q.finish(t, err)
+		}
+	}
+}
+
+// finish 记录执行结果,失败时重试或者进入死信队列
+func (q *Queue) finish(t *Task, err error) {
+	q.mux.Lock()
+	if q.tasks[t.ID] != t {
+		// 执行期间被取消
+		q.mux.Unlock()
+		return
+	}
+
+	dead := false
+	ready := false
+	if err == nil {
+		delete(q.tasks, t.ID)
+		err = q.log(opDone, t)
+	} else {
+		t.Attempts++
+		t.Error = err.Error()
+		delay := q.delay(t.Attempts)
+		if t.Attempts >= q.opts.MaxAttempts || delay == backoff.Stop {
+			t.Dead = true
+			dead = true
+			err = q.log(opDead, t)
+		} else {
+			t.RunAt = time.Now().Add(delay)
+			err = q.log(opRetry, t)
+			ready = !q.quit && q.schedule(t)
+		}
+	}
+
+	if err != nil {
+		log.Printf("[taskq] write journal fail, %+v", err)
+	}
+
+	if j := q.journal; j != nil && j.count > q.opts.Compact && j.count > 2*len(q.tasks) {
+		if err := j.compact(q.tasks); err != nil {
+			log.Printf("[taskq] compact journal fail, %+v", err)
+		}
+	}
+	q.mux.Unlock()
+
+	if dead && q.opts.DeadLetter != nil {
+		q.opts.DeadLetter(t)
+	}
+
+	if ready {
+		q.run(t)
+	}
+}
+
+// delay 第n次失败后的等待时间,每次重新计算,因此重启后也能得到相同的结果
+func (q *Queue) delay(attempts int) time.Duration {
+	b := q.opts.Backoff()
+	var d time.Duration
+	for i := 0; i < attempts; i++ {
+		d = b.Next()
+		if d == backoff.Stop {
+			break
+		}
+	}
+
+	return d
+}
+
+type runner struct {
+	q *Queue
+	t *Task
+}
+
+func (r *runner) Run() error {
+	defer r.q.wg.Done()
+
+	r.q.mux.Lock()
+	h := r.q.handlers[r.t.Type]
+	r.q.mux.Unlock()
+
+	err := ErrNoHandler
+	if h != nil {
+		err = call(h, r.t)
+	}
+
+	r.q.finish(r.t, err)
+	return err
+}
+
+func call(h Handler, t *Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return h(t)
+}
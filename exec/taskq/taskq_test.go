@@ -0,0 +1,199 @@
+package taskq
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/exec/pooled"
+	"github.com/jeckbjy/gsk/util/backoff"
+)
+
+func TestDelay(t *testing.T) {
+	q, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Stop()
+
+	done := make(chan time.Time, 2)
+	q.Handle("echo", func(task *Task) error {
+		done <- time.Now()
+		return nil
+	})
+	_ = q.Start()
+
+	start := time.Now()
+	if _, err := q.PostDelay("echo", nil, time.Millisecond*100); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Post("echo", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	first := <-done
+	if first.Sub(start) >= time.Millisecond*100 {
+		t.Fatal("post should run immediately")
+	}
+
+	select {
+	case second := <-done:
+		if second.Sub(start) < time.Millisecond*100 {
+			t.Fatalf("run too early, %v", second.Sub(start))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("delay task timeout")
+	}
+
+	if len(q.Pending()) != 0 {
+		t.Fatal("task not removed")
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	dead := make(chan *Task, 1)
+	q, _ := New(
+		MaxAttempts(3),
+		Backoff(func() backoff.BackOff { return backoff.NewConstant(time.Millisecond * 10) }),
+		DeadLetter(func(task *Task) { dead <- task }),
+	)
+	defer q.Stop()
+
+	var calls int32
+	var fail int32 = 1
+	q.Handle("fail", func(task *Task) error {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			return errors.New("fail")
+		}
+		return nil
+	})
+	_ = q.Start()
+
+	task, _ := q.Post("fail", []byte("data"))
+	select {
+	case d := <-dead:
+		if d.ID != task.ID || d.Attempts != 3 || d.Error != "fail" {
+			t.Fatalf("bad dead letter, %+v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("dead letter timeout")
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf("bad attempts, %d", n)
+	}
+
+	// Post返回的是副本,不会被重试修改
+	if task.Attempts != 0 || task.Error != "" {
+		t.Fatalf("posted task modified, %+v", task)
+	}
+
+	if letters := q.DeadLetters(); len(letters) != 1 || string(letters[0].Payload) != "data" {
+		t.Fatalf("bad dead letters, %+v", letters)
+	}
+
+	// 修复后重新投递
+	atomic.StoreInt32(&fail, 0)
+	if err := q.Retry(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 50)
+	if len(q.DeadLetters()) != 0 || atomic.LoadInt32(&calls) != 4 {
+		t.Fatal("retry dead letter fail")
+	}
+}
+
+func TestPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taskq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	q, err := New(Dir(dir), MaxAttempts(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Handle("fail", func(task *Task) error { return errors.New("fail") })
+	_ = q.Start()
+
+	later, _ := q.PostDelay("later", []byte("later"), time.Hour)
+	cancel, _ := q.PostDelay("later", nil, time.Hour)
+	dead, _ := q.Post("fail", nil)
+	q.Cancel(cancel.ID)
+	time.Sleep(time.Millisecond * 50)
+	if err := q.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后恢复
+	q, err = New(Dir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Stop()
+
+	pending := q.Pending()
+	if len(pending) != 1 || pending[0].ID != later.ID || string(pending[0].Payload) != "later" {
+		t.Fatalf("bad pending, %+v", pending)
+	}
+
+	letters := q.DeadLetters()
+	if len(letters) != 1 || letters[0].ID != dead.ID {
+		t.Fatalf("bad dead letters, %+v", letters)
+	}
+
+	// 到期的任务在Start后执行
+	done := make(chan string, 1)
+	q.Handle("now", func(task *Task) error {
+		done <- task.ID
+		return nil
+	})
+	now, _ := q.PostAt("now", nil, time.Now().Add(-time.Minute))
+	_ = q.Start()
+	select {
+	case id := <-done:
+		if id != now.ID {
+			t.Fatal("bad task")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("run timeout")
+	}
+}
+
+func TestStopExecutor(t *testing.T) {
+	q, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = q.Start()
+	_ = q.Stop()
+
+	// 默认的Executor随Queue一起停止
+	if err := q.opts.Executor.Post(taskFunc(func() error { return nil })); err == nil {
+		t.Fatal("default executor not stopped")
+	}
+
+	// 外部传入的Executor由调用者负责停止
+	e := pooled.New(1)
+	defer e.Stop()
+	q, err = New(Executor(e))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = q.Start()
+	_ = q.Stop()
+	if err := e.Post(taskFunc(func() error { return nil })); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type taskFunc func() error
+
+func (f taskFunc) Run() error {
+	return f()
+}
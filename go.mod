@@ -5,4 +5,6 @@ go 1.13
 require (
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/lib/pq v1.3.0
+	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
 )
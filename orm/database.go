@@ -114,13 +114,15 @@ func (d *Database) DeleteOne(table string, filter Cond, opts ...DeleteOption) (*
 	return d.database.Delete(table, filter, &o)
 }
 
+// Update 更新数据,如果update是含有版本列(orm:"version")的struct指针,则会自动进行乐观锁检查
+// 只有版本号与update中一致时才会更新,同时版本号加1,没有匹配到记录时返回ErrVersionConflict
 func (d *Database) Update(table string, filter Cond, update interface{}, opts ...UpdateOption) (*UpdateResult, error) {
 	o := driver.UpdateOptions{}
 	for _, fn := range opts {
 		fn(&o)
 	}
 
-	return d.database.Update(table, filter, update, &o)
+	return d.update(table, filter, update, &o)
 }
 
 func (d *Database) UpdateOne(table string, filter Cond, update interface{}, opts ...UpdateOption) (*UpdateResult, error) {
@@ -130,7 +132,34 @@ func (d *Database) UpdateOne(table string, filter Cond, update interface{}, opts
 		fn(&o)
 	}
 
-	return d.database.Update(table, filter, update, &o)
+	return d.update(table, filter, update, &o)
+}
+
+func (d *Database) update(table string, filter Cond, update interface{}, o *driver.UpdateOptions) (*UpdateResult, error) {
+	field, column, ok := findVersion(update)
+	if !ok {
+		return d.database.Update(table, filter, update, o)
+	}
+
+	version := field.Int()
+	if filter != nil {
+		filter = And(filter, Eq(column, version))
+	} else {
+		filter = Eq(column, version)
+	}
+
+	field.SetInt(version + 1)
+	res, err := d.database.Update(table, filter, update, o)
+	if err == nil && res.MatchedCount == 0 {
+		err = ErrVersionConflict
+	}
+
+	if err != nil {
+		field.SetInt(version)
+		return res, err
+	}
+
+	return res, nil
 }
 
 func (d *Database) Query(table string, filter Cond, opts ...QueryOption) error {
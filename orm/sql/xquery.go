@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 
 	"github.com/jeckbjy/gsk/orm/driver"
 )
@@ -25,7 +26,7 @@ func toWhere(cond driver.Cond) (string, error) {
 
 func buildWhere(b *sqlBuilder, cond driver.Cond, depth int) error {
 	switch op := cond.Operator(); {
-	case op > driver.TOK_EQ && op <= driver.TOK_NIN:
+	case op >= driver.TOK_EQ && op <= driver.TOK_NIN:
 		//
 		s := cond.(driver.ExprCond)
 		b.Write(spBlank, "%s %s %s", s.Key(), sqlToken[op], toString(s.Value()))
@@ -57,6 +58,59 @@ func buildWhere(b *sqlBuilder, cond driver.Cond, depth int) error {
 	return nil
 }
 
+// toSet 生成UPDATE中SET的部分,update可以是map[string]interface{}或者struct(指针)
+// struct使用db tag作为列名,没有则使用字段名,db:"-"表示忽略,只处理导出的字段
+func toSet(update interface{}) (string, []interface{}, error) {
+	var columns []string
+	var args []interface{}
+	if m, ok := update.(map[string]interface{}); ok {
+		for k := range m {
+			columns = append(columns, k)
+		}
+		// 保证生成的sql稳定
+		sort.Strings(columns)
+		for _, k := range columns {
+			args = append(args, m[k])
+		}
+	} else {
+		v := reflect.ValueOf(update)
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return "", nil, errors.New("update must be map or struct")
+		}
+
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := f.Tag.Get("db")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			columns = append(columns, name)
+			args = append(args, v.Field(i).Interface())
+		}
+	}
+
+	if len(columns) == 0 {
+		return "", nil, errors.New("nothing to update")
+	}
+
+	b := sqlBuilder{}
+	for _, c := range columns {
+		b.Write(spComma, "%s = ?", c)
+	}
+
+	return b.String(), args, nil
+}
+
 // 只能是普通类型?
 func toString(data interface{}) string {
 	var v reflect.Value
@@ -0,0 +1,120 @@
+package sql
+
+import (
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/jeckbjy/gsk/orm"
+	"github.com/jeckbjy/gsk/orm/driver"
+)
+
+// 只能注册一次,放在init中,否则-count=2时会panic
+func init() {
+	sql.Register("orm_record", &recordDriver{})
+}
+
+// recordDriver 记录最后一次执行的sql,用于测试生成的语句
+type recordDriver struct {
+	mux   sync.Mutex
+	query string
+	args  []sqldriver.Value
+}
+
+func (d *recordDriver) Open(name string) (sqldriver.Conn, error) { return &recordConn{d}, nil }
+
+type recordConn struct {
+	d *recordDriver
+}
+
+func (c *recordConn) Prepare(query string) (sqldriver.Stmt, error) {
+	return &recordStmt{d: c.d, query: query}, nil
+}
+func (c *recordConn) Close() error                 { return nil }
+func (c *recordConn) Begin() (sqldriver.Tx, error) { return nil, errors.New("not support") }
+
+type recordStmt struct {
+	d     *recordDriver
+	query string
+}
+
+func (s *recordStmt) Close() error  { return nil }
+func (s *recordStmt) NumInput() int { return -1 }
+func (s *recordStmt) Exec(args []sqldriver.Value) (sqldriver.Result, error) {
+	s.d.mux.Lock()
+	s.d.query = s.query
+	s.d.args = args
+	s.d.mux.Unlock()
+	return sqldriver.RowsAffected(1), nil
+}
+func (s *recordStmt) Query(args []sqldriver.Value) (sqldriver.Rows, error) {
+	return nil, errors.New("not support")
+}
+
+func TestWhere(t *testing.T) {
+	cases := []struct {
+		cond orm.Cond
+		sql  string
+	}{
+		{nil, ""},
+		{orm.Eq("name", "a"), "WHERE name = 'a'"},
+		{orm.Gt("age", 10), "WHERE age > 10"},
+		{orm.And(orm.Eq("id", 1), orm.Eq("version", 2)), "WHERE id = 1 AND version = 2"},
+	}
+
+	for _, c := range cases {
+		where, err := toWhere(c.cond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if where != c.sql {
+			t.Errorf("expect %s, got %s", c.sql, where)
+		}
+	}
+}
+
+type account struct {
+	ID      int64  `db:"id"`
+	Name    string `db:"name"`
+	Version int64  `db:"version"`
+	Cache   string `db:"-"`
+	secret  string
+}
+
+func TestUpdate(t *testing.T) {
+	db, err := sql.Open("orm_record", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	d := &baseDB{}
+	d.Bind(db)
+	rd := db.Driver().(*recordDriver)
+
+	a := &account{ID: 1, Name: "a", Version: 3, Cache: "x", secret: "y"}
+	res, err := d.Update("account", orm.And(orm.Eq("id", 1), orm.Eq("version", 2)), a, &driver.UpdateOptions{One: true})
+	if err != nil || res.MatchedCount != 1 {
+		t.Fatalf("update fail, %+v, %v", res, err)
+	}
+
+	expect := "UPDATE account SET id = ?,name = ?,version = ? WHERE id = 1 AND version = 2 LIMIT 1"
+	if rd.query != expect || !reflect.DeepEqual(rd.args, []sqldriver.Value{int64(1), "a", int64(3)}) {
+		t.Fatalf("bad sql, %s, %+v", rd.query, rd.args)
+	}
+
+	_, err = d.Update("account", orm.Eq("id", 1), map[string]interface{}{"version": 4, "name": "b"}, &driver.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rd.query != "UPDATE account SET name = ?,version = ? WHERE id = 1" || !reflect.DeepEqual(rd.args, []sqldriver.Value{"b", int64(4)}) {
+		t.Fatalf("bad sql, %s, %+v", rd.query, rd.args)
+	}
+
+	if _, err := d.Update("account", nil, 1, &driver.UpdateOptions{}); err == nil {
+		t.Fatal("should not support")
+	}
+}
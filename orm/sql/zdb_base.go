@@ -225,14 +225,19 @@ func (d *baseDB) Update(table string, filter driver.Cond, update interface{}, op
 		return nil, err
 	}
 
+	set, args, err := toSet(update)
+	if err != nil {
+		return nil, err
+	}
+
 	builder := sqlBuilder{}
-	builder.Write(spBlank, "UPDATE %s SET VALUES", table)
+	builder.Write(spBlank, "UPDATE %s SET %s", table, set)
 	builder.Write(spBlank, where)
 	if opts.One {
 		builder.Write(spBlank, "LIMIT 1")
 	}
 
-	res, err := d.db.Exec(builder.String())
+	res, err := d.db.Exec(builder.String(), args...)
 	if err != nil {
 		return nil, err
 	}
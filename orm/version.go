@@ -0,0 +1,60 @@
+package orm
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// ErrVersionConflict 乐观锁冲突,记录已经被其他人修改
+var ErrVersionConflict = errors.New("version conflict")
+
+// 版本列,需要是整数类型,例如: Version int64 `db:"version" orm:"version"`
+// Database.Update时会自动增加过滤条件version=旧版本,并将版本号加1,没有匹配到记录时返回ErrVersionConflict
+const tagVersion = "version"
+
+// findVersion 查找带有orm:"version"标签的字段,返回字段和列名
+func findVersion(doc interface{}) (reflect.Value, string, bool) {
+	v := reflect.ValueOf(doc)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return reflect.Value{}, "", false
+	}
+
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, "", false
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !hasTag(f.Tag.Get("orm"), tagVersion) {
+			continue
+		}
+
+		switch f.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		default:
+			return reflect.Value{}, "", false
+		}
+
+		name := f.Tag.Get("db")
+		if name == "" {
+			name = f.Name
+		}
+
+		return v.Field(i), name, true
+	}
+
+	return reflect.Value{}, "", false
+}
+
+func hasTag(tag string, name string) bool {
+	for _, t := range strings.Split(tag, ",") {
+		if strings.TrimSpace(t) == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,385 @@
+package gossip
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jeckbjy/gsk/registry"
+	"github.com/jeckbjy/gsk/util/errorx"
+	"github.com/jeckbjy/gsk/util/idgen/xid"
+)
+
+// New 创建基于Gossip的注册中心,不依赖中心节点
+// 节点之间通过UDP通信,使用SWIM协议检测节点故障,服务信息随探测消息传播
+// 可以通过Seeds指定任意几个已知节点加入集群
+func New(opts ...Option) (registry.Registry, error) {
+	o := &Options{
+		Bind:             DefaultBind,
+		ProbeInterval:    DefaultProbeInterval,
+		ProbeTimeout:     DefaultProbeTimeout,
+		SuspicionTimeout: DefaultSuspicionTimeout,
+		SyncInterval:     DefaultSyncInterval,
+		IndirectChecks:   DefaultIndirectChecks,
+		RetransmitMult:   DefaultRetransmitMult,
+		MaxPiggyback:     DefaultMaxPiggyback,
+	}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.Name == "" {
+		o.Name = xid.New().String()
+	}
+
+	laddr, err := net.ResolveUDPAddr("udp", o.Bind)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := o.Advertise
+	if addr == "" {
+		addr = conn.LocalAddr().String()
+	}
+
+	g := &gossipRegistry{
+		opts:    o,
+		conn:    conn,
+		self:    &member{Name: o.Name, Addr: addr},
+		members: make(map[string]*member),
+		since:   make(map[string]time.Time),
+		records: make(map[string]*record),
+		deleted: make(map[string]time.Time),
+		local:   make(map[string]*registry.Service),
+		queue:   newBroadcastQueue(),
+		acks:    make(map[uint64]func()),
+		quit:    make(chan struct{}),
+	}
+
+	g.queue.pushMember(g.self)
+	g.wg.Add(3)
+	go g.readLoop()
+	go g.probeLoop()
+	go g.syncLoop()
+
+	for _, seed := range o.Seeds {
+		if seed != addr {
+			g.sync(seed)
+		}
+	}
+
+	return g, nil
+}
+
+type watcher struct {
+	names map[string]bool
+	cb    registry.Callback
+}
+
+// gossipRegistry 基于SWIM协议的注册中心
+//
+// 成员状态: alive,suspect,dead,通过Incarnation判断消息新旧,只有节点自己能够增加Incarnation,用于反驳怀疑
+// 故障检测: 每个周期轮询探测一个节点,超时后请求IndirectChecks个节点间接探测,仍然失败则标记为suspect,
+// 超过SuspicionTimeout后标记为dead,并删除该节点注册的所有服务
+// 消息传播: 成员和服务的变化放入队列,附带在探测消息中传播,每条消息转发RetransmitMult*log10(n+1)次
+// 全量同步: 加入集群时以及每隔SyncInterval,与随机节点交换全量数据,全量数据需要能够放入一个UDP包中
+// 服务记录: 由注册节点维护版本号,注销时保留一段时间的墓碑,防止旧数据重新传播
+type gossipRegistry struct {
+	opts     *Options
+	conn     *net.UDPConn
+	mux      sync.Mutex
+	self     *member
+	members  map[string]*member   // 其他成员
+	since    map[string]time.Time // 进入suspect或者dead状态的时间
+	records  map[string]*record   // 所有服务记录,包括墓碑
+	deleted  map[string]time.Time // 墓碑创建时间
+	local    map[string]*registry.Service
+	version  uint64          // 本节点服务版本号
+	queue    *broadcastQueue // 等待传播的消息
+	seq      uint64
+	acks     map[uint64]func() // 等待应答的回调
+	probes   []string          // 探测顺序
+	watchers []*watcher
+	closed   bool
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func (g *gossipRegistry) Name() string {
+	return "gossip"
+}
+
+func (g *gossipRegistry) Register(srv *registry.Service) error {
+	g.mux.Lock()
+	if g.closed {
+		g.mux.Unlock()
+		return errorx.ErrNotAvailable
+	}
+
+	g.local[srv.Id] = srv
+	events := g.announce(srv.Id, srv)
+	g.mux.Unlock()
+
+	g.notify(events)
+	return nil
+}
+
+func (g *gossipRegistry) Unregister(serviceID string) error {
+	g.mux.Lock()
+	if _, ok := g.local[serviceID]; !ok {
+		g.mux.Unlock()
+		return errorx.ErrNotFound
+	}
+
+	delete(g.local, serviceID)
+	events := g.announce(serviceID, nil)
+	g.mux.Unlock()
+
+	g.notify(events)
+	return nil
+}
+
+// announce 更新本地服务记录并传播,srv为nil表示注销
+func (g *gossipRegistry) announce(id string, srv *registry.Service) []*registry.Event {
+	g.version++
+	return g.apply(&record{Owner: g.self.Name, Version: g.version, Id: id, Service: srv})
+}
+
+func (g *gossipRegistry) Query(name string, opts *registry.QueryOptions) ([]*registry.Service, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	results := make([]*registry.Service, 0)
+	for _, r := range g.records {
+		if r.Service == nil {
+			continue
+		}
+
+		if name != "" && r.Service.Name != name {
+			continue
+		}
+
+		if !r.Service.Match(opts) {
+			continue
+		}
+
+		// 拷贝一份,防止外部修改
+		srv := *r.Service
+		results = append(results, &srv)
+	}
+
+	return results, nil
+}
+
+func (g *gossipRegistry) Watch(names []string, cb registry.Callback) error {
+	w := &watcher{cb: cb}
+	if len(names) > 0 {
+		w.names = make(map[string]bool)
+		for _, name := range names {
+			w.names[name] = true
+		}
+	}
+
+	g.mux.Lock()
+	g.watchers = append(g.watchers, w)
+	g.mux.Unlock()
+	return nil
+}
+
+// Close 注销所有服务,并通知所有成员本节点离开
+func (g *gossipRegistry) Close() error {
+	g.mux.Lock()
+	if g.closed {
+		g.mux.Unlock()
+		return nil
+	}
+	g.closed = true
+
+	var tombstones []record
+	for id := range g.local {
+		g.version++
+		r := record{Owner: g.self.Name, Version: g.version, Id: id}
+		g.records[id] = &r
+		tombstones = append(tombstones, r)
+	}
+	g.local = make(map[string]*registry.Service)
+
+	g.self.State = stateDead
+	msg := &message{Type: msgGossip, From: g.self.Name, Members: []member{*g.self}, Services: tombstones}
+	var addrs []string
+	for _, m := range g.members {
+		if m.State != stateDead {
+			addrs = append(addrs, m.Addr)
+		}
+	}
+	g.watchers = nil
+	g.mux.Unlock()
+
+	for _, addr := range addrs {
+		g.send(addr, msg)
+	}
+
+	return g.shutdown()
+}
+
+// shutdown 直接停止,不通知其他成员,测试时用于模拟节点故障
+func (g *gossipRegistry) shutdown() error {
+	g.mux.Lock()
+	g.closed = true
+	g.mux.Unlock()
+
+	select {
+	case <-g.quit:
+		return nil
+	default:
+		close(g.quit)
+	}
+
+	err := g.conn.Close()
+	g.wg.Wait()
+	return err
+}
+
+// merge 合并其他节点传播的数据,先合并成员,再合并服务
+func (g *gossipRegistry) merge(msg *message) []*registry.Event {
+	var events []*registry.Event
+	for i := range msg.Members {
+		events = append(events, g.mergeMember(&msg.Members[i])...)
+	}
+
+	for i := range msg.Services {
+		events = append(events, g.mergeRecord(&msg.Services[i])...)
+	}
+
+	return events
+}
+
+func (g *gossipRegistry) mergeMember(m *member) []*registry.Event {
+	if m.Name == g.self.Name {
+		// 其他节点怀疑自己,增加Incarnation反驳,并重新传播本地服务
+		if m.State != stateAlive && m.Incarnation >= g.self.Incarnation && !g.closed {
+			g.self.Incarnation = m.Incarnation + 1
+			g.queue.pushMember(g.self)
+			for id, srv := range g.local {
+				g.announce(id, srv)
+			}
+		}
+		return nil
+	}
+
+	cur := g.members[m.Name]
+	switch m.State {
+	case stateAlive:
+		if cur != nil && m.Incarnation <= cur.Incarnation {
+			return nil
+		}
+	case stateSuspect:
+		if cur == nil || cur.State == stateDead || m.Incarnation < cur.Incarnation {
+			return nil
+		}
+		if cur.State == stateSuspect && m.Incarnation == cur.Incarnation {
+			return nil
+		}
+	case stateDead:
+		if cur != nil && (m.Incarnation < cur.Incarnation || (cur.State == stateDead && m.Incarnation == cur.Incarnation)) {
+			return nil
+		}
+	}
+
+	return g.setMember(m)
+}
+
+// setMember 更新成员状态并传播,成员死亡时删除其注册的服务
+func (g *gossipRegistry) setMember(m *member) []*registry.Event {
+	cp := *m
+	g.members[m.Name] = &cp
+	g.queue.pushMember(&cp)
+	if m.State == stateAlive {
+		delete(g.since, m.Name)
+	} else {
+		g.since[m.Name] = time.Now()
+	}
+
+	if m.State != stateDead {
+		return nil
+	}
+
+	var events []*registry.Event
+	for id, r := range g.records {
+		if r.Owner != m.Name {
+			continue
+		}
+
+		delete(g.records, id)
+		delete(g.deleted, id)
+		if r.Service != nil {
+			events = append(events, &registry.Event{Id: id, Type: registry.EventDelete, Service: r.Service})
+		}
+	}
+
+	return events
+}
+
+func (g *gossipRegistry) mergeRecord(r *record) []*registry.Event {
+	if r.Owner == g.self.Name {
+		// 收到比本地更新的自己的记录,通常是重启后版本号重置,需要使用更大的版本号覆盖
+		if r.Version > g.version && !g.closed {
+			g.version = r.Version
+			return g.announce(r.Id, g.local[r.Id])
+		}
+		return nil
+	}
+
+	if owner := g.members[r.Owner]; owner != nil && owner.State == stateDead {
+		return nil
+	}
+
+	cur := g.records[r.Id]
+	if cur != nil && (r.Version < cur.Version || (r.Version == cur.Version && r.Owner <= cur.Owner)) {
+		return nil
+	}
+
+	return g.apply(r)
+}
+
+// apply 保存服务记录并传播
+func (g *gossipRegistry) apply(r *record) []*registry.Event {
+	cur := g.records[r.Id]
+	cp := *r
+	g.records[r.Id] = &cp
+	g.queue.pushRecord(&cp)
+
+	if r.Service == nil {
+		g.deleted[r.Id] = time.Now()
+		if cur != nil && cur.Service != nil {
+			return []*registry.Event{{Id: r.Id, Type: registry.EventDelete, Service: cur.Service}}
+		}
+		return nil
+	}
+
+	delete(g.deleted, r.Id)
+	return []*registry.Event{{Id: r.Id, Type: registry.EventUpsert, Service: r.Service}}
+}
+
+func (g *gossipRegistry) notify(events []*registry.Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	g.mux.Lock()
+	watchers := g.watchers
+	g.mux.Unlock()
+
+	for _, ev := range events {
+		for _, w := range watchers {
+			if w.names == nil || w.names[ev.Service.Name] {
+				w.cb(ev)
+			}
+		}
+	}
+}
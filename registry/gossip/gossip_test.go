@@ -0,0 +1,203 @@
+package gossip
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/registry"
+)
+
+type events struct {
+	mux  sync.Mutex
+	list []*registry.Event
+}
+
+func (e *events) add(ev *registry.Event) {
+	e.mux.Lock()
+	e.list = append(e.list, ev)
+	e.mux.Unlock()
+}
+
+func (e *events) has(typ registry.EventType, id string) bool {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	for _, ev := range e.list {
+		if ev.Type == typ && ev.Id == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func waitFor(t *testing.T, timeout time.Duration, msg string, fn func() bool) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if fn() {
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	t.Fatalf("wait timeout: %s", msg)
+}
+
+func newCluster(t *testing.T, n int) []*gossipRegistry {
+	var nodes []*gossipRegistry
+	var seed string
+	for i := 0; i < n; i++ {
+		opts := []Option{
+			Name(fmt.Sprintf("node-%d", i)),
+			ProbeInterval(time.Millisecond * 50),
+			ProbeTimeout(time.Millisecond * 20),
+			SuspicionTimeout(time.Millisecond * 200),
+			SyncInterval(time.Millisecond * 200),
+		}
+		if seed != "" {
+			opts = append(opts, Seeds(seed))
+		}
+
+		r, err := New(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		g := r.(*gossipRegistry)
+		if seed == "" {
+			seed = g.self.Addr
+		}
+		nodes = append(nodes, g)
+	}
+
+	return nodes
+}
+
+func TestGossip(t *testing.T) {
+	const n = 8
+	nodes := newCluster(t, n)
+	defer func() {
+		for _, g := range nodes {
+			_ = g.Close()
+		}
+	}()
+
+	evs := &events{}
+	_ = nodes[0].Watch([]string{"echo"}, evs.add)
+
+	for i, g := range nodes {
+		srv := registry.NewService("echo", fmt.Sprintf("echo-%d", i), fmt.Sprintf("127.0.0.1:%d", 1000+i), nil)
+		if err := g.Register(srv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 所有节点都能看到全部服务
+	waitFor(t, time.Second*5, "dissemination", func() bool {
+		for _, g := range nodes {
+			if services, _ := g.Query("echo", nil); len(services) != n {
+				return false
+			}
+		}
+		return true
+	})
+
+	// 注销
+	if err := nodes[1].Unregister("echo-1"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, time.Second*2, "unregister", func() bool { return evs.has(registry.EventDelete, "echo-1") })
+
+	// 主动退出,其他节点立即收到通知
+	_ = nodes[2].Close()
+	waitFor(t, time.Second*2, "leave", func() bool { return evs.has(registry.EventDelete, "echo-2") })
+
+	// 模拟崩溃,通过探测发现
+	_ = nodes[3].shutdown()
+	waitFor(t, time.Second*5, "failure detection", func() bool { return evs.has(registry.EventDelete, "echo-3") })
+
+	waitFor(t, time.Second*5, "converge", func() bool {
+		for i, g := range nodes {
+			if i >= 1 && i <= 3 {
+				continue
+			}
+			if services, _ := g.Query("echo", nil); len(services) != n-3 {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestRefute(t *testing.T) {
+	nodes := newCluster(t, 2)
+	defer func() {
+		for _, g := range nodes {
+			_ = g.Close()
+		}
+	}()
+
+	_ = nodes[1].Register(registry.NewService("echo", "echo-1", "127.0.0.1:1", nil))
+	waitFor(t, time.Second*2, "join", func() bool {
+		services, _ := nodes[0].Query("echo", nil)
+		return len(services) == 1
+	})
+
+	// 错误地怀疑对方,对方增加Incarnation反驳
+	nodes[0].mux.Lock()
+	m := *nodes[0].members["node-1"]
+	m.State = stateSuspect
+	nodes[0].setMember(&m)
+	nodes[0].mux.Unlock()
+
+	waitFor(t, time.Second*2, "refute", func() bool {
+		nodes[0].mux.Lock()
+		defer nodes[0].mux.Unlock()
+		cur := nodes[0].members["node-1"]
+		return cur.State == stateAlive && cur.Incarnation > m.Incarnation
+	})
+
+	if services, _ := nodes[0].Query("echo", nil); len(services) != 1 {
+		t.Fatalf("service lost after refute, %+v", services)
+	}
+}
+
+// TestFullStateSplit 全量数据超过UDP包长度时拆分成多个消息
+func TestFullStateSplit(t *testing.T) {
+	nodes := newCluster(t, 1)
+	g := nodes[0]
+	defer g.Close()
+
+	const n = 200
+	value := strings.Repeat("x", 1024)
+	for i := 0; i < n; i++ {
+		srv := registry.NewService("echo", fmt.Sprintf("echo-%d", i), fmt.Sprintf("127.0.0.1:%d", 1000+i), map[string]string{"data": value})
+		if err := g.Register(srv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g.mux.Lock()
+	msgs := g.fullState(msgSync)
+	g.mux.Unlock()
+	if len(msgs) < 2 {
+		t.Fatalf("should split, %d", len(msgs))
+	}
+
+	services := 0
+	for i, msg := range msgs {
+		data, err := encode(msg)
+		if err != nil || len(data) > maxPacketSize {
+			t.Fatalf("bad packet, %d, %+v", len(data), err)
+		}
+		if (i == 0 && msg.Type != msgSync) || (i > 0 && msg.Type != msgGossip) {
+			t.Fatalf("bad type, %d, %d", i, msg.Type)
+		}
+		services += len(msg.Services)
+	}
+	if services != n {
+		t.Fatalf("services lost, %d", services)
+	}
+}
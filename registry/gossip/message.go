@@ -0,0 +1,138 @@
+package gossip
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+
+	"github.com/jeckbjy/gsk/registry"
+)
+
+type msgType int
+
+const (
+	msgPing    msgType = iota + 1 // 直接探测
+	msgPingReq                    // 请求其他节点间接探测
+	msgAck                        // 探测应答
+	msgSync                       // 发送全量数据,并请求对方的全量数据
+	msgSyncAck                    // 全量数据应答
+	msgGossip                     // 仅用于传播消息,不需要应答
+)
+
+type memberState int
+
+const (
+	stateAlive memberState = iota
+	stateSuspect
+	stateDead
+)
+
+// member 集群成员,Incarnation只能由节点自己增加,用于反驳怀疑
+type member struct {
+	Name        string      `json:"name"`
+	Addr        string      `json:"addr"`
+	Incarnation uint64      `json:"inc"`
+	State       memberState `json:"state"`
+}
+
+// record 服务记录,Version由Owner维护,Service为nil表示已经注销
+type record struct {
+	Owner   string            `json:"owner"`
+	Version uint64            `json:"ver"`
+	Id      string            `json:"id"`
+	Service *registry.Service `json:"srv,omitempty"`
+}
+
+// message UDP消息,所有消息都可以携带需要传播的成员和服务信息
+type message struct {
+	Type     msgType  `json:"type"`
+	Seq      uint64   `json:"seq,omitempty"`
+	From     string   `json:"from"`
+	Target   string   `json:"target,omitempty"` // Ping,PingReq:探测的节点名
+	Addr     string   `json:"addr,omitempty"`   // PingReq:探测的节点地址
+	Members  []member `json:"members,omitempty"`
+	Services []record `json:"services,omitempty"`
+}
+
+func encode(m *message) ([]byte, error) {
+	return json.Marshal(m)
+}
+
+func decode(data []byte) (*message, error) {
+	m := &message{}
+	err := json.Unmarshal(data, m)
+	return m, err
+}
+
+// broadcast 等待传播的消息,同一个成员或者服务只保留最新的一条
+type broadcast struct {
+	key       string
+	member    *member
+	record    *record
+	transmits int
+}
+
+// broadcastQueue 优先传播发送次数最少的消息,超过次数后丢弃
+type broadcastQueue struct {
+	items map[string]*broadcast
+}
+
+func newBroadcastQueue() *broadcastQueue {
+	return &broadcastQueue{items: make(map[string]*broadcast)}
+}
+
+func (q *broadcastQueue) pushMember(m *member) {
+	cp := *m
+	q.items["m:"+m.Name] = &broadcast{key: "m:" + m.Name, member: &cp}
+}
+
+func (q *broadcastQueue) pushRecord(r *record) {
+	cp := *r
+	q.items["r:"+r.Id] = &broadcast{key: "r:" + r.Id, record: &cp}
+}
+
+func (q *broadcastQueue) Len() int {
+	return len(q.items)
+}
+
+// fill 填充消息,limit为每条消息最多发送的次数
+func (q *broadcastQueue) fill(msg *message, max int, limit int) {
+	if len(q.items) == 0 || max <= 0 {
+		return
+	}
+
+	list := make([]*broadcast, 0, len(q.items))
+	for _, b := range q.items {
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].transmits < list[j].transmits
+	})
+
+	if len(list) > max {
+		list = list[:max]
+	}
+
+	for _, b := range list {
+		if b.member != nil {
+			msg.Members = append(msg.Members, *b.member)
+		} else {
+			msg.Services = append(msg.Services, *b.record)
+		}
+
+		b.transmits++
+		if b.transmits >= limit {
+			delete(q.items, b.key)
+		}
+	}
+}
+
+// retransmitLimit 集群越大,需要转发的次数越多
+func retransmitLimit(mult int, n int) int {
+	limit := mult * int(math.Ceil(math.Log10(float64(n+1))))
+	if limit < 1 {
+		limit = 1
+	}
+
+	return limit
+}
@@ -0,0 +1,96 @@
+package gossip
+
+import "time"
+
+const (
+	DefaultBind             = "127.0.0.1:0"
+	DefaultProbeInterval    = time.Second
+	DefaultProbeTimeout     = time.Millisecond * 500
+	DefaultSuspicionTimeout = time.Second * 5
+	DefaultSyncInterval     = time.Second * 30
+	DefaultIndirectChecks   = 3
+	DefaultRetransmitMult   = 4
+	DefaultMaxPiggyback     = 16
+)
+
+type Options struct {
+	Name             string        // 节点名,需要全局唯一,默认随机生成
+	Bind             string        // UDP监听地址
+	Advertise        string        // 通知给其他节点的地址,默认使用监听地址
+	Seeds            []string      // 启动时用于加入集群的节点地址
+	ProbeInterval    time.Duration // 探测周期,每个周期探测一个节点
+	ProbeTimeout     time.Duration // 直接探测超时时间,超时后发起间接探测
+	SuspicionTimeout time.Duration // 怀疑状态超时后标记为死亡
+	SyncInterval     time.Duration // 定期与随机节点同步全量数据,用于修复丢失的消息
+	IndirectChecks   int           // 间接探测的节点数
+	RetransmitMult   int           // 每条消息的转发次数为RetransmitMult*log10(n+1)
+	MaxPiggyback     int           // 每个包最多携带的消息数
+}
+
+type Option func(o *Options)
+
+func Name(name string) Option {
+	return func(o *Options) {
+		o.Name = name
+	}
+}
+
+func Bind(addr string) Option {
+	return func(o *Options) {
+		o.Bind = addr
+	}
+}
+
+func Advertise(addr string) Option {
+	return func(o *Options) {
+		o.Advertise = addr
+	}
+}
+
+func Seeds(addrs ...string) Option {
+	return func(o *Options) {
+		o.Seeds = addrs
+	}
+}
+
+func ProbeInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.ProbeInterval = d
+	}
+}
+
+func ProbeTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.ProbeTimeout = d
+	}
+}
+
+func SuspicionTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.SuspicionTimeout = d
+	}
+}
+
+func SyncInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.SyncInterval = d
+	}
+}
+
+func IndirectChecks(n int) Option {
+	return func(o *Options) {
+		o.IndirectChecks = n
+	}
+}
+
+func RetransmitMult(n int) Option {
+	return func(o *Options) {
+		o.RetransmitMult = n
+	}
+}
+
+func MaxPiggyback(n int) Option {
+	return func(o *Options) {
+		o.MaxPiggyback = n
+	}
+}
@@ -0,0 +1,355 @@
+package gossip
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/jeckbjy/gsk/registry"
+)
+
+// maxPacketSize UDP包最大长度
+const maxPacketSize = 65507
+
+func (g *gossipRegistry) readLoop() {
+	defer g.wg.Done()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := g.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-g.quit:
+				return
+			default:
+				continue
+			}
+		}
+
+		msg, err := decode(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		g.handle(msg, from)
+	}
+}
+
+func (g *gossipRegistry) handle(msg *message, from *net.UDPAddr) {
+	g.mux.Lock()
+	if g.closed {
+		g.mux.Unlock()
+		return
+	}
+
+	events := g.merge(msg)
+	var reply []*message
+	var replyTo string
+	switch msg.Type {
+	case msgPing:
+		// 节点重启后地址可能被复用,只应答发给自己的探测
+		if msg.Target == "" || msg.Target == g.self.Name {
+			ack := g.newMessage(msgAck)
+			ack.Seq = msg.Seq
+			reply = []*message{ack}
+			replyTo = from.String()
+		}
+	case msgPingReq:
+		// 代替对方探测目标节点,收到应答后转发
+		seq := g.nextSeq()
+		origin, originSeq := from.String(), msg.Seq
+		g.waitAck(seq, g.opts.ProbeTimeout, func() {
+			ack := g.newMessage(msgAck)
+			ack.Seq = originSeq
+			g.sendLocked(origin, ack)
+		})
+		ping := g.newMessage(msgPing)
+		ping.Seq = seq
+		ping.Target = msg.Target
+		reply = []*message{ping}
+		replyTo = msg.Addr
+	case msgAck:
+		if cb := g.acks[msg.Seq]; cb != nil {
+			delete(g.acks, msg.Seq)
+			cb()
+		}
+	case msgSync:
+		reply = g.fullState(msgSyncAck)
+		replyTo = from.String()
+	}
+
+	for _, m := range reply {
+		g.sendLocked(replyTo, m)
+	}
+	g.mux.Unlock()
+
+	g.notify(events)
+}
+
+// newMessage 创建消息,并附带需要传播的数据
+func (g *gossipRegistry) newMessage(t msgType) *message {
+	msg := &message{Type: t, From: g.self.Name}
+	g.queue.fill(msg, g.opts.MaxPiggyback, retransmitLimit(g.opts.RetransmitMult, len(g.members)+1))
+	return msg
+}
+
+// fullState 全量数据,包括自己,超过UDP包长度时拆分成多个消息,
+// 只有第一个消息的类型为t,其余的为msgGossip,避免对方多次应答Sync
+func (g *gossipRegistry) fullState(t msgType) []*message {
+	msg := &message{Type: t, From: g.self.Name}
+	msgs := []*message{msg}
+	header, _ := encode(msg)
+	// 空的Members和Services字段的长度
+	base := len(header) + len(`,"members":[],"services":[]`)
+	size := base
+
+	// reserve 预留n个字节,放不下时创建新的消息
+	reserve := func(v interface{}) {
+		data, _ := json.Marshal(v)
+		n := len(data) + 1 // 逗号
+		if size+n > maxPacketSize && (len(msg.Members) > 0 || len(msg.Services) > 0) {
+			msg = &message{Type: msgGossip, From: g.self.Name}
+			msgs = append(msgs, msg)
+			size = base
+		}
+		size += n
+	}
+
+	reserve(g.self)
+	msg.Members = append(msg.Members, *g.self)
+	for _, m := range g.members {
+		reserve(m)
+		msg.Members = append(msg.Members, *m)
+	}
+	for _, r := range g.records {
+		reserve(r)
+		msg.Services = append(msg.Services, *r)
+	}
+
+	return msgs
+}
+
+func (g *gossipRegistry) send(addr string, msg *message) {
+	data, err := encode(msg)
+	if err != nil {
+		log.Printf("[gossip] encode message fail, %+v", err)
+		return
+	}
+
+	// 单个服务数据过大时无法通过UDP发送
+	if len(data) > maxPacketSize {
+		log.Printf("[gossip] drop message to %s, type %d, size %d exceeds %d", addr, msg.Type, len(data), maxPacketSize)
+		return
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return
+	}
+
+	_, _ = g.conn.WriteToUDP(data, udpAddr)
+}
+
+// sendLocked 持有锁时发送,UDP写入不会阻塞
+func (g *gossipRegistry) sendLocked(addr string, msg *message) {
+	g.send(addr, msg)
+}
+
+func (g *gossipRegistry) nextSeq() uint64 {
+	g.seq++
+	return g.seq
+}
+
+// waitAck 注册应答回调,超时后自动删除
+func (g *gossipRegistry) waitAck(seq uint64, timeout time.Duration, cb func()) {
+	g.acks[seq] = cb
+	time.AfterFunc(timeout, func() {
+		g.mux.Lock()
+		delete(g.acks, seq)
+		g.mux.Unlock()
+	})
+}
+
+// sync 与指定节点交换全量数据
+func (g *gossipRegistry) sync(addr string) {
+	g.mux.Lock()
+	msgs := g.fullState(msgSync)
+	g.mux.Unlock()
+	for _, msg := range msgs {
+		g.send(addr, msg)
+	}
+}
+
+func (g *gossipRegistry) syncLoop() {
+	defer g.wg.Done()
+	if g.opts.SyncInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(g.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.mux.Lock()
+			var addrs []string
+			for _, m := range g.members {
+				if m.State == stateAlive {
+					addrs = append(addrs, m.Addr)
+				}
+			}
+			g.mux.Unlock()
+
+			if len(addrs) > 0 {
+				g.sync(addrs[rand.Intn(len(addrs))])
+			}
+		case <-g.quit:
+			return
+		}
+	}
+}
+
+func (g *gossipRegistry) probeLoop() {
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(g.opts.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.expire()
+			g.probe()
+		case <-g.quit:
+			return
+		}
+	}
+}
+
+// nextTarget 随机打乱后轮询所有节点,保证每个节点在有限时间内都会被探测到
+func (g *gossipRegistry) nextTarget() *member {
+	for i := 0; i < 2; i++ {
+		for len(g.probes) > 0 {
+			name := g.probes[0]
+			g.probes = g.probes[1:]
+			if m := g.members[name]; m != nil && m.State != stateDead {
+				return m
+			}
+		}
+
+		for name, m := range g.members {
+			if m.State != stateDead {
+				g.probes = append(g.probes, name)
+			}
+		}
+		rand.Shuffle(len(g.probes), func(i, j int) {
+			g.probes[i], g.probes[j] = g.probes[j], g.probes[i]
+		})
+	}
+
+	return nil
+}
+
+// probe 直接探测,超时后间接探测,都失败则标记为suspect
+func (g *gossipRegistry) probe() {
+	g.mux.Lock()
+	target := g.nextTarget()
+	if target == nil || g.closed {
+		g.mux.Unlock()
+		return
+	}
+
+	acked := make(chan struct{}, 1)
+	seq := g.nextSeq()
+	g.waitAck(seq, g.opts.ProbeInterval, func() {
+		acked <- struct{}{}
+	})
+	ping := g.newMessage(msgPing)
+	ping.Seq = seq
+	ping.Target = target.Name
+	g.sendLocked(target.Addr, ping)
+	g.mux.Unlock()
+
+	select {
+	case <-acked:
+		return
+	case <-time.After(g.opts.ProbeTimeout):
+	case <-g.quit:
+		return
+	}
+
+	g.mux.Lock()
+	var peers []*member
+	for _, m := range g.members {
+		if m.State == stateAlive && m.Name != target.Name {
+			peers = append(peers, m)
+		}
+	}
+	rand.Shuffle(len(peers), func(i, j int) {
+		peers[i], peers[j] = peers[j], peers[i]
+	})
+	if len(peers) > g.opts.IndirectChecks {
+		peers = peers[:g.opts.IndirectChecks]
+	}
+	for _, p := range peers {
+		req := g.newMessage(msgPingReq)
+		req.Seq = seq
+		req.Target = target.Name
+		req.Addr = target.Addr
+		g.sendLocked(p.Addr, req)
+	}
+	g.mux.Unlock()
+
+	wait := g.opts.ProbeInterval - g.opts.ProbeTimeout
+	if wait <= 0 {
+		wait = g.opts.ProbeTimeout
+	}
+
+	select {
+	case <-acked:
+		return
+	case <-time.After(wait):
+	case <-g.quit:
+		return
+	}
+
+	g.mux.Lock()
+	var events []*registry.Event
+	if cur := g.members[target.Name]; cur != nil && cur.State == stateAlive && cur.Incarnation == target.Incarnation {
+		suspect := *cur
+		suspect.State = stateSuspect
+		events = g.setMember(&suspect)
+	}
+	g.mux.Unlock()
+	g.notify(events)
+}
+
+// expire 怀疑超时的节点标记为死亡,并删除过期的墓碑
+func (g *gossipRegistry) expire() {
+	g.mux.Lock()
+	now := time.Now()
+	var events []*registry.Event
+	for name, since := range g.since {
+		m := g.members[name]
+		if m != nil && m.State == stateSuspect && now.Sub(since) >= g.opts.SuspicionTimeout {
+			dead := *m
+			dead.State = stateDead
+			events = append(events, g.setMember(&dead)...)
+		}
+	}
+
+	// 墓碑至少保留到消息传播完成
+	ttl := g.opts.SuspicionTimeout + g.opts.SyncInterval
+	for id, t := range g.deleted {
+		if now.Sub(t) >= ttl {
+			delete(g.deleted, id)
+			if r := g.records[id]; r != nil && r.Service == nil {
+				delete(g.records, id)
+			}
+		}
+	}
+	g.mux.Unlock()
+
+	g.notify(events)
+}
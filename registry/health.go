@@ -0,0 +1,23 @@
+package registry
+
+import (
+	"context"
+	"net"
+)
+
+// Checker 主动健康检查,返回错误表示服务不健康
+// 检查失败的服务依然会通过Query和Watch返回,但是会设置Unhealthy,Selector会跳过这些服务
+type Checker func(ctx context.Context, srv *Service) error
+
+// TCPChecker 通过TCP连接检测服务是否可用
+func TCPChecker() Checker {
+	return func(ctx context.Context, srv *Service) error {
+		d := net.Dialer{}
+		conn, err := d.DialContext(ctx, "tcp", srv.Addr)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+}
@@ -0,0 +1,418 @@
+package kv
+
+import (
+	"context"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/jeckbjy/gsk/registry"
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/util/errorx"
+)
+
+const (
+	DefaultRoot      = "/services"
+	DefaultTTL       = time.Second * 10
+	DefaultCheckTime = time.Second * 5
+	DefaultTimeout   = time.Second * 3
+)
+
+// New 基于store.Store创建Registry,可以使用etcd,store/raft,store/file等任意存储
+// 服务保存在Root/<name>/<id>中,值为Service.Marshal的结果
+// 存储需要实现store.Transactional才能支持TTL,否则只能定期重新写入,异常退出时服务不会被删除
+// Close时不会关闭store,需要调用者自己关闭
+func New(s store.Store, opts ...registry.Option) registry.Registry {
+	o := registry.Options{Root: DefaultRoot, TTL: DefaultTTL, Timeout: DefaultTimeout, CheckTime: DefaultCheckTime}
+	o.Init(opts...)
+	if o.Interval <= 0 {
+		o.Interval = o.TTL / 3
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &kvRegistry{
+		opts:      o,
+		store:     s,
+		entries:   make(map[string]*entry),
+		services:  make(map[string]*registry.Service),
+		unhealthy: make(map[string]bool),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	return r
+}
+
+// entry 本地注册的服务
+type entry struct {
+	srv   *registry.Service
+	lease store.LeaseID
+}
+
+type watcher struct {
+	names map[string]bool
+	cb    registry.Callback
+}
+
+// kvRegistry 基于KV存储的服务注册与发现
+// 注册: 支持租约时,服务绑定到租约上,定期续约,租约过期后重新注册
+// 监听: 监听Root前缀,将store.Event转换为registry.Event
+// 健康检查: 设置Checker后,定期检查监听到的服务,不健康的服务会设置Unhealthy并产生Upsert事件
+type kvRegistry struct {
+	opts      registry.Options
+	store     store.Store
+	rmux      sync.Mutex                   // 注册相关
+	entries   map[string]*entry            // 本地注册的服务
+	running   bool                         // 是否已经启动续约
+	mux       sync.Mutex                   // 监听相关
+	nmux      sync.Mutex                   // 保证事件按照状态修改的顺序通知,需要在mux之前加锁
+	watchers  []*watcher                   //
+	watching  bool                         // 是否已经开始监听
+	services  map[string]*registry.Service // 监听到的所有服务
+	unhealthy map[string]bool              // 健康检查失败的服务
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func (r *kvRegistry) Name() string {
+	return "kv"
+}
+
+func (r *kvRegistry) key(name, id string) string {
+	return path.Join(r.opts.Root, name, id)
+}
+
+func (r *kvRegistry) Register(srv *registry.Service) error {
+	r.rmux.Lock()
+	defer r.rmux.Unlock()
+
+	e := r.entries[srv.Id]
+	if e == nil {
+		e = &entry{srv: srv}
+		r.entries[srv.Id] = e
+	} else {
+		e.srv = srv
+	}
+
+	if err := r.put(e); err != nil {
+		return err
+	}
+
+	if !r.running {
+		r.running = true
+		go r.keepAlive()
+	}
+
+	return nil
+}
+
+func (r *kvRegistry) Unregister(serviceID string) error {
+	r.rmux.Lock()
+	defer r.rmux.Unlock()
+
+	e := r.entries[serviceID]
+	if e == nil {
+		return errorx.ErrNotFound
+	}
+
+	delete(r.entries, serviceID)
+	return r.remove(e)
+}
+
+// put 写入服务,租约失效时重新申请
+func (r *kvRegistry) put(e *entry) error {
+	key := r.key(e.srv.Name, e.srv.Id)
+	value := []byte(e.srv.Marshal())
+	tx, ok := r.store.(store.Transactional)
+	if !ok {
+		return r.store.Put(r.ctx, key, value)
+	}
+
+	for i := 0; i < 2; i++ {
+		if e.lease == 0 {
+			lease, err := tx.Grant(r.ctx, r.opts.TTL)
+			if err != nil {
+				return err
+			}
+			e.lease = lease
+		}
+
+		_, err := tx.Txn(r.ctx, &store.Txn{Then: []store.Op{store.OpPutWithLease(key, value, e.lease)}})
+		if err != store.ErrLeaseNotFound {
+			return err
+		}
+		e.lease = 0
+	}
+
+	return store.ErrLeaseNotFound
+}
+
+func (r *kvRegistry) remove(e *entry) error {
+	if e.lease != 0 {
+		if tx, ok := r.store.(store.Transactional); ok {
+			if err := tx.Revoke(r.ctx, e.lease); err == nil {
+				return nil
+			}
+		}
+	}
+
+	err := r.store.Delete(r.ctx, r.key(e.srv.Name, e.srv.Id))
+	if err == store.ErrNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// keepAlive 定期续约,不支持租约时重新写入
+func (r *kvRegistry) keepAlive() {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.rmux.Lock()
+			tx, ok := r.store.(store.Transactional)
+			for _, e := range r.entries {
+				if ok && e.lease != 0 && tx.KeepAlive(r.ctx, e.lease) == nil {
+					continue
+				}
+
+				// 租约已经过期,服务可能已经被删除,需要重新注册
+				e.lease = 0
+				_ = r.put(e)
+			}
+			r.rmux.Unlock()
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *kvRegistry) Query(name string, opts *registry.QueryOptions) ([]*registry.Service, error) {
+	prefix := r.opts.Root + "/"
+	if name != "" {
+		prefix = r.key(name, "") + "/"
+	}
+
+	kvs, err := r.store.List(r.ctx, prefix)
+	if err != nil && err != store.ErrNotFound {
+		return nil, err
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	results := make([]*registry.Service, 0, len(kvs))
+	for _, kv := range kvs {
+		srv, err := registry.Unmarshal(string(kv.Value))
+		if err != nil {
+			continue
+		}
+
+		if name != "" && srv.Name != name {
+			continue
+		}
+
+		if !srv.Match(opts) {
+			continue
+		}
+
+		srv.Unhealthy = r.unhealthy[srv.Id]
+		results = append(results, srv)
+	}
+
+	return results, nil
+}
+
+func (r *kvRegistry) Watch(names []string, cb registry.Callback) error {
+	w := &watcher{cb: cb}
+	if len(names) > 0 {
+		w.names = make(map[string]bool)
+		for _, name := range names {
+			w.names[name] = true
+		}
+	}
+
+	r.mux.Lock()
+	r.watchers = append(r.watchers, w)
+	watching := r.watching
+	r.watching = true
+	r.mux.Unlock()
+
+	if watching {
+		return nil
+	}
+
+	if err := r.startWatch(); err != nil {
+		r.mux.Lock()
+		r.watching = false
+		r.mux.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// startWatch 监听所有服务,并加载已经存在的服务用于健康检查
+func (r *kvRegistry) startWatch() error {
+	if err := r.store.Watch(r.ctx, r.opts.Root+"/", r.onEvent, store.Prefix()); err != nil {
+		return err
+	}
+
+	services, err := r.Query("", nil)
+	if err != nil {
+		return err
+	}
+
+	r.mux.Lock()
+	for _, srv := range services {
+		if _, ok := r.services[srv.Id]; !ok {
+			r.services[srv.Id] = srv
+		}
+	}
+	r.mux.Unlock()
+
+	if r.opts.Checker != nil && r.opts.CheckTime > 0 {
+		go r.checkLoop()
+	}
+
+	return nil
+}
+
+func (r *kvRegistry) onEvent(ev *store.Event) {
+	id := path.Base(ev.Data.Key)
+
+	r.nmux.Lock()
+	defer r.nmux.Unlock()
+
+	r.mux.Lock()
+	var rev *registry.Event
+	switch ev.Type {
+	case store.PUT:
+		srv, err := registry.Unmarshal(string(ev.Data.Value))
+		if err != nil {
+			r.mux.Unlock()
+			return
+		}
+		srv.Unhealthy = r.unhealthy[srv.Id]
+		r.services[srv.Id] = srv
+		rev = &registry.Event{Id: srv.Id, Type: registry.EventUpsert, Service: srv}
+	case store.DELETE:
+		srv := r.services[id]
+		if srv == nil && ev.Prev != nil {
+			srv, _ = registry.Unmarshal(string(ev.Prev.Value))
+		}
+		if srv == nil {
+			// 通过key解析服务名
+			srv = &registry.Service{Id: id, Name: path.Base(path.Dir(ev.Data.Key))}
+		}
+		delete(r.services, id)
+		delete(r.unhealthy, id)
+		rev = &registry.Event{Id: id, Type: registry.EventDelete, Service: srv}
+	}
+	r.mux.Unlock()
+
+	r.notify(rev)
+}
+
+func (r *kvRegistry) notify(ev *registry.Event) {
+	r.mux.Lock()
+	watchers := r.watchers
+	r.mux.Unlock()
+
+	for _, w := range watchers {
+		if w.names == nil || w.names[ev.Service.Name] {
+			w.cb(ev)
+		}
+	}
+}
+
+// checkLoop 定期并行检查所有服务,状态变化时通知
+func (r *kvRegistry) checkLoop() {
+	ticker := time.NewTicker(r.opts.CheckTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.check()
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *kvRegistry) check() {
+	r.mux.Lock()
+	services := make([]*registry.Service, 0, len(r.services))
+	for _, srv := range r.services {
+		services = append(services, srv)
+	}
+	r.mux.Unlock()
+
+	results := make([]error, len(services))
+	wg := sync.WaitGroup{}
+	for i, srv := range services {
+		wg.Add(1)
+		go func(i int, srv *registry.Service) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.ctx, r.opts.Timeout)
+			results[i] = r.opts.Checker(ctx, srv)
+			cancel()
+		}(i, srv)
+	}
+	wg.Wait()
+
+	// 修改状态和通知期间持有nmux,防止并发的DELETE先于Upsert通知,导致已经删除的服务被重新加入
+	r.nmux.Lock()
+	defer r.nmux.Unlock()
+
+	var events []*registry.Event
+	r.mux.Lock()
+	for i, srv := range services {
+		unhealthy := results[i] != nil
+		// 检查期间服务可能已经被删除或者更新
+		if r.services[srv.Id] != srv || r.unhealthy[srv.Id] == unhealthy {
+			continue
+		}
+
+		if unhealthy {
+			r.unhealthy[srv.Id] = true
+		} else {
+			delete(r.unhealthy, srv.Id)
+		}
+
+		// 拷贝一份,防止修改已经通知出去的数据
+		clone := *srv
+		clone.Unhealthy = unhealthy
+		r.services[srv.Id] = &clone
+		events = append(events, &registry.Event{Id: srv.Id, Type: registry.EventUpsert, Service: &clone})
+	}
+	r.mux.Unlock()
+
+	for _, ev := range events {
+		r.notify(ev)
+	}
+}
+
+// Close 注销所有服务,并停止监听,不会关闭store
+func (r *kvRegistry) Close() error {
+	r.rmux.Lock()
+	entries := r.entries
+	r.entries = make(map[string]*entry)
+	r.rmux.Unlock()
+
+	var err error
+	for _, e := range entries {
+		if e2 := r.remove(e); e2 != nil {
+			err = e2
+		}
+	}
+
+	r.cancel()
+	r.mux.Lock()
+	r.watchers = nil
+	r.mux.Unlock()
+	return err
+}
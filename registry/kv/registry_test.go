@@ -0,0 +1,192 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/registry"
+	"github.com/jeckbjy/gsk/selector"
+	rselector "github.com/jeckbjy/gsk/selector/registry"
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/store/memory"
+)
+
+type events struct {
+	mux  sync.Mutex
+	list []*registry.Event
+}
+
+func (e *events) add(ev *registry.Event) {
+	e.mux.Lock()
+	e.list = append(e.list, ev)
+	e.mux.Unlock()
+}
+
+// wait 等待满足条件的事件
+func (e *events) wait(t *testing.T, fn func(ev *registry.Event) bool) *registry.Event {
+	deadline := time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		e.mux.Lock()
+		for _, ev := range e.list {
+			if fn(ev) {
+				e.mux.Unlock()
+				return ev
+			}
+		}
+		e.mux.Unlock()
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	t.Fatal("wait event timeout")
+	return nil
+}
+
+func TestRegistry(t *testing.T) {
+	s := memory.New()
+	defer s.Close()
+
+	r1 := New(s, registry.WithTTL(time.Millisecond*200))
+	r2 := New(s)
+	defer r2.Close()
+
+	evs := &events{}
+	if err := r2.Watch([]string{"echo"}, evs.add); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = r1.Register(registry.NewService("echo", "echo-1", "127.0.0.1:1", map[string]string{"zone": "a"}))
+	_ = r1.Register(registry.NewService("echo", "echo-2", "127.0.0.1:2", nil))
+	_ = r1.Register(registry.NewService("other", "other-1", "127.0.0.1:3", nil))
+
+	services, err := r2.Query("echo", nil)
+	if err != nil || len(services) != 2 {
+		t.Fatalf("bad query, %+v, %+v", services, err)
+	}
+	services, _ = r2.Query("echo", &registry.QueryOptions{Tags: map[string]string{"zone": "a"}})
+	if len(services) != 1 || services[0].Id != "echo-1" {
+		t.Fatalf("bad filter, %+v", services)
+	}
+	if all, _ := r2.Query("", nil); len(all) != 3 {
+		t.Fatalf("bad query all, %+v", all)
+	}
+
+	evs.wait(t, func(ev *registry.Event) bool { return ev.Type == registry.EventUpsert && ev.Id == "echo-2" })
+
+	// 续约后不会过期
+	time.Sleep(time.Millisecond * 400)
+	if services, _ := r2.Query("echo", nil); len(services) != 2 {
+		t.Fatalf("expired after keepalive, %+v", services)
+	}
+
+	if err := r1.Unregister("echo-2"); err != nil {
+		t.Fatal(err)
+	}
+	ev := evs.wait(t, func(ev *registry.Event) bool { return ev.Type == registry.EventDelete && ev.Id == "echo-2" })
+	if ev.Service == nil || ev.Service.Name != "echo" {
+		t.Fatalf("bad delete event, %+v", ev)
+	}
+
+	// 模拟进程异常退出,不再续约,租约过期后自动删除
+	r1.(*kvRegistry).cancel()
+	evs.wait(t, func(ev *registry.Event) bool { return ev.Type == registry.EventDelete && ev.Id == "echo-1" })
+	if services, _ := r2.Query("", nil); len(services) != 0 {
+		t.Fatalf("not expired, %+v", services)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	s := memory.New()
+	defer s.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// 获取一个没有监听的端口
+	l2, _ := net.Listen("tcp", "127.0.0.1:0")
+	bad := l2.Addr().String()
+	_ = l2.Close()
+
+	r := New(s, registry.WithChecker(registry.TCPChecker(), time.Millisecond*20), registry.WithTimeout(time.Millisecond*100))
+	defer r.Close()
+	_ = r.Register(registry.NewService("echo", "good", l.Addr().String(), nil))
+	_ = r.Register(registry.NewService("echo", "bad", bad, nil))
+
+	sel := rselector.New(r)
+	defer sel.Close()
+	if _, err := sel.Select("echo", &selector.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	evs := &events{}
+	_ = r.Watch(nil, evs.add)
+	evs.wait(t, func(ev *registry.Event) bool { return ev.Id == "bad" && ev.Service.Unhealthy })
+
+	services, _ := r.Query("echo", nil)
+	for _, srv := range services {
+		if srv.Unhealthy != (srv.Id == "bad") {
+			t.Fatalf("bad health, %+v", srv)
+		}
+	}
+
+	// Selector跳过不健康的节点
+	for i := 0; i < 10; i++ {
+		next, err := sel.Select("echo", &selector.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node, _ := next(); node.Id() != "good" {
+			t.Fatalf("select unhealthy node, %+v", node.Id())
+		}
+	}
+
+	// 指定节点时忽略健康状态
+	next, err := sel.Select("echo", &selector.Options{Node: "bad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node, _ := next(); node.Id() != "bad" {
+		t.Fatalf("bad node, %+v", node.Id())
+	}
+}
+
+// TestCheckDelete 健康检查与删除并发时,删除事件必须最后通知,否则已经删除的服务会被重新加入
+func TestCheckDelete(t *testing.T) {
+	checker := func(ctx context.Context, srv *registry.Service) error {
+		return errors.New("unhealthy")
+	}
+	r := New(nil, registry.WithChecker(checker, time.Second)).(*kvRegistry)
+	defer r.cancel()
+
+	evs := &events{}
+	r.watchers = append(r.watchers, &watcher{cb: evs.add})
+	srv := registry.NewService("echo", "echo-1", "127.0.0.1:1", nil)
+	del := &store.Event{Type: store.DELETE, Data: &store.KV{Key: r.key(srv.Name, srv.Id)}}
+	for i := 0; i < 100; i++ {
+		r.mux.Lock()
+		r.services[srv.Id] = srv
+		r.mux.Unlock()
+
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.check()
+		}()
+		r.onEvent(del)
+		wg.Wait()
+
+		evs.mux.Lock()
+		last := evs.list[len(evs.list)-1]
+		evs.mux.Unlock()
+		if last.Type != registry.EventDelete {
+			t.Fatalf("upsert after delete, %+v", last)
+		}
+	}
+}
@@ -95,7 +95,7 @@ func (r *localRegistry) Unregister(serviceID string) error {
 	return errorx.ErrNotFound
 }
 
-func (r *localRegistry) Query(name string, filters map[string]string) ([]*registry.Service, error) {
+func (r *localRegistry) Query(name string, opts *registry.QueryOptions) ([]*registry.Service, error) {
 	// 遍历目录
 	now := time.Now().UnixNano() / int64(time.Millisecond)
 	results := make([]*registry.Service, 0)
@@ -128,7 +128,7 @@ func (r *localRegistry) Query(name string, filters map[string]string) ([]*regist
 			return nil
 		}
 
-		if !entry.Srv.Match(filters) {
+		if !entry.Srv.Match(opts) {
 			return nil
 		}
 
@@ -16,6 +16,8 @@ type Options struct {
 	TTL       time.Duration
 	Interval  time.Duration
 	Root      string
+	Checker   Checker       // 健康检查,nil表示不检查
+	CheckTime time.Duration // 健康检查间隔
 }
 
 func (o *Options) Init(opts ...Option) {
@@ -68,3 +70,11 @@ func WithRoot(r string) Option {
 		o.Root = r
 	}
 }
+
+// WithChecker 设置主动健康检查,interval为检查间隔
+func WithChecker(c Checker, interval time.Duration) Option {
+	return func(o *Options) {
+		o.Checker = c
+		o.CheckTime = interval
+	}
+}
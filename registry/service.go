@@ -1,31 +1,72 @@
 package registry
 
-import "encoding/json"
+import (
+	"encoding/json"
+)
+
+// QueryOptions Query的过滤条件,多个条件表示且的关系,为空的条件会被忽略
+type QueryOptions struct {
+	Namespace string            // 命名空间,完全匹配
+	Zone      string            // 可用区,完全匹配
+	Version   string            // 版本范围,格式见MatchVersion,比如">=1.2.0,<2.0.0"
+	Tags      map[string]string // Tags,每个key都需要完全匹配
+}
 
 func NewService(name string, id string, addr string, tags map[string]string) *Service {
 	s := &Service{Id: id, Addr: addr, Name: name, Tags: tags}
 	return s
 }
 
-// TODO:添加其他信息,比如Version,Zone,Endpoint等信息
+// Endpoint 服务提供的消息处理函数,由Server根据Router自动填充
+type Endpoint struct {
+	Name   string `json:"name,omitempty"`   // 请求消息名
+	Method string `json:"method,omitempty"` // 方法名
+	ID     int    `json:"id,omitempty"`     // 消息ID
+}
+
 type Service struct {
-	Id   string            `json:"id"`
-	Addr string            `json:"addr"`
-	Name string            `json:"name"`
-	Tags map[string]string `json:"tags"`
+	Id        string            `json:"id"`
+	Addr      string            `json:"addr"`
+	Name      string            `json:"name"`
+	Tags      map[string]string `json:"tags"`
+	Version   string            `json:"version,omitempty"`   // 服务版本,比如1.2.0
+	Namespace string            `json:"namespace,omitempty"` // 命名空间,用于隔离不同环境
+	Zone      string            `json:"zone,omitempty"`      // 可用区,用于就近访问
+	Weight    int               `json:"weight,omitempty"`    // 权重,0表示使用默认权重
+	Endpoints []*Endpoint       `json:"endpoints,omitempty"` // 提供的消息处理函数
+	Unhealthy bool              `json:"-"`                   // 健康检查失败,由Registry设置,不会序列化
 }
 
-/// 检测Service是否完全满足filter条件
-func (s *Service) Match(filters map[string]string) bool {
-	if len(filters) == 0 {
+// HasEndpoint 查询是否提供了某个消息处理函数,name可以是消息名或者方法名
+func (s *Service) HasEndpoint(name string) bool {
+	for _, ep := range s.Endpoints {
+		if ep.Name == name || ep.Method == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+/// 检测Service是否完全满足过滤条件,opts为nil时总是满足
+func (s *Service) Match(opts *QueryOptions) bool {
+	if opts == nil {
 		return true
 	}
 
-	if len(s.Tags) < len(filters) {
+	if opts.Namespace != "" && s.Namespace != opts.Namespace {
+		return false
+	}
+
+	if opts.Zone != "" && s.Zone != opts.Zone {
+		return false
+	}
+
+	if opts.Version != "" && !MatchVersion(s.Version, opts.Version) {
 		return false
 	}
 
-	for k, v := range filters {
+	for k, v := range opts.Tags {
 		if s.Tags[k] != v {
 			return false
 		}
@@ -0,0 +1,65 @@
+package registry
+
+import "testing"
+
+func TestCompareVersion(t *testing.T) {
+	cases := []struct {
+		a, b string
+		r    int
+	}{
+		{"1.2.0", "1.2", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"1.0.0-rc1", "1.0.0", -1},
+		{"1.0.0-rc1", "1.0.0-rc2", -1},
+		{"1.0.0-rc10", "1.0.0-rc2", 1},
+		{"1.0.0-alpha.10", "1.0.0-alpha.9", 1},
+		{"1.0.0-alpha.1", "1.0.0-alpha", 1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta", "1.0.0-alpha.2", 1},
+		{"1.0.0+build1", "1.0.0", 0},
+		{"2", "1.99.99", 1},
+	}
+
+	for _, c := range cases {
+		if r := CompareVersion(c.a, c.b); r != c.r {
+			t.Errorf("compare %s %s, expect %d, got %d", c.a, c.b, c.r, r)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	srv := NewService("echo", "echo-1", "127.0.0.1:1", map[string]string{"group": "a"})
+	srv.Namespace = "prod"
+	srv.Version = "1.4.2"
+
+	cases := []struct {
+		opts *QueryOptions
+		ok   bool
+	}{
+		{nil, true},
+		{&QueryOptions{}, true},
+		{&QueryOptions{Tags: map[string]string{"group": "a"}}, true},
+		{&QueryOptions{Tags: map[string]string{"group": "b"}}, false},
+		{&QueryOptions{Namespace: "prod"}, true},
+		{&QueryOptions{Namespace: "test"}, false},
+		{&QueryOptions{Zone: "a"}, false},
+		{&QueryOptions{Version: ">=1.2.0,<2.0.0"}, true},
+		{&QueryOptions{Version: ">=1.2.0 <1.4.2"}, false},
+		{&QueryOptions{Version: "1.4.2", Namespace: "prod", Tags: map[string]string{"group": "a"}}, true},
+	}
+
+	for _, c := range cases {
+		if srv.Match(c.opts) != c.ok {
+			t.Errorf("match %+v, expect %v", c.opts, c.ok)
+		}
+	}
+
+	if !MatchVersion("1.0.0-rc10", ">1.0.0-rc2") {
+		t.Error("rc10 should be newer than rc2")
+	}
+
+	if MatchVersion("", ">=1.0.0") {
+		t.Error("empty version should not match")
+	}
+}
@@ -0,0 +1,220 @@
+package registry
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersion 比较两个版本号,a<b返回-1,a==b返回0,a>b返回1
+// 版本格式类似semver:[v]major.minor.patch[-prerelease][+build],缺少的部分视为0,build信息会被忽略
+// 带有prerelease的版本小于正式版本,比如1.0.0-rc1 < 1.0.0
+func CompareVersion(a, b string) int {
+	va, pa := splitVersion(a)
+	vb, pb := splitVersion(b)
+
+	n := len(va)
+	if len(vb) > n {
+		n = len(vb)
+	}
+
+	for i := 0; i < n; i++ {
+		var x, y string
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+
+		if r := compareField(x, y); r != 0 {
+			return r
+		}
+	}
+
+	switch {
+	case pa == pb:
+		return 0
+	case pa == "":
+		return 1
+	case pb == "":
+		return -1
+	default:
+		return comparePrerelease(pa, pb)
+	}
+}
+
+// comparePrerelease 按照点号分隔逐段比较,纯数字的字段按照数值比较且小于非数字字段,
+// 非数字字段中的数字部分同样按照数值比较,比如rc2 < rc10,字段较少的版本更小
+func comparePrerelease(a, b string) int {
+	fa := strings.Split(a, ".")
+	fb := strings.Split(b, ".")
+	for i := 0; i < len(fa) && i < len(fb); i++ {
+		_, ea := strconv.ParseUint(fa[i], 10, 64)
+		_, eb := strconv.ParseUint(fb[i], 10, 64)
+		var r int
+		switch {
+		case ea == nil && eb == nil:
+			r = compareField(fa[i], fb[i])
+		case ea == nil:
+			r = -1
+		case eb == nil:
+			r = 1
+		default:
+			r = compareNatural(fa[i], fb[i])
+		}
+
+		if r != 0 {
+			return r
+		}
+	}
+
+	return compareInt(len(fa), len(fb))
+}
+
+// compareNatural 将字符串拆分成数字和非数字的片段依次比较,数字片段按照数值比较
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		ca, ra := nextChunk(a)
+		cb, rb := nextChunk(b)
+		if isDigit(ca[0]) && isDigit(cb[0]) {
+			if r := compareField(ca, cb); r != 0 {
+				return r
+			}
+			// 数值相同时,前导0较多的更大,保证结果稳定
+			if r := compareInt(len(ca), len(cb)); r != 0 {
+				return r
+			}
+		} else if r := strings.Compare(ca, cb); r != 0 {
+			return r
+		}
+		a, b = ra, rb
+	}
+
+	return compareInt(len(a), len(b))
+}
+
+func nextChunk(s string) (string, string) {
+	digit := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digit {
+		i++
+	}
+
+	return s[:i], s[i:]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func splitVersion(v string) ([]string, string) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexByte(v, '+'); i != -1 {
+		v = v[:i]
+	}
+
+	var pre string
+	if i := strings.IndexByte(v, '-'); i != -1 {
+		pre = v[i+1:]
+		v = v[:i]
+	}
+
+	if v == "" {
+		return nil, pre
+	}
+
+	return strings.Split(v, "."), pre
+}
+
+// compareField 数字按照数值比较,否则按照字符串比较
+func compareField(x, y string) int {
+	if x == "" {
+		x = "0"
+	}
+	if y == "" {
+		y = "0"
+	}
+
+	nx, ex := strconv.ParseUint(x, 10, 64)
+	ny, ey := strconv.ParseUint(y, 10, 64)
+	if ex == nil && ey == nil {
+		switch {
+		case nx < ny:
+			return -1
+		case nx > ny:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	return strings.Compare(x, y)
+}
+
+// MatchVersion 判断版本是否满足约束条件
+// 多个条件使用逗号或者空格分隔,表示且的关系,比如">=1.2.0,<2.0.0"
+// 支持的操作符有:=,!=,>,>=,<,<=,不带操作符等价于=
+// 约束为空时总是满足,版本为空时总是不满足
+func MatchVersion(version string, constraint string) bool {
+	fields := strings.FieldsFunc(constraint, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	if len(fields) == 0 {
+		return true
+	}
+
+	if version == "" {
+		return false
+	}
+
+	for _, f := range fields {
+		op, v := splitOperator(f)
+		r := CompareVersion(version, v)
+		var ok bool
+		switch op {
+		case "=":
+			ok = r == 0
+		case "!=":
+			ok = r != 0
+		case ">":
+			ok = r > 0
+		case ">=":
+			ok = r >= 0
+		case "<":
+			ok = r < 0
+		case "<=":
+			ok = r <= 0
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func splitOperator(s string) (string, string) {
+	for _, op := range []string{">=", "<=", "!=", "==", ">", "<", "="} {
+		if strings.HasPrefix(s, op) {
+			if op == "==" {
+				op = "="
+			}
+			return op, s[len(op):]
+		}
+	}
+
+	return "=", s
+}
@@ -0,0 +1,57 @@
+// Package chash 一致性hash算法,用于按照key将请求固定分配到某个节点,节点增减时只有少量key需要重新映射
+//
+// Ring: hash环+虚拟节点,支持增量更新和权重,节点变化时只影响相邻区间
+// Maglev: Google Maglev查找表,分布更加均匀,查询O(1),节点变化时需要重建查找表
+// Jump: Google Jump Consistent Hash,不需要额外内存,但只有在末尾增删节点时才能保证最少的重新映射
+//
+// 所有实现都是并发安全的,并且只依赖节点集合,与添加顺序无关,因此不同客户端对同一个key总是得到相同的结果
+package chash
+
+// Hash 一致性hash接口,节点使用字符串ID标识
+// Add:添加节点,节点已经存在时更新权重,weight小于等于0时使用默认权重
+// Remove:删除节点
+// Get:查询key对应的节点,没有节点时返回空
+// Walk:按照优先顺序遍历key对应的节点,fn返回false时停止,用于有界负载时寻找下一个可用节点
+// Len:节点个数
+type Hash interface {
+	Add(id string, weight int)
+	Remove(id string)
+	Get(key uint64) string
+	Walk(key uint64, fn func(id string) bool)
+	Len() int
+}
+
+// DefaultWeight 默认权重,与selector.DefaultWeight保持一致
+const DefaultWeight = 100
+
+func normWeight(weight int) int {
+	if weight <= 0 {
+		return DefaultWeight
+	}
+
+	return weight
+}
+
+// Mix 将key打散,防止连续的key(比如uid)分布不均匀,splitmix64
+func Mix(key uint64) uint64 {
+	key += 0x9e3779b97f4a7c15
+	key = (key ^ (key >> 30)) * 0xbf58476d1ce4e5b9
+	key = (key ^ (key >> 27)) * 0x94d049bb133111eb
+	return key ^ (key >> 31)
+}
+
+// hashString FNV-1a 64,再经过Mix打散
+func hashString(s string, seed uint64) uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+
+	h := uint64(offset) ^ seed
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= prime
+	}
+
+	return Mix(h)
+}
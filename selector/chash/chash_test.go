@@ -0,0 +1,136 @@
+package chash
+
+import (
+	"fmt"
+	"testing"
+)
+
+const keys = 100000
+
+func newHashes() map[string]func() Hash {
+	return map[string]func() Hash{
+		"ring":   func() Hash { return NewRing(0) },
+		"maglev": func() Hash { return NewMaglev(0) },
+		"jump":   func() Hash { return NewJump() },
+	}
+}
+
+func fill(h Hash, n int) {
+	for i := 0; i < n; i++ {
+		h.Add(fmt.Sprintf("node-%03d", i), 0)
+	}
+}
+
+func TestBalance(t *testing.T) {
+	for name, fn := range newHashes() {
+		h := fn()
+		fill(h, 10)
+
+		counts := make(map[string]int)
+		for k := 0; k < keys; k++ {
+			counts[h.Get(uint64(k))]++
+		}
+
+		if len(counts) != 10 {
+			t.Fatalf("%s: bad nodes, %+v", name, counts)
+		}
+
+		// 每个节点的负载不能偏离平均值太多
+		for id, c := range counts {
+			if c < keys/10*7/10 || c > keys/10*13/10 {
+				t.Errorf("%s: unbalanced, %s=%d", name, id, c)
+			}
+		}
+	}
+}
+
+func TestMinimalRemap(t *testing.T) {
+	for name, fn := range newHashes() {
+		h := fn()
+		fill(h, 10)
+
+		before := make([]string, keys)
+		for k := range before {
+			before[k] = h.Get(uint64(k))
+		}
+
+		// 扩容一个节点,理论上只有1/11的key需要迁移,并且只能迁移到新节点
+		// Maglev为了保证均匀,会有少量key在旧节点之间迁移
+		h.Add("node-010", 0)
+		moved := 0
+		for k, old := range before {
+			if cur := h.Get(uint64(k)); cur != old {
+				moved++
+				if cur != "node-010" && name != "maglev" {
+					t.Fatalf("%s: key moved between old nodes, %s -> %s", name, old, cur)
+				}
+			}
+		}
+
+		if moved > keys/11*15/10 {
+			t.Errorf("%s: too many keys moved, %d", name, moved)
+		}
+	}
+}
+
+func TestOrderIndependent(t *testing.T) {
+	for name, fn := range newHashes() {
+		a, b := fn(), fn()
+		for i := 0; i < 8; i++ {
+			a.Add(fmt.Sprintf("node-%d", i), 0)
+			b.Add(fmt.Sprintf("node-%d", 7-i), 0)
+		}
+
+		for k := 0; k < 1000; k++ {
+			if a.Get(uint64(k)) != b.Get(uint64(k)) {
+				t.Fatalf("%s: result depends on add order", name)
+			}
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	for name, fn := range newHashes() {
+		h := fn()
+		fill(h, 5)
+
+		var first string
+		visited := make(map[string]bool)
+		h.Walk(42, func(id string) bool {
+			if first == "" {
+				first = id
+			}
+			if visited[id] {
+				t.Fatalf("%s: duplicate %s", name, id)
+			}
+			visited[id] = true
+			return true
+		})
+
+		if len(visited) != 5 || first != h.Get(42) {
+			t.Fatalf("%s: bad walk, %+v, %s", name, visited, first)
+		}
+
+		h.Remove("node-000")
+		if h.Len() != 4 {
+			t.Fatalf("%s: bad remove", name)
+		}
+	}
+}
+
+func TestWeight(t *testing.T) {
+	for _, h := range []Hash{NewRing(0), NewMaglev(0)} {
+		h.Add("a", 100)
+		h.Add("b", 300)
+
+		counts := make(map[string]int)
+		for k := 0; k < keys; k++ {
+			counts[h.Get(uint64(k))]++
+		}
+
+		ratio := float64(counts["b"]) / float64(counts["a"])
+		if ratio < 2.4 || ratio > 3.6 {
+			t.Errorf("bad weight ratio %v, %+v", ratio, counts)
+		}
+	}
+}
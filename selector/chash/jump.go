@@ -0,0 +1,112 @@
+package chash
+
+import (
+	"sort"
+	"sync"
+)
+
+// NewJump 创建Jump Consistent Hash
+func NewJump() *Jump {
+	return &Jump{weights: make(map[string]int)}
+}
+
+// Jump Google Jump Consistent Hash,节点按照ID排序后作为桶
+// 在末尾增加节点时,只有1/n的key会迁移到新节点,因此节点ID需要按照扩容顺序递增,比如game-001,game-002
+// 在中间删除节点会导致后面的节点整体移动,这种场景应使用Ring或者Maglev,不支持权重
+type Jump struct {
+	mux     sync.RWMutex
+	weights map[string]int
+	ids     []string
+}
+
+// JumpHash 返回key对应的桶,范围[0,buckets)
+func JumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+
+	return int(b)
+}
+
+func (h *Jump) Len() int {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	return len(h.ids)
+}
+
+func (h *Jump) Add(id string, weight int) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if _, ok := h.weights[id]; ok {
+		return
+	}
+
+	h.weights[id] = weight
+	i := sort.SearchStrings(h.ids, id)
+	h.ids = append(h.ids, "")
+	copy(h.ids[i+1:], h.ids[i:])
+	h.ids[i] = id
+}
+
+func (h *Jump) Remove(id string) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if _, ok := h.weights[id]; !ok {
+		return
+	}
+
+	delete(h.weights, id)
+	i := sort.SearchStrings(h.ids, id)
+	h.ids = append(h.ids[:i], h.ids[i+1:]...)
+}
+
+func (h *Jump) Get(key uint64) string {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	if len(h.ids) == 0 {
+		return ""
+	}
+
+	return h.ids[JumpHash(Mix(key), len(h.ids))]
+}
+
+// Walk 第一个节点为Get的结果,之后使用不同的种子重新计算,最后按照顺序遍历剩余节点
+func (h *Jump) Walk(key uint64, fn func(id string) bool) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	n := len(h.ids)
+	if n == 0 {
+		return
+	}
+
+	visited := make([]bool, n)
+	count := 0
+	key = Mix(key)
+	for i := 0; i < n && count < n; i++ {
+		idx := JumpHash(key, n)
+		key = Mix(key)
+		if visited[idx] {
+			continue
+		}
+		visited[idx] = true
+		count++
+		if !fn(h.ids[idx]) {
+			return
+		}
+	}
+
+	for idx := 0; idx < n && count < n; idx++ {
+		if visited[idx] {
+			continue
+		}
+		visited[idx] = true
+		count++
+		if !fn(h.ids[idx]) {
+			return
+		}
+	}
+}
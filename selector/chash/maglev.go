@@ -0,0 +1,157 @@
+package chash
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultTableSize 查找表大小,需要是质数,并且远大于节点数,通常为节点数的100倍以上
+const DefaultTableSize = 65537
+
+// NewMaglev 创建Maglev查找表,size需要是质数
+func NewMaglev(size int) *Maglev {
+	if size <= 0 {
+		size = DefaultTableSize
+	}
+
+	return &Maglev{size: size, weights: make(map[string]int)}
+}
+
+// Maglev Google Maglev一致性hash
+// 每个节点根据ID生成一个排列,按照排列轮流填充查找表,权重高的节点每轮填充更多的位置
+// 查找表在节点变化后的第一次查询时重建,节点变化时大部分key的映射保持不变
+type Maglev struct {
+	mux     sync.RWMutex
+	size    int
+	weights map[string]int
+	ids     []string // 按照ID排序
+	table   []int    // 查找表,值为ids中的索引
+	dirty   bool
+}
+
+func (m *Maglev) Len() int {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return len(m.weights)
+}
+
+func (m *Maglev) Add(id string, weight int) {
+	weight = normWeight(weight)
+	m.mux.Lock()
+	if old, ok := m.weights[id]; !ok || old != weight {
+		m.weights[id] = weight
+		m.dirty = true
+	}
+	m.mux.Unlock()
+}
+
+func (m *Maglev) Remove(id string) {
+	m.mux.Lock()
+	if _, ok := m.weights[id]; ok {
+		delete(m.weights, id)
+		m.dirty = true
+	}
+	m.mux.Unlock()
+}
+
+func (m *Maglev) Get(key uint64) string {
+	var result string
+	m.Walk(key, func(id string) bool {
+		result = id
+		return false
+	})
+
+	return result
+}
+
+// Walk 从key对应的位置开始依次遍历查找表,每个节点只访问一次
+func (m *Maglev) Walk(key uint64, fn func(id string) bool) {
+	m.mux.RLock()
+	if m.dirty {
+		m.mux.RUnlock()
+		m.mux.Lock()
+		if m.dirty {
+			m.rebuild()
+		}
+		m.mux.Unlock()
+		m.mux.RLock()
+	}
+	defer m.mux.RUnlock()
+
+	if len(m.ids) == 0 {
+		return
+	}
+
+	start := int(Mix(key) % uint64(m.size))
+	visited := make([]bool, len(m.ids))
+	count := 0
+	for i := 0; i < m.size && count < len(m.ids); i++ {
+		idx := m.table[(start+i)%m.size]
+		if visited[idx] {
+			continue
+		}
+		visited[idx] = true
+		count++
+		if !fn(m.ids[idx]) {
+			return
+		}
+	}
+}
+
+func (m *Maglev) rebuild() {
+	m.dirty = false
+	m.ids = m.ids[:0]
+	for id := range m.weights {
+		m.ids = append(m.ids, id)
+	}
+	sort.Strings(m.ids)
+
+	n := len(m.ids)
+	if n == 0 {
+		m.table = nil
+		return
+	}
+
+	size := uint64(m.size)
+	offsets := make([]uint64, n)
+	skips := make([]uint64, n)
+	nexts := make([]uint64, n)
+	credits := make([]int, n)
+	maxWeight := 0
+	for i, id := range m.ids {
+		offsets[i] = hashString(id, 0) % size
+		skips[i] = hashString(id, 1)%(size-1) + 1
+		if w := m.weights[id]; w > maxWeight {
+			maxWeight = w
+		}
+	}
+
+	table := make([]int, m.size)
+	for i := range table {
+		table[i] = -1
+	}
+
+	// 每轮每个节点累积与权重成正比的额度,额度足够时填充一个位置
+	filled := 0
+	for filled < m.size {
+		for i := 0; i < n && filled < m.size; i++ {
+			credits[i] += m.weights[m.ids[i]]
+			if credits[i] < maxWeight {
+				continue
+			}
+			credits[i] -= maxWeight
+
+			for {
+				pos := (offsets[i] + nexts[i]*skips[i]) % size
+				nexts[i]++
+				if table[pos] == -1 {
+					table[pos] = i
+					filled++
+					break
+				}
+			}
+		}
+	}
+
+	m.table = table
+}
@@ -0,0 +1,142 @@
+package chash
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const DefaultReplicas = 160
+
+// NewRing 创建hash环,replicas为默认权重下每个节点的虚拟节点数
+func NewRing(replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+
+	return &Ring{replicas: replicas, weights: make(map[string]int)}
+}
+
+type point struct {
+	hash uint64
+	id   string
+}
+
+// Ring hash环,每个节点按照权重生成若干虚拟节点,key顺时针查找第一个虚拟节点
+// 增删节点时只需要插入或删除对应的虚拟节点,不需要重建
+type Ring struct {
+	mux      sync.RWMutex
+	replicas int
+	points   []point // 按照hash排序
+	weights  map[string]int
+}
+
+func (r *Ring) Len() int {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return len(r.weights)
+}
+
+func (r *Ring) Add(id string, weight int) {
+	weight = normWeight(weight)
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if old, ok := r.weights[id]; ok {
+		if old == weight {
+			return
+		}
+		r.remove(id)
+	}
+
+	r.weights[id] = weight
+	n := r.replicas * weight / DefaultWeight
+	if n < 1 {
+		n = 1
+	}
+
+	added := make([]point, 0, n)
+	for i := 0; i < n; i++ {
+		added = append(added, point{hash: hashString(id+"#"+strconv.Itoa(i), 0), id: id})
+	}
+	sort.Slice(added, func(i, j int) bool { return less(added[i], added[j]) })
+
+	// 有序合并
+	merged := make([]point, 0, len(r.points)+len(added))
+	i, j := 0, 0
+	for i < len(r.points) && j < len(added) {
+		if less(r.points[i], added[j]) {
+			merged = append(merged, r.points[i])
+			i++
+		} else {
+			merged = append(merged, added[j])
+			j++
+		}
+	}
+	merged = append(merged, r.points[i:]...)
+	merged = append(merged, added[j:]...)
+	r.points = merged
+}
+
+// less hash冲突时按照id排序,保证结果与添加顺序无关
+func less(a, b point) bool {
+	if a.hash != b.hash {
+		return a.hash < b.hash
+	}
+
+	return a.id < b.id
+}
+
+func (r *Ring) Remove(id string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, ok := r.weights[id]; ok {
+		r.remove(id)
+	}
+}
+
+func (r *Ring) remove(id string) {
+	delete(r.weights, id)
+	points := r.points[:0]
+	for _, p := range r.points {
+		if p.id != id {
+			points = append(points, p)
+		}
+	}
+	r.points = points
+}
+
+func (r *Ring) Get(key uint64) string {
+	var result string
+	r.Walk(key, func(id string) bool {
+		result = id
+		return false
+	})
+
+	return result
+}
+
+// Walk 从key的位置顺时针遍历,每个节点只访问一次
+func (r *Ring) Walk(key uint64, fn func(id string) bool) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	n := len(r.points)
+	if n == 0 {
+		return
+	}
+
+	key = Mix(key)
+	start := sort.Search(n, func(i int) bool { return r.points[i].hash >= key })
+	visited := make(map[string]bool, len(r.weights))
+	for i := 0; i < n && len(visited) < len(r.weights); i++ {
+		p := r.points[(start+i)%n]
+		if visited[p.id] {
+			continue
+		}
+		visited[p.id] = true
+		if !fn(p.id) {
+			return
+		}
+	}
+}
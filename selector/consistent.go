@@ -0,0 +1,89 @@
+package selector
+
+import (
+	"math"
+
+	"github.com/jeckbjy/gsk/selector/chash"
+)
+
+// HashMode Options.Hash大于0时使用的hash算法
+type HashMode int
+
+const (
+	HashModulo HashMode = iota // 取模,节点数变化时几乎所有key都会重新映射
+	HashRing                   // hash环+虚拟节点
+	HashMaglev                 // Maglev查找表
+	HashJump                   // Jump Consistent Hash
+)
+
+// NewHash 根据节点创建一致性hash,HashModulo返回nil
+func NewHash(mode HashMode, nodes []Node) chash.Hash {
+	var h chash.Hash
+	switch mode {
+	case HashRing:
+		h = chash.NewRing(0)
+	case HashMaglev:
+		h = chash.NewMaglev(0)
+	case HashJump:
+		h = chash.NewJump()
+	default:
+		return nil
+	}
+
+	for _, n := range nodes {
+		h.Add(n.Id(), int(weightOf(n)))
+	}
+
+	return h
+}
+
+// ConsistentHash 通过一致性hash选择节点,h中的节点需要与nodes一致,不存在于nodes中的节点会被跳过
+// factor大于0时启用有界负载(Consistent Hashing with Bounded Loads):
+// 每个节点的负载上限为ceil(平均负载*factor),超过上限时顺延到下一个节点,防止热点key压垮单个节点
+// factor通常取1.25,负载使用节点Stats中的Inflight,节点没有实现Stater时不限制
+func ConsistentHash(h chash.Hash, nodes []Node, key uint64, factor float64) Next {
+	return func() (Node, error) {
+		dict := make(map[string]Node, len(nodes))
+		var total int64
+		for _, n := range nodes {
+			dict[n.Id()] = n
+			if st := statsOf(n); st != nil {
+				total += st.Inflight()
+			}
+		}
+
+		var limit int64 = math.MaxInt64
+		if factor > 0 {
+			limit = int64(math.Ceil(float64(total+1) / float64(len(nodes)) * factor))
+		}
+
+		var first, result Node
+		h.Walk(key, func(id string) bool {
+			n := dict[id]
+			if n == nil {
+				return true
+			}
+			if first == nil {
+				first = n
+			}
+
+			if st := statsOf(n); st == nil || st.Inflight() < limit {
+				result = n
+				return false
+			}
+
+			return true
+		})
+
+		if result == nil {
+			result = first
+		}
+
+		if result == nil {
+			// hash中的节点与nodes不一致,退化为取模
+			return nodes[key%uint64(len(nodes))], nil
+		}
+
+		return result, nil
+	}
+}
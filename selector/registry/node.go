@@ -3,24 +3,85 @@ package registry
 import (
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/apm/breaker"
 	"github.com/jeckbjy/gsk/registry"
 	"github.com/jeckbjy/gsk/selector"
+	"github.com/jeckbjy/gsk/selector/chash"
 )
 
+func newNode(s *_Selector, srv *registry.Service) *_Node {
+	n := &_Node{sel: s}
+	n.srv.Store(srv)
+	if s.opts.Breaker != nil {
+		n.breaker = s.opts.Breaker.Get(srv.Id)
+	}
+	return n
+}
+
 type _Node struct {
-	srv  *registry.Service
-	conn anet.Conn
-	mux  sync.Mutex
+	srv      atomic.Value // *registry.Service,更新时会被替换
+	conn     anet.Conn
+	mux      sync.Mutex
+	stats    selector.Stats  // 负载统计,服务信息更新时保留
+	sel      *_Selector      //
+	breaker  breaker.Breaker // 可选的熔断器
+	failures int32           // 连续失败次数,原子操作
+	// 以下字段由_Selector.mux保护
+	ejected   bool      // 是否被摘除
+	until     time.Time // 摘除截止时间
+	ejections int       // 连续被摘除的次数
+	lastEject time.Time // 最近一次摘除时间
+	reason    string    // 最近一次摘除原因
+}
+
+func (n *_Node) Service() *registry.Service {
+	return n.srv.Load().(*registry.Service)
 }
 
 func (n *_Node) Id() string {
-	return n.srv.Id
+	return n.Service().Id
 }
 
 func (n *_Node) Addr() string {
-	return n.srv.Addr
+	return n.Service().Addr
+}
+
+func (n *_Node) Weight() int {
+	return n.Service().Weight
+}
+
+func (n *_Node) Zone() string {
+	return n.Service().Zone
+}
+
+func (n *_Node) Stats() *selector.Stats {
+	return &n.stats
+}
+
+// Feedback 反馈调用结果,连续失败达到上限后摘除节点
+func (n *_Node) Feedback(err error, latency time.Duration) {
+	if n.breaker != nil {
+		n.breaker.Mark(err)
+	}
+
+	if err == nil {
+		atomic.StoreInt32(&n.failures, 0)
+		return
+	}
+
+	max := n.sel.opts.MaxFailures
+	if max > 0 && int(atomic.AddInt32(&n.failures, 1)) >= max {
+		n.sel.eject(n, err)
+	}
+}
+
+// available 能否参与负载均衡,健康检查失败或者被摘除的节点不可用
+func (n *_Node) available() bool {
+	return !n.ejected && !n.Service().Unhealthy
 }
 
 func (n *_Node) Conn(tran anet.Tran) (anet.Conn, error) {
@@ -54,21 +115,126 @@ func (n *_Node) Close() error {
 type _Group struct {
 	nodes  []*_Node
 	shadow []selector.Node
+	hashes map[hashKey]*_Hash // 按需创建的一致性hash,节点变化时增量更新
+}
+
+// hashKey 每个可用区以及算法单独缓存,zone为空表示包含所有可用区的节点
+type hashKey struct {
+	zone string
+	mode selector.HashMode
+}
+
+type _Hash struct {
+	hash    chash.Hash
+	members map[string]int // hash中的节点及权重
 }
 
 func (g *_Group) Add(node *_Node) {
 	g.nodes = append(g.nodes, node)
 	g.shadow = nil
+	g.syncHash()
 }
 
 func (g *_Group) Remove(id string) {
 	for i, n := range g.nodes {
 		if n.Id() == id {
 			g.nodes = append(g.nodes[:i], g.nodes[i+1:]...)
+			break
 		}
 	}
 
 	g.shadow = nil
+	g.syncHash()
+}
+
+// Reset 节点状态变化后需要重新生成shadow
+func (g *_Group) Reset() {
+	g.shadow = nil
+	g.syncHash()
+}
+
+// Hash 获取一致性hash以及其中包含的节点,只包含健康的节点,第一次使用时创建
+// zone不为空时优先使用该可用区的节点,与selector.PreferZone一致,可用区没有可用节点时使用所有节点
+func (g *_Group) Hash(mode selector.HashMode, zone string) (chash.Hash, []selector.Node) {
+	nodes := g.Shadow()
+	if zone != "" {
+		if zoned := zoneNodes(nodes, zone); len(zoned) > 0 {
+			nodes = zoned
+		} else {
+			zone = ""
+		}
+	}
+
+	key := hashKey{zone: zone, mode: mode}
+	if h, ok := g.hashes[key]; ok {
+		return h.hash, nodes
+	}
+
+	hash := selector.NewHash(mode, nodes)
+	if hash == nil {
+		return nil, nodes
+	}
+
+	h := &_Hash{hash: hash, members: make(map[string]int, len(nodes))}
+	for _, n := range nodes {
+		h.members[n.Id()] = n.(*_Node).Weight()
+	}
+	if g.hashes == nil {
+		g.hashes = make(map[hashKey]*_Hash)
+	}
+	g.hashes[key] = h
+	return hash, nodes
+}
+
+func zoneNodes(nodes []selector.Node, zone string) []selector.Node {
+	var result []selector.Node
+	for _, n := range nodes {
+		if n.(*_Node).Zone() == zone {
+			result = append(result, n)
+		}
+	}
+
+	return result
+}
+
+// syncHash 对比健康节点和hash中的节点,只更新有变化的节点
+func (g *_Group) syncHash() {
+	if len(g.hashes) == 0 {
+		return
+	}
+
+	for key, h := range g.hashes {
+		healthy := make(map[string]int, len(g.nodes))
+		for _, n := range g.nodes {
+			if n.available() && (key.zone == "" || n.Zone() == key.zone) {
+				healthy[n.Id()] = n.Weight()
+			}
+		}
+
+		for id := range h.members {
+			if _, ok := healthy[id]; !ok {
+				delete(h.members, id)
+				h.hash.Remove(id)
+			}
+		}
+
+		for id, w := range healthy {
+			if old, ok := h.members[id]; !ok || old != w {
+				h.members[id] = w
+				h.hash.Add(id, w)
+			}
+		}
+	}
+}
+
+func (g *_Group) Find(id string) *_Node {
+	for _, n := range g.nodes {
+		if n.Id() == id {
+			return n
+		}
+	}
+
+	return nil
 }
 
 func (g *_Group) Shadow() []selector.Node {
@@ -80,7 +246,9 @@ func (g *_Group) Shadow() []selector.Node {
 	}
 
 	for _, n := range g.nodes {
-		g.shadow = append(g.shadow, n)
+		if n.available() {
+			g.shadow = append(g.shadow, n)
+		}
 	}
 
 	return g.shadow
@@ -89,7 +257,7 @@ func (g *_Group) Shadow() []selector.Node {
 func (g *_Group) Filter(filters map[string]string) []selector.Node {
 	results := make([]selector.Node, 0, len(g.nodes))
 	for _, n := range g.nodes {
-		if n.srv.Match(filters) {
+		if n.available() && n.Service().Match(&registry.QueryOptions{Tags: filters}) {
 			results = append(results, n)
 		}
 	}
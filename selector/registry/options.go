@@ -0,0 +1,56 @@
+package registry
+
+import (
+	"time"
+
+	"github.com/jeckbjy/gsk/apm/breaker"
+)
+
+const (
+	DefaultMaxFailures        = 5
+	DefaultEjectionTime       = time.Second * 10
+	DefaultMaxEjectionTime    = time.Minute * 5
+	DefaultMaxEjectionPercent = 50
+)
+
+// Options 异常节点摘除(Outlier Ejection)
+// 节点连续失败MaxFailures次后被摘除,摘除时间为EjectionTime*2^(n-1),n为连续被摘除的次数,最大为MaxEjectionTime
+// 摘除到期后节点重新参与选择,如果超过MaxEjectionTime没有再次被摘除,n重置为0
+// 同一个服务最多摘除MaxEjectionPercent比例的节点,防止所有节点都被摘除
+type Options struct {
+	MaxFailures        int           // 连续失败次数,小于等于0表示不摘除
+	EjectionTime       time.Duration // 第一次摘除时间
+	MaxEjectionTime    time.Duration // 最长摘除时间
+	MaxEjectionPercent int           // 最多摘除的节点比例,0-100
+	Breaker            breaker.Group // 可选,为每个节点创建熔断器,key为节点ID
+}
+
+type Option func(o *Options)
+
+// MaxFailures 连续失败n次后摘除节点,0表示不摘除
+func MaxFailures(n int) Option {
+	return func(o *Options) {
+		o.MaxFailures = n
+	}
+}
+
+// EjectionTime 第一次摘除时间以及最长摘除时间
+func EjectionTime(base time.Duration, max time.Duration) Option {
+	return func(o *Options) {
+		o.EjectionTime = base
+		o.MaxEjectionTime = max
+	}
+}
+
+func MaxEjectionPercent(percent int) Option {
+	return func(o *Options) {
+		o.MaxEjectionPercent = percent
+	}
+}
+
+// Breaker 为每个节点创建熔断器,熔断的节点不会被选择,比如breaker.NewGroup(&breaker.Config{})
+func Breaker(g breaker.Group) Option {
+	return func(o *Options) {
+		o.Breaker = g
+	}
+}
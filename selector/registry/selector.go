@@ -1,20 +1,40 @@
 package registry
 
 import (
+	"fmt"
+	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/jeckbjy/gsk/apm/breaker"
 	"github.com/jeckbjy/gsk/registry"
 	"github.com/jeckbjy/gsk/selector"
+	"github.com/jeckbjy/gsk/selector/chash"
 	"github.com/jeckbjy/gsk/util/errorx"
 )
 
-func New(reg registry.Registry) selector.Selector {
-	s := &_Selector{reg: reg, groups: make(map[string]*_Group), nodes: make(map[string]*_Node)}
+func New(reg registry.Registry, opts ...Option) selector.Selector {
+	o := &Options{
+		MaxFailures:        DefaultMaxFailures,
+		EjectionTime:       DefaultEjectionTime,
+		MaxEjectionTime:    DefaultMaxEjectionTime,
+		MaxEjectionPercent: DefaultMaxEjectionPercent,
+	}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	s := &_Selector{opts: o, reg: reg, groups: make(map[string]*_Group), nodes: make(map[string]*_Node)}
 	return s
 }
 
+// _Selector 基于Registry的Selector
+// 节点通过Feedback反馈调用结果,连续失败的节点会被临时摘除,见Options
+// 实现了selector.Reporter接口,可以查询每个节点的状态以及被摘除的原因
 type _Selector struct {
 	mux    sync.Mutex
+	opts   *Options
 	reg    registry.Registry
 	groups map[string]*_Group
 	nodes  map[string]*_Node
@@ -25,17 +45,13 @@ func (s *_Selector) Name() string {
 }
 
 func (s *_Selector) Select(service string, opts *selector.Options) (selector.Next, error) {
-	var nodes []selector.Node
+	var hash chash.Hash
 
 	s.mux.Lock()
-	g, err := s.getGroup(service)
-	if err == nil {
-		if len(opts.Filters) > 0 {
-			// filter每次都会拷贝,比较低效,不如定制selector,使用cache,慎重使用
-			nodes = g.Filter(opts.Filters)
-		} else {
-			nodes = g.Shadow()
-		}
+	nodes, g, err := s.find(service, opts)
+	if err == nil && g != nil && opts.Hash > 0 && opts.HashMode != selector.HashModulo && opts.Strategy == nil {
+		// 使用缓存的一致性hash,每个可用区单独缓存,节点变化时增量更新
+		hash, nodes = g.Hash(opts.HashMode, opts.Zone)
 	}
 	s.mux.Unlock()
 
@@ -43,11 +59,80 @@ func (s *_Selector) Select(service string, opts *selector.Options) (selector.Nex
 		return nil, err
 	}
 
+	var next selector.Next
+	if hash != nil && len(nodes) > 1 {
+		next = selector.ConsistentHash(hash, nodes, uint64(opts.Hash), opts.LoadFactor)
+	} else {
+		next = opts.GetNext(nodes)
+	}
+
+	if s.opts.Breaker != nil && opts.Node == "" {
+		next = withBreaker(next, len(nodes))
+	}
+
+	return next, nil
+}
+
+// List 查询服务所有可用节点,不包括健康检查失败和被摘除的节点,实现selector.Lister接口
+func (s *_Selector) List(service string, opts *selector.Options) ([]selector.Node, error) {
+	s.mux.Lock()
+	nodes, _, err := s.find(service, opts)
+	s.mux.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Shadow是共享的,需要拷贝
+	result := make([]selector.Node, len(nodes))
+	copy(result, nodes)
+	return result, nil
+}
+
+// find 根据Node和Filters查找可用节点,只有没有指定Node和Filters时返回Group,用于缓存一致性hash
+func (s *_Selector) find(service string, opts *selector.Options) ([]selector.Node, *_Group, error) {
+	g, err := s.getGroup(service)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var nodes []selector.Node
+	if opts.Node != "" {
+		// 指定节点时忽略健康状态
+		if n := g.Find(opts.Node); n != nil {
+			nodes = []selector.Node{n}
+		}
+		g = nil
+	} else if len(opts.Filters) > 0 {
+		// filter每次都会拷贝,比较低效,不如定制selector,使用cache,慎重使用
+		nodes = g.Filter(opts.Filters)
+		g = nil
+	} else {
+		nodes = g.Shadow()
+	}
+
 	if len(nodes) == 0 {
-		return nil, errorx.ErrNotAvailable
+		return nil, nil, errorx.ErrNotAvailable
 	}
 
-	return opts.GetNext(nodes), nil
+	return nodes, g, nil
+}
+
+// withBreaker 跳过熔断的节点,最多尝试n次
+func withBreaker(next selector.Next, n int) selector.Next {
+	return func() (selector.Node, error) {
+		for i := 0; i < n; i++ {
+			node, err := next()
+			if err != nil {
+				return nil, err
+			}
+
+			if b := node.(*_Node).breaker; b == nil || b.Allow() == nil {
+				return node, nil
+			}
+		}
+
+		return nil, breaker.ErrReject
+	}
 }
 
 func (s *_Selector) getGroup(service string) (*_Group, error) {
@@ -96,21 +181,139 @@ func (s *_Selector) onEvent(ev *registry.Event) {
 }
 
 func (s *_Selector) upsert(srv *registry.Service) {
+	g := s.groups[srv.Name]
+	if g == nil {
+		return
+	}
+
 	node, ok := s.nodes[srv.Id]
 	if !ok {
-		s.groups[srv.Name].Add(&_Node{srv: srv})
-	} else if node.srv.Addr != srv.Addr {
+		node = newNode(s, srv)
+		s.nodes[srv.Id] = node
+		g.Add(node)
+		return
+	}
+
+	if node.Addr() != srv.Addr {
 		// ip change,maybe have some error?
 		_ = node.Close()
 	}
+
+	// 更新服务信息,健康状态可能发生变化
+	node.srv.Store(srv)
+	g.Reset()
 }
 
 func (s *_Selector) remove(id string) {
 	node, ok := s.nodes[id]
 	if ok {
 		_ = node.Close()
-		name := node.srv.Name
+		name := node.Service().Name
 		s.groups[name].Remove(id)
 		delete(s.nodes, id)
 	}
 }
+
+// eject 摘除连续失败的节点,一段时间后自动恢复
+func (s *_Selector) eject(n *_Node, cause error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	srv := n.Service()
+	g := s.groups[srv.Name]
+	if n.ejected || g == nil || s.nodes[srv.Id] != n {
+		return
+	}
+
+	failures := atomic.LoadInt32(&n.failures)
+	ejected := 0
+	for _, node := range g.nodes {
+		if node.ejected {
+			ejected++
+		}
+	}
+	if (ejected+1)*100 > s.opts.MaxEjectionPercent*len(g.nodes) {
+		n.reason = fmt.Sprintf("skip ejection, too many ejected nodes(%d/%d), consecutive failures %d, last error: %v", ejected, len(g.nodes), failures, cause)
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(n.lastEject) > s.opts.MaxEjectionTime+n.until.Sub(n.lastEject) {
+		// 恢复后长时间没有再次被摘除
+		n.ejections = 0
+	}
+	n.ejections++
+
+	d := s.opts.EjectionTime
+	for i := 1; i < n.ejections && d < s.opts.MaxEjectionTime; i++ {
+		d *= 2
+	}
+	if d > s.opts.MaxEjectionTime {
+		d = s.opts.MaxEjectionTime
+	}
+
+	n.ejected = true
+	n.lastEject = now
+	n.until = now.Add(d)
+	n.reason = fmt.Sprintf("consecutive failures %d, last error: %v", failures, cause)
+	g.Reset()
+	log.Printf("[selector] eject node %s(%s) for %v, %s", srv.Id, srv.Addr, d, n.reason)
+
+	time.AfterFunc(d, func() {
+		s.restore(n)
+	})
+}
+
+// restore 摘除到期,节点重新参与选择
+func (s *_Selector) restore(n *_Node) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if !n.ejected {
+		return
+	}
+
+	n.ejected = false
+	atomic.StoreInt32(&n.failures, 0)
+	if g := s.groups[n.Service().Name]; g != nil && s.nodes[n.Id()] == n {
+		g.Reset()
+	}
+}
+
+// Report 查询服务所有节点的状态,包括健康检查失败和被摘除的节点
+func (s *_Selector) Report(service string) ([]*selector.NodeStatus, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	g, err := s.getGroup(service)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*selector.NodeStatus, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		srv := n.Service()
+		st := &selector.NodeStatus{
+			Id:        srv.Id,
+			Addr:      srv.Addr,
+			Healthy:   !srv.Unhealthy,
+			Ejected:   n.ejected,
+			Ejections: n.ejections,
+			Reason:    n.reason,
+			Failures:  int(atomic.LoadInt32(&n.failures)),
+			Success:   n.stats.Success(),
+			Failure:   n.stats.Failure(),
+			Inflight:  n.stats.Inflight(),
+			Latency:   n.stats.Latency(),
+		}
+		if n.ejected {
+			st.Until = n.until
+		}
+		if n.breaker != nil {
+			st.Breaker = n.breaker.String()
+		}
+		results = append(results, st)
+	}
+
+	return results, nil
+}
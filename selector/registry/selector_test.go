@@ -0,0 +1,202 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/registry"
+	"github.com/jeckbjy/gsk/selector"
+)
+
+// staticRegistry 固定的服务列表
+type staticRegistry struct {
+	services []*registry.Service
+}
+
+func (r *staticRegistry) Name() string                                     { return "static" }
+func (r *staticRegistry) Register(srv *registry.Service) error             { return nil }
+func (r *staticRegistry) Unregister(id string) error                       { return nil }
+func (r *staticRegistry) Watch(names []string, cb registry.Callback) error { return nil }
+func (r *staticRegistry) Close() error                                     { return nil }
+func (r *staticRegistry) Query(name string, opts *registry.QueryOptions) ([]*registry.Service, error) {
+	return r.services, nil
+}
+
+func newTestSelector(n int, opts ...Option) selector.Selector {
+	reg := &staticRegistry{}
+	for i := 0; i < n; i++ {
+		reg.services = append(reg.services, registry.NewService("echo", fmt.Sprintf("echo-%d", i), fmt.Sprintf("127.0.0.1:%d", i+1), nil))
+	}
+
+	return New(reg, opts...)
+}
+
+func fail(t *testing.T, s selector.Selector, id string, times int) {
+	next, err := s.Select("echo", &selector.Options{Node: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node, _ := next()
+	for i := 0; i < times; i++ {
+		selector.Begin(node)(errors.New("connection refused"))
+	}
+}
+
+func selectAll(t *testing.T, s selector.Selector, opts *selector.Options) map[string]bool {
+	result := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		next, err := s.Select("echo", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node, _ := next()
+		result[node.Id()] = true
+	}
+
+	return result
+}
+
+func TestEjection(t *testing.T) {
+	s := newTestSelector(4, MaxFailures(3), EjectionTime(time.Millisecond*100, time.Second))
+	defer s.Close()
+
+	fail(t, s, "echo-0", 2)
+	if got := selectAll(t, s, &selector.Options{}); !got["echo-0"] {
+		t.Fatalf("ejected too early, %+v", got)
+	}
+
+	fail(t, s, "echo-0", 1)
+	if got := selectAll(t, s, &selector.Options{}); got["echo-0"] || len(got) != 3 {
+		t.Fatalf("not ejected, %+v", got)
+	}
+
+	// 一致性hash同样跳过被摘除的节点
+	if got := selectAll(t, s, &selector.Options{Hash: 1, HashMode: selector.HashRing}); got["echo-0"] {
+		t.Fatalf("hash select ejected node, %+v", got)
+	}
+
+	// 最多摘除50%的节点
+	fail(t, s, "echo-1", 3)
+	fail(t, s, "echo-2", 3)
+	status, err := s.(selector.Reporter).Report("echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ejected := 0
+	for _, st := range status {
+		if st.Ejected {
+			ejected++
+			if st.Reason == "" || st.Until.IsZero() {
+				t.Fatalf("bad status, %+v", st)
+			}
+		}
+		if st.Id == "echo-2" && (st.Ejected || st.Reason == "") {
+			t.Fatalf("should skip ejection with reason, %+v", st)
+		}
+	}
+	if ejected != 2 {
+		t.Fatalf("bad ejected count, %d", ejected)
+	}
+
+	// 到期后恢复
+	time.Sleep(time.Millisecond * 200)
+	if got := selectAll(t, s, &selector.Options{}); len(got) != 4 {
+		t.Fatalf("not restored, %+v", got)
+	}
+
+	// 再次摘除时间翻倍
+	fail(t, s, "echo-0", 3)
+	status, _ = s.(selector.Reporter).Report("echo")
+	for _, st := range status {
+		if st.Id == "echo-0" && (!st.Ejected || st.Ejections != 2 || time.Until(st.Until) < time.Millisecond*150) {
+			t.Fatalf("bad second ejection, %+v", st)
+		}
+	}
+}
+
+func TestSuccessResetFailures(t *testing.T) {
+	s := newTestSelector(2, MaxFailures(3))
+	defer s.Close()
+
+	fail(t, s, "echo-0", 2)
+	next, _ := s.Select("echo", &selector.Options{Node: "echo-0"})
+	node, _ := next()
+	selector.Begin(node)(nil)
+	fail(t, s, "echo-0", 2)
+
+	if got := selectAll(t, s, &selector.Options{}); !got["echo-0"] {
+		t.Fatalf("success should reset failures, %+v", got)
+	}
+}
+
+func TestList(t *testing.T) {
+	s := newTestSelector(3, MaxFailures(1))
+	defer s.Close()
+
+	fail(t, s, "echo-0", 1)
+	nodes, err := selector.Nodes(s, "echo", &selector.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("bad nodes, %+v", nodes)
+	}
+	for _, n := range nodes {
+		if n.Id() == "echo-0" {
+			t.Fatal("list ejected node")
+		}
+	}
+
+	nodes, err = selector.Nodes(s, "echo", &selector.Options{Node: "echo-2"})
+	if err != nil || len(nodes) != 1 || nodes[0].Id() != "echo-2" {
+		t.Fatalf("bad node, %+v, %v", nodes, err)
+	}
+}
+
+func TestZoneHash(t *testing.T) {
+	reg := &staticRegistry{}
+	for i := 0; i < 4; i++ {
+		srv := registry.NewService("echo", fmt.Sprintf("echo-%d", i), fmt.Sprintf("127.0.0.1:%d", i+1), nil)
+		srv.Zone = "a"
+		if i >= 2 {
+			srv.Zone = "b"
+		}
+		reg.services = append(reg.services, srv)
+	}
+
+	s := New(reg, MaxFailures(1), MaxEjectionPercent(100))
+	defer s.Close()
+
+	opts := &selector.Options{Hash: 1, HashMode: selector.HashRing, Zone: "a"}
+	for i := int64(1); i <= 100; i++ {
+		opts.Hash = i
+		next, err := s.Select("echo", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node, _ := next(); node.(*_Node).Zone() != "a" {
+			t.Fatalf("select other zone, %s", node.Id())
+		}
+	}
+
+	// 每个可用区只创建一次
+	g := s.(*_Selector).groups["echo"]
+	h := g.hashes[hashKey{zone: "a", mode: selector.HashRing}]
+	if len(g.hashes) != 1 || h == nil || len(h.members) != 2 {
+		t.Fatalf("bad hash cache, %+v", g.hashes)
+	}
+
+	// 可用区没有可用节点时使用所有节点
+	fail(t, s, "echo-0", 1)
+	fail(t, s, "echo-1", 1)
+	if got := selectAll(t, s, opts); got["echo-0"] || got["echo-1"] {
+		t.Fatalf("select ejected node, %+v", got)
+	}
+	if len(g.hashes) != 2 || len(h.members) != 0 {
+		t.Fatalf("bad hash cache, %+v", g.hashes)
+	}
+}
@@ -1,7 +1,10 @@
 package selector
 
 import (
+	"time"
+
 	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/util/errorx"
 )
 
 // Selector 客户端LoadBalance
@@ -23,15 +26,50 @@ type Node interface {
 
 type Next func() (Node, error)
 
+// Lister 可选接口,查询服务所有可用节点,会使用opts中的Filters和Node,忽略Strategy,Hash和Zone
+type Lister interface {
+	List(service string, opts *Options) ([]Node, error)
+}
+
+// Reporter 可选接口,查询服务所有节点的状态,用于运维排查节点为什么没有流量
+type Reporter interface {
+	Report(service string) ([]*NodeStatus, error)
+}
+
+// NodeStatus 节点状态
+type NodeStatus struct {
+	Id        string        `json:"id"`
+	Addr      string        `json:"addr"`
+	Healthy   bool          `json:"healthy"`             // 注册中心健康检查结果
+	Ejected   bool          `json:"ejected"`             // 是否因为连续失败被摘除
+	Until     time.Time     `json:"until,omitempty"`     // 摘除截止时间
+	Ejections int           `json:"ejections,omitempty"` // 连续被摘除的次数,摘除时间随次数增加
+	Reason    string        `json:"reason,omitempty"`    // 最近一次摘除的原因
+	Failures  int           `json:"failures,omitempty"`  // 当前连续失败次数
+	Success   int64         `json:"success"`             // 累计成功次数
+	Failure   int64         `json:"failure"`             // 累计失败次数
+	Inflight  int64         `json:"inflight"`            // 正在处理的请求数
+	Latency   time.Duration `json:"latency"`             // EWMA延迟
+	Breaker   string        `json:"breaker,omitempty"`   // 熔断器状态
+}
+
 type Strategy func([]Node) Next
 
 type Options struct {
-	Filters  map[string]string
-	Strategy Strategy
-	Hash     int64
+	Filters    map[string]string
+	Strategy   Strategy
+	Hash       int64
+	HashMode   HashMode // Hash大于0时使用的算法,默认取模
+	LoadFactor float64  // 一致性hash的负载上限系数,比如1.25,0表示不限制,见ConsistentHash
+	Node       string   // 指定节点ID,会忽略Filters和健康状态,常用于健康检查或者定向调用
+	Zone       string   // 调用方所在可用区,优先选择相同可用区的节点,不存在时选择其他可用区
 }
 
 func (o *Options) GetNext(nodes []Node) Next {
+	if o.Zone != "" {
+		nodes = PreferZone(nodes, o.Zone)
+	}
+
 	if len(nodes) == 1 {
 		return First(nodes)
 	}
@@ -41,8 +79,22 @@ func (o *Options) GetNext(nodes []Node) Next {
 	}
 
 	if o.Hash > 0 {
-		return Hash(nodes, uint64(o.Hash))
+		if o.HashMode == HashModulo {
+			return Hash(nodes, uint64(o.Hash))
+		}
+		// 没有缓存,每次都需要重新创建,Selector实现时应该按照服务缓存,见selector/registry
+		return ConsistentHash(NewHash(o.HashMode, nodes), nodes, uint64(o.Hash), o.LoadFactor)
 	}
 
 	return Random(nodes)
 }
+
+// Nodes 通过Selector查询服务所有可用节点,可用于广播,Selector需要实现Lister接口
+func Nodes(s Selector, service string, opts *Options) ([]Node, error) {
+	l, ok := s.(Lister)
+	if !ok {
+		return nil, errorx.ErrNotSupport
+	}
+
+	return l.List(service, opts)
+}
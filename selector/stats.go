@@ -0,0 +1,148 @@
+package selector
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultWeight = 100              // 没有设置权重时使用的默认权重
+	DecayTime     = time.Second * 10 // EWMA衰减时间,越小对延迟变化越敏感
+)
+
+// Weighter 可选接口,节点权重,小于等于0时使用DefaultWeight
+type Weighter interface {
+	Weight() int
+}
+
+// Zoner 可选接口,节点所在的可用区
+type Zoner interface {
+	Zone() string
+}
+
+// Stater 可选接口,节点的负载统计,LeastLoaded,P2C,WeightedRoundRobin需要依赖统计数据
+type Stater interface {
+	Stats() *Stats
+}
+
+// Stats 节点实时负载统计,需要调用方在请求开始时调用Begin,结束时调用返回的函数
+// 延迟使用基于时间衰减的EWMA,距离上次更新越久,旧数据的权重越低
+type Stats struct {
+	success  int64 // 成功次数
+	failure  int64 // 失败次数
+	inflight int64 // 正在处理的请求数
+	latency  int64 // EWMA延迟,纳秒
+	stamp    int64 // 上次更新延迟的时间,纳秒
+	current  int64 // 平滑加权轮询的当前权重
+}
+
+// Inflight 正在处理的请求数
+func (s *Stats) Inflight() int64 {
+	return atomic.LoadInt64(&s.inflight)
+}
+
+// Success 成功的请求数
+func (s *Stats) Success() int64 {
+	return atomic.LoadInt64(&s.success)
+}
+
+// Failure 失败的请求数
+func (s *Stats) Failure() int64 {
+	return atomic.LoadInt64(&s.failure)
+}
+
+// Latency 平均延迟,没有数据时返回0
+func (s *Stats) Latency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.latency))
+}
+
+// Begin 开始一次请求,返回的函数需要在请求结束时调用,且只能调用一次
+func (s *Stats) Begin() func(err error) {
+	atomic.AddInt64(&s.inflight, 1)
+	start := time.Now()
+	return func(err error) {
+		atomic.AddInt64(&s.inflight, -1)
+		if err == nil {
+			atomic.AddInt64(&s.success, 1)
+		} else {
+			atomic.AddInt64(&s.failure, 1)
+		}
+		now := time.Now()
+		s.observe(now, now.Sub(start))
+	}
+}
+
+func (s *Stats) observe(now time.Time, rtt time.Duration) {
+	for {
+		old := atomic.LoadInt64(&s.latency)
+		stamp := atomic.LoadInt64(&s.stamp)
+		latency := int64(rtt)
+		if old != 0 {
+			elapsed := now.UnixNano() - stamp
+			if elapsed < 0 {
+				elapsed = 0
+			}
+			w := math.Exp(-float64(elapsed) / float64(DecayTime))
+			latency = int64(float64(old)*w + float64(rtt)*(1-w))
+		}
+
+		if atomic.CompareAndSwapInt64(&s.latency, old, latency) {
+			atomic.StoreInt64(&s.stamp, now.UnixNano())
+			return
+		}
+	}
+}
+
+// Feedback 可选接口,请求结束后反馈调用结果,可用于摘除异常节点
+type Feedback interface {
+	Feedback(err error, latency time.Duration)
+}
+
+// Begin 开始统计一次请求,返回的函数需要在请求结束时调用
+// 节点实现Stater时更新负载统计,实现Feedback时反馈调用结果
+func Begin(node Node) func(err error) {
+	var end func(err error)
+	if st, ok := node.(Stater); ok {
+		end = st.Stats().Begin()
+	}
+
+	fb, _ := node.(Feedback)
+	if end == nil && fb == nil {
+		return func(err error) {}
+	}
+
+	start := time.Now()
+	return func(err error) {
+		if end != nil {
+			end(err)
+		}
+		if fb != nil {
+			fb.Feedback(err, time.Since(start))
+		}
+	}
+}
+
+func weightOf(node Node) int64 {
+	if w, ok := node.(Weighter); ok && w.Weight() > 0 {
+		return int64(w.Weight())
+	}
+
+	return DefaultWeight
+}
+
+func zoneOf(node Node) string {
+	if z, ok := node.(Zoner); ok {
+		return z.Zone()
+	}
+
+	return ""
+}
+
+func statsOf(node Node) *Stats {
+	if st, ok := node.(Stater); ok {
+		return st.Stats()
+	}
+
+	return nil
+}
@@ -43,3 +43,155 @@ func Hash(nodes []Node, key uint64) Next {
 		return nodes[id], nil
 	}
 }
+
+// WeightedRoundRobin 平滑加权轮询(nginx smooth weighted round-robin)
+// 每次选择时所有节点的当前权重加上各自的权重,选择当前权重最大的节点,并减去总权重
+// 这样权重大的节点不会被连续选中,选择结果更加平滑,比如权重{5,1,1}的选择序列为a,a,b,a,c,a,a
+// 当前权重保存在节点的Stats中,因此需要节点实现Stater接口,否则退化为加权随机
+// 返回的Strategy使用独立的锁,通常每个Selector创建一个,不同Selector之间不会相互阻塞
+func WeightedRoundRobin() Strategy {
+	mux := &sync.Mutex{}
+	return func(nodes []Node) Next {
+		return func() (Node, error) {
+			// 先检查所有节点,防止修改部分节点的当前权重后退化为加权随机
+			stats := make([]*Stats, len(nodes))
+			for i, n := range nodes {
+				if stats[i] = statsOf(n); stats[i] == nil {
+					return weightedRandom(nodes), nil
+				}
+			}
+
+			mux.Lock()
+			defer mux.Unlock()
+
+			best := -1
+			var total int64
+			for i, n := range nodes {
+				w := weightOf(n)
+				stats[i].current += w
+				total += w
+				if best == -1 || stats[i].current > stats[best].current {
+					best = i
+				}
+			}
+
+			stats[best].current -= total
+			return nodes[best], nil
+		}
+	}
+}
+
+// WeightedRandom 按照权重随机选择
+func WeightedRandom(nodes []Node) Next {
+	return func() (Node, error) {
+		return weightedRandom(nodes), nil
+	}
+}
+
+func weightedRandom(nodes []Node) Node {
+	var total int64
+	for _, n := range nodes {
+		total += weightOf(n)
+	}
+
+	r := rand.Int63n(total)
+	for _, n := range nodes {
+		r -= weightOf(n)
+		if r < 0 {
+			return n
+		}
+	}
+
+	return nodes[len(nodes)-1]
+}
+
+// LeastLoaded 选择正在处理请求数最少的节点,会考虑权重,即选择inflight/weight最小的节点
+// 相同负载时从随机位置开始选择,防止所有请求都集中在第一个节点上,节点需要实现Stater接口
+func LeastLoaded(nodes []Node) Next {
+	return func() (Node, error) {
+		offset := rand.Intn(len(nodes))
+		var best Node
+		var bestLoad, bestWeight int64
+		for i := range nodes {
+			n := nodes[(offset+i)%len(nodes)]
+			var load int64
+			if st := statsOf(n); st != nil {
+				load = st.Inflight()
+			}
+			w := weightOf(n)
+			// load/w < bestLoad/bestWeight
+			if best == nil || load*bestWeight < bestLoad*w {
+				best, bestLoad, bestWeight = n, load, w
+			}
+		}
+
+		return best, nil
+	}
+}
+
+// P2C Power of Two Choices,随机选择两个节点,选择负载较低的一个
+// 负载为EWMA延迟*(inflight+1)/weight,没有延迟数据的节点负载为0,会被优先选择以便收集数据
+// 相比LeastLoaded,不需要遍历所有节点,并且能够避免所有客户端同时涌向同一个负载最低的节点
+func P2C(nodes []Node) Next {
+	return func() (Node, error) {
+		if len(nodes) == 1 {
+			return nodes[0], nil
+		}
+
+		i := rand.Intn(len(nodes))
+		j := rand.Intn(len(nodes) - 1)
+		if j >= i {
+			j++
+		}
+
+		a, b := nodes[i], nodes[j]
+		if p2cCost(b) < p2cCost(a) {
+			return b, nil
+		}
+
+		return a, nil
+	}
+}
+
+func p2cCost(n Node) float64 {
+	st := statsOf(n)
+	if st == nil {
+		return 0
+	}
+
+	return float64(st.Latency()) * float64(st.Inflight()+1) / float64(weightOf(n))
+}
+
+// PreferZone 优先返回指定可用区的节点,按照zones的顺序依次查找,都不存在时返回全部节点
+func PreferZone(nodes []Node, zones ...string) []Node {
+	for _, zone := range zones {
+		if zone == "" {
+			continue
+		}
+
+		var result []Node
+		for _, n := range nodes {
+			if zoneOf(n) == zone {
+				result = append(result, n)
+			}
+		}
+
+		if len(result) > 0 {
+			return result
+		}
+	}
+
+	return nodes
+}
+
+// ZoneAffinity 可用区亲和,优先选择相同可用区的节点,不存在时按照zones的顺序跨区选择,最后选择任意节点
+// 用于实现多区域多活,每个区域的调用尽量在本区域内完成,本区域没有可用节点时自动切换到其他区域
+func ZoneAffinity(strategy Strategy, zones ...string) Strategy {
+	if strategy == nil {
+		strategy = Random
+	}
+
+	return func(nodes []Node) Next {
+		return strategy(PreferZone(nodes, zones...))
+	}
+}
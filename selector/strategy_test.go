@@ -0,0 +1,153 @@
+package selector
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/anet"
+)
+
+type testNode struct {
+	id     string
+	weight int
+	zone   string
+	stats  Stats
+}
+
+func (n *testNode) Id() string                             { return n.id }
+func (n *testNode) Addr() string                           { return n.id }
+func (n *testNode) Conn(tran anet.Tran) (anet.Conn, error) { return nil, nil }
+func (n *testNode) Weight() int                            { return n.weight }
+func (n *testNode) Zone() string                           { return n.zone }
+func (n *testNode) Stats() *Stats                          { return &n.stats }
+
+// plainNode 没有实现Stater的节点
+type plainNode struct {
+	id string
+}
+
+func (n *plainNode) Id() string                             { return n.id }
+func (n *plainNode) Addr() string                           { return n.id }
+func (n *plainNode) Conn(tran anet.Tran) (anet.Conn, error) { return nil, nil }
+
+func TestWeightedRoundRobin(t *testing.T) {
+	nodes := []Node{&testNode{id: "a", weight: 5}, &testNode{id: "b", weight: 1}, &testNode{id: "c", weight: 1}}
+
+	// 每次Select都会创建新的Next,状态保存在节点中
+	strategy := WeightedRoundRobin()
+	var ids []string
+	for i := 0; i < 7; i++ {
+		node, _ := strategy(nodes)()
+		ids = append(ids, node.Id())
+	}
+
+	if seq := strings.Join(ids, ""); seq != "aabacaa" {
+		t.Fatalf("bad sequence, %s", seq)
+	}
+
+	// 存在没有Stats的节点时退化为加权随机,不能修改其他节点的当前权重
+	a := &testNode{id: "a", weight: 5}
+	mixed := []Node{a, &plainNode{id: "p"}}
+	for i := 0; i < 10; i++ {
+		_, _ = strategy(mixed)()
+	}
+	if a.stats.current != 0 {
+		t.Fatalf("current changed, %d", a.stats.current)
+	}
+}
+
+func TestLeastLoaded(t *testing.T) {
+	a := &testNode{id: "a"}
+	b := &testNode{id: "b"}
+	nodes := []Node{a, b}
+
+	done := Begin(a)
+	for i := 0; i < 10; i++ {
+		if node, _ := LeastLoaded(nodes)(); node != b {
+			t.Fatalf("select busy node, %s", node.Id())
+		}
+	}
+
+	done(nil)
+	if a.stats.Inflight() != 0 {
+		t.Fatalf("bad inflight, %d", a.stats.Inflight())
+	}
+
+	// 权重是a的两倍,可以承担两倍的负载
+	b.weight = DefaultWeight * 2
+	Begin(a)
+	Begin(b)
+	if node, _ := LeastLoaded(nodes)(); node != b {
+		t.Fatalf("weight not considered, %s", node.Id())
+	}
+}
+
+func TestP2C(t *testing.T) {
+	fast := &testNode{id: "fast"}
+	slow := &testNode{id: "slow"}
+	fast.stats.observe(time.Now(), time.Millisecond)
+	slow.stats.observe(time.Now(), time.Millisecond*100)
+
+	nodes := []Node{fast, slow}
+	for i := 0; i < 10; i++ {
+		if node, _ := P2C(nodes)(); node != fast {
+			t.Fatalf("select slow node")
+		}
+	}
+
+	// EWMA随时间衰减,旧数据权重降低
+	slow.stats.observe(time.Now().Add(DecayTime*10), time.Millisecond)
+	if l := slow.stats.Latency(); l > time.Millisecond*2 {
+		t.Fatalf("latency not decayed, %v", l)
+	}
+}
+
+func TestZoneAffinity(t *testing.T) {
+	nodes := []Node{&testNode{id: "a1", zone: "a"}, &testNode{id: "b1", zone: "b"}, &testNode{id: "c1", zone: "c"}}
+
+	for i := 0; i < 10; i++ {
+		if node, _ := ZoneAffinity(Random, "b")(nodes)(); node.Id() != "b1" {
+			t.Fatalf("bad zone, %s", node.Id())
+		}
+	}
+
+	// 本区域没有节点时,按照顺序跨区选择
+	if node, _ := ZoneAffinity(nil, "x", "c")(nodes)(); node.Id() != "c1" {
+		t.Fatalf("bad fallback zone, %s", node.Id())
+	}
+
+	o := &Options{Zone: "a"}
+	if node, _ := o.GetNext(nodes)(); node.Id() != "a1" {
+		t.Fatalf("bad options zone, %s", node.Id())
+	}
+
+	if got := PreferZone(nodes, "x"); len(got) != len(nodes) {
+		t.Fatalf("should fallback to all nodes, %d", len(got))
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	var nodes []Node
+	for i := 0; i < 4; i++ {
+		nodes = append(nodes, &testNode{id: string(rune('a' + i))})
+	}
+
+	o := &Options{Hash: 12345, HashMode: HashRing}
+	first, _ := o.GetNext(nodes)()
+	for i := 0; i < 10; i++ {
+		if node, _ := o.GetNext(nodes)(); node != first {
+			t.Fatalf("not consistent, %s != %s", node.Id(), first.Id())
+		}
+	}
+
+	// 有界负载:热点节点超过上限时顺延到下一个节点
+	o.LoadFactor = 1.25
+	for i := 0; i < 3; i++ {
+		Begin(first)
+	}
+	node, _ := o.GetNext(nodes)()
+	if node == first {
+		t.Fatalf("bounded load not work")
+	}
+}
@@ -8,26 +8,39 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jeckbjy/gsk/store"
 )
 
 func New(opts ...Option) store.Store {
-	o := Options{}
+	o := Options{CacheSize: DefaultCacheSize, SweepInterval: DefaultSweepInterval}
 	for _, fn := range opts {
 		fn(&o)
 	}
 
-	s := &fileStore{base: o.Base}
+	s := &fileStore{base: o.Base, opts: o, watchers: make(map[*fileWatcher]struct{}), quit: make(chan struct{})}
 	return s
 }
 
 // 基于本地文件的存储系统
-// 目前不支持watch
-// Get,List不支持MVCC
+// 写入时先写临时文件再重命名,读取时不会读到不完整的数据
+// 元数据文件中保存全局递增的revision,以及每个key的版本信息,通过store以外的方式修改的文件没有版本信息
+// Watch基于fsnotify,Prefix时会递归监听所有子目录,事件中的Prev来自于缓存
+// Get,List不支持查询历史版本
+//
+// 实现了store.Transactional,读写时会加文件锁,多个进程可以共享同一个目录
+// 租约信息同样保存在元数据中,过期的key在读取时视为不存在,由后台协程或者下一次写入时删除
 type fileStore struct {
-	base string
+	base     string
+	opts     Options
+	rw       sync.RWMutex
+	mux      sync.Mutex
+	watchers map[*fileWatcher]struct{}
+	sweeping bool
+	quit     chan struct{}
+	once     sync.Once
 }
 
 func (f *fileStore) normalize(key string) string {
@@ -38,6 +51,132 @@ func (f *fileStore) normalize(key string) string {
 	}
 }
 
+// canonical 统一key的格式,用于查询版本信息以及Watch时匹配
+func (f *fileStore) canonical(key string) string {
+	if f.base != "" {
+		return strings.TrimPrefix(path.Clean("/"+key), "/")
+	}
+
+	return path.Clean(key)
+}
+
+// keyOf 通过文件路径获取key
+func (f *fileStore) keyOf(name string) string {
+	if f.base != "" {
+		if rel, err := filepath.Rel(f.base, name); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+
+	return path.Clean(filepath.ToSlash(name))
+}
+
+func (f *fileStore) metaPath() string {
+	if f.base != "" {
+		return filepath.Join(f.base, metaFile)
+	}
+
+	return metaFile
+}
+
+func (f *fileStore) loadMeta() (*meta, error) {
+	return loadMeta(f.metaPath())
+}
+
+// lock 加锁,进程内使用读写锁,进程间使用文件锁
+func (f *fileStore) lock(exclusive bool) (func(), error) {
+	if exclusive {
+		f.rw.Lock()
+	} else {
+		f.rw.RLock()
+	}
+
+	unlock := func() {
+		if exclusive {
+			f.rw.Unlock()
+		} else {
+			f.rw.RUnlock()
+		}
+	}
+
+	name := lockFile
+	if f.base != "" {
+		if err := os.MkdirAll(f.base, os.ModePerm); err != nil {
+			unlock()
+			return nil, err
+		}
+		name = filepath.Join(f.base, lockFile)
+	}
+
+	funlock, err := flock(name, exclusive)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+
+	return func() {
+		funlock()
+		unlock()
+	}, nil
+}
+
+// view 加读锁并加载元数据
+func (f *fileStore) view(fn func(m *meta) error) error {
+	unlock, err := f.lock(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := f.loadMeta()
+	if err != nil {
+		return err
+	}
+
+	return fn(m)
+}
+
+// update 加写锁并加载元数据,会先删除租约过期的key
+// fn需要自己保存元数据,因为元数据需要在重命名文件之前保存
+func (f *fileStore) update(fn func(m *meta) error) error {
+	unlock, err := f.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := f.loadMeta()
+	if err != nil {
+		return err
+	}
+
+	if err := f.sweep(m); err != nil {
+		return err
+	}
+
+	return fn(m)
+}
+
+// newKV 读取文件并填充版本信息
+func (f *fileStore) newKV(key string, name string, keyOnly bool, m *meta) (*store.KV, error) {
+	kv := &store.KV{Key: key}
+	if km := m.Keys[f.canonical(key)]; km != nil {
+		kv.CreateRevision = km.Create
+		kv.ModifyRevision = km.Modify
+		kv.Version = km.Version
+	}
+
+	if !keyOnly {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		kv.Value = data
+	}
+
+	return kv, nil
+}
+
 func (f *fileStore) Name() string {
 	return "file"
 }
@@ -45,6 +184,9 @@ func (f *fileStore) Name() string {
 func (f *fileStore) List(ctx context.Context, key string, opts ...store.Option) ([]*store.KV, error) {
 	o := store.Options{}
 	o.Build(opts...)
+	if o.Revision != 0 {
+		return nil, store.ErrNotSupport
+	}
 
 	// 当前目录全部
 	if key == "" {
@@ -52,63 +194,66 @@ func (f *fileStore) List(ctx context.Context, key string, opts ...store.Option)
 	}
 
 	name := f.normalize(key)
-	s, err := os.Stat(name)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, store.ErrNotFound
+	results := make([]*store.KV, 0)
+	err := f.view(func(m *meta) error {
+		s, err := os.Stat(name)
+		if err != nil {
+			return err
 		}
 
-		return nil, err
-	}
+		now := time.Now().UnixNano()
+		if !s.IsDir() {
+			if m.expired(f.canonical(key), now) {
+				return nil
+			}
 
-	results := make([]*store.KV, 0)
+			kv, err := f.newKV(key, name, o.KeyOnly, m)
+			if err != nil {
+				return err
+			}
+			results = append(results, kv)
+			return nil
+		}
 
-	if s.IsDir() {
 		// 遍历所有子目录
-		_ = filepath.Walk(name, func(path string, info os.FileInfo, err error) error {
+		return filepath.Walk(name, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
 
+			// ignore hide
+			if isHidden(path) && path != name {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
 			// ignore dir
 			if info.IsDir() {
 				return nil
 			}
 
-			// ignore hide
-			if strings.HasPrefix(path, ".") {
+			fileKey := f.keyOf(path)
+			if m.expired(fileKey, now) {
 				return nil
 			}
 
-			fileKey := path
-			if f.base != "" {
-				// remove base and /?
-				if len(path) > len(f.base)+1 {
-					fileKey = path[len(f.base)+1:]
-				}
-			}
-			if o.KeyOnly {
-				results = append(results, &store.KV{Key: fileKey})
-			} else {
-				data, err := ioutil.ReadFile(path)
-				if err != nil {
-					return nil
-				}
-				results = append(results, &store.KV{Key: fileKey, Value: data, ModifyRevision: info.ModTime().UnixNano() / int64(time.Millisecond)})
+			kv, err := f.newKV(fileKey, path, o.KeyOnly, m)
+			if err == nil {
+				results = append(results, kv)
 			}
 
 			return nil
 		})
-	} else {
-		if o.KeyOnly {
-			results = append(results, &store.KV{Key: key})
-		} else {
-			data, err := ioutil.ReadFile(name)
-			if err != nil {
-				return nil, err
-			}
-			results = append(results, &store.KV{Key: key, Value: data, ModifyRevision: s.ModTime().UnixNano() / int64(time.Millisecond)})
+	})
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, store.ErrNotFound
 		}
+
+		return nil, err
 	}
 
 	if len(results) == 0 {
@@ -121,51 +266,78 @@ func (f *fileStore) List(ctx context.Context, key string, opts ...store.Option)
 func (f *fileStore) Get(ctx context.Context, key string, opts ...store.Option) (*store.KV, error) {
 	o := store.Options{}
 	o.Build(opts...)
+	if o.Revision != 0 {
+		return nil, store.ErrNotSupport
+	}
+
+	var kv *store.KV
+	err := f.view(func(m *meta) (err error) {
+		kv, err = f.current(m, key, !o.KeyOnly)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if kv == nil {
+		return nil, store.ErrNotFound
+	}
+
+	return kv, nil
+}
 
+// current 查询当前数据,不存在或者租约过期返回nil
+func (f *fileStore) current(m *meta, key string, withValue bool) (*store.KV, error) {
 	name := f.normalize(key)
 	s, err := os.Stat(name)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, store.ErrNotFound
+			return nil, nil
 		}
 
 		return nil, err
 	}
 
-	if s.IsDir() {
-		return nil, store.ErrNotFound
+	if s.IsDir() || m.expired(f.canonical(key), time.Now().UnixNano()) {
+		return nil, nil
 	}
-	if o.KeyOnly {
-		return &store.KV{Key: key}, nil
-	} else {
-		data, err := ioutil.ReadFile(name)
-		if err != nil {
-			return nil, err
-		}
 
-		kv := &store.KV{Key: key, Value: data, ModifyRevision: s.ModTime().UnixNano() / int64(time.Millisecond)}
-		return kv, nil
+	kv, err := f.newKV(key, name, !withValue, m)
+	if os.IsNotExist(err) {
+		return nil, nil
 	}
+
+	return kv, err
 }
 
+// Put 先写入临时文件,更新元数据后再重命名
+// 如果重命名前进程退出,只会导致revision增加,不会破坏数据
 func (f *fileStore) Put(ctx context.Context, key string, value []byte) error {
-	name := f.normalize(key)
-	dir := path.Dir(name)
-	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-		return err
-	}
-	return ioutil.WriteFile(name, value, os.ModePerm)
+	return f.update(func(m *meta) error {
+		return f.apply(m, []store.Op{store.OpPut(key, value)})
+	})
 }
 
 func (f *fileStore) Delete(ctx context.Context, key string, opts ...store.Option) error {
 	o := store.Options{}
 	o.Build(opts...)
-	name := f.normalize(key)
-	if !o.Prefix {
-		return os.Remove(name)
-	} else {
-		return os.RemoveAll(name)
-	}
+
+	return f.update(func(m *meta) error {
+		if !o.Prefix {
+			if kv, err := f.current(m, key, false); err != nil {
+				return err
+			} else if kv == nil {
+				return store.ErrNotFound
+			}
+		} else if _, err := os.Stat(f.normalize(key)); err != nil {
+			if os.IsNotExist(err) {
+				return store.ErrNotFound
+			}
+			return err
+		}
+
+		return f.apply(m, []store.Op{store.OpDelete(key, opts...)})
+	})
 }
 
 func (f *fileStore) Exists(ctx context.Context, key string) (bool, error) {
@@ -183,16 +355,56 @@ func (f *fileStore) Exists(ctx context.Context, key string) (bool, error) {
 		return false, errors.New("is a dir,not file")
 	}
 
-	return true, nil
+	exists := true
+	err = f.view(func(m *meta) error {
+		exists = !m.expired(f.canonical(key), time.Now().UnixNano())
+		return nil
+	})
+
+	return exists, err
 }
 
+// Watch 监听文件变化,ctx结束或者Close后停止监听
+// 不支持通过Revision回放历史事件
 func (f *fileStore) Watch(ctx context.Context, key string, cb store.Callback, opts ...store.Option) error {
-	// https://github.com/fsnotify/fsnotify
-	// https://github.com/radovskyb/watcher
-	// TODO: implement me
-	return store.ErrNotSupport
+	o := store.Options{}
+	o.Build(opts...)
+	if o.Revision != 0 {
+		return store.ErrNotSupport
+	}
+
+	w, err := newWatcher(f, key, o.Prefix, cb)
+	if err != nil {
+		return err
+	}
+
+	f.mux.Lock()
+	f.watchers[w] = struct{}{}
+	f.mux.Unlock()
+
+	go w.run(ctx)
+	return nil
+}
+
+func (f *fileStore) removeWatcher(w *fileWatcher) {
+	f.mux.Lock()
+	delete(f.watchers, w)
+	f.mux.Unlock()
 }
 
 func (f *fileStore) Close() error {
+	f.once.Do(func() {
+		close(f.quit)
+	})
+
+	f.mux.Lock()
+	watchers := f.watchers
+	f.watchers = make(map[*fileWatcher]struct{})
+	f.mux.Unlock()
+
+	for w := range watchers {
+		w.close()
+	}
+
 	return nil
 }
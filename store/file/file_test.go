@@ -1,16 +1,28 @@
 package file
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jeckbjy/gsk/store"
 )
 
 func TestFileStore(t *testing.T) {
-	s := New()
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := New(Base(dir))
 	key := "config"
 	val := "test"
-	err := s.Put(nil, key, []byte(val))
+	err = s.Put(nil, key, []byte(val))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -56,3 +68,123 @@ func TestFileStore(t *testing.T) {
 		t.Log(f.Key, string(f.Value))
 	}
 }
+
+func TestRevision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := New(Base(dir))
+	_ = s.Put(nil, "cfg/a", []byte("1"))
+	_ = s.Put(nil, "cfg/a", []byte("2"))
+	_ = s.Put(nil, "cfg/b", []byte("3"))
+
+	kv, err := s.Get(nil, "cfg/a")
+	if err != nil || string(kv.Value) != "2" || kv.CreateRevision != 1 || kv.ModifyRevision != 2 || kv.Version != 2 {
+		t.Fatalf("bad kv, %+v, %+v", kv, err)
+	}
+
+	// 没有临时文件以及元数据
+	kvs, err := s.List(nil, "")
+	if err != nil || len(kvs) != 2 || kvs[1].Key != "cfg/b" || kvs[1].ModifyRevision != 3 {
+		t.Fatalf("bad list, %+v, %+v", kvs, err)
+	}
+
+	// 重新打开后revision继续递增
+	if err := s.Delete(nil, "cfg", store.Prefix()); err != nil {
+		t.Fatal(err)
+	}
+	s = New(Base(dir))
+	_ = s.Put(nil, "cfg/a", []byte("4"))
+	kv, err = s.Get(nil, "cfg/a")
+	if err != nil || kv.CreateRevision != 5 || kv.Version != 1 {
+		t.Fatalf("bad revision, %+v, %+v", kv, err)
+	}
+
+	if err := s.Delete(nil, "cfg/c"); err != store.ErrNotFound {
+		t.Fatalf("expect not found, %+v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := New(Base(dir))
+	defer s.Close()
+	_ = s.Put(nil, "svc/a", []byte("1"))
+
+	mux := sync.Mutex{}
+	var events []*store.Event
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = s.Watch(ctx, "svc", func(ev *store.Event) {
+		mux.Lock()
+		events = append(events, ev)
+		mux.Unlock()
+	}, store.Prefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wait := func(n int) []*store.Event {
+		deadline := time.Now().Add(time.Second * 5)
+		for time.Now().Before(deadline) {
+			mux.Lock()
+			if len(events) >= n {
+				list := events
+				mux.Unlock()
+				return list
+			}
+			mux.Unlock()
+			time.Sleep(time.Millisecond * 10)
+		}
+		t.Fatalf("wait events timeout, %+v", len(events))
+		return nil
+	}
+
+	_ = s.Put(nil, "svc/a", []byte("2"))
+	list := wait(1)
+	if list[0].Type != store.PUT || string(list[0].Data.Value) != "2" || string(list[0].Prev.Value) != "1" || list[0].Data.ModifyRevision != 2 {
+		t.Fatalf("bad put event, %+v", list[0])
+	}
+
+	// 递归监听新建的子目录
+	_ = s.Put(nil, "svc/sub/b", []byte("3"))
+	list = wait(2)
+	if list[1].Data.Key != "svc/sub/b" || list[1].Prev != nil {
+		t.Fatalf("bad sub event, %+v", list[1])
+	}
+
+	// 外部直接写入的文件也能监听到,非原子写入可能会产生多个事件
+	time.Sleep(time.Millisecond * 50)
+	_ = ioutil.WriteFile(filepath.Join(dir, "svc/sub/c"), []byte("4"), 0644)
+	for n := 3; ; n++ {
+		list = wait(n)
+		if ev := list[n-1]; ev.Data.Key == "svc/sub/c" && string(ev.Data.Value) == "4" {
+			break
+		}
+	}
+
+	_ = s.Delete(nil, "svc/a")
+	list = wait(len(list) + 1)
+	last := list[len(list)-1]
+	if last.Type != store.DELETE || last.Data.Key != "svc/a" || string(last.Prev.Value) != "2" {
+		t.Fatalf("bad delete event, %+v", last)
+	}
+
+	cancel()
+	time.Sleep(time.Millisecond * 50)
+	_ = s.Put(nil, "svc/d", []byte("5"))
+	time.Sleep(time.Millisecond * 50)
+	mux.Lock()
+	defer mux.Unlock()
+	if len(events) != len(list) {
+		t.Fatalf("watch not stopped, %+v", len(events))
+	}
+}
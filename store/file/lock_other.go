@@ -0,0 +1,8 @@
+// +build windows plan9
+
+package file
+
+// flock 暂不支持跨进程的文件锁,只能保证进程内互斥
+func flock(name string, exclusive bool) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,168 @@
+package file
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 元数据文件和锁文件,隐藏文件不会被List返回
+const (
+	metaFile = ".meta"
+	lockFile = ".lock"
+)
+
+// keyMeta 单个key的版本信息
+type keyMeta struct {
+	Create  int64 `json:"create"`
+	Modify  int64 `json:"modify"`
+	Version int64 `json:"version"`
+	Lease   int64 `json:"lease,omitempty"`
+}
+
+// leaseMeta 租约信息,时间单位为纳秒
+type leaseMeta struct {
+	TTL    int64 `json:"ttl"`
+	Expire int64 `json:"expire"`
+}
+
+// meta 记录全局revision以及每个key的版本信息
+// 每次修改revision加一,通过store以外的方式修改的文件没有版本信息
+type meta struct {
+	Rev     int64                `json:"rev"`
+	LeaseID int64                `json:"lease_id"`
+	Keys    map[string]*keyMeta  `json:"keys"`
+	Leases  map[int64]*leaseMeta `json:"leases,omitempty"`
+}
+
+func loadMeta(name string) (*meta, error) {
+	m := &meta{Keys: make(map[string]*keyMeta), Leases: make(map[int64]*leaseMeta)}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return m, nil
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+
+	if m.Keys == nil {
+		m.Keys = make(map[string]*keyMeta)
+	}
+	if m.Leases == nil {
+		m.Leases = make(map[int64]*leaseMeta)
+	}
+
+	return m, nil
+}
+
+func (m *meta) save(name string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(name, data)
+}
+
+// put 写入数据,使用当前的revision,调用前需要先增加revision
+func (m *meta) put(key string, lease int64) {
+	km := m.Keys[key]
+	if km == nil {
+		km = &keyMeta{Create: m.Rev}
+		m.Keys[key] = km
+	}
+	km.Modify = m.Rev
+	km.Version++
+	km.Lease = lease
+}
+
+// remove 删除key,prefix为true时删除所有子key
+func (m *meta) remove(key string, prefix bool) {
+	for k := range m.Keys {
+		if k == key || (prefix && isChild(key, k)) {
+			delete(m.Keys, k)
+		}
+	}
+}
+
+// alive 判断租约是否有效
+func (m *meta) alive(lease int64, now int64) bool {
+	l := m.Leases[lease]
+	return l != nil && l.Expire > now
+}
+
+// expired 判断key绑定的租约是否已经过期,过期的key在删除前也视为不存在
+func (m *meta) expired(key string, now int64) bool {
+	km := m.Keys[key]
+	return km != nil && km.Lease != 0 && !m.alive(km.Lease, now)
+}
+
+// isChild 判断key是否在dir目录下,dir为空表示根目录
+func isChild(dir, key string) bool {
+	if dir == "" || dir == "." {
+		return true
+	}
+
+	return strings.HasPrefix(key, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// isHidden 隐藏文件,包括元数据以及写入时的临时文件
+func isHidden(name string) bool {
+	return strings.HasPrefix(filepath.Base(name), ".")
+}
+
+// writeFile 先写入同目录下的临时文件,然后重命名,保证读取时不会读到不完整的数据
+func writeFile(name string, data []byte) error {
+	tmp, err := prepareFile(name, data)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, name); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// prepareFile 写入临时文件,返回临时文件名
+func prepareFile(name string, data []byte) (string, error) {
+	dir, base := filepath.Split(name)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
@@ -1,7 +1,16 @@
 package file
 
+import "time"
+
+const (
+	DefaultCacheSize     = 1024
+	DefaultSweepInterval = time.Millisecond * 500
+)
+
 type Options struct {
-	Base string
+	Base          string
+	CacheSize     int           // Watch时缓存的数据个数,用于生成事件中的Prev
+	SweepInterval time.Duration // 检测租约过期的间隔
 }
 
 type Option func(o *Options)
@@ -11,3 +20,15 @@ func Base(s string) Option {
 		o.Base = s
 	}
 }
+
+func CacheSize(n int) Option {
+	return func(o *Options) {
+		o.CacheSize = n
+	}
+}
+
+func SweepInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.SweepInterval = d
+	}
+}
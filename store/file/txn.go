@@ -0,0 +1,274 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+)
+
+var ErrBadTTL = errors.New("file: ttl must be positive")
+
+func (f *fileStore) Txn(ctx context.Context, txn *store.Txn) (*store.TxnResponse, error) {
+	rsp := &store.TxnResponse{}
+	err := f.update(func(m *meta) error {
+		rsp.Succeeded = true
+		for i := range txn.If {
+			c := &txn.If[i]
+			kv, err := f.current(m, c.Key, c.Target == store.CompareValue)
+			if err != nil {
+				return err
+			}
+
+			if !c.Match(kv) {
+				rsp.Succeeded = false
+				break
+			}
+		}
+
+		ops := txn.Then
+		if !rsp.Succeeded {
+			ops = txn.Else
+		}
+
+		err := f.apply(m, ops)
+		rsp.Revision = m.Rev
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rsp, nil
+}
+
+func (f *fileStore) Grant(ctx context.Context, ttl time.Duration) (store.LeaseID, error) {
+	if ttl <= 0 {
+		return 0, ErrBadTTL
+	}
+
+	var id int64
+	err := f.update(func(m *meta) error {
+		m.LeaseID++
+		id = m.LeaseID
+		m.Leases[id] = &leaseMeta{TTL: int64(ttl), Expire: time.Now().UnixNano() + int64(ttl)}
+		return m.save(f.metaPath())
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	f.startSweep()
+	return store.LeaseID(id), nil
+}
+
+func (f *fileStore) KeepAlive(ctx context.Context, id store.LeaseID) error {
+	return f.update(func(m *meta) error {
+		// 过期的租约已经在update中删除
+		l := m.Leases[int64(id)]
+		if l == nil {
+			return store.ErrLeaseNotFound
+		}
+
+		l.Expire = time.Now().UnixNano() + l.TTL
+		return m.save(f.metaPath())
+	})
+}
+
+func (f *fileStore) Revoke(ctx context.Context, id store.LeaseID) error {
+	return f.update(func(m *meta) error {
+		if m.Leases[int64(id)] == nil {
+			return store.ErrLeaseNotFound
+		}
+
+		return f.revoke(m, []int64{int64(id)})
+	})
+}
+
+// apply 执行写操作,所有操作共用一个revision
+// 先写入所有临时文件,然后保存元数据,最后重命名或者删除文件
+func (f *fileStore) apply(m *meta, ops []store.Op) error {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	now := time.Now().UnixNano()
+	for _, op := range ops {
+		if op.Type == store.OpTypePut && op.Lease != 0 && !m.alive(int64(op.Lease), now) {
+			return store.ErrLeaseNotFound
+		}
+	}
+
+	tmps := make([]string, len(ops))
+	cleanup := func() {
+		for _, tmp := range tmps {
+			if tmp != "" {
+				_ = os.Remove(tmp)
+			}
+		}
+	}
+
+	for i, op := range ops {
+		if op.Type != store.OpTypePut {
+			continue
+		}
+
+		name := f.normalize(op.Key)
+		if err := os.MkdirAll(path.Dir(name), os.ModePerm); err != nil {
+			cleanup()
+			return err
+		}
+
+		tmp, err := prepareFile(name, op.Value)
+		if err != nil {
+			cleanup()
+			return err
+		}
+		tmps[i] = tmp
+	}
+
+	m.Rev++
+	for _, op := range ops {
+		switch op.Type {
+		case store.OpTypePut:
+			m.put(f.canonical(op.Key), int64(op.Lease))
+		case store.OpTypeDelete:
+			m.remove(f.canonical(op.Key), op.Prefix)
+		}
+	}
+
+	if err := m.save(f.metaPath()); err != nil {
+		cleanup()
+		return err
+	}
+
+	var result error
+	for i, op := range ops {
+		var err error
+		name := f.normalize(op.Key)
+		switch {
+		case op.Type == store.OpTypePut:
+			if err = os.Rename(tmps[i], name); err == nil {
+				tmps[i] = ""
+			}
+		case op.Prefix:
+			err = f.removeAll(name)
+		default:
+			err = os.Remove(name)
+		}
+
+		if err != nil && !os.IsNotExist(err) && result == nil {
+			result = err
+		}
+	}
+
+	cleanup()
+	return result
+}
+
+// removeAll 删除目录,删除根目录时需要保留元数据和锁文件
+func (f *fileStore) removeAll(name string) error {
+	root := f.base
+	if root == "" {
+		root = "."
+	}
+
+	if filepath.Clean(name) != filepath.Clean(root) {
+		return os.RemoveAll(name)
+	}
+
+	infos, err := ioutil.ReadDir(name)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		if isHidden(info.Name()) {
+			continue
+		}
+
+		if err := os.RemoveAll(filepath.Join(name, info.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sweep 删除过期的租约以及绑定的key
+func (f *fileStore) sweep(m *meta) error {
+	now := time.Now().UnixNano()
+	var expired []int64
+	for id, l := range m.Leases {
+		if l.Expire <= now {
+			expired = append(expired, id)
+		}
+	}
+
+	if len(expired) == 0 {
+		return nil
+	}
+
+	return f.revoke(m, expired)
+}
+
+// revoke 删除租约,以及绑定的key,使用一个revision
+func (f *fileStore) revoke(m *meta, ids []int64) error {
+	for _, id := range ids {
+		delete(m.Leases, id)
+	}
+
+	var keys []string
+	for k, km := range m.Keys {
+		if km.Lease != 0 && m.Leases[km.Lease] == nil {
+			keys = append(keys, k)
+			delete(m.Keys, k)
+		}
+	}
+
+	if len(keys) > 0 {
+		m.Rev++
+	}
+
+	if err := m.save(f.metaPath()); err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err := os.Remove(f.normalize(k)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startSweep 启动后台协程定期删除过期的key,保证Watch能够及时收到删除事件
+func (f *fileStore) startSweep() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.sweeping || f.opts.SweepInterval <= 0 {
+		return
+	}
+
+	f.sweeping = true
+	go func() {
+		ticker := time.NewTicker(f.opts.SweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = f.update(func(m *meta) error { return nil })
+			case <-f.quit:
+				return
+			}
+		}
+	}()
+}
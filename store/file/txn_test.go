@@ -0,0 +1,183 @@
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+)
+
+func TestTxn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := New(Base(dir))
+	defer s.Close()
+	ctx := context.Background()
+
+	// key不存在时才能创建
+	if ok, err := store.CompareAndSwap(ctx, s, "cfg/a", 0, []byte("1")); !ok || err != nil {
+		t.Fatalf("cas fail, %+v", err)
+	}
+	if ok, err := store.CompareAndSwap(ctx, s, "cfg/a", 0, []byte("2")); ok || err != nil {
+		t.Fatalf("cas should fail, %+v", err)
+	}
+
+	tx := s.(store.Transactional)
+	rsp, err := tx.Txn(ctx, &store.Txn{
+		If: []store.Compare{
+			store.CmpValue("cfg/a", store.Equal, []byte("1")),
+			store.CmpExists("cfg/b", false),
+			store.CmpModify("cfg/a", store.Less, 2),
+		},
+		Then: []store.Op{store.OpPut("cfg/a", []byte("2")), store.OpPut("cfg/b", []byte("3"))},
+		Else: []store.Op{store.OpPut("cfg/fail", []byte("x"))},
+	})
+	if err != nil || !rsp.Succeeded || rsp.Revision != 2 {
+		t.Fatalf("bad txn, %+v, %+v", rsp, err)
+	}
+
+	// 同一个事务共用一个revision
+	a, _ := s.Get(ctx, "cfg/a")
+	b, _ := s.Get(ctx, "cfg/b")
+	if a.ModifyRevision != 2 || b.ModifyRevision != 2 || a.Version != 2 || b.Version != 1 {
+		t.Fatalf("bad revision, %+v, %+v", a, b)
+	}
+
+	rsp, err = tx.Txn(ctx, &store.Txn{
+		If:   []store.Compare{store.CmpVersion("cfg/a", store.Greater, 5)},
+		Then: []store.Op{store.OpDelete("cfg", store.Prefix())},
+		Else: []store.Op{store.OpDelete("cfg/b")},
+	})
+	if err != nil || rsp.Succeeded {
+		t.Fatalf("bad else, %+v, %+v", rsp, err)
+	}
+	if ok, _ := s.Exists(ctx, "cfg/b"); ok {
+		t.Fatal("else not applied")
+	}
+	if ok, _ := s.Exists(ctx, "cfg/a"); !ok {
+		t.Fatal("then applied")
+	}
+
+	if ok, err := store.CompareAndDelete(ctx, s, "cfg/a", 2); !ok || err != nil {
+		t.Fatalf("cad fail, %+v", err)
+	}
+}
+
+func TestLease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := New(Base(dir), SweepInterval(time.Millisecond*10))
+	defer s.Close()
+	ctx := context.Background()
+	tx := s.(store.Transactional)
+
+	deleted := make(chan string, 10)
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	_ = s.Watch(wctx, "svc", func(ev *store.Event) {
+		if ev.Type == store.DELETE {
+			deleted <- ev.Data.Key
+		}
+	}, store.Prefix())
+
+	id, err := store.PutWithTTL(ctx, s, "svc/a", []byte("1"), time.Millisecond*100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 续约后不会过期
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond * 50)
+		if err := tx.KeepAlive(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ok, _ := s.Exists(ctx, "svc/a"); !ok {
+		t.Fatal("expired after keepalive")
+	}
+
+	// 不续约则自动删除,并产生删除事件
+	select {
+	case key := <-deleted:
+		if key != "svc/a" {
+			t.Fatalf("bad key, %+v", key)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("lease not expired")
+	}
+	if _, err := s.Get(ctx, "svc/a"); err != store.ErrNotFound {
+		t.Fatalf("expect not found, %+v", err)
+	}
+	if err := tx.KeepAlive(ctx, id); err != store.ErrLeaseNotFound {
+		t.Fatalf("expect lease not found, %+v", err)
+	}
+
+	// Revoke立即删除
+	id, _ = tx.Grant(ctx, time.Minute)
+	_, _ = tx.Txn(ctx, &store.Txn{Then: []store.Op{store.OpPutWithLease("svc/b", []byte("2"), id), store.OpPut("svc/c", []byte("3"))}})
+	if err := tx.Revoke(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.Exists(ctx, "svc/b"); ok {
+		t.Fatal("revoke fail")
+	}
+	if ok, _ := s.Exists(ctx, "svc/c"); !ok {
+		t.Fatal("revoke delete key without lease")
+	}
+}
+
+// 多个实例共享同一个目录,通过文件锁保证CAS的正确性
+func TestConcurrentCAS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const workers = 4
+	const count = 20
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := New(Base(dir))
+			for n := 0; n < count; {
+				var version int64
+				value := 0
+				if kv, err := s.Get(nil, "counter"); err == nil {
+					version = kv.Version
+					value, _ = strconv.Atoi(string(kv.Value))
+				}
+
+				ok, err := store.CompareAndSwap(nil, s, "counter", version, []byte(strconv.Itoa(value+1)))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if ok {
+					n++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	kv, err := New(Base(dir)).Get(nil, "counter")
+	if err != nil || string(kv.Value) != strconv.Itoa(workers*count) {
+		t.Fatalf("bad counter, %+v, %+v", kv, err)
+	}
+}
@@ -0,0 +1,251 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/util/fsnotify"
+)
+
+// fileWatcher 基于fsnotify监听文件变化
+// 监听单个key时监听所在目录,因为写入时会重命名,直接监听文件会丢失事件
+// 监听前缀时递归监听所有子目录,新建的目录会自动加入监听
+// cache记录所有存在的key,并缓存最近的数据,用于过滤重复的写事件,以及生成事件中的Prev
+// 缓存的数据超过容量后随机淘汰,被淘汰的key事件中不再有Prev
+type fileWatcher struct {
+	store   *fileStore
+	key     string
+	prefix  bool
+	cb      store.Callback
+	watcher *fsnotify.Watcher
+	cache   map[string]*store.KV
+	cached  int
+	size    int
+	quit    chan struct{}
+	once    sync.Once
+}
+
+func newWatcher(f *fileStore, key string, prefix bool, cb store.Callback) (*fileWatcher, error) {
+	w := &fileWatcher{
+		store:  f,
+		key:    f.canonical(key),
+		prefix: prefix,
+		cb:     cb,
+		cache:  make(map[string]*store.KV),
+		size:   f.opts.CacheSize,
+		quit:   make(chan struct{}),
+	}
+
+	root := w.root(f.normalize(key))
+	if err := os.MkdirAll(root, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w.watcher = watcher
+
+	if prefix {
+		err = w.add(root, false)
+	} else if err = watcher.Add(root); err == nil {
+		w.load(f.normalize(key), false)
+	}
+
+	if err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// root 需要监听的目录
+func (w *fileWatcher) root(name string) string {
+	if name == "" {
+		return "."
+	}
+
+	if w.prefix {
+		if s, err := os.Stat(name); (err == nil && s.IsDir()) || (os.IsNotExist(err) && strings.HasSuffix(name, "/")) {
+			return name
+		}
+	}
+
+	return filepath.Dir(name)
+}
+
+// add 递归添加监听目录,并加载已经存在的文件
+// notify为true时,新发现的文件会产生PUT事件,用于新建目录时补充丢失的事件
+func (w *fileWatcher) add(dir string, notify bool) error {
+	return filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if name != dir && isHidden(name) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			return w.watcher.Add(name)
+		}
+
+		w.load(name, notify)
+		return nil
+	})
+}
+
+func (w *fileWatcher) match(key string) bool {
+	if w.prefix {
+		return isChild(w.key, key) || strings.HasPrefix(key, w.key)
+	}
+
+	return key == w.key
+}
+
+// load 读取文件并放入缓存,如果数据有变化并且notify为true,则产生PUT事件
+func (w *fileWatcher) load(name string, notify bool) {
+	key := w.store.keyOf(name)
+	if !w.match(key) {
+		return
+	}
+
+	var kv *store.KV
+	err := w.store.view(func(m *meta) (err error) {
+		if m.expired(key, time.Now().UnixNano()) {
+			return os.ErrNotExist
+		}
+
+		kv, err = w.store.newKV(key, name, false, m)
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	prev := w.cache[key]
+	if prev != nil && bytes.Equal(prev.Value, kv.Value) {
+		return
+	}
+
+	if prev == nil && w.cached >= w.size {
+		w.evict()
+	}
+
+	if w.cached < w.size {
+		if prev == nil {
+			w.cached++
+		}
+		w.cache[key] = kv
+	} else {
+		w.cache[key] = nil
+	}
+
+	if notify {
+		w.cb(&store.Event{Type: store.PUT, Data: kv, Prev: prev})
+	}
+}
+
+// remove 删除文件或者目录,目录删除时会通知所有缓存中的子key
+func (w *fileWatcher) remove(name string) {
+	key := w.store.keyOf(name)
+	var rev int64
+	_ = w.store.view(func(m *meta) error {
+		rev = m.Rev
+		return nil
+	})
+
+	for k, prev := range w.cache {
+		if k != key && !isChild(key, k) {
+			continue
+		}
+
+		if prev != nil {
+			w.cached--
+		}
+		delete(w.cache, k)
+		w.cb(&store.Event{Type: store.DELETE, Data: &store.KV{Key: k, ModifyRevision: rev}, Prev: prev})
+	}
+}
+
+// evict 随机淘汰一个缓存的数据,只保留key,删除时依然能够产生事件
+func (w *fileWatcher) evict() {
+	for k, kv := range w.cache {
+		if kv != nil {
+			w.cache[k] = nil
+			w.cached--
+			return
+		}
+	}
+}
+
+func (w *fileWatcher) run(ctx context.Context) {
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+
+	defer func() {
+		w.store.removeWatcher(w)
+		_ = w.watcher.Close()
+	}()
+
+	for {
+		select {
+		case ev, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handle(&ev)
+		case _, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+		case <-done:
+			return
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+func (w *fileWatcher) handle(ev *fsnotify.Event) {
+	if isHidden(ev.Name) {
+		return
+	}
+
+	switch {
+	case ev.Op.IsRemove() || ev.Op.IsRename():
+		w.remove(ev.Name)
+	case ev.Op.IsCreate() || ev.Op.IsWrite():
+		s, err := os.Stat(ev.Name)
+		if err != nil {
+			return
+		}
+
+		if s.IsDir() {
+			if w.prefix {
+				_ = w.add(ev.Name, true)
+			}
+		} else {
+			w.load(ev.Name, true)
+		}
+	}
+}
+
+func (w *fileWatcher) close() {
+	w.once.Do(func() {
+		close(w.quit)
+	})
+}
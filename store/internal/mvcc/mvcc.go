@@ -0,0 +1,404 @@
+package mvcc
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jeckbjy/gsk/store"
+)
+
+var (
+	ErrCompacted = store.ErrCompacted
+	ErrFutureRev = store.ErrFutureRev
+)
+
+// record 某个key在某个revision时的数据,KV为nil表示已经删除
+type record struct {
+	Rev int64
+	KV  *store.KV
+}
+
+// New 创建KV存储
+func New() *Store {
+	return &Store{keys: make(map[string][]*record), watchers: make(map[*watcher]struct{})}
+}
+
+// Store 多版本KV存储,不负责持久化,可用于实现store.Store
+//
+// revision: 全局递增,每次修改(一次Delete可能删除多个key)加一,初始为0
+// 每个key保存所有的历史版本,用于按照revision查询,以及Watch时回放历史事件
+// Compact后会删除指定revision之前的历史数据,此后不能再查询或者监听这些revision
+type Store struct {
+	mux       sync.RWMutex
+	rev       int64
+	compacted int64
+	keys      map[string][]*record
+	history   []*store.Event
+	watchers  map[*watcher]struct{}
+}
+
+// Rev 返回当前revision
+func (s *Store) Rev() int64 {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.rev
+}
+
+// Compacted 返回已经压缩的revision
+func (s *Store) Compacted() int64 {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.compacted
+}
+
+// Put 写入数据,返回新的数据和旧的数据
+func (s *Store) Put(key string, value []byte) (*store.KV, *store.KV) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.rev++
+	kv, prev := s.put(key, value)
+	return copyKV(kv, false), copyKV(prev, false)
+}
+
+// Delete 删除数据,prefix为true时删除所有前缀匹配的key,返回删除的个数
+// 同一次删除的所有key使用相同的revision,没有删除任何数据时revision不变
+func (s *Store) Delete(key string, prefix bool) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	keys := s.match(key, prefix)
+	if len(keys) == 0 {
+		return 0
+	}
+
+	s.rev++
+	s.remove(keys)
+	return len(keys)
+}
+
+// Txn 原子执行事务,先判断If中的条件,全部满足执行Then,否则执行Else,所有修改共用一个revision
+// check非空时在修改前调用,返回错误时不做任何修改,没有修改任何数据时revision不变
+func (s *Store) Txn(txn *store.Txn, check func(ops []store.Op) error) (bool, int64, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	succeeded := true
+	for i := range txn.If {
+		if !txn.If[i].Match(s.latest(txn.If[i].Key)) {
+			succeeded = false
+			break
+		}
+	}
+
+	ops := txn.Then
+	if !succeeded {
+		ops = txn.Else
+	}
+
+	if check != nil {
+		if err := check(ops); err != nil {
+			return succeeded, s.rev, err
+		}
+	}
+
+	changed := false
+	s.rev++
+	for _, op := range ops {
+		switch op.Type {
+		case store.OpTypePut:
+			s.put(op.Key, op.Value)
+			changed = true
+		case store.OpTypeDelete:
+			if keys := s.match(op.Key, op.Prefix); len(keys) > 0 {
+				s.remove(keys)
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		s.rev--
+	}
+
+	return succeeded, s.rev, nil
+}
+
+// Get 查询数据,rev<=0表示查询最新数据
+func (s *Store) Get(key string, rev int64, keyOnly bool) (*store.KV, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if err := s.checkRev(rev); err != nil {
+		return nil, err
+	}
+
+	kv := s.at(key, rev)
+	if kv == nil {
+		return nil, store.ErrNotFound
+	}
+
+	return copyKV(kv, keyOnly), nil
+}
+
+// Range 查询所有前缀匹配的数据,按照key排序,rev<=0表示查询最新数据
+func (s *Store) Range(prefix string, rev int64, keyOnly bool) ([]*store.KV, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if err := s.checkRev(rev); err != nil {
+		return nil, err
+	}
+
+	var results []*store.KV
+	for k := range s.keys {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if kv := s.at(k, rev); kv != nil {
+			results = append(results, copyKV(kv, keyOnly))
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, store.ErrNotFound
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Key < results[j].Key
+	})
+
+	return results, nil
+}
+
+// Compact 删除rev之前的历史版本,每个key只保留rev时刻仍然有效的版本
+func (s *Store) Compact(rev int64) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if rev > s.rev {
+		return ErrFutureRev
+	}
+	if rev <= s.compacted {
+		return ErrCompacted
+	}
+
+	for k, records := range s.keys {
+		// 找到rev时刻有效的版本
+		i := sort.Search(len(records), func(i int) bool { return records[i].Rev > rev }) - 1
+		if i > 0 {
+			records = append(records[:0:0], records[i:]...)
+		}
+		if len(records) > 0 && records[0].KV == nil && records[0].Rev <= rev {
+			records = records[1:]
+		}
+		if len(records) == 0 {
+			delete(s.keys, k)
+		} else {
+			s.keys[k] = records
+		}
+	}
+
+	i := sort.Search(len(s.history), func(i int) bool { return s.history[i].Data.ModifyRevision > rev })
+	s.history = append(s.history[:0:0], s.history[i:]...)
+	s.compacted = rev
+	return nil
+}
+
+// Watch 监听key的变化,rev>0时会先回放从rev开始的历史事件
+// ctx结束或者Store关闭时停止监听,ctx为nil表示一直监听
+func (s *Store) Watch(ctx context.Context, key string, prefix bool, rev int64, cb store.Callback) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if rev > 0 && rev <= s.compacted {
+		return ErrCompacted
+	}
+
+	w := newWatcher(key, prefix, cb)
+	if rev > 0 {
+		i := sort.Search(len(s.history), func(i int) bool { return s.history[i].Data.ModifyRevision >= rev })
+		for _, ev := range s.history[i:] {
+			w.push(ev)
+		}
+	}
+
+	s.watchers[w] = struct{}{}
+	go w.run()
+	if ctx != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.unwatch(w)
+			case <-w.done:
+			}
+		}()
+	}
+
+	return nil
+}
+
+// Close 停止所有的Watch
+func (s *Store) Close() {
+	s.mux.Lock()
+	watchers := s.watchers
+	s.watchers = make(map[*watcher]struct{})
+	s.mux.Unlock()
+	for w := range watchers {
+		w.close()
+	}
+}
+
+type snapshot struct {
+	Rev       int64
+	Compacted int64
+	Keys      map[string][]*record
+	History   []*store.Event
+}
+
+// Snapshot 序列化所有数据
+func (s *Store) Snapshot() ([]byte, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	buf := bytes.Buffer{}
+	err := gob.NewEncoder(&buf).Encode(&snapshot{Rev: s.rev, Compacted: s.compacted, Keys: s.keys, History: s.history})
+	return buf.Bytes(), err
+}
+
+// Restore 从Snapshot中恢复数据,Watch不会中断,并会收到新增的历史事件
+func (s *Store) Restore(data []byte) error {
+	snap := &snapshot{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(snap); err != nil {
+		return err
+	}
+	if snap.Keys == nil {
+		snap.Keys = make(map[string][]*record)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	old := s.rev
+	s.rev = snap.Rev
+	s.compacted = snap.Compacted
+	s.keys = snap.Keys
+	s.history = snap.History
+	for _, ev := range s.history {
+		if ev.Data.ModifyRevision <= old {
+			continue
+		}
+		for w := range s.watchers {
+			w.push(ev)
+		}
+	}
+
+	return nil
+}
+
+// put 使用当前revision写入,同一个revision中多次写入同一个key时只保留最后一次
+func (s *Store) put(key string, value []byte) (*store.KV, *store.KV) {
+	records := s.keys[key]
+	if n := len(records); n > 0 && records[n-1].Rev == s.rev {
+		records = records[:n-1]
+	}
+
+	var prev *store.KV
+	if n := len(records); n > 0 {
+		prev = records[n-1].KV
+	}
+
+	kv := &store.KV{Key: key, Value: value, CreateRevision: s.rev, ModifyRevision: s.rev, Version: 1}
+	if prev != nil {
+		kv.CreateRevision = prev.CreateRevision
+		kv.Version = prev.Version + 1
+	}
+
+	s.keys[key] = append(records, &record{Rev: s.rev, KV: kv})
+	s.notify(&store.Event{Type: store.PUT, Data: kv, Prev: prev})
+	return kv, prev
+}
+
+// match 返回需要删除的key,已经删除的key会被忽略
+func (s *Store) match(key string, prefix bool) []string {
+	var keys []string
+	if prefix {
+		for k := range s.keys {
+			if strings.HasPrefix(k, key) && s.latest(k) != nil {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+	} else if s.latest(key) != nil {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// remove 使用当前revision删除
+func (s *Store) remove(keys []string) {
+	for _, k := range keys {
+		prev := s.latest(k)
+		s.keys[k] = append(s.keys[k], &record{Rev: s.rev})
+		s.notify(&store.Event{Type: store.DELETE, Data: &store.KV{Key: k, ModifyRevision: s.rev}, Prev: prev})
+	}
+}
+
+func (s *Store) unwatch(w *watcher) {
+	s.mux.Lock()
+	delete(s.watchers, w)
+	s.mux.Unlock()
+	w.close()
+}
+
+func (s *Store) notify(ev *store.Event) {
+	s.history = append(s.history, ev)
+	for w := range s.watchers {
+		w.push(ev)
+	}
+}
+
+func (s *Store) checkRev(rev int64) error {
+	if rev > s.rev {
+		return ErrFutureRev
+	}
+	if rev > 0 && rev < s.compacted {
+		return ErrCompacted
+	}
+
+	return nil
+}
+
+// latest 返回最新的数据,已经删除返回nil
+func (s *Store) latest(key string) *store.KV {
+	records := s.keys[key]
+	if len(records) == 0 {
+		return nil
+	}
+
+	return records[len(records)-1].KV
+}
+
+// at 返回rev时刻的数据,rev<=0表示最新
+func (s *Store) at(key string, rev int64) *store.KV {
+	if rev <= 0 {
+		return s.latest(key)
+	}
+
+	records := s.keys[key]
+	i := sort.Search(len(records), func(i int) bool { return records[i].Rev > rev }) - 1
+	if i < 0 {
+		return nil
+	}
+
+	return records[i].KV
+}
+
+// copyKV 内部数据不能被外部修改,Value只读,不需要拷贝
+func copyKV(kv *store.KV, keyOnly bool) *store.KV {
+	if kv == nil {
+		return nil
+	}
+
+	r := *kv
+	if keyOnly {
+		r.Value = nil
+	}
+
+	return &r
+}
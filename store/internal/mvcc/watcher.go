@@ -0,0 +1,82 @@
+package mvcc
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/jeckbjy/gsk/store"
+)
+
+func newWatcher(key string, prefix bool, cb store.Callback) *watcher {
+	w := &watcher{key: key, prefix: prefix, cb: cb, done: make(chan struct{})}
+	w.cond = sync.NewCond(&w.mux)
+	return w
+}
+
+// watcher 在独立的协程中按顺序回调,不会阻塞写操作
+type watcher struct {
+	key    string
+	prefix bool
+	cb     store.Callback
+	mux    sync.Mutex
+	cond   *sync.Cond
+	queue  []*store.Event
+	closed bool
+	done   chan struct{}
+}
+
+func (w *watcher) match(key string) bool {
+	if w.prefix {
+		return strings.HasPrefix(key, w.key)
+	}
+
+	return key == w.key
+}
+
+func (w *watcher) push(ev *store.Event) {
+	if !w.match(ev.Data.Key) {
+		return
+	}
+
+	w.mux.Lock()
+	if !w.closed {
+		w.queue = append(w.queue, ev)
+		w.cond.Signal()
+	}
+	w.mux.Unlock()
+}
+
+func (w *watcher) close() {
+	w.mux.Lock()
+	if !w.closed {
+		w.closed = true
+		w.queue = nil
+		close(w.done)
+		w.cond.Signal()
+	}
+	w.mux.Unlock()
+}
+
+func (w *watcher) run() {
+	for {
+		w.mux.Lock()
+		for !w.closed && len(w.queue) == 0 {
+			w.cond.Wait()
+		}
+		if w.closed {
+			w.mux.Unlock()
+			return
+		}
+		queue := w.queue
+		w.queue = nil
+		w.mux.Unlock()
+
+		for _, ev := range queue {
+			w.cb(copyEvent(ev))
+		}
+	}
+}
+
+func copyEvent(ev *store.Event) *store.Event {
+	return &store.Event{Type: ev.Type, Data: copyKV(ev.Data, false), Prev: copyKV(ev.Prev, false)}
+}
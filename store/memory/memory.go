@@ -0,0 +1,233 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/store/internal/mvcc"
+)
+
+var ErrBadTTL = errors.New("memory: ttl must be positive")
+
+// New 创建基于内存的存储,数据不会持久化
+func New() store.Store {
+	return &memoryStore{kv: mvcc.New(), leases: make(map[store.LeaseID]*lease), bindings: make(map[string]store.LeaseID)}
+}
+
+// memoryStore 基于内存的多版本存储,语义与etcd一致,主要用于单元测试
+//
+// revision: 每次修改加一,一次前缀删除使用同一个revision
+// Get,List: 支持KeyOnly,以及通过Revision查询历史版本
+// Delete,Watch: 支持Prefix,Watch可以通过Revision回放历史事件,事件中会携带修改前的数据
+// Compact: 删除指定revision之前的历史版本
+// Txn,Grant,KeepAlive,Revoke: 实现store.Transactional,租约到期后由timer删除绑定的key
+type memoryStore struct {
+	kv       *mvcc.Store
+	mux      sync.Mutex // 串行所有写操作,保证租约与key的绑定关系与数据一致
+	leases   map[store.LeaseID]*lease
+	bindings map[string]store.LeaseID // key绑定的租约
+	leaseID  store.LeaseID
+	closed   bool
+}
+
+type lease struct {
+	ttl   time.Duration
+	timer *time.Timer
+	keys  map[string]struct{}
+}
+
+func (m *memoryStore) Name() string {
+	return "memory"
+}
+
+func (m *memoryStore) List(ctx context.Context, key string, opts ...store.Option) ([]*store.KV, error) {
+	o := store.Options{}
+	o.Build(opts...)
+	return m.kv.Range(key, o.Revision, o.KeyOnly)
+}
+
+func (m *memoryStore) Get(ctx context.Context, key string, opts ...store.Option) (*store.KV, error) {
+	o := store.Options{}
+	o.Build(opts...)
+	return m.kv.Get(key, o.Revision, o.KeyOnly)
+}
+
+func (m *memoryStore) Put(ctx context.Context, key string, value []byte) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	// 拷贝一份,防止外部修改
+	m.kv.Put(key, append([]byte(nil), value...))
+	m.bind(key, 0)
+	return nil
+}
+
+func (m *memoryStore) Delete(ctx context.Context, key string, opts ...store.Option) error {
+	o := store.Options{}
+	o.Build(opts...)
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.kv.Delete(key, o.Prefix) == 0 {
+		return store.ErrNotFound
+	}
+
+	m.unbind(key, o.Prefix)
+	return nil
+}
+
+func (m *memoryStore) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := m.kv.Get(key, 0, true)
+	return err == nil, nil
+}
+
+// Watch 监听变化,ctx结束或者Close后停止监听
+func (m *memoryStore) Watch(ctx context.Context, key string, cb store.Callback, opts ...store.Option) error {
+	o := store.Options{}
+	o.Build(opts...)
+	return m.kv.Watch(ctx, key, o.Prefix, o.Revision, cb)
+}
+
+func (m *memoryStore) Compact(ctx context.Context, rev int64) error {
+	return m.kv.Compact(rev)
+}
+
+func (m *memoryStore) Txn(ctx context.Context, txn *store.Txn) (*store.TxnResponse, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	var ops []store.Op
+	succeeded, rev, err := m.kv.Txn(txn, func(chosen []store.Op) error {
+		ops = chosen
+		for _, op := range ops {
+			if op.Type == store.OpTypePut && op.Lease != 0 && m.leases[op.Lease] == nil {
+				return store.ErrLeaseNotFound
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range ops {
+		if op.Type == store.OpTypePut {
+			m.bind(op.Key, op.Lease)
+		} else {
+			m.unbind(op.Key, op.Prefix)
+		}
+	}
+
+	return &store.TxnResponse{Succeeded: succeeded, Revision: rev}, nil
+}
+
+func (m *memoryStore) Grant(ctx context.Context, ttl time.Duration) (store.LeaseID, error) {
+	if ttl <= 0 {
+		return 0, ErrBadTTL
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.leaseID++
+	id := m.leaseID
+	l := &lease{ttl: ttl, keys: make(map[string]struct{})}
+	l.timer = time.AfterFunc(ttl, func() { m.expire(id, l) })
+	m.leases[id] = l
+	return id, nil
+}
+
+func (m *memoryStore) KeepAlive(ctx context.Context, id store.LeaseID) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	l := m.leases[id]
+	if l == nil {
+		return store.ErrLeaseNotFound
+	}
+
+	l.timer.Reset(l.ttl)
+	return nil
+}
+
+func (m *memoryStore) Revoke(ctx context.Context, id store.LeaseID) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	l := m.leases[id]
+	if l == nil {
+		return store.ErrLeaseNotFound
+	}
+
+	m.revoke(id, l)
+	return nil
+}
+
+func (m *memoryStore) Close() error {
+	m.mux.Lock()
+	m.closed = true
+	for _, l := range m.leases {
+		l.timer.Stop()
+	}
+	m.mux.Unlock()
+	m.kv.Close()
+	return nil
+}
+
+// expire 租约到期,timer触发时可能已经续约或者删除
+func (m *memoryStore) expire(id store.LeaseID, l *lease) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.closed || m.leases[id] != l {
+		return
+	}
+
+	m.revoke(id, l)
+}
+
+// revoke 删除租约以及绑定的key,所有key使用一个revision
+func (m *memoryStore) revoke(id store.LeaseID, l *lease) {
+	l.timer.Stop()
+	delete(m.leases, id)
+	keys := make([]string, 0, len(l.keys))
+	for k := range l.keys {
+		delete(m.bindings, k)
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ops := make([]store.Op, 0, len(keys))
+	for _, k := range keys {
+		ops = append(ops, store.OpDelete(k))
+	}
+
+	if len(ops) > 0 {
+		_, _, _ = m.kv.Txn(&store.Txn{Then: ops}, nil)
+	}
+}
+
+// bind 绑定key与租约,id为0表示不绑定
+func (m *memoryStore) bind(key string, id store.LeaseID) {
+	m.unbind(key, false)
+	if l := m.leases[id]; l != nil {
+		l.keys[key] = struct{}{}
+		m.bindings[key] = id
+	}
+}
+
+func (m *memoryStore) unbind(key string, prefix bool) {
+	if !prefix {
+		if id, ok := m.bindings[key]; ok {
+			delete(m.bindings, key)
+			delete(m.leases[id].keys, key)
+		}
+		return
+	}
+
+	for k, id := range m.bindings {
+		if strings.HasPrefix(k, key) {
+			delete(m.bindings, k)
+			delete(m.leases[id].keys, k)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+)
+
+func TestMemory(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	ctx := context.Background()
+	_ = s.Put(ctx, "/cfg/a", []byte("1"))
+	_ = s.Put(ctx, "/cfg/a", []byte("2"))
+	_ = s.Put(ctx, "/cfg/b", []byte("3"))
+
+	kv, err := s.Get(ctx, "/cfg/a")
+	if err != nil || string(kv.Value) != "2" || kv.CreateRevision != 1 || kv.ModifyRevision != 2 || kv.Version != 2 {
+		t.Fatalf("bad kv, %+v, %+v", kv, err)
+	}
+
+	// 历史版本
+	kv, err = s.Get(ctx, "/cfg/a", store.Revision(1))
+	if err != nil || string(kv.Value) != "1" || kv.Version != 1 {
+		t.Fatalf("bad history, %+v, %+v", kv, err)
+	}
+	if _, err := s.Get(ctx, "/cfg/b", store.Revision(2)); err != store.ErrNotFound {
+		t.Fatalf("expect not found, %+v", err)
+	}
+
+	kvs, err := s.List(ctx, "/cfg/", store.KeyOnly())
+	if err != nil || len(kvs) != 2 || kvs[0].Key != "/cfg/a" || kvs[0].Value != nil {
+		t.Fatalf("bad list, %+v, %+v", kvs, err)
+	}
+
+	if err := s.Delete(ctx, "/cfg/", store.Prefix()); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.Exists(ctx, "/cfg/a"); ok {
+		t.Fatal("delete fail")
+	}
+	if err := s.Delete(ctx, "/cfg/a"); err != store.ErrNotFound {
+		t.Fatalf("expect not found, %+v", err)
+	}
+
+	// 前缀删除只占用一个revision
+	kvs, err = s.List(ctx, "/cfg/", store.Revision(3))
+	if err != nil || len(kvs) != 2 {
+		t.Fatalf("bad history list, %+v, %+v", kvs, err)
+	}
+	_ = s.Put(ctx, "/cfg/c", []byte("4"))
+	if kv, _ := s.Get(ctx, "/cfg/c"); kv.ModifyRevision != 5 {
+		t.Fatalf("bad revision, %+v", kv)
+	}
+
+	c := s.(store.Compactor)
+	if err := c.Compact(ctx, 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "/cfg/a", store.Revision(1)); err != store.ErrCompacted {
+		t.Fatalf("expect compacted, %+v", err)
+	}
+	if err := c.Compact(ctx, 10); err != store.ErrFutureRev {
+		t.Fatalf("expect future rev, %+v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_ = s.Put(ctx, "/svc/a", []byte("1"))
+	_ = s.Put(ctx, "/other", []byte("x"))
+
+	events := make(chan *store.Event, 10)
+	err := s.Watch(ctx, "/svc/", func(ev *store.Event) {
+		events <- ev
+	}, store.Prefix(), store.Revision(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = s.Put(ctx, "/svc/a", []byte("2"))
+	_ = s.Delete(ctx, "/svc/a")
+
+	var list []*store.Event
+	timeout := time.After(time.Second * 5)
+	for len(list) < 3 {
+		select {
+		case ev := <-events:
+			list = append(list, ev)
+		case <-timeout:
+			t.Fatalf("bad events, %+v", len(list))
+		}
+	}
+
+	if list[0].Type != store.PUT || string(list[0].Data.Value) != "1" || list[0].Prev != nil {
+		t.Fatalf("bad history event, %+v", list[0])
+	}
+	if string(list[1].Prev.Value) != "1" || list[1].Data.Version != 2 {
+		t.Fatalf("bad put event, %+v", list[1])
+	}
+	if list[2].Type != store.DELETE || string(list[2].Prev.Value) != "2" {
+		t.Fatalf("bad delete event, %+v", list[2])
+	}
+
+	if err := s.(store.Compactor).Compact(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Watch(ctx, "/svc/", func(ev *store.Event) {}, store.Revision(1)); err != store.ErrCompacted {
+		t.Fatalf("expect compacted, %+v", err)
+	}
+}
+
+func TestTxn(t *testing.T) {
+	s := New()
+	defer s.Close()
+	ctx := context.Background()
+
+	// key不存在时才能创建
+	if ok, err := store.CompareAndSwap(ctx, s, "cfg/a", 0, []byte("1")); !ok || err != nil {
+		t.Fatalf("cas fail, %+v", err)
+	}
+	if ok, err := store.CompareAndSwap(ctx, s, "cfg/a", 0, []byte("2")); ok || err != nil {
+		t.Fatalf("cas should fail, %+v", err)
+	}
+
+	tx := s.(store.Transactional)
+	rsp, err := tx.Txn(ctx, &store.Txn{
+		If: []store.Compare{
+			store.CmpValue("cfg/a", store.Equal, []byte("1")),
+			store.CmpExists("cfg/b", false),
+			store.CmpModify("cfg/a", store.Less, 2),
+		},
+		Then: []store.Op{store.OpPut("cfg/a", []byte("2")), store.OpPut("cfg/b", []byte("3"))},
+		Else: []store.Op{store.OpPut("cfg/fail", []byte("x"))},
+	})
+	if err != nil || !rsp.Succeeded || rsp.Revision != 2 {
+		t.Fatalf("bad txn, %+v, %+v", rsp, err)
+	}
+
+	// 同一个事务共用一个revision
+	a, _ := s.Get(ctx, "cfg/a")
+	b, _ := s.Get(ctx, "cfg/b")
+	if a.ModifyRevision != 2 || b.ModifyRevision != 2 || a.Version != 2 || b.Version != 1 {
+		t.Fatalf("bad revision, %+v, %+v", a, b)
+	}
+
+	rsp, err = tx.Txn(ctx, &store.Txn{
+		If:   []store.Compare{store.CmpVersion("cfg/a", store.Greater, 5)},
+		Then: []store.Op{store.OpDelete("cfg", store.Prefix())},
+		Else: []store.Op{store.OpDelete("cfg/b"), store.OpDelete("cfg/none")},
+	})
+	if err != nil || rsp.Succeeded || rsp.Revision != 3 {
+		t.Fatalf("bad else, %+v, %+v", rsp, err)
+	}
+	if ok, _ := s.Exists(ctx, "cfg/b"); ok {
+		t.Fatal("else not applied")
+	}
+	if ok, _ := s.Exists(ctx, "cfg/a"); !ok {
+		t.Fatal("then applied")
+	}
+
+	// 没有修改数据时revision不变
+	rsp, err = tx.Txn(ctx, &store.Txn{Then: []store.Op{store.OpDelete("cfg/none")}})
+	if err != nil || rsp.Revision != 3 {
+		t.Fatalf("revision changed, %+v, %+v", rsp, err)
+	}
+
+	if ok, err := store.CompareAndDelete(ctx, s, "cfg/a", 2); !ok || err != nil {
+		t.Fatalf("cad fail, %+v", err)
+	}
+}
+
+func TestLease(t *testing.T) {
+	s := New()
+	defer s.Close()
+	ctx := context.Background()
+	tx := s.(store.Transactional)
+
+	deleted := make(chan string, 10)
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	_ = s.Watch(wctx, "svc", func(ev *store.Event) {
+		if ev.Type == store.DELETE {
+			deleted <- ev.Data.Key
+		}
+	}, store.Prefix())
+
+	id, err := store.PutWithTTL(ctx, s, "svc/a", []byte("1"), time.Millisecond*100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 续约后不会过期
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond * 50)
+		if err := tx.KeepAlive(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ok, _ := s.Exists(ctx, "svc/a"); !ok {
+		t.Fatal("expired after keepalive")
+	}
+
+	// 不续约则自动删除,并产生删除事件
+	select {
+	case key := <-deleted:
+		if key != "svc/a" {
+			t.Fatalf("bad key, %+v", key)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("lease not expired")
+	}
+	if _, err := s.Get(ctx, "svc/a"); err != store.ErrNotFound {
+		t.Fatalf("expect not found, %+v", err)
+	}
+	if err := tx.KeepAlive(ctx, id); err != store.ErrLeaseNotFound {
+		t.Fatalf("expect lease not found, %+v", err)
+	}
+	if _, err := tx.Txn(ctx, &store.Txn{Then: []store.Op{store.OpPutWithLease("svc/a", nil, id)}}); err != store.ErrLeaseNotFound {
+		t.Fatalf("put with expired lease, %+v", err)
+	}
+
+	// Revoke立即删除,重新写入不带租约的key不再绑定
+	id, _ = tx.Grant(ctx, time.Minute)
+	_, _ = tx.Txn(ctx, &store.Txn{Then: []store.Op{
+		store.OpPutWithLease("svc/b", []byte("2"), id),
+		store.OpPutWithLease("svc/c", []byte("3"), id),
+	}})
+	_ = s.Put(ctx, "svc/c", []byte("4"))
+	if err := tx.Revoke(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.Exists(ctx, "svc/b"); ok {
+		t.Fatal("revoke fail")
+	}
+	if ok, _ := s.Exists(ctx, "svc/c"); !ok {
+		t.Fatal("revoke delete key without lease")
+	}
+}
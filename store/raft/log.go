@@ -0,0 +1,101 @@
+package raft
+
+// raftLog 日志,Snapshot之前的日志会被删除
+// entries[0].Index == snapIndex+1
+type raftLog struct {
+	snapIndex uint64
+	snapTerm  uint64
+	snapshot  []byte
+	entries   []Entry
+}
+
+func (l *raftLog) lastIndex() uint64 {
+	return l.snapIndex + uint64(len(l.entries))
+}
+
+func (l *raftLog) lastTerm() uint64 {
+	t, _ := l.term(l.lastIndex())
+	return t
+}
+
+// term 查询日志的term,已经被压缩或者不存在时返回false
+func (l *raftLog) term(index uint64) (uint64, bool) {
+	if index == l.snapIndex {
+		return l.snapTerm, true
+	}
+
+	if index < l.snapIndex || index > l.lastIndex() {
+		return 0, false
+	}
+
+	return l.entries[index-l.snapIndex-1].Term, true
+}
+
+func (l *raftLog) entry(index uint64) *Entry {
+	if index <= l.snapIndex || index > l.lastIndex() {
+		return nil
+	}
+
+	return &l.entries[index-l.snapIndex-1]
+}
+
+// slice 返回[lo,hi)之间的日志,最多max条
+func (l *raftLog) slice(lo, hi uint64, max int) []Entry {
+	if lo <= l.snapIndex {
+		lo = l.snapIndex + 1
+	}
+	if hi > l.lastIndex()+1 {
+		hi = l.lastIndex() + 1
+	}
+	if lo >= hi {
+		return nil
+	}
+	if hi-lo > uint64(max) {
+		hi = lo + uint64(max)
+	}
+
+	result := make([]Entry, hi-lo)
+	copy(result, l.entries[lo-l.snapIndex-1:hi-l.snapIndex-1])
+	return result
+}
+
+func (l *raftLog) append(entries ...Entry) {
+	l.entries = append(l.entries, entries...)
+}
+
+// truncate 删除index及之后的日志
+func (l *raftLog) truncate(index uint64) {
+	if index <= l.snapIndex {
+		l.entries = nil
+		return
+	}
+
+	if index <= l.lastIndex() {
+		l.entries = l.entries[:index-l.snapIndex-1]
+	}
+}
+
+// compact 生成Snapshot,删除index及之前的日志
+func (l *raftLog) compact(index, term uint64, data []byte) {
+	if index <= l.snapIndex {
+		return
+	}
+
+	if index >= l.lastIndex() {
+		l.entries = nil
+	} else {
+		l.entries = append([]Entry(nil), l.entries[index-l.snapIndex:]...)
+	}
+
+	l.snapIndex = index
+	l.snapTerm = term
+	l.snapshot = data
+}
+
+// restore 使用Leader发送的Snapshot替换所有日志
+func (l *raftLog) restore(snap *Snapshot) {
+	l.entries = nil
+	l.snapIndex = snap.Index
+	l.snapTerm = snap.Term
+	l.snapshot = snap.Data
+}
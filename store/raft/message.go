@@ -0,0 +1,143 @@
+package raft
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+
+	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/anet/base"
+	"github.com/jeckbjy/gsk/frame"
+	"github.com/jeckbjy/gsk/util/buffer"
+)
+
+type msgType uint8
+
+const (
+	msgVote          msgType = iota + 1 // 请求投票
+	msgVoteResp                         // 投票应答
+	msgAppend                           // 日志复制,也作为心跳
+	msgAppendResp                       // 日志复制应答
+	msgSnapshot                         // 发送Snapshot
+	msgPropose                          // Follower转发写请求给Leader
+	msgProposeResp                      // 写请求应答
+	msgReadIndex                        // Follower向Leader查询commit index
+	msgReadIndexResp                    // commit index应答
+)
+
+// Entry 日志,Data为nil表示空日志,Leader当选后会写入一条空日志
+type Entry struct {
+	Term  uint64
+	Index uint64
+	Data  []byte
+}
+
+// Snapshot 状态机快照,Index和Term为最后一条日志
+type Snapshot struct {
+	Index uint64
+	Term  uint64
+	Data  []byte
+}
+
+// Message 节点间通信消息,不同类型只使用部分字段
+//	Vote:         LogIndex,LogTerm为候选者最后一条日志
+//	Append:       LogIndex,LogTerm为Entries前一条日志,Commit为Leader的commit index
+//	AppendResp:   Reject为false时Index为已经匹配的日志,否则为Leader下一次尝试的日志
+//	              ReadSeq为Append中的ReadSeq,Leader据此确认多数节点仍然认可自己,用于ReadIndex
+//	Propose:      ReqID用于匹配应答,Data为写请求
+//	ReadIndexResp:Index为Leader的commit index
+type Message struct {
+	Type     msgType
+	From     string
+	To       string
+	Term     uint64
+	LogIndex uint64
+	LogTerm  uint64
+	Commit   uint64
+	Index    uint64
+	Reject   bool
+	Entries  []Entry
+	Snapshot *Snapshot
+	ReqID    uint64
+	Data     []byte
+	Error    string
+	ReadSeq  uint64
+}
+
+// msgFilter 消息编解码,直接放在TransferFilter之后
+// 使用varint处理粘包,一次读取可能包含多个消息,需要全部处理
+type msgFilter struct {
+	base.Filter
+	node  *node
+	tr    *transport
+	frame frame.Frame
+}
+
+func (f *msgFilter) Name() string {
+	return "raft"
+}
+
+// HandleOpen 记录被动接收的连接,用于关闭
+func (f *msgFilter) HandleOpen(ctx anet.FilterCtx) error {
+	if conn := ctx.Conn(); !conn.IsDial() {
+		f.tr.accept(conn)
+	}
+
+	return nil
+}
+
+func (f *msgFilter) HandleClose(ctx anet.FilterCtx) error {
+	f.tr.remove(ctx.Conn())
+	return nil
+}
+
+func (f *msgFilter) HandleRead(ctx anet.FilterCtx) error {
+	buff, ok := ctx.Data().(*buffer.Buffer)
+	if !ok {
+		return nil
+	}
+
+	for {
+		_, _ = buff.Seek(0, io.SeekStart)
+		data, err := f.frame.Decode(buff)
+		if err != nil {
+			// 长度不完整时,buffer会返回ErrOverflow
+			if err == frame.ErrIncomplete || err == buffer.ErrOverflow || err == io.EOF {
+				_, _ = buff.Seek(0, io.SeekStart)
+				return nil
+			}
+			return err
+		}
+
+		msg := &Message{}
+		if err := gob.NewDecoder(bytes.NewReader(data.Bytes())).Decode(msg); err != nil {
+			return err
+		}
+
+		if !f.tr.isIsolated() {
+			f.node.recv(msg)
+		}
+	}
+}
+
+func (f *msgFilter) HandleWrite(ctx anet.FilterCtx) error {
+	msg, ok := ctx.Data().(*Message)
+	if !ok {
+		return nil
+	}
+
+	data := bytes.Buffer{}
+	if err := gob.NewEncoder(&data).Encode(msg); err != nil {
+		return err
+	}
+
+	buff := buffer.New()
+	buff.Append(data.Bytes())
+
+	if err := f.frame.Encode(buff); err != nil {
+		return err
+	}
+
+	ctx.SetData(buff)
+	return nil
+}
@@ -0,0 +1,87 @@
+package raft
+
+import (
+	"time"
+)
+
+const (
+	DefaultHeartbeat     = 100 * time.Millisecond
+	DefaultElection      = 1000 * time.Millisecond
+	DefaultTimeout       = 5 * time.Second
+	DefaultSnapshotCount = 10000
+	DefaultRetention     = 10000
+)
+
+type Options struct {
+	ID            string            // 当前节点ID,必须在Peers中
+	Peers         map[string]string // 所有节点,ID->Addr,包括当前节点
+	Dir           string            // 数据目录,空表示只保存在内存中
+	Heartbeat     time.Duration     // Leader心跳间隔
+	Election      time.Duration     // 选举超时,实际超时时间为[Election,2*Election)之间的随机值
+	Timeout       time.Duration     // 读写请求默认超时时间,ctx没有设置Deadline时使用
+	SnapshotCount uint64            // 应用多少条日志后生成Snapshot并压缩日志
+	Retention     int64             // 生成Snapshot时保留多少个revision的历史数据,<=0表示不压缩
+}
+
+type Option func(o *Options)
+
+// ID 当前节点ID
+func ID(id string) Option {
+	return func(o *Options) {
+		o.ID = id
+	}
+}
+
+// Peer 添加节点,需要包括当前节点
+func Peer(id string, addr string) Option {
+	return func(o *Options) {
+		if o.Peers == nil {
+			o.Peers = make(map[string]string)
+		}
+		o.Peers[id] = addr
+	}
+}
+
+// Peers 设置所有节点,需要包括当前节点
+func Peers(peers map[string]string) Option {
+	return func(o *Options) {
+		o.Peers = peers
+	}
+}
+
+// Dir 数据目录,用于持久化日志和Snapshot
+func Dir(dir string) Option {
+	return func(o *Options) {
+		o.Dir = dir
+	}
+}
+
+func Heartbeat(d time.Duration) Option {
+	return func(o *Options) {
+		o.Heartbeat = d
+	}
+}
+
+func Election(d time.Duration) Option {
+	return func(o *Options) {
+		o.Election = d
+	}
+}
+
+func Timeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = d
+	}
+}
+
+func SnapshotCount(n uint64) Option {
+	return func(o *Options) {
+		o.SnapshotCount = n
+	}
+}
+
+func Retention(n int64) Option {
+	return func(o *Options) {
+		o.Retention = n
+	}
+}
@@ -0,0 +1,943 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+)
+
+var (
+	ErrNoLeader      = errors.New("raft: no leader")
+	ErrLeaderChanged = errors.New("raft: leader changed")
+	ErrStopped       = errors.New("raft: stopped")
+	ErrBadOptions    = errors.New("raft: bad options")
+)
+
+const maxBatch = 256 // 单次复制的最大日志条数
+
+type stateType int
+
+const (
+	follower stateType = iota
+	candidate
+	leader
+)
+
+// fsm 状态机,只在node的协程中调用
+type fsm interface {
+	apply(data []byte) error
+	snapshot() ([]byte, error)
+	restore(data []byte) error
+}
+
+// proposal 写请求,done不为空表示本地请求,否则为Follower转发的请求
+type proposal struct {
+	data  []byte
+	term  uint64
+	from  string
+	reqID uint64
+	done  chan error
+}
+
+// readRequest 读请求,需要等待状态机应用到Leader的commit index后才能读取
+type readRequest struct {
+	index uint64
+	from  string
+	reqID uint64
+	done  chan error
+}
+
+// readConfirm Leader等待多数节点通过心跳确认身份的读请求,seq随心跳发送,Follower原样返回
+type readConfirm struct {
+	seq   uint64
+	acks  map[string]bool
+	reads []*readRequest
+}
+
+// node Raft节点,所有状态只在run协程中修改,不需要加锁
+//
+// 选举:Follower超时后发起选举,获得多数投票后成为Leader,并写入一条空日志用于提交之前任期的日志
+// 复制:Leader每个心跳周期向Follower发送日志,日志冲突时Follower返回下一次尝试的位置
+// 写入:Follower收到的写请求会转发给Leader,日志应用到状态机后返回结果
+// 读取:通过ReadIndex保证读到已经提交的数据,Leader在当前任期有日志提交后记录commit index,
+// 并通过心跳确认多数节点仍然认可自己是Leader后才会响应,因此网络分区时旧Leader不会返回过期数据
+// 持久化:term,vote以及日志都会在应答之前写入磁盘,写入失败时节点会停止,避免违反Raft的安全性
+// 快照:应用的日志超过SnapshotCount后生成Snapshot并删除旧日志,落后太多的Follower会收到Snapshot
+type node struct {
+	id    string
+	peers []string
+	opts  *Options
+	tr    *transport
+	st    *storage
+	fsm   fsm
+
+	state        stateType
+	term         uint64
+	vote         string
+	leader       string
+	log          *raftLog
+	commit       uint64
+	applied      uint64
+	deadline     time.Time
+	votes        map[string]bool
+	next         map[string]uint64
+	match        map[string]uint64
+	proposals    map[uint64]*proposal    // Leader:日志索引->写请求
+	forwards     map[uint64]*proposal    // Follower:转发给Leader的写请求
+	readIndexes  map[uint64]*readRequest // Follower:等待Leader返回commit index的读请求
+	pendingReads []*readRequest          // Leader:等待当前任期有日志提交的读请求
+	confirms     []*readConfirm          // Leader:等待多数节点确认身份的读请求,按照seq排序
+	readSeq      uint64                  // Leader:最后一次读请求的seq
+	waitReads    []*readRequest          // 等待状态机应用的读请求
+	reqID        uint64
+	err          error // 持久化失败的原因,设置后节点停止
+
+	recvc chan *Message
+	propc chan *proposal
+	readc chan *readRequest
+	stopc chan struct{}
+	donec chan struct{}
+
+	mux      sync.RWMutex
+	leaderID string
+}
+
+func newNode(opts *Options, sm fsm) (*node, error) {
+	n := &node{
+		id:          opts.ID,
+		opts:        opts,
+		fsm:         sm,
+		log:         &raftLog{},
+		proposals:   make(map[uint64]*proposal),
+		forwards:    make(map[uint64]*proposal),
+		readIndexes: make(map[uint64]*readRequest),
+		recvc:       make(chan *Message, 1024),
+		propc:       make(chan *proposal, 128),
+		readc:       make(chan *readRequest, 128),
+		stopc:       make(chan struct{}),
+		donec:       make(chan struct{}),
+	}
+
+	for id := range opts.Peers {
+		if id != n.id {
+			n.peers = append(n.peers, id)
+		}
+	}
+	sort.Strings(n.peers)
+
+	if opts.Dir != "" {
+		st, hs, l, err := openStorage(opts.Dir)
+		if err != nil {
+			return nil, err
+		}
+		n.st = st
+		n.term = hs.Term
+		n.vote = hs.Vote
+		n.log = l
+		if l.snapIndex > 0 {
+			if err := sm.restore(l.snapshot); err != nil {
+				st.close()
+				return nil, err
+			}
+			n.commit = l.snapIndex
+			n.applied = l.snapIndex
+		}
+	}
+
+	n.tr = newTransport(n, opts.Peers)
+	if err := n.tr.listen(opts.Peers[n.id]); err != nil {
+		if n.st != nil {
+			n.st.close()
+		}
+		return nil, err
+	}
+
+	n.resetDeadline()
+	return n, nil
+}
+
+// start 开始运行,状态机需要在此之前准备好
+func (n *node) start() {
+	go n.run()
+}
+
+// Leader 返回当前Leader,空表示没有Leader
+func (n *node) Leader() string {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.leaderID
+}
+
+// IsLeader 当前节点是否是Leader
+func (n *node) IsLeader() bool {
+	return n.Leader() == n.id
+}
+
+// propose 提交写请求,等待日志应用到状态机后返回
+func (n *node) propose(ctx context.Context, data []byte) error {
+	p := &proposal{data: data, done: make(chan error, 1)}
+	select {
+	case n.propc <- p:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-n.donec:
+		return n.stopped()
+	}
+
+	select {
+	case err := <-p.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-n.donec:
+		return n.stopped()
+	}
+}
+
+// readIndex 等待状态机应用到Leader的commit index,之后可以读取本地数据
+func (n *node) readIndex(ctx context.Context) error {
+	r := &readRequest{done: make(chan error, 1)}
+	select {
+	case n.readc <- r:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-n.donec:
+		return n.stopped()
+	}
+
+	select {
+	case err := <-r.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-n.donec:
+		return n.stopped()
+	}
+}
+
+// stopped 节点停止的原因,只能在donec关闭后调用
+func (n *node) stopped() error {
+	if n.err != nil {
+		return n.err
+	}
+
+	return ErrStopped
+}
+
+// recv 收到其他节点的消息,在网络协程中调用
+func (n *node) recv(m *Message) {
+	select {
+	case n.recvc <- m:
+	case <-n.donec:
+	}
+}
+
+func (n *node) stop() {
+	select {
+	case <-n.stopc:
+		return
+	default:
+	}
+
+	close(n.stopc)
+	<-n.donec
+	n.tr.close()
+	if n.st != nil {
+		n.st.close()
+	}
+}
+
+func (n *node) run() {
+	ticker := time.NewTicker(n.opts.Heartbeat)
+	defer func() {
+		ticker.Stop()
+		n.failAll(n.stopped())
+		close(n.donec)
+	}()
+
+	for n.err == nil {
+		select {
+		case <-n.stopc:
+			return
+		case m := <-n.recvc:
+			n.step(m)
+		case p := <-n.propc:
+			n.handleProposal(p)
+		case r := <-n.readc:
+			n.handleRead(r)
+		case <-ticker.C:
+			n.tick()
+		}
+	}
+
+	log.Printf("[raft] node %s stopped, %v", n.id, n.err)
+}
+
+// fail 持久化失败,记录错误后节点停止,不再处理任何消息
+func (n *node) fail(err error) bool {
+	if err != nil && n.err == nil {
+		n.err = err
+	}
+
+	return n.err != nil
+}
+
+func (n *node) tick() {
+	if n.state == leader {
+		n.broadcastAppend()
+	} else if time.Now().After(n.deadline) {
+		n.campaign()
+	}
+}
+
+func (n *node) quorum() int {
+	return (len(n.peers)+1)/2 + 1
+}
+
+func (n *node) resetDeadline() {
+	timeout := n.opts.Election + time.Duration(rand.Int63n(int64(n.opts.Election)))
+	n.deadline = time.Now().Add(timeout)
+}
+
+func (n *node) send(m *Message) {
+	m.From = n.id
+	if m.Term == 0 {
+		m.Term = n.term
+	}
+	n.tr.send(m)
+}
+
+func (n *node) persist() error {
+	if n.st != nil {
+		return n.st.saveState(&hardState{Term: n.term, Vote: n.vote})
+	}
+
+	return nil
+}
+
+func (n *node) setLeader(id string) {
+	if n.leader != id {
+		// Leader变化后,之前转发的请求结果未知
+		n.failForwards(ErrLeaderChanged)
+	}
+
+	n.leader = id
+	n.mux.Lock()
+	n.leaderID = id
+	n.mux.Unlock()
+}
+
+func (n *node) campaign() {
+	if n.state == leader {
+		n.failLeader(ErrLeaderChanged)
+	}
+
+	n.state = candidate
+	n.term++
+	n.vote = n.id
+	if n.fail(n.persist()) {
+		return
+	}
+	n.setLeader("")
+	n.votes = map[string]bool{n.id: true}
+	n.resetDeadline()
+	if n.quorum() == 1 {
+		n.becomeLeader()
+		return
+	}
+
+	for _, id := range n.peers {
+		n.send(&Message{Type: msgVote, To: id, LogIndex: n.log.lastIndex(), LogTerm: n.log.lastTerm()})
+	}
+}
+
+func (n *node) becomeFollower(term uint64, lead string) {
+	if n.state == leader {
+		n.failLeader(ErrLeaderChanged)
+	}
+
+	if term > n.term {
+		n.term = term
+		n.vote = ""
+		if n.fail(n.persist()) {
+			return
+		}
+	}
+
+	n.state = follower
+	n.setLeader(lead)
+	n.resetDeadline()
+}
+
+func (n *node) becomeLeader() {
+	n.state = leader
+	n.setLeader(n.id)
+	n.next = make(map[string]uint64)
+	n.match = make(map[string]uint64)
+	for _, id := range n.peers {
+		n.next[id] = n.log.lastIndex() + 1
+		n.match[id] = 0
+	}
+
+	// 写入空日志,用于提交之前任期的日志
+	if n.fail(n.appendEntry(&Entry{})) {
+		return
+	}
+	n.broadcastAppend()
+	n.maybeCommit()
+}
+
+func (n *node) step(m *Message) {
+	switch m.Type {
+	case msgVote, msgVoteResp, msgAppend, msgAppendResp, msgSnapshot:
+		if m.Term > n.term {
+			if m.Type == msgAppend || m.Type == msgSnapshot {
+				n.becomeFollower(m.Term, m.From)
+			} else {
+				n.becomeFollower(m.Term, "")
+			}
+		}
+	}
+
+	if n.err != nil {
+		return
+	}
+
+	switch m.Type {
+	case msgVote:
+		n.handleVote(m)
+	case msgVoteResp:
+		if n.state == candidate && m.Term == n.term {
+			n.votes[m.From] = !m.Reject
+			granted := 0
+			for _, v := range n.votes {
+				if v {
+					granted++
+				}
+			}
+			if granted >= n.quorum() {
+				n.becomeLeader()
+			}
+		}
+	case msgAppend:
+		if m.Term < n.term {
+			n.send(&Message{Type: msgAppendResp, To: m.From, Reject: true})
+		} else {
+			n.handleAppend(m)
+		}
+	case msgAppendResp:
+		if n.state == leader && m.Term == n.term {
+			n.handleAppendResp(m)
+		}
+	case msgSnapshot:
+		if m.Term < n.term {
+			n.send(&Message{Type: msgAppendResp, To: m.From, Reject: true})
+		} else {
+			n.handleSnapshot(m)
+		}
+	case msgPropose:
+		if n.state != leader {
+			n.send(&Message{Type: msgProposeResp, To: m.From, ReqID: m.ReqID, Error: ErrNoLeader.Error()})
+		} else {
+			n.appendProposal(&proposal{data: m.Data, from: m.From, reqID: m.ReqID})
+		}
+	case msgProposeResp:
+		if p := n.forwards[m.ReqID]; p != nil {
+			delete(n.forwards, m.ReqID)
+			p.done <- toError(m.Error)
+		}
+	case msgReadIndex:
+		if n.state != leader {
+			n.send(&Message{Type: msgReadIndexResp, To: m.From, ReqID: m.ReqID, Error: ErrNoLeader.Error()})
+		} else {
+			n.leaderRead(&readRequest{from: m.From, reqID: m.ReqID})
+		}
+	case msgReadIndexResp:
+		if r := n.readIndexes[m.ReqID]; r != nil {
+			delete(n.readIndexes, m.ReqID)
+			if m.Error != "" {
+				r.done <- toError(m.Error)
+			} else {
+				r.index = m.Index
+				n.waitReads = append(n.waitReads, r)
+				n.checkReads()
+			}
+		}
+	}
+}
+
+func (n *node) handleVote(m *Message) {
+	upToDate := m.LogTerm > n.log.lastTerm() || (m.LogTerm == n.log.lastTerm() && m.LogIndex >= n.log.lastIndex())
+	grant := m.Term == n.term && (n.vote == "" || n.vote == m.From) && upToDate
+	if grant {
+		n.vote = m.From
+		if n.fail(n.persist()) {
+			return
+		}
+		n.resetDeadline()
+	}
+
+	n.send(&Message{Type: msgVoteResp, To: m.From, Reject: !grant})
+}
+
+func (n *node) handleAppend(m *Message) {
+	if n.state != follower || n.leader != m.From {
+		if n.becomeFollower(m.Term, m.From); n.err != nil {
+			return
+		}
+	} else {
+		n.resetDeadline()
+	}
+
+	// ReadSeq原样返回,用于Leader确认身份
+	resp := &Message{Type: msgAppendResp, To: m.From, ReadSeq: m.ReadSeq}
+	prev := m.LogIndex
+	prevTerm := m.LogTerm
+	entries := m.Entries
+	if prev < n.log.snapIndex {
+		// Snapshot中的日志都已经提交,不会冲突
+		skip := n.log.snapIndex - prev
+		if uint64(len(entries)) <= skip {
+			resp.Index = n.log.snapIndex
+			n.send(resp)
+			return
+		}
+		entries = entries[skip:]
+		prev = n.log.snapIndex
+		prevTerm = n.log.snapTerm
+	}
+
+	if t, ok := n.log.term(prev); !ok || t != prevTerm {
+		resp.Reject = true
+		if !ok {
+			resp.Index = n.log.lastIndex() + 1
+		} else {
+			// 跳过冲突任期的所有日志
+			index := prev
+			for index > n.log.snapIndex+1 {
+				if pt, _ := n.log.term(index - 1); pt != t {
+					break
+				}
+				index--
+			}
+			resp.Index = index
+		}
+		n.send(resp)
+		return
+	}
+
+	for i, e := range entries {
+		if t, ok := n.log.term(e.Index); ok && t == e.Term {
+			continue
+		}
+
+		conflict := e.Index <= n.log.lastIndex()
+		n.log.truncate(e.Index)
+		n.log.append(entries[i:]...)
+		if n.st != nil {
+			// 日志写入磁盘后才能应答
+			var err error
+			if conflict {
+				err = n.st.rewrite(n.log.entries)
+			} else {
+				err = n.st.append(entries[i:])
+			}
+			if n.fail(err) {
+				return
+			}
+		}
+		break
+	}
+
+	last := prev + uint64(len(entries))
+	if m.Commit > n.commit {
+		commit := m.Commit
+		if commit > last {
+			commit = last
+		}
+		if commit > n.commit {
+			n.commit = commit
+			n.applyCommitted()
+		}
+	}
+
+	resp.Index = last
+	n.send(resp)
+}
+
+func (n *node) handleAppendResp(m *Message) {
+	// 拒绝日志的应答同样表示Follower认可当前Leader
+	n.ackRead(m.From, m.ReadSeq)
+
+	if m.Reject {
+		next := m.Index
+		if next < 1 {
+			next = 1
+		}
+		if next <= n.match[m.From] {
+			next = n.match[m.From] + 1
+		}
+		n.next[m.From] = next
+		n.sendAppend(m.From)
+		return
+	}
+
+	if m.Index > n.match[m.From] {
+		n.match[m.From] = m.Index
+	}
+	if n.next[m.From] <= m.Index {
+		n.next[m.From] = m.Index + 1
+	}
+
+	n.maybeCommit()
+	if n.next[m.From] <= n.log.lastIndex() {
+		n.sendAppend(m.From)
+	}
+}
+
+func (n *node) handleSnapshot(m *Message) {
+	if n.state != follower || n.leader != m.From {
+		if n.becomeFollower(m.Term, m.From); n.err != nil {
+			return
+		}
+	} else {
+		n.resetDeadline()
+	}
+
+	snap := m.Snapshot
+	resp := &Message{Type: msgAppendResp, To: m.From, ReadSeq: m.ReadSeq}
+	if snap == nil || snap.Index <= n.commit {
+		resp.Index = n.commit
+		n.send(resp)
+		return
+	}
+
+	if err := n.fsm.restore(snap.Data); err != nil {
+		resp.Reject = true
+		resp.Index = n.log.lastIndex() + 1
+		n.send(resp)
+		return
+	}
+
+	n.log.restore(snap)
+	n.commit = snap.Index
+	n.applied = snap.Index
+	if n.st != nil && n.fail(n.st.saveSnapshot(n.log)) {
+		return
+	}
+
+	n.checkReads()
+	resp.Index = snap.Index
+	n.send(resp)
+}
+
+func (n *node) broadcastAppend() {
+	for _, id := range n.peers {
+		n.sendAppend(id)
+	}
+}
+
+// sendAppend 发送日志,需要的日志已经被压缩时发送Snapshot
+// 发送后乐观的更新next,如果丢失,Follower会拒绝后续的日志并返回正确的位置
+func (n *node) sendAppend(to string) {
+	next := n.next[to]
+	prev := next - 1
+	prevTerm, ok := n.log.term(prev)
+	if !ok {
+		snap := &Snapshot{Index: n.log.snapIndex, Term: n.log.snapTerm, Data: n.log.snapshot}
+		n.send(&Message{Type: msgSnapshot, To: to, Snapshot: snap, ReadSeq: n.readSeq})
+		n.next[to] = snap.Index + 1
+		return
+	}
+
+	entries := n.log.slice(next, n.log.lastIndex()+1, maxBatch)
+	n.send(&Message{Type: msgAppend, To: to, LogIndex: prev, LogTerm: prevTerm, Entries: entries, Commit: n.commit, ReadSeq: n.readSeq})
+	if len(entries) > 0 {
+		n.next[to] = entries[len(entries)-1].Index + 1
+	}
+}
+
+// maybeCommit 多数节点已经复制的日志可以提交,只能直接提交当前任期的日志
+func (n *node) maybeCommit() {
+	matches := []uint64{n.log.lastIndex()}
+	for _, id := range n.peers {
+		matches = append(matches, n.match[id])
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i] > matches[j] })
+
+	index := matches[n.quorum()-1]
+	if index <= n.commit {
+		return
+	}
+
+	if t, _ := n.log.term(index); t != n.term {
+		return
+	}
+
+	n.commit = index
+	n.applyCommitted()
+	if n.err != nil {
+		return
+	}
+
+	// 当前任期已经有日志提交,可以响应读请求
+	if reads := n.pendingReads; len(reads) > 0 {
+		n.pendingReads = nil
+		n.leaderRead(reads...)
+	}
+
+	// 尽快通知Follower提交
+	n.broadcastAppend()
+}
+
+func (n *node) appendEntry(e *Entry) error {
+	e.Term = n.term
+	e.Index = n.log.lastIndex() + 1
+	n.log.append(*e)
+	if n.st != nil {
+		return n.st.append([]Entry{*e})
+	}
+
+	return nil
+}
+
+func (n *node) appendProposal(p *proposal) {
+	e := &Entry{Data: p.data}
+	if err := n.appendEntry(e); n.fail(err) {
+		n.finish(p, err)
+		return
+	}
+	p.term = e.Term
+	n.proposals[e.Index] = p
+	n.broadcastAppend()
+	n.maybeCommit()
+}
+
+func (n *node) handleProposal(p *proposal) {
+	switch {
+	case n.state == leader:
+		n.appendProposal(p)
+	case n.leader != "":
+		n.reqID++
+		n.forwards[n.reqID] = p
+		n.send(&Message{Type: msgPropose, To: n.leader, ReqID: n.reqID, Data: p.data})
+	default:
+		p.done <- ErrNoLeader
+	}
+}
+
+func (n *node) handleRead(r *readRequest) {
+	switch {
+	case n.state == leader:
+		n.leaderRead(r)
+	case n.leader != "":
+		n.reqID++
+		r.reqID = n.reqID
+		n.readIndexes[n.reqID] = r
+		n.send(&Message{Type: msgReadIndex, To: n.leader, ReqID: n.reqID})
+	default:
+		r.done <- ErrNoLeader
+	}
+}
+
+// leaderRead 记录当前的commit index,并通过心跳确认身份
+func (n *node) leaderRead(reads ...*readRequest) {
+	if t, _ := n.log.term(n.commit); t != n.term {
+		n.pendingReads = append(n.pendingReads, reads...)
+		return
+	}
+
+	for _, r := range reads {
+		r.index = n.commit
+	}
+
+	if n.quorum() == 1 {
+		n.serveReads(reads)
+		return
+	}
+
+	n.readSeq++
+	n.confirms = append(n.confirms, &readConfirm{seq: n.readSeq, acks: make(map[string]bool), reads: reads})
+	n.broadcastAppend()
+}
+
+// ackRead Follower应答了seq及之前的心跳,多数节点确认后响应对应的读请求
+// 认可较大seq的节点必然也认可较小的seq,因此只需要从前向后检查
+func (n *node) ackRead(from string, seq uint64) {
+	done := 0
+	for i, c := range n.confirms {
+		if c.seq > seq {
+			break
+		}
+		c.acks[from] = true
+		if len(c.acks)+1 >= n.quorum() {
+			done = i + 1
+		}
+	}
+
+	if done == 0 {
+		return
+	}
+
+	confirms := n.confirms[:done]
+	n.confirms = append([]*readConfirm(nil), n.confirms[done:]...)
+	for _, c := range confirms {
+		n.serveReads(c.reads)
+	}
+}
+
+func (n *node) serveReads(reads []*readRequest) {
+	for _, r := range reads {
+		if r.done != nil {
+			n.waitReads = append(n.waitReads, r)
+		} else {
+			n.send(&Message{Type: msgReadIndexResp, To: r.from, ReqID: r.reqID, Index: r.index})
+		}
+	}
+
+	n.checkReads()
+}
+
+func (n *node) checkReads() {
+	reads := n.waitReads[:0]
+	for _, r := range n.waitReads {
+		if n.applied >= r.index {
+			r.done <- nil
+		} else {
+			reads = append(reads, r)
+		}
+	}
+	n.waitReads = reads
+}
+
+func (n *node) applyCommitted() {
+	for n.applied < n.commit {
+		n.applied++
+		e := n.log.entry(n.applied)
+		if e == nil {
+			continue
+		}
+
+		var err error
+		if e.Data != nil {
+			err = n.fsm.apply(e.Data)
+		}
+
+		if p := n.proposals[e.Index]; p != nil {
+			delete(n.proposals, e.Index)
+			if p.term != e.Term {
+				err = ErrLeaderChanged
+			}
+			n.finish(p, err)
+		}
+	}
+
+	n.checkReads()
+	n.maybeSnapshot()
+}
+
+func (n *node) maybeSnapshot() {
+	if n.opts.SnapshotCount == 0 || n.applied-n.log.snapIndex < n.opts.SnapshotCount {
+		return
+	}
+
+	data, err := n.fsm.snapshot()
+	if err != nil {
+		return
+	}
+
+	t, _ := n.log.term(n.applied)
+	n.log.compact(n.applied, t, data)
+	if n.st != nil {
+		n.fail(n.st.saveSnapshot(n.log))
+	}
+}
+
+func (n *node) finish(p *proposal, err error) {
+	if p.done != nil {
+		p.done <- err
+	} else {
+		n.send(&Message{Type: msgProposeResp, To: p.from, ReqID: p.reqID, Error: toString(err)})
+	}
+}
+
+// failLeader 不再是Leader,未完成的请求结果未知
+func (n *node) failLeader(err error) {
+	for index, p := range n.proposals {
+		delete(n.proposals, index)
+		n.finish(p, err)
+	}
+
+	reads := n.pendingReads
+	n.pendingReads = nil
+	for _, c := range n.confirms {
+		reads = append(reads, c.reads...)
+	}
+	n.confirms = nil
+	for _, r := range reads {
+		if r.done != nil {
+			r.done <- err
+		} else {
+			n.send(&Message{Type: msgReadIndexResp, To: r.from, ReqID: r.reqID, Error: err.Error()})
+		}
+	}
+}
+
+func (n *node) failForwards(err error) {
+	for id, p := range n.forwards {
+		delete(n.forwards, id)
+		p.done <- err
+	}
+
+	for id, r := range n.readIndexes {
+		delete(n.readIndexes, id)
+		r.done <- err
+	}
+}
+
+func (n *node) failAll(err error) {
+	if n.state == leader {
+		n.failLeader(err)
+	}
+	n.failForwards(err)
+	for _, r := range n.waitReads {
+		r.done <- err
+	}
+	n.waitReads = nil
+}
+
+func toString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
+
+// toError 还原常用的错误,便于调用者判断
+func toError(s string) error {
+	switch s {
+	case "":
+		return nil
+	case store.ErrNotFound.Error():
+		return store.ErrNotFound
+	case ErrNoLeader.Error():
+		return ErrNoLeader
+	case ErrLeaderChanged.Error():
+		return ErrLeaderChanged
+	case ErrStopped.Error():
+		return ErrStopped
+	case store.ErrCompacted.Error():
+		return store.ErrCompacted
+	case store.ErrFutureRev.Error():
+		return store.ErrFutureRev
+	default:
+		return errors.New(s)
+	}
+}
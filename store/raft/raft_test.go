@@ -0,0 +1,353 @@
+package raft
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+)
+
+type cluster struct {
+	peers  map[string]string
+	dir    string
+	stores map[string]*raftStore
+}
+
+func newCluster(t *testing.T, size int, opts ...Option) *cluster {
+	dir, err := ioutil.TempDir("", "raft")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &cluster{peers: make(map[string]string), dir: dir, stores: make(map[string]*raftStore)}
+	for i := 1; i <= size; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.peers[fmt.Sprintf("n%d", i)] = l.Addr().String()
+		_ = l.Close()
+	}
+
+	for id := range c.peers {
+		c.start(t, id, opts...)
+	}
+
+	return c
+}
+
+func (c *cluster) start(t *testing.T, id string, opts ...Option) {
+	opts = append([]Option{
+		ID(id),
+		Peers(c.peers),
+		Dir(filepath.Join(c.dir, id)),
+		Heartbeat(time.Millisecond * 20),
+		Election(time.Millisecond * 150),
+	}, opts...)
+	s, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.stores[id] = s.(*raftStore)
+}
+
+func (c *cluster) stop(id string) {
+	_ = c.stores[id].Close()
+	delete(c.stores, id)
+}
+
+func (c *cluster) close() {
+	for id := range c.stores {
+		c.stop(id)
+	}
+	_ = os.RemoveAll(c.dir)
+}
+
+// leader 等待所有节点选出相同的Leader
+func (c *cluster) leader(t *testing.T) string {
+	deadline := time.Now().Add(time.Second * 10)
+	for time.Now().Before(deadline) {
+		leader := ""
+		agree := true
+		for _, s := range c.stores {
+			l := s.Leader()
+			if l == "" || (leader != "" && l != leader) {
+				agree = false
+				break
+			}
+			leader = l
+		}
+		if agree && c.stores[leader] != nil {
+			return leader
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+
+	t.Fatal("no leader")
+	return ""
+}
+
+func (c *cluster) follower(leader string) *raftStore {
+	for id, s := range c.stores {
+		if id != leader {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func TestCluster(t *testing.T) {
+	c := newCluster(t, 3)
+	defer c.close()
+
+	leader := c.leader(t)
+	f := c.follower(leader)
+	ctx := context.Background()
+
+	// Follower写入会转发给Leader
+	if err := f.Put(ctx, "/cfg/a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.stores[leader].Put(ctx, "/cfg/a", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Put(ctx, "/cfg/b", []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 所有节点都能读到已经提交的数据
+	for id, s := range c.stores {
+		kv, err := s.Get(ctx, "/cfg/a")
+		if err != nil {
+			t.Fatal(id, err)
+		}
+		if string(kv.Value) != "2" || kv.CreateRevision != 1 || kv.ModifyRevision != 2 || kv.Version != 2 {
+			t.Fatalf("%s: bad kv, %+v", id, kv)
+		}
+	}
+
+	// 历史版本
+	kv, err := f.Get(ctx, "/cfg/a", store.Revision(1))
+	if err != nil || string(kv.Value) != "1" {
+		t.Fatalf("bad history, %+v, %+v", kv, err)
+	}
+
+	kvs, err := f.List(ctx, "/cfg/", store.KeyOnly())
+	if err != nil || len(kvs) != 2 || kvs[0].Key != "/cfg/a" || kvs[0].Value != nil {
+		t.Fatalf("bad list, %+v, %+v", kvs, err)
+	}
+
+	if err := f.Delete(ctx, "/cfg/", store.Prefix()); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := f.Exists(ctx, "/cfg/a"); ok || err != nil {
+		t.Fatalf("delete fail, %+v", err)
+	}
+	if err := f.Delete(ctx, "/cfg/a"); err != store.ErrNotFound {
+		t.Fatalf("expect not found, %+v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	c := newCluster(t, 3)
+	defer c.close()
+
+	leader := c.leader(t)
+	ctx := context.Background()
+	s := c.stores[leader]
+	_ = s.Put(ctx, "/svc/a", []byte("1"))
+	_ = s.Put(ctx, "/other", []byte("x"))
+
+	mux := sync.Mutex{}
+	var events []*store.Event
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	f := c.follower(leader)
+	_ = f.sync(ctx)
+	err := f.Watch(wctx, "/svc/", func(ev *store.Event) {
+		mux.Lock()
+		events = append(events, ev)
+		mux.Unlock()
+	}, store.Prefix(), store.Revision(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = s.Put(ctx, "/svc/a", []byte("2"))
+	_ = s.Delete(ctx, "/svc/a")
+
+	deadline := time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		mux.Lock()
+		n := len(events)
+		mux.Unlock()
+		if n >= 3 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if len(events) != 3 {
+		t.Fatalf("bad events, %+v", len(events))
+	}
+	if events[0].Type != store.PUT || string(events[0].Data.Value) != "1" || events[0].Prev != nil {
+		t.Fatalf("bad history event, %+v", events[0])
+	}
+	if string(events[1].Prev.Value) != "1" || events[1].Data.Version != 2 {
+		t.Fatalf("bad put event, %+v", events[1])
+	}
+	if events[2].Type != store.DELETE || string(events[2].Prev.Value) != "2" {
+		t.Fatalf("bad delete event, %+v", events[2])
+	}
+}
+
+func TestFailover(t *testing.T) {
+	c := newCluster(t, 3)
+	defer c.close()
+
+	ctx := context.Background()
+	leader := c.leader(t)
+	if err := c.stores[leader].Put(ctx, "key", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	c.stop(leader)
+	newLeader := c.leader(t)
+	if newLeader == leader {
+		t.Fatal("leader not changed")
+	}
+
+	f := c.follower(newLeader)
+	if err := f.Put(ctx, "key", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+
+	kv, err := f.Get(ctx, "key")
+	if err != nil || string(kv.Value) != "2" || kv.Version != 2 {
+		t.Fatalf("bad value, %+v, %+v", kv, err)
+	}
+}
+
+func TestPartitionRead(t *testing.T) {
+	c := newCluster(t, 3)
+	defer c.close()
+
+	ctx := context.Background()
+	leader := c.leader(t)
+	old := c.stores[leader]
+	if err := old.Put(ctx, "key", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧Leader被隔离后,其他节点选出新的Leader并写入新数据
+	old.node.tr.isolate(true)
+	f := c.follower(leader)
+	deadline := time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		if l := f.Leader(); l != "" && l != leader {
+			break
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	if err := f.Put(ctx, "key", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧Leader无法得到多数节点的确认,不能返回过期数据
+	rctx, cancel := context.WithTimeout(ctx, time.Millisecond*300)
+	defer cancel()
+	if kv, err := old.Get(rctx, "key"); err == nil {
+		t.Fatalf("stale read, %+v", kv)
+	}
+
+	// 恢复后读到最新的数据
+	old.node.tr.isolate(false)
+	deadline = time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		kv, err := old.Get(ctx, "key")
+		if err == nil && string(kv.Value) == "2" {
+			return
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	t.Fatal("not recovered")
+}
+
+func TestSnapshot(t *testing.T) {
+	c := newCluster(t, 3, SnapshotCount(5), Retention(3))
+	defer c.close()
+
+	ctx := context.Background()
+	leader := c.leader(t)
+	lagging := ""
+	for id := range c.stores {
+		if id != leader {
+			lagging = id
+			break
+		}
+	}
+	c.stop(lagging)
+
+	for i := 0; i < 20; i++ {
+		if err := c.stores[leader].Put(ctx, fmt.Sprintf("key%02d", i), []byte("v")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 生成Snapshot后Leader异步提交压缩日志
+	waitCompacted(t, c.stores[leader])
+
+	// 重启后日志已经被压缩,只能通过Snapshot恢复
+	c.start(t, lagging, SnapshotCount(5), Retention(3))
+	s := c.stores[lagging]
+	deadline := time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		if s.kv.Rev() == 20 {
+			break
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+
+	kvs, err := s.List(ctx, "key")
+	if err != nil || len(kvs) != 20 {
+		t.Fatalf("bad snapshot, %+v, %+v", len(kvs), err)
+	}
+
+	// 历史数据已经被压缩
+	if _, err := s.Get(ctx, "key00", store.Revision(1)); err == nil {
+		t.Fatal("expect compacted")
+	}
+
+	// 压缩通过日志复制,所有节点在相同的revision压缩
+	compacted := c.stores[leader].kv.Compacted()
+	for id, st := range c.stores {
+		deadline := time.Now().Add(time.Second * 5)
+		for st.kv.Compacted() != compacted && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 20)
+		}
+		if st.kv.Compacted() != compacted {
+			t.Fatalf("compacted not equal, %s, %d, %d", id, st.kv.Compacted(), compacted)
+		}
+	}
+}
+
+func waitCompacted(t *testing.T, s *raftStore) {
+	deadline := time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		if s.kv.Compacted() > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	t.Fatal("not compacted")
+}
@@ -0,0 +1,217 @@
+package raft
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	stateFile    = "state"
+	snapshotFile = "snapshot"
+	walFile      = "wal"
+	walHeadSize  = 8 + 8 + 4 // Term+Index+Len
+)
+
+type hardState struct {
+	Term uint64
+	Vote string
+}
+
+// storage 持久化Raft状态,包括term和vote,Snapshot,以及日志
+// 日志使用追加写的方式,只有截断或者压缩时才会重写
+type storage struct {
+	dir string
+	wal *os.File
+}
+
+// openStorage 打开数据目录并加载数据
+func openStorage(dir string) (*storage, *hardState, *raftLog, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, nil, nil, err
+	}
+
+	s := &storage{dir: dir}
+	hs := &hardState{}
+	l := &raftLog{}
+	if err := s.load(stateFile, hs); err != nil {
+		return nil, nil, nil, err
+	}
+
+	snap := &Snapshot{}
+	if err := s.load(snapshotFile, snap); err != nil {
+		return nil, nil, nil, err
+	}
+	l.restore(snap)
+
+	entries, err := s.readWal()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, e := range entries {
+		if e.Index > l.snapIndex {
+			l.truncate(e.Index)
+			l.append(e)
+		}
+	}
+
+	if err := s.rewrite(l.entries); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return s, hs, l, nil
+}
+
+func (s *storage) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *storage) load(name string, v interface{}) error {
+	data, err := ioutil.ReadFile(s.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// save 先写入临时文件再重命名,保证文件完整
+func (s *storage) save(name string, v interface{}) error {
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+
+	tmp := s.path(name + ".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(buf.Bytes()); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path(name))
+}
+
+func (s *storage) saveState(hs *hardState) error {
+	return s.save(stateFile, hs)
+}
+
+// saveSnapshot 保存Snapshot,并重写剩余的日志
+func (s *storage) saveSnapshot(l *raftLog) error {
+	snap := &Snapshot{Index: l.snapIndex, Term: l.snapTerm, Data: l.snapshot}
+	if err := s.save(snapshotFile, snap); err != nil {
+		return err
+	}
+
+	return s.rewrite(l.entries)
+}
+
+// append 追加日志
+func (s *storage) append(entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	w := bufio.NewWriter(s.wal)
+	for _, e := range entries {
+		if err := writeEntry(w, &e); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return s.wal.Sync()
+}
+
+// rewrite 重写所有日志
+func (s *storage) rewrite(entries []Entry) error {
+	if s.wal != nil {
+		_ = s.wal.Close()
+		s.wal = nil
+	}
+
+	tmp := s.path(walFile + ".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	s.wal = f
+	if err := s.append(entries); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path(walFile))
+}
+
+func (s *storage) readWal() ([]Entry, error) {
+	f, err := os.Open(s.path(walFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	r := bufio.NewReader(f)
+	head := make([]byte, walHeadSize)
+	for {
+		// 最后一条日志可能没有写完整,直接丢弃
+		if _, err := io.ReadFull(r, head); err != nil {
+			break
+		}
+
+		e := Entry{Term: binary.LittleEndian.Uint64(head), Index: binary.LittleEndian.Uint64(head[8:])}
+		if size := binary.LittleEndian.Uint32(head[16:]); size > 0 {
+			e.Data = make([]byte, size)
+			if _, err := io.ReadFull(r, e.Data); err != nil {
+				break
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func (s *storage) close() {
+	if s.wal != nil {
+		_ = s.wal.Close()
+		s.wal = nil
+	}
+}
+
+func writeEntry(w io.Writer, e *Entry) error {
+	head := make([]byte, walHeadSize)
+	binary.LittleEndian.PutUint64(head, e.Term)
+	binary.LittleEndian.PutUint64(head[8:], e.Index)
+	binary.LittleEndian.PutUint32(head[16:], uint32(len(e.Data)))
+	if _, err := w.Write(head); err != nil {
+		return err
+	}
+
+	_, err := w.Write(e.Data)
+	return err
+}
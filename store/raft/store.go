@@ -0,0 +1,201 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/store/internal/mvcc"
+)
+
+// New 创建基于Raft的分布式KV存储,可以嵌入到服务中,不需要额外部署etcd
+// 需要通过ID和Peer指定所有节点,节点数通常为3或5
+func New(opts ...Option) (store.Store, error) {
+	o := &Options{
+		Heartbeat:     DefaultHeartbeat,
+		Election:      DefaultElection,
+		Timeout:       DefaultTimeout,
+		SnapshotCount: DefaultSnapshotCount,
+		Retention:     DefaultRetention,
+	}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.ID == "" || o.Peers[o.ID] == "" || o.Heartbeat <= 0 || o.Election <= 0 {
+		return nil, ErrBadOptions
+	}
+
+	s := &raftStore{opts: o, kv: mvcc.New()}
+	n, err := newNode(o, s)
+	if err != nil {
+		return nil, err
+	}
+	s.node = n
+	n.start()
+	return s, nil
+}
+
+const (
+	opPut = iota + 1
+	opDelete
+	opCompact
+)
+
+// command 写请求,作为日志复制到所有节点
+type command struct {
+	Op     int
+	Key    string
+	Value  []byte
+	Prefix bool
+	Rev    int64
+}
+
+// raftStore 基于Raft的KV存储
+// 写操作需要提交到Leader,多数节点复制后才会返回
+// 读操作通过ReadIndex保证能读到已经提交的数据,然后读取本地状态机
+// 数据使用mvcc保存,支持按照Revision查询历史数据,Watch时可以从指定的Revision回放历史事件
+// 生成Snapshot时由Leader提交压缩日志,只保留最近Retention个revision,所有节点在相同的revision压缩
+type raftStore struct {
+	opts *Options
+	node *node
+	kv   *mvcc.Store
+}
+
+func (s *raftStore) Name() string {
+	return "raft"
+}
+
+// Leader 返回当前Leader的ID
+func (s *raftStore) Leader() string {
+	return s.node.Leader()
+}
+
+func (s *raftStore) List(ctx context.Context, key string, opts ...store.Option) ([]*store.KV, error) {
+	o := store.Options{}
+	o.Build(opts...)
+	if err := s.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.kv.Range(key, o.Revision, o.KeyOnly)
+}
+
+func (s *raftStore) Get(ctx context.Context, key string, opts ...store.Option) (*store.KV, error) {
+	o := store.Options{}
+	o.Build(opts...)
+	if err := s.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.kv.Get(key, o.Revision, o.KeyOnly)
+}
+
+func (s *raftStore) Put(ctx context.Context, key string, value []byte) error {
+	return s.propose(ctx, &command{Op: opPut, Key: key, Value: value})
+}
+
+func (s *raftStore) Delete(ctx context.Context, key string, opts ...store.Option) error {
+	o := store.Options{}
+	o.Build(opts...)
+	return s.propose(ctx, &command{Op: opDelete, Key: key, Prefix: o.Prefix})
+}
+
+func (s *raftStore) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.Get(ctx, key, store.KeyOnly())
+	switch err {
+	case nil:
+		return true, nil
+	case store.ErrNotFound:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Watch 监听本地状态机的变化,store.Revision指定从哪个revision开始回放历史事件
+// ctx结束或者Close后停止监听
+func (s *raftStore) Watch(ctx context.Context, key string, cb store.Callback, opts ...store.Option) error {
+	o := store.Options{}
+	o.Build(opts...)
+	return s.kv.Watch(ctx, key, o.Prefix, o.Revision, cb)
+}
+
+// Compact 压缩历史数据,会作为日志复制到所有节点
+func (s *raftStore) Compact(ctx context.Context, rev int64) error {
+	return s.propose(ctx, &command{Op: opCompact, Rev: rev})
+}
+
+func (s *raftStore) Close() error {
+	s.node.stop()
+	s.kv.Close()
+	return nil
+}
+
+func (s *raftStore) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if _, ok := ctx.Deadline(); !ok && s.opts.Timeout > 0 {
+		return context.WithTimeout(ctx, s.opts.Timeout)
+	}
+
+	return ctx, func() {}
+}
+
+func (s *raftStore) sync(ctx context.Context) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+	return s.node.readIndex(ctx)
+}
+
+func (s *raftStore) propose(ctx context.Context, cmd *command) error {
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(cmd); err != nil {
+		return err
+	}
+
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+	return s.node.propose(ctx, buf.Bytes())
+}
+
+func (s *raftStore) apply(data []byte) error {
+	cmd := &command{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(cmd); err != nil {
+		return err
+	}
+
+	switch cmd.Op {
+	case opPut:
+		s.kv.Put(cmd.Key, cmd.Value)
+	case opDelete:
+		if s.kv.Delete(cmd.Key, cmd.Prefix) == 0 {
+			return store.ErrNotFound
+		}
+	case opCompact:
+		return s.kv.Compact(cmd.Rev)
+	}
+
+	return nil
+}
+
+func (s *raftStore) snapshot() ([]byte, error) {
+	// 各节点生成Snapshot的时机不同,不能直接在本地压缩,否则按照revision查询时结果会不一致
+	// 由Leader异步提交压缩日志,snapshot在节点协程中调用,不能同步等待
+	if s.opts.Retention > 0 && s.node.IsLeader() {
+		if rev := s.kv.Rev() - s.opts.Retention; rev > s.kv.Compacted() {
+			go func() {
+				_ = s.Compact(context.Background(), rev)
+			}()
+		}
+	}
+
+	return s.kv.Snapshot()
+}
+
+func (s *raftStore) restore(data []byte) error {
+	return s.kv.Restore(data)
+}
@@ -0,0 +1,160 @@
+package raft
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/jeckbjy/gsk/anet"
+	"github.com/jeckbjy/gsk/anet/tcp"
+	"github.com/jeckbjy/gsk/frame/varint"
+)
+
+func newTransport(n *node, peers map[string]string) *transport {
+	t := &transport{tran: tcp.New(), peers: make(map[string]*peer), accepted: make(map[anet.Conn]struct{})}
+	t.tran.AddFilters(&msgFilter{node: n, tr: t, frame: varint.New()})
+	for id, addr := range peers {
+		if id != n.id {
+			t.peers[id] = &peer{id: id, addr: addr}
+		}
+	}
+
+	return t
+}
+
+// transport 基于anet/tcp的节点间通信
+// 每个节点主动连接其他所有节点,只通过主动建立的连接发送消息,被动接收的连接只用于读取消息
+// 发送失败的消息会直接丢弃,由raft协议自身保证重传
+type transport struct {
+	tran     anet.Tran
+	listener anet.Listener
+	peers    map[string]*peer
+	mux      sync.Mutex
+	accepted map[anet.Conn]struct{}
+	closed   bool
+	isolated int32 // 测试使用,模拟网络分区,丢弃所有收发的消息
+}
+
+type peer struct {
+	id      string
+	addr    string
+	mux     sync.Mutex
+	conn    anet.Conn
+	dialing bool
+	closed  bool
+}
+
+func (t *transport) listen(addr string) error {
+	l, err := t.tran.Listen(addr)
+	if err != nil {
+		return err
+	}
+
+	t.listener = l
+	return nil
+}
+
+func (t *transport) send(msg *Message) {
+	p := t.peers[msg.To]
+	if p == nil || t.isIsolated() {
+		return
+	}
+
+	if conn := p.get(t.tran); conn != nil {
+		_ = conn.Send(msg)
+	}
+}
+
+func (t *transport) isolate(isolated bool) {
+	v := int32(0)
+	if isolated {
+		v = 1
+	}
+	atomic.StoreInt32(&t.isolated, v)
+}
+
+func (t *transport) isIsolated() bool {
+	return atomic.LoadInt32(&t.isolated) == 1
+}
+
+func (t *transport) accept(conn anet.Conn) {
+	t.mux.Lock()
+	closed := t.closed
+	if !closed {
+		t.accepted[conn] = struct{}{}
+	}
+	t.mux.Unlock()
+
+	if closed {
+		_ = conn.Close()
+	}
+}
+
+func (t *transport) remove(conn anet.Conn) {
+	t.mux.Lock()
+	delete(t.accepted, conn)
+	t.mux.Unlock()
+}
+
+func (t *transport) close() {
+	if t.listener != nil {
+		_ = t.listener.Close()
+	}
+
+	for _, p := range t.peers {
+		p.close()
+	}
+
+	t.mux.Lock()
+	t.closed = true
+	accepted := t.accepted
+	t.accepted = make(map[anet.Conn]struct{})
+	t.mux.Unlock()
+	for conn := range accepted {
+		_ = conn.Close()
+	}
+
+	_ = t.tran.Close()
+}
+
+// get 返回可用的连接,没有连接时异步重连
+func (p *peer) get(tran anet.Tran) anet.Conn {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.conn != nil && p.conn.IsActive() {
+		return p.conn
+	}
+
+	if !p.dialing && !p.closed {
+		p.dialing = true
+		go p.dial(tran)
+	}
+
+	return nil
+}
+
+func (p *peer) dial(tran anet.Tran) {
+	conn, _ := tran.Dial(p.addr, anet.WithBlocking(true), anet.WithTimeout(DefaultHeartbeat))
+	p.mux.Lock()
+	p.dialing = false
+	if conn != nil && conn.IsActive() && !p.closed {
+		p.conn = conn
+		conn = nil
+	}
+	p.mux.Unlock()
+
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
+
+func (p *peer) close() {
+	p.mux.Lock()
+	p.closed = true
+	conn := p.conn
+	p.conn = nil
+	p.mux.Unlock()
+
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
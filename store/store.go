@@ -8,11 +8,15 @@ import (
 var (
 	ErrNotSupport = errors.New("not support")
 	ErrNotFound   = errors.New("not found")
+	ErrCompacted  = errors.New("required revision has been compacted")
+	ErrFutureRev  = errors.New("required revision is a future revision")
 )
 
 // Store kv storage
 // 主要用途:配置文件管理
-// 推荐使用etcd,拥有mvcc控制
+// 推荐使用etcd,拥有mvcc控制,不想额外部署时可以使用store/raft,嵌入到服务中
+// 单元测试可以使用store/memory,语义与etcd一致
+// 可选功能通过接口提供,使用时需要类型断言:Compactor(压缩历史版本),Transactional(事务和租约)
 // 可以是本地文件存储,也可以是分布式kv存储,如etcd,consul,zookeeper
 // consul的value限制不超过512kb
 //
@@ -40,6 +44,16 @@ type Store interface {
 	Close() error
 }
 
+// Compactor 可选接口,支持MVCC的存储可以删除指定revision之前的历史版本
+// 压缩后不能再查询或者Watch这些revision,会返回错误
+// 使用时通过类型断言判断是否支持:
+//	if c, ok := s.(store.Compactor); ok {
+//		err := c.Compact(ctx, rev)
+//	}
+type Compactor interface {
+	Compact(ctx context.Context, rev int64) error
+}
+
 type EventType int
 
 const (
@@ -0,0 +1,225 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrLeaseNotFound = errors.New("lease not found")
+
+// Transactional 可选接口,支持条件写入和租约
+// Txn: 先判断If中所有条件,全部满足则执行Then,否则执行Else,所有修改原子生效并共用一个revision
+// Grant: 创建租约,绑定租约的key会在租约过期后自动删除,KeepAlive可以续约,Revoke会立即删除所有绑定的key
+//
+// 并不是所有的存储都支持事务,使用时需要通过类型断言判断:
+//	if t, ok := s.(store.Transactional); ok {
+//		rsp, err := t.Txn(ctx, &store.Txn{
+//			If:   []store.Compare{store.CmpVersion(key, store.Equal, 0)},
+//			Then: []store.Op{store.OpPut(key, value)},
+//		})
+//	}
+// 也可以直接使用CompareAndSwap,PutWithTTL等辅助函数,不支持时会返回ErrNotSupport
+type Transactional interface {
+	Txn(ctx context.Context, txn *Txn) (*TxnResponse, error)
+	Grant(ctx context.Context, ttl time.Duration) (LeaseID, error)
+	KeepAlive(ctx context.Context, id LeaseID) error
+	Revoke(ctx context.Context, id LeaseID) error
+}
+
+// LeaseID 租约ID,0表示没有租约
+type LeaseID int64
+
+type Txn struct {
+	If   []Compare
+	Then []Op
+	Else []Op
+}
+
+type TxnResponse struct {
+	Succeeded bool  // If中的条件是否全部满足
+	Revision  int64 // 执行后的revision
+}
+
+type CompareTarget int
+
+const (
+	CompareVersion CompareTarget = iota
+	CompareModify
+	CompareValue
+	CompareExists
+)
+
+type CompareResult int
+
+const (
+	Equal CompareResult = iota
+	NotEqual
+	Less
+	Greater
+)
+
+// Compare 比较条件,key不存在时Version和ModifyRevision为0,Value为nil
+type Compare struct {
+	Key    string
+	Target CompareTarget
+	Result CompareResult
+	Rev    int64  // Version,ModifyRevision
+	Value  []byte // Value
+	Exists bool   // Exists
+}
+
+func CmpVersion(key string, result CompareResult, version int64) Compare {
+	return Compare{Key: key, Target: CompareVersion, Result: result, Rev: version}
+}
+
+func CmpModify(key string, result CompareResult, rev int64) Compare {
+	return Compare{Key: key, Target: CompareModify, Result: result, Rev: rev}
+}
+
+func CmpValue(key string, result CompareResult, value []byte) Compare {
+	return Compare{Key: key, Target: CompareValue, Result: result, Value: value}
+}
+
+func CmpExists(key string, exists bool) Compare {
+	return Compare{Key: key, Target: CompareExists, Result: Equal, Exists: exists}
+}
+
+// Match 判断条件是否满足,kv为nil表示key不存在,用于实现Transactional
+func (c *Compare) Match(kv *KV) bool {
+	var r int
+	switch c.Target {
+	case CompareVersion:
+		r = compareInt(kv != nil, func() int64 { return kv.Version }, c.Rev)
+	case CompareModify:
+		r = compareInt(kv != nil, func() int64 { return kv.ModifyRevision }, c.Rev)
+	case CompareValue:
+		var value []byte
+		if kv != nil {
+			value = kv.Value
+		}
+		r = bytes.Compare(value, c.Value)
+	case CompareExists:
+		if (kv != nil) != c.Exists {
+			r = 1
+		}
+	}
+
+	switch c.Result {
+	case Equal:
+		return r == 0
+	case NotEqual:
+		return r != 0
+	case Less:
+		return r < 0
+	case Greater:
+		return r > 0
+	}
+
+	return false
+}
+
+func compareInt(exists bool, get func() int64, v int64) int {
+	var x int64
+	if exists {
+		x = get()
+	}
+
+	switch {
+	case x < v:
+		return -1
+	case x > v:
+		return 1
+	default:
+		return 0
+	}
+}
+
+type OpType int
+
+const (
+	OpTypePut OpType = iota
+	OpTypeDelete
+)
+
+// Op 事务中的写操作
+type Op struct {
+	Type   OpType
+	Key    string
+	Value  []byte
+	Lease  LeaseID // Put时绑定的租约
+	Prefix bool    // Delete时是否前缀匹配
+}
+
+func OpPut(key string, value []byte) Op {
+	return Op{Type: OpTypePut, Key: key, Value: value}
+}
+
+// OpPutWithLease 写入并绑定租约,租约过期后key会被删除
+func OpPutWithLease(key string, value []byte, lease LeaseID) Op {
+	return Op{Type: OpTypePut, Key: key, Value: value, Lease: lease}
+}
+
+// OpDelete 删除key,支持Prefix
+func OpDelete(key string, opts ...Option) Op {
+	o := Options{}
+	o.Build(opts...)
+	return Op{Type: OpTypeDelete, Key: key, Prefix: o.Prefix}
+}
+
+// CompareAndSwap 当key的Version等于version时写入value,version为0表示key不存在
+func CompareAndSwap(ctx context.Context, s Store, key string, version int64, value []byte) (bool, error) {
+	t, ok := s.(Transactional)
+	if !ok {
+		return false, ErrNotSupport
+	}
+
+	rsp, err := t.Txn(ctx, &Txn{
+		If:   []Compare{CmpVersion(key, Equal, version)},
+		Then: []Op{OpPut(key, value)},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return rsp.Succeeded, nil
+}
+
+// CompareAndDelete 当key的Version等于version时删除
+func CompareAndDelete(ctx context.Context, s Store, key string, version int64) (bool, error) {
+	t, ok := s.(Transactional)
+	if !ok {
+		return false, ErrNotSupport
+	}
+
+	rsp, err := t.Txn(ctx, &Txn{
+		If:   []Compare{CmpVersion(key, Equal, version)},
+		Then: []Op{OpDelete(key)},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return rsp.Succeeded, nil
+}
+
+// PutWithTTL 创建租约并写入,需要定期调用KeepAlive续约,否则ttl之后key会被删除
+func PutWithTTL(ctx context.Context, s Store, key string, value []byte, ttl time.Duration) (LeaseID, error) {
+	t, ok := s.(Transactional)
+	if !ok {
+		return 0, ErrNotSupport
+	}
+
+	id, err := t.Grant(ctx, ttl)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := t.Txn(ctx, &Txn{Then: []Op{OpPutWithLease(key, value, id)}}); err != nil {
+		_ = t.Revoke(ctx, id)
+		return 0, err
+	}
+
+	return id, nil
+}
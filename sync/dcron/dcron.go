@@ -0,0 +1,436 @@
+// Package dcron 分布式定时任务
+// 基于util/cron,多个实例注册相同的任务,但每次触发只有一个实例执行:
+// ModeLeader: 通过sync/leader选举,只有leader执行所有任务
+// ModeShard: 实例通过租约注册到store中,任务根据名字一致性hash分配给某个实例
+// 执行前通过CompareAndSwap写入本次的计划触发时间,即使短时间内出现两个leader或者成员列表不一致,同一个计划时间也只会执行一次,
+// 计划时间由schedule计算而不是取本地时间,实例之间的时钟偏差不会导致重复执行,但偏差超过任务间隔时可能跳过部分执行;
+// @every这类相对时间的任务没有固定的计划时间,使用本地时间(精确到秒)
+// 接管任务时根据最后执行时间补执行错过的任务,每次执行的结果保存在store中,可以通过History查询
+// store需要实现store.Transactional
+package dcron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/sync/leader"
+	"github.com/jeckbjy/gsk/sync/leader/kv"
+	"github.com/jeckbjy/gsk/sync/optimistic"
+	"github.com/jeckbjy/gsk/util/cron"
+)
+
+var (
+	ErrDuplicateJob = errors.New("duplicate job")
+	ErrRunning      = errors.New("cron is running")
+)
+
+// Record 一次任务执行记录
+type Record struct {
+	Name     string    `json:"name"`
+	Instance string    `json:"instance"`          // 执行的实例
+	Time     time.Time `json:"time"`              // 计划触发时间
+	Start    time.Time `json:"start"`             // 开始时间
+	End      time.Time `json:"end"`               // 结束时间
+	CatchUp  bool      `json:"catchup,omitempty"` // 是否是补执行
+	Error    string    `json:"error,omitempty"`   // panic信息
+}
+
+// Duration 执行时间
+func (r *Record) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+type job struct {
+	name     string
+	id       cron.EntryID
+	schedule cron.Schedule
+	job      cron.Job
+}
+
+// Cron 分布式定时任务,任务使用名字标识,所有实例需要使用相同的名字注册相同的任务
+type Cron struct {
+	opts    *Options
+	store   store.Store
+	cron    *cron.Cron
+	mux     sync.Mutex
+	jobs    map[string]*job
+	running bool
+	leader  int32              // ModeLeader时是否是leader
+	shard   *shard             // ModeShard时的成员列表
+	leave   context.CancelFunc // 取消选举或者成员注册
+	cancel  context.CancelFunc // 取消任务中的store操作
+	ctx     context.Context
+	wg      sync.WaitGroup
+	runs    sync.WaitGroup // 正在补执行的任务
+	now     func() time.Time
+}
+
+// New 创建分布式定时任务,默认ModeLeader
+func New(s store.Store, opts ...Option) *Cron {
+	o := &Options{}
+	o.Build(opts...)
+	c := &Cron{
+		opts:  o,
+		store: s,
+		cron:  cron.New(cron.WithParser(o.Parser), cron.WithLocation(o.Location)),
+		jobs:  make(map[string]*job),
+		ctx:   context.Background(),
+		now:   time.Now,
+	}
+	if o.Mode == ModeShard {
+		c.shard = newShard(c)
+	}
+
+	return c
+}
+
+// ID 实例id
+func (c *Cron) ID() string {
+	return c.opts.ID
+}
+
+// AddFunc 添加任务,name需要全局唯一
+func (c *Cron) AddFunc(name string, spec string, fn func()) (cron.EntryID, error) {
+	return c.AddJob(name, spec, cron.FuncJob(fn))
+}
+
+// AddJob 添加任务,name需要全局唯一,返回的EntryID仅在本实例中有效
+func (c *Cron) AddJob(name string, spec string, cmd cron.Job) (cron.EntryID, error) {
+	schedule, err := c.opts.Parser.Parse(spec)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if _, ok := c.jobs[name]; ok {
+		return 0, ErrDuplicateJob
+	}
+
+	j := &job{name: name, schedule: schedule, job: cmd}
+	j.id = c.cron.Schedule(schedule, cron.FuncJob(func() { c.trigger(j, false) }))
+	c.jobs[name] = j
+	return j.id, nil
+}
+
+// Remove 删除任务
+func (c *Cron) Remove(name string) {
+	c.mux.Lock()
+	j, ok := c.jobs[name]
+	delete(c.jobs, name)
+	c.mux.Unlock()
+	if ok {
+		c.cron.Remove(j.id)
+	}
+}
+
+// Entries 本地cron中的任务
+func (c *Cron) Entries() []cron.Entry {
+	return c.cron.Entries()
+}
+
+// IsOwner 判断任务当前是否由本实例执行
+func (c *Cron) IsOwner(name string) bool {
+	if c.shard != nil {
+		return c.shard.owner(name) == c.opts.ID
+	}
+
+	return atomic.LoadInt32(&c.leader) == 1
+}
+
+// Start 开始调度,并开始选举或者注册成员
+func (c *Cron) Start() error {
+	if _, ok := c.store.(store.Transactional); !ok {
+		return store.ErrNotSupport
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.running {
+		return ErrRunning
+	}
+	c.running = true
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	ctx, leave := context.WithCancel(c.ctx)
+	c.leave = leave
+
+	c.wg.Add(1)
+	if c.shard != nil {
+		go c.shard.run(ctx)
+	} else {
+		go c.campaign(ctx)
+	}
+
+	c.cron.Start()
+	return nil
+}
+
+// Stop 停止调度,放弃leader或者退出成员列表,返回的context在正在执行的任务结束后Done
+// 正在执行的任务仍然可以写入执行记录,直到结束后才取消store操作
+func (c *Cron) Stop() context.Context {
+	c.mux.Lock()
+	running := c.running
+	c.running = false
+	leave, cancel := c.leave, c.cancel
+	c.mux.Unlock()
+
+	cronCtx := c.cron.Stop()
+	if !running {
+		return cronCtx
+	}
+
+	// 先退出选举,不会再有新的补执行
+	leave()
+	c.wg.Wait()
+
+	ctx, done := context.WithCancel(context.Background())
+	go func() {
+		<-cronCtx.Done()
+		c.runs.Wait()
+		cancel()
+		done()
+	}()
+
+	return ctx
+}
+
+// History 查询任务的执行记录,按照时间从旧到新排列
+// 只读查询不依赖Cron的运行状态,Start之前或者Stop之后都可以调用
+func (c *Cron) History(name string) ([]*Record, error) {
+	kv, err := c.store.Get(context.Background(), c.historyKey(name))
+	if err == store.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var records []*Record
+	if err := json.Unmarshal(kv.Value, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// LastRun 查询任务最后一次执行记录,没有执行过时返回nil,与History一样可以在Stop之后调用
+func (c *Cron) LastRun(name string) (*Record, error) {
+	r, _, err := c.lastRun(context.Background(), name)
+	return r, err
+}
+
+func (c *Cron) prefix() string {
+	return fmt.Sprintf("%s/%s", c.opts.Root, c.opts.Group)
+}
+
+func (c *Cron) lastKey(name string) string {
+	return c.prefix() + "/last/" + name
+}
+
+func (c *Cron) historyKey(name string) string {
+	return c.prefix() + "/history/" + name
+}
+
+func (c *Cron) lastRun(ctx context.Context, name string) (*Record, int64, error) {
+	kv, err := c.store.Get(ctx, c.lastKey(name))
+	if err == store.ErrNotFound {
+		return nil, 0, nil
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	r := &Record{}
+	if err := json.Unmarshal(kv.Value, r); err != nil {
+		return nil, 0, err
+	}
+
+	return r, kv.Version, nil
+}
+
+// campaign ModeLeader时参与选举,成为leader后补执行错过的任务,失去leader后重新选举
+func (c *Cron) campaign(ctx context.Context) {
+	defer c.wg.Done()
+
+	l := c.opts.Leader
+	if l == nil {
+		l = kv.New(c.store, leader.Group("cron/"+c.opts.Group), leader.Context(ctx))
+	}
+
+	var e leader.Elected
+	var err error
+	for {
+		if e == nil {
+			e, err = l.Elect(c.opts.ID, leader.TTL(c.opts.TTL))
+		} else {
+			err = e.Reelect()
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			log.Printf("[dcron] elect fail, %+v", err)
+			e = nil
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		atomic.StoreInt32(&c.leader, 1)
+		c.catchUp()
+
+		select {
+		case <-e.Revoked():
+			atomic.StoreInt32(&c.leader, 0)
+		case <-ctx.Done():
+			atomic.StoreInt32(&c.leader, 0)
+			_ = e.Resign()
+			return
+		}
+	}
+}
+
+// catchUp 补执行本实例负责的任务中错过的任务
+func (c *Cron) catchUp() {
+	if !c.opts.CatchUp {
+		return
+	}
+
+	c.mux.Lock()
+	jobs := make([]*job, 0, len(c.jobs))
+	for _, j := range c.jobs {
+		jobs = append(jobs, j)
+	}
+	c.mux.Unlock()
+
+	for _, j := range jobs {
+		if c.IsOwner(j.name) {
+			c.runs.Add(1)
+			go func(j *job) {
+				defer c.runs.Done()
+				c.trigger(j, true)
+			}(j)
+		}
+	}
+}
+
+// trigger 定时触发或者补执行,通过CompareAndSwap保证同一个计划时间只执行一次
+func (c *Cron) trigger(j *job, catchUp bool) {
+	if !c.IsOwner(j.name) {
+		return
+	}
+
+	now := c.now().In(c.opts.Location)
+	last, version, err := c.lastRun(c.ctx, j.name)
+	if err != nil {
+		log.Printf("[dcron] get last run fail, %s, %+v", j.name, err)
+		return
+	}
+
+	var tick time.Time
+	if catchUp {
+		// 从未执行过时无法判断是否错过
+		if last == nil {
+			return
+		}
+		tick = lastTick(j.schedule, last.Time.In(c.opts.Location), now)
+		if tick.IsZero() {
+			return
+		}
+	} else {
+		tick = lastTick(j.schedule, now.Add(-tickLookback), now)
+		if tick.IsZero() {
+			tick = now.Truncate(time.Second)
+		}
+	}
+
+	if last != nil && !last.Time.Before(tick) {
+		// 其他实例已经执行过
+		return
+	}
+
+	r := &Record{Name: j.name, Instance: c.opts.ID, Time: tick, Start: time.Now(), CatchUp: catchUp}
+	data, _ := json.Marshal(r)
+	ok, err := store.CompareAndSwap(c.ctx, c.store, c.lastKey(j.name), version, data)
+	if err != nil || !ok {
+		return
+	}
+
+	r.Error = run(j.job)
+	r.End = time.Now()
+	if err := c.appendHistory(r); err != nil {
+		log.Printf("[dcron] save history fail, %s, %+v", j.name, err)
+	}
+}
+
+func (c *Cron) appendHistory(r *Record) error {
+	return optimistic.Update(c.store, c.historyKey(r.Name), func(kv *store.KV) ([]byte, error) {
+		var records []*Record
+		if kv != nil {
+			_ = json.Unmarshal(kv.Value, &records)
+		}
+
+		records = append(records, r)
+		if n := len(records) - c.opts.History; n > 0 {
+			records = records[n:]
+		}
+
+		return json.Marshal(records)
+	}, optimistic.Context(c.ctx))
+}
+
+const (
+	tickLookback = time.Minute // 定时触发时向前查找计划时间的范围
+	maxTicks     = 1000        // 补执行时最多向后查找的次数
+)
+
+// lastTick 返回(after, now]之间最后一个计划时间,没有时返回零值
+// 定时触发时计划时间不会晚于now,因此只需要向前查找一小段时间
+func lastTick(s cron.Schedule, after, now time.Time) time.Time {
+	var tick time.Time
+	for t, i := s.Next(after), 0; !t.IsZero() && !t.After(now); t, i = s.Next(t), i+1 {
+		if i == maxTicks {
+			// 间隔太久,只查找最近的计划时间
+			if t := lastTick(s, now.Add(-tickLookback), now); !t.IsZero() {
+				return t
+			}
+			return now.Truncate(time.Second)
+		}
+		tick = t
+	}
+
+	return tick
+}
+
+// run 执行任务,返回panic信息
+func run(j cron.Job) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			buf := make([]byte, 4096)
+			buf = buf[:runtime.Stack(buf, false)]
+			msg = fmt.Sprintf("%v", r)
+			log.Printf("[dcron] job panic, %s\n%s", msg, buf)
+		}
+	}()
+
+	j.Run()
+	return ""
+}
+
+func hashName(name string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return h.Sum64()
+}
@@ -0,0 +1,280 @@
+package dcron
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/store/memory"
+)
+
+// newStores 所有实例共享同一个内存store,模拟多个进程访问同一个集群
+func newStores(t *testing.T, n int) ([]store.Store, func()) {
+	s := memory.New()
+	var stores []store.Store
+	for i := 0; i < n; i++ {
+		stores = append(stores, s)
+	}
+
+	return stores, func() {
+		_ = s.Close()
+	}
+}
+
+// runs 记录每个任务每次触发被执行的次数
+type runs struct {
+	mux   sync.Mutex
+	count map[string]int
+}
+
+func (r *runs) add(name string, instance string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	key := fmt.Sprintf("%s@%d", name, time.Now().Unix())
+	r.count[key]++
+}
+
+func (r *runs) check(t *testing.T) int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	total := 0
+	for k, v := range r.count {
+		if v > 1 {
+			t.Errorf("job run more than once, %s, %d", k, v)
+		}
+		total += v
+	}
+	return total
+}
+
+func TestLeader(t *testing.T) {
+	stores, closer := newStores(t, 3)
+	defer closer()
+
+	r := &runs{count: make(map[string]int)}
+	var crons []*Cron
+	for i, s := range stores {
+		c := New(s, ID(fmt.Sprintf("node-%d", i)), TTL(time.Millisecond*300), WithSeconds())
+		id := c.ID()
+		if _, err := c.AddFunc("reset", "* * * * * *", func() { r.add("reset", id) }); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Start(); err != nil {
+			t.Fatal(err)
+		}
+		crons = append(crons, c)
+	}
+
+	time.Sleep(time.Millisecond * 2500)
+
+	// leader退出后由其他实例接管
+	leader := -1
+	for i, c := range crons {
+		if c.IsOwner("reset") {
+			leader = i
+		}
+	}
+	if leader == -1 {
+		t.Fatal("no leader")
+	}
+	crons[leader].Stop()
+
+	time.Sleep(time.Millisecond * 2500)
+	for i, c := range crons {
+		if i != leader {
+			c.Stop()
+		}
+	}
+
+	if total := r.check(t); total < 3 {
+		t.Fatalf("too few runs, %d", total)
+	}
+
+	history, err := crons[0].History("reset")
+	if err != nil || len(history) == 0 {
+		t.Fatalf("no history, %+v", err)
+	}
+	instances := make(map[string]bool)
+	for _, h := range history {
+		instances[h.Instance] = true
+	}
+	if len(instances) < 2 {
+		t.Fatalf("job not taken over, %+v", instances)
+	}
+}
+
+func TestShard(t *testing.T) {
+	stores, closer := newStores(t, 3)
+	defer closer()
+
+	r := &runs{count: make(map[string]int)}
+	names := []string{"a", "b", "c", "d", "e", "f"}
+	var crons []*Cron
+	for i, s := range stores {
+		c := New(s, ID(fmt.Sprintf("node-%d", i)), Shard(), TTL(time.Millisecond*300), WithSeconds())
+		id := c.ID()
+		for _, name := range names {
+			name := name
+			if _, err := c.AddFunc(name, "* * * * * *", func() { r.add(name, id) }); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.Start(); err != nil {
+			t.Fatal(err)
+		}
+		crons = append(crons, c)
+	}
+
+	time.Sleep(time.Millisecond * 2500)
+
+	// 每个任务只属于一个实例
+	owners := make(map[string]bool)
+	for _, name := range names {
+		n := 0
+		for _, c := range crons {
+			if c.IsOwner(name) {
+				n++
+				owners[c.ID()] = true
+			}
+		}
+		if n != 1 {
+			t.Fatalf("bad owner count, %s, %d", name, n)
+		}
+	}
+	if len(owners) < 2 {
+		t.Fatalf("jobs not sharded, %+v", owners)
+	}
+
+	for _, c := range crons {
+		c.Stop()
+	}
+
+	if total := r.check(t); total < len(names) {
+		t.Fatalf("too few runs, %d", total)
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	stores, closer := newStores(t, 1)
+	defer closer()
+
+	// 上次执行在两小时之前,错过了一次执行
+	s := stores[0]
+	last := &Record{Name: "daily", Instance: "old", Time: time.Now().Add(-time.Hour * 2).Truncate(time.Second)}
+	data, _ := json.Marshal(last)
+	c := New(s, ID("node"), TTL(time.Millisecond*300))
+	if err := s.Put(context.Background(), c.lastKey("daily"), data); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{}, 1)
+	if _, err := c.AddFunc("daily", "@every 1h", func() { done <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 2):
+		t.Fatal("catch up timeout")
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	r, err := c.LastRun("daily")
+	if err != nil || r == nil || !r.CatchUp || r.Instance != "node" {
+		t.Fatalf("bad last run, %+v, %+v", r, err)
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	stores, closer := newStores(t, 2)
+	defer closer()
+
+	// node-1的时钟比node-0快1.2秒,两个实例都在各自的10:00:00触发
+	tick := time.Date(2020, 1, 1, 10, 0, 0, 0, time.Local)
+	skews := []time.Duration{time.Millisecond * 300, time.Millisecond * 1500}
+	count := 0
+	for i, s := range stores {
+		c := New(s, ID(fmt.Sprintf("node-%d", i)), WithSeconds())
+		if _, err := c.AddFunc("minute", "0 * * * * *", func() { count++ }); err != nil {
+			t.Fatal(err)
+		}
+		atomic.StoreInt32(&c.leader, 1)
+		now := tick.Add(skews[i])
+		c.now = func() time.Time { return now }
+		c.trigger(c.jobs["minute"], false)
+	}
+
+	if count != 1 {
+		t.Fatalf("job run %d times", count)
+	}
+
+	r, err := New(stores[0]).LastRun("minute")
+	if err != nil || r == nil || !r.Time.Equal(tick) {
+		t.Fatalf("bad last run, %+v, %+v", r, err)
+	}
+}
+
+// ctxStore 与远程store一样,ctx结束后Get返回错误
+type ctxStore struct {
+	store.Store
+	store.Transactional
+}
+
+func (s ctxStore) Get(ctx context.Context, key string, opts ...store.Option) (*store.KV, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.Store.Get(ctx, key, opts...)
+}
+
+func TestStopWaitJob(t *testing.T) {
+	stores, closer := newStores(t, 1)
+	defer closer()
+
+	c := New(ctxStore{stores[0], stores[0].(store.Transactional)}, ID("node"), TTL(time.Millisecond*300), WithSeconds())
+	started := make(chan struct{}, 1)
+	if _, err := c.AddFunc("slow", "* * * * * *", func() {
+		select {
+		case started <- struct{}{}:
+			time.Sleep(time.Millisecond * 300)
+		default:
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(time.Second * 3):
+		t.Fatal("job not started")
+	}
+
+	// Stop时任务还在执行,结束后仍然需要保存执行记录
+	select {
+	case <-c.Stop().Done():
+	case <-time.After(time.Second * 2):
+		t.Fatal("stop timeout")
+	}
+
+	// Stop之后依然可以查询
+	history, err := c.History("slow")
+	if err != nil || len(history) == 0 || history[len(history)-1].End.IsZero() {
+		t.Fatalf("history not saved, %+v, %+v", history, err)
+	}
+	if r, err := c.LastRun("slow"); err != nil || r == nil {
+		t.Fatalf("bad last run, %+v, %+v", r, err)
+	}
+}
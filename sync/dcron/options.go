@@ -0,0 +1,144 @@
+package dcron
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jeckbjy/gsk/sync/leader"
+	"github.com/jeckbjy/gsk/util/cron"
+)
+
+const (
+	DefaultRoot    = "/cron"
+	DefaultGroup   = "default"
+	DefaultTTL     = time.Second * 10
+	DefaultHistory = 10
+)
+
+// Mode 任务分配方式
+type Mode int
+
+const (
+	ModeLeader Mode = iota // 只有leader执行所有任务
+	ModeShard              // 根据任务名一致性hash分配到不同实例
+)
+
+type Option func(o *Options)
+
+type Options struct {
+	ID       string              // 实例id,默认hostname-pid
+	Group    string              // 分组,相同分组的实例共同执行同一批任务
+	Root     string              // store中的根目录
+	Mode     Mode                // 任务分配方式
+	Leader   leader.Leader       // ModeLeader时使用,默认使用sync/leader/kv
+	TTL      time.Duration       // leader和shard成员的租约时间,宕机后最长TTL时间后由其他实例接管
+	Parser   cron.ScheduleParser // 解析spec,默认标准格式
+	Location *time.Location      // 时区
+	CatchUp  bool                // 接管任务时是否补执行错过的任务,默认true
+	History  int                 // 每个任务保留的历史记录数
+}
+
+func (o *Options) Build(opts ...Option) {
+	o.CatchUp = true
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.ID == "" {
+		host, _ := os.Hostname()
+		o.ID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	if o.Group == "" {
+		o.Group = DefaultGroup
+	}
+
+	if o.Root == "" {
+		o.Root = DefaultRoot
+	}
+
+	if o.TTL <= 0 {
+		o.TTL = DefaultTTL
+	}
+
+	if o.Parser == nil {
+		o.Parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	}
+
+	if o.Location == nil {
+		o.Location = time.Local
+	}
+
+	if o.History <= 0 {
+		o.History = DefaultHistory
+	}
+}
+
+func ID(id string) Option {
+	return func(o *Options) {
+		o.ID = id
+	}
+}
+
+func Group(group string) Option {
+	return func(o *Options) {
+		o.Group = group
+	}
+}
+
+func Root(root string) Option {
+	return func(o *Options) {
+		o.Root = root
+	}
+}
+
+// Leader 只有leader执行任务,l为nil时使用基于store的选举
+func Leader(l leader.Leader) Option {
+	return func(o *Options) {
+		o.Mode = ModeLeader
+		o.Leader = l
+	}
+}
+
+// Shard 任务根据名字分配给不同的实例
+func Shard() Option {
+	return func(o *Options) {
+		o.Mode = ModeShard
+	}
+}
+
+func TTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+func Parser(p cron.ScheduleParser) Option {
+	return func(o *Options) {
+		o.Parser = p
+	}
+}
+
+// WithSeconds spec第一个字段为秒
+func WithSeconds() Option {
+	return Parser(cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor))
+}
+
+func Location(loc *time.Location) Option {
+	return func(o *Options) {
+		o.Location = loc
+	}
+}
+
+func CatchUp(enable bool) Option {
+	return func(o *Options) {
+		o.CatchUp = enable
+	}
+}
+
+func History(n int) Option {
+	return func(o *Options) {
+		o.History = n
+	}
+}
@@ -0,0 +1,133 @@
+package dcron
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeckbjy/gsk/selector/chash"
+	"github.com/jeckbjy/gsk/store"
+)
+
+// shard 成员通过租约注册到Root/Group/members/ID,宕机后租约过期自动删除
+// 所有实例监听成员变化,使用相同的一致性hash计算任务归属
+type shard struct {
+	c       *Cron
+	mux     sync.RWMutex
+	members []string
+	hash    chash.Hash
+}
+
+func newShard(c *Cron) *shard {
+	return &shard{c: c, hash: chash.NewRing(0)}
+}
+
+func (s *shard) key() string {
+	return s.c.prefix() + "/members/"
+}
+
+func (s *shard) owner(name string) string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	if s.hash.Len() == 0 {
+		return ""
+	}
+
+	return s.hash.Get(hashName(name))
+}
+
+func (s *shard) run(ctx context.Context) {
+	c := s.c
+	defer c.wg.Done()
+
+	st := c.store
+	txn := st.(store.Transactional)
+	key := s.key() + c.opts.ID
+
+	changed := make(chan struct{}, 1)
+	_ = st.Watch(ctx, s.key(), func(ev *store.Event) {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}, store.Prefix())
+
+	ticker := time.NewTicker(c.opts.TTL / 3)
+	defer ticker.Stop()
+
+	var lease store.LeaseID
+	for {
+		if lease != 0 {
+			if err := txn.KeepAlive(ctx, lease); err == store.ErrLeaseNotFound {
+				lease = 0
+			}
+		}
+
+		if lease == 0 {
+			id, err := store.PutWithTTL(ctx, st, key, []byte(c.opts.ID), c.opts.TTL)
+			if err != nil {
+				log.Printf("[dcron] join fail, %+v", err)
+			} else {
+				lease = id
+			}
+		}
+
+		if s.refresh(ctx) {
+			c.catchUp()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-changed:
+		case <-ctx.Done():
+			if lease != 0 {
+				_ = txn.Revoke(context.Background(), lease)
+			}
+			return
+		}
+	}
+}
+
+// refresh 重新加载成员列表,返回是否变化
+func (s *shard) refresh(ctx context.Context) bool {
+	kvs, err := s.c.store.List(ctx, s.key())
+	if err != nil && err != store.ErrNotFound {
+		return false
+	}
+
+	members := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		members = append(members, string(kv.Value))
+	}
+	sort.Strings(members)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if equal(members, s.members) {
+		return false
+	}
+
+	h := chash.NewRing(0)
+	for _, m := range members {
+		h.Add(m, 0)
+	}
+	s.members = members
+	s.hash = h
+	return true
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,101 @@
+package lease
+
+import (
+	"context"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+)
+
+// Key 使用租约独占store中的一个key,sync/lock/kv和sync/leader/kv共用
+// 获取: 创建租约,使用事务判断key不存在时写入Value并绑定租约,事务的revision作为fencing token
+// 持有: KeepAlive定时续约,进程异常退出时租约过期后key被自动删除,其他等待者可以重新获取
+type Key struct {
+	Store        store.Store
+	Txn          store.Transactional
+	Key          string
+	Value        []byte
+	TTL          time.Duration
+	PollInterval time.Duration // 等待时的轮询间隔,Watch不可用或者丢失事件时保证能够继续获取
+}
+
+// TryAcquire 尝试获取一次,失败时撤销租约
+func (k *Key) TryAcquire(ctx context.Context) (store.LeaseID, int64, bool, error) {
+	lease, err := k.Txn.Grant(ctx, k.TTL)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	rsp, err := k.Txn.Txn(ctx, &store.Txn{
+		If:   []store.Compare{store.CmpExists(k.Key, false)},
+		Then: []store.Op{store.OpPutWithLease(k.Key, k.Value, lease)},
+	})
+	if err != nil || !rsp.Succeeded {
+		_ = k.Txn.Revoke(ctx, lease)
+		return 0, 0, false, err
+	}
+
+	return lease, rsp.Revision, true, nil
+}
+
+// Acquire 阻塞直到获取成功或者ctx结束,等待期间Watch key的删除事件,同时定时轮询
+func (k *Key) Acquire(ctx context.Context) (store.LeaseID, int64, error) {
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 先Watch再尝试,避免错过两者之间的删除事件,Watch失败时依赖轮询
+	released := make(chan struct{}, 1)
+	_ = k.Store.Watch(wctx, k.Key, func(ev *store.Event) {
+		if ev.Type == store.DELETE {
+			select {
+			case released <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	poll := time.NewTicker(k.PollInterval)
+	defer poll.Stop()
+
+	for {
+		lease, rev, ok, err := k.TryAcquire(ctx)
+		if err != nil || ok {
+			return lease, rev, err
+		}
+
+		select {
+		case <-released:
+		case <-poll.C:
+		case <-ctx.Done():
+			return 0, 0, ctx.Err()
+		}
+	}
+}
+
+// KeepAlive 每TTL/3续约一次,阻塞直到ctx结束并返回nil
+// 租约不存在或者超过TTL没有续约成功时认为已经丢失,返回最后一次续约的错误
+func (k *Key) KeepAlive(ctx context.Context, lease store.LeaseID) error {
+	ticker := time.NewTicker(k.TTL / 3)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+			err := k.Txn.KeepAlive(ctx, lease)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err == nil {
+				last = time.Now()
+				continue
+			}
+
+			if err == store.ErrLeaseNotFound || time.Since(last) >= k.TTL {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
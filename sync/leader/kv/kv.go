@@ -0,0 +1,241 @@
+package kv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/sync/internal/lease"
+	"github.com/jeckbjy/gsk/sync/leader"
+)
+
+const (
+	DefaultRoot = "/leader"
+	// 等待leader释放时的轮询间隔,Watch不可用或者丢失事件时保证能够继续选举
+	DefaultPollInterval = time.Second
+)
+
+// New 基于store.Store实现的leader选举,store需要实现store.Transactional
+// leader的id写入Root/Group,并绑定租约,后台定时续约,宕机后租约过期自动删除,其他候选者重新选举
+// 续约失败时通过Revoked通知,此时需要立即停止leader的工作,可以调用Reelect重新参与选举
+func New(s store.Store, opts ...leader.Option) leader.Leader {
+	o := leader.NewOptions(opts...)
+	return &kvLeader{opts: o, store: s, key: DefaultRoot + "/" + o.Group}
+}
+
+type kvLeader struct {
+	opts  *leader.Options
+	store store.Store
+	key   string
+}
+
+func (l *kvLeader) Elect(id string, opts ...leader.ElectOption) (leader.Elected, error) {
+	txn, ok := l.store.(store.Transactional)
+	if !ok {
+		return nil, store.ErrNotSupport
+	}
+
+	o := leader.NewElectOptions(opts...)
+	e := &kvElected{
+		owner: l,
+		id:    id,
+		key: &lease.Key{
+			Store:        l.store,
+			Txn:          txn,
+			Key:          l.key,
+			Value:        []byte(id),
+			TTL:          o.TTL,
+			PollInterval: DefaultPollInterval,
+		},
+		revoked: make(chan bool, 1),
+	}
+
+	if err := e.campaign(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Follow 通过Watch监听leader变化,Watch失败时轮询,Options.Context结束后关闭channel
+func (l *kvLeader) Follow() chan string {
+	ch := make(chan string, 1)
+	ctx := l.opts.Context
+
+	// Watch回调可能在Context结束后执行,因此不直接写入ch,由下面的goroutine负责发送和关闭
+	events := make(chan string, 1)
+	var poll *time.Ticker
+	if err := l.store.Watch(ctx, l.key, func(ev *store.Event) {
+		if ev.Type == store.PUT {
+			publish(events, string(ev.Data.Value))
+		}
+	}); err != nil {
+		poll = time.NewTicker(DefaultPollInterval)
+	}
+
+	get := func() {
+		if kv, err := l.store.Get(ctx, l.key); err == nil && kv != nil {
+			publish(events, string(kv.Value))
+		}
+	}
+	get()
+
+	go func() {
+		defer close(ch)
+		var tick <-chan time.Time
+		if poll != nil {
+			defer poll.Stop()
+			tick = poll.C
+		}
+
+		last := ""
+		for {
+			select {
+			case id := <-events:
+				if id != last {
+					last = id
+					publish(ch, id)
+				}
+			case <-tick:
+				get()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+type kvElected struct {
+	owner   *kvLeader
+	id      string
+	key     *lease.Key
+	revoked chan bool
+	cmux    sync.Mutex // 串行化campaign,等待期间不持有mux,保证Resign不会被阻塞
+	mux     sync.Mutex
+	lease   store.LeaseID // 0表示不是leader
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func (e *kvElected) Id() string {
+	return e.id
+}
+
+func (e *kvElected) Revoked() chan bool {
+	return e.revoked
+}
+
+func (e *kvElected) Reelect() error {
+	return e.campaign()
+}
+
+func (e *kvElected) Resign() error {
+	e.mux.Lock()
+	id, cancel := e.lease, e.cancel
+	e.lease = 0
+	e.cancel = nil
+	e.mux.Unlock()
+
+	if cancel != nil {
+		cancel()
+		e.wg.Wait()
+	}
+
+	if id == 0 {
+		return nil
+	}
+
+	err := e.key.Txn.Revoke(context.Background(), id)
+	if err == store.ErrLeaseNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// campaign 阻塞直到成为leader
+func (e *kvElected) campaign() error {
+	e.cmux.Lock()
+	defer e.cmux.Unlock()
+
+	e.mux.Lock()
+	id := e.lease
+	e.mux.Unlock()
+
+	if id != 0 {
+		// 确认租约依然有效
+		if err := e.key.Txn.KeepAlive(e.owner.opts.Context, id); err != store.ErrLeaseNotFound {
+			return err
+		}
+
+		e.mux.Lock()
+		if e.lease == id {
+			if e.cancel != nil {
+				e.cancel()
+			}
+			e.lease = 0
+			e.cancel = nil
+		}
+		e.mux.Unlock()
+	}
+
+	id, _, err := e.key.Acquire(e.owner.opts.Context)
+	if err != nil {
+		return err
+	}
+
+	// 清除之前的通知,避免重新当选后读到旧的Revoked
+	select {
+	case <-e.revoked:
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.mux.Lock()
+	e.lease = id
+	e.cancel = cancel
+	e.wg.Add(1)
+	e.mux.Unlock()
+
+	go e.keepAlive(ctx, id)
+	return nil
+}
+
+// keepAlive 后台续约,失去leadership时通过Revoked通知
+func (e *kvElected) keepAlive(ctx context.Context, id store.LeaseID) {
+	defer e.wg.Done()
+	if err := e.key.KeepAlive(ctx, id); err == nil {
+		return
+	}
+
+	e.mux.Lock()
+	lost := e.lease == id
+	if lost {
+		e.lease = 0
+	}
+	e.mux.Unlock()
+	if lost {
+		select {
+		case e.revoked <- true:
+		default:
+		}
+	}
+}
+
+// publish 发送最新的leader,channel满时丢弃旧的数据
+func publish(ch chan string, id string) {
+	for {
+		select {
+		case ch <- id:
+			return
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+		}
+	}
+}
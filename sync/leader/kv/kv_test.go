@@ -0,0 +1,218 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/store/memory"
+	"github.com/jeckbjy/gsk/sync/leader"
+)
+
+func TestElect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := memory.New()
+	defer s.Close()
+
+	// 每个候选者使用独立的Leader,模拟多个进程
+	const n = 3
+
+	follow := New(s, leader.Group("job"), leader.Context(ctx)).Follow()
+
+	var mux sync.Mutex
+	var leaders []leader.Elected
+	elected := make(chan leader.Elected, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			l := New(s, leader.Group("job"), leader.Context(ctx))
+			e, err := l.Elect(fmt.Sprintf("node-%d", i), leader.TTL(time.Millisecond*300))
+			if err != nil {
+				return
+			}
+			mux.Lock()
+			leaders = append(leaders, e)
+			mux.Unlock()
+			elected <- e
+		}(i)
+	}
+
+	first := <-elected
+	select {
+	case id := <-follow:
+		if id != first.Id() {
+			t.Fatalf("bad follow, %s != %s", id, first.Id())
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("follow timeout")
+	}
+
+	// 续约期间只有一个leader
+	time.Sleep(time.Millisecond * 500)
+	mux.Lock()
+	if len(leaders) != 1 {
+		t.Fatalf("more than one leader, %d", len(leaders))
+	}
+	mux.Unlock()
+
+	if err := first.Reelect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 主动放弃,其他候选者立即当选
+	if err := first.Resign(); err != nil {
+		t.Fatal(err)
+	}
+
+	var second leader.Elected
+	select {
+	case second = <-elected:
+	case <-time.After(time.Second * 2):
+		t.Fatal("reelect timeout")
+	}
+
+	select {
+	case id := <-follow:
+		if id != second.Id() {
+			t.Fatalf("bad follow, %s != %s", id, second.Id())
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("follow timeout")
+	}
+
+	// 模拟卡死,不再续约,租约过期后第三个候选者当选
+	second.(*kvElected).cancel()
+	select {
+	case <-elected:
+	case <-time.After(time.Second * 2):
+		t.Fatal("elect after expire timeout")
+	}
+
+	// 租约已经过期,Reelect需要重新参与选举,其他节点是leader时阻塞
+	reelected := make(chan error, 1)
+	go func() { reelected <- second.Reelect() }()
+	select {
+	case err := <-reelected:
+		t.Fatalf("reelect should block while other is leader, %v", err)
+	case <-time.After(time.Millisecond * 200):
+	}
+	cancel()
+	<-reelected
+
+	// Context结束后关闭Follow的channel
+	select {
+	case _, ok := <-follow:
+		if ok {
+			if _, ok = <-follow; ok {
+				t.Fatal("follow not closed")
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("follow not closed")
+	}
+}
+
+// noWatch Watch失败的store
+type noWatch struct {
+	store.Store
+}
+
+func (noWatch) Watch(ctx context.Context, key string, cb store.Callback, opts ...store.Option) error {
+	return store.ErrNotSupport
+}
+
+// TestFollowPoll Watch失败时通过轮询获取leader
+func TestFollowPoll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := memory.New()
+	defer s.Close()
+
+	follow := New(noWatch{s}, leader.Group("job"), leader.Context(ctx)).Follow()
+	e, err := New(s, leader.Group("job")).Elect("node", leader.TTL(time.Millisecond*300))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Resign()
+
+	select {
+	case id := <-follow:
+		if id != "node" {
+			t.Fatalf("bad follow, %s", id)
+		}
+	case <-time.After(DefaultPollInterval * 2):
+		t.Fatal("follow timeout")
+	}
+}
+
+func TestRevoked(t *testing.T) {
+	s := memory.New()
+	defer s.Close()
+
+	e, err := New(s, leader.Group("job")).Elect("node", leader.TTL(time.Millisecond*300))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 租约被撤销,续约失败后通知
+	_ = s.(store.Transactional).Revoke(context.Background(), e.(*kvElected).lease)
+	select {
+	case <-e.Revoked():
+	case <-time.After(time.Second):
+		t.Fatal("revoked not notified")
+	}
+
+	if err := e.Reelect(); err != nil {
+		t.Fatal(err)
+	}
+	_ = e.Resign()
+}
+
+// TestResignWhileCampaign 重新选举等待期间不能阻塞Resign
+func TestResignWhileCampaign(t *testing.T) {
+	s := memory.New()
+	defer s.Close()
+
+	l := New(s, leader.Group("job"))
+	a, err := l.Elect("a", leader.TTL(time.Millisecond*300))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = s.(store.Transactional).Revoke(context.Background(), a.(*kvElected).lease)
+	<-a.Revoked()
+	b, err := l.Elect("b", leader.TTL(time.Millisecond*300))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reelected := make(chan error, 1)
+	go func() {
+		reelected <- a.Reelect()
+	}()
+
+	time.Sleep(time.Millisecond * 50)
+	resigned := make(chan error, 1)
+	go func() {
+		resigned <- a.Resign()
+	}()
+	select {
+	case err := <-resigned:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Millisecond * 500):
+		t.Fatal("resign blocked by campaign")
+	}
+
+	_ = b.Resign()
+	if err := <-reelected; err != nil {
+		t.Fatal(err)
+	}
+	_ = a.Resign()
+}
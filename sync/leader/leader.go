@@ -1,21 +1,26 @@
 package leader
 
 // Leader provides leadership election
+// 同一个Group中同时最多只有一个leader,Leader的实现有:
+// NewLocal: 进程内实现,用于单元测试
+// sync/leader/kv: 基于store.Store,使用租约保证leader宕机后能够重新选举
 type Leader interface {
-	// elect leader
+	// elect leader, 阻塞直到成为leader或者Options.Context结束
 	Elect(id string, opts ...ElectOption) (Elected, error)
-	// follow the leader
+	// follow the leader, 每次leader变化时发送新leader的id,订阅时如果已经有leader会立即发送
+	// Options.Context结束后停止并关闭channel
 	Follow() chan string
 }
 
+// Elected 选举成功后返回
 type Elected interface {
 	// id of leader
 	Id() string
-	// seek re-election
+	// seek re-election, 仍然是leader时直接返回,否则阻塞直到重新成为leader
 	Reelect() error
-	// resign leadership
+	// resign leadership, 主动放弃,其他候选者可以立即成为leader
 	Resign() error
-	// observe leadership revocation
+	// observe leadership revocation, 因为租约过期等原因被动失去leadership时发送true
 	Revoked() chan bool
 }
 
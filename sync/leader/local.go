@@ -0,0 +1,150 @@
+package leader
+
+import (
+	"sync"
+)
+
+var gLocal = struct {
+	mux    sync.Mutex
+	groups map[string]*localGroup
+}{groups: make(map[string]*localGroup)}
+
+// NewLocal 进程内实现,Group相同的Leader共享选举状态,用于单元测试
+// 没有租约,leader只能通过Resign主动放弃,因此Revoked永远不会触发
+func NewLocal(opts ...Option) Leader {
+	o := NewOptions(opts...)
+
+	gLocal.mux.Lock()
+	g := gLocal.groups[o.Group]
+	if g == nil {
+		g = &localGroup{vacant: make(chan struct{})}
+		gLocal.groups[o.Group] = g
+	}
+	gLocal.mux.Unlock()
+
+	return &localLeader{opts: o, group: g}
+}
+
+type localGroup struct {
+	mux       sync.Mutex
+	leader    *localElected
+	vacant    chan struct{} // leader放弃时关闭,用于唤醒等待者
+	followers []chan string
+}
+
+type localLeader struct {
+	opts  *Options
+	group *localGroup
+}
+
+func (l *localLeader) Elect(id string, opts ...ElectOption) (Elected, error) {
+	e := &localElected{owner: l, id: id, revoked: make(chan bool, 1)}
+	if err := e.campaign(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (l *localLeader) Follow() chan string {
+	ch := make(chan string, 1)
+	g := l.group
+	g.mux.Lock()
+	g.followers = append(g.followers, ch)
+	if g.leader != nil {
+		publish(ch, g.leader.id)
+	}
+	g.mux.Unlock()
+
+	// Context结束后取消订阅并关闭channel,publish在g.mux内执行,因此不会写入已关闭的channel
+	if done := l.opts.Context.Done(); done != nil {
+		go func() {
+			<-done
+			g.mux.Lock()
+			for i, c := range g.followers {
+				if c == ch {
+					g.followers = append(g.followers[:i], g.followers[i+1:]...)
+					break
+				}
+			}
+			close(ch)
+			g.mux.Unlock()
+		}()
+	}
+
+	return ch
+}
+
+type localElected struct {
+	owner   *localLeader
+	id      string
+	revoked chan bool
+}
+
+func (e *localElected) campaign() error {
+	g := e.owner.group
+	for {
+		g.mux.Lock()
+		if g.leader == e {
+			g.mux.Unlock()
+			return nil
+		}
+
+		if g.leader == nil {
+			g.leader = e
+			for _, ch := range g.followers {
+				publish(ch, e.id)
+			}
+			g.mux.Unlock()
+			return nil
+		}
+
+		vacant := g.vacant
+		g.mux.Unlock()
+
+		select {
+		case <-vacant:
+		case <-e.owner.opts.Context.Done():
+			return e.owner.opts.Context.Err()
+		}
+	}
+}
+
+func (e *localElected) Id() string {
+	return e.id
+}
+
+func (e *localElected) Reelect() error {
+	return e.campaign()
+}
+
+func (e *localElected) Resign() error {
+	g := e.owner.group
+	g.mux.Lock()
+	if g.leader == e {
+		g.leader = nil
+		close(g.vacant)
+		g.vacant = make(chan struct{})
+	}
+	g.mux.Unlock()
+	return nil
+}
+
+func (e *localElected) Revoked() chan bool {
+	return e.revoked
+}
+
+// publish 发送最新的leader,Follow的channel满时丢弃旧的数据,保证读取到的总是最新的leader
+func publish(ch chan string, id string) {
+	for {
+		select {
+		case ch <- id:
+			return
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+		}
+	}
+}
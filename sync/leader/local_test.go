@@ -0,0 +1,69 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLocal(t *testing.T) {
+	l1 := NewLocal(Group("test"))
+	l2 := NewLocal(Group("test"))
+
+	follow := l1.Follow()
+	e1, err := l1.Elect("node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := <-follow; id != "node-1" {
+		t.Fatalf("bad leader, %s", id)
+	}
+
+	elected := make(chan Elected, 1)
+	go func() {
+		e2, err := l2.Elect("node-2")
+		if err == nil {
+			elected <- e2
+		}
+	}()
+
+	select {
+	case <-elected:
+		t.Fatal("two leaders")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	_ = e1.Resign()
+	select {
+	case e2 := <-elected:
+		// gLocal是全局的,结束时需要释放,否则-count=2时会永久阻塞
+		defer e2.Resign()
+		if id := <-follow; id != e2.Id() {
+			t.Fatalf("bad follow, %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("elect timeout")
+	}
+
+	// 其他节点是leader时阻塞,直到Context结束
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if _, err := NewLocal(Group("test"), Context(ctx)).Elect("node-3"); err != context.DeadlineExceeded {
+		t.Fatalf("should timeout, %v", err)
+	}
+}
+
+func TestFollowClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	follow := NewLocal(Group("close"), Context(ctx)).Follow()
+	cancel()
+
+	select {
+	case _, ok := <-follow:
+		if ok {
+			t.Fatal("should not receive leader")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("follow not closed")
+	}
+}
@@ -1,11 +1,20 @@
 package leader
 
+import (
+	"context"
+	"time"
+)
+
+const DefaultTTL = time.Second * 10
+
 type Options struct {
-	Nodes []string
-	Group string
+	Nodes   []string
+	Group   string
+	Context context.Context // 用于停止Elect等待以及Follow
 }
 
 type ElectOptions struct {
+	TTL time.Duration // 租约时间,leader宕机后最长TTL时间后重新选举,<=0则使用默认值
 }
 
 // Nodes sets the addresses of the underlying systems
@@ -21,3 +30,41 @@ func Group(g string) Option {
 		o.Group = g
 	}
 }
+
+// Context sets the context, Elect and Follow stop when it is done
+func Context(ctx context.Context) Option {
+	return func(o *Options) {
+		o.Context = ctx
+	}
+}
+
+// TTL sets the lease ttl of the leadership
+func TTL(ttl time.Duration) ElectOption {
+	return func(o *ElectOptions) {
+		o.TTL = ttl
+	}
+}
+
+// NewOptions 创建Options并设置默认值
+func NewOptions(opts ...Option) *Options {
+	o := &Options{Context: context.Background()}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return o
+}
+
+// NewElectOptions 创建ElectOptions并设置默认值
+func NewElectOptions(opts ...ElectOption) *ElectOptions {
+	o := &ElectOptions{}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.TTL <= 0 {
+		o.TTL = DefaultTTL
+	}
+
+	return o
+}
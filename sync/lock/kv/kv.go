@@ -0,0 +1,220 @@
+package kv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/sync/internal/lease"
+	"github.com/jeckbjy/gsk/sync/lock"
+	"github.com/jeckbjy/gsk/util/idgen/xid"
+)
+
+const (
+	DefaultRoot = "/locks"
+	// 等待锁释放时的轮询间隔,Watch不可用或者丢失事件时保证能够继续获取锁
+	DefaultPollInterval = time.Second
+)
+
+type Options struct {
+	Root         string        // 锁的key前缀
+	PollInterval time.Duration // 等待时的轮询间隔
+}
+
+type Option func(o *Options)
+
+func Root(root string) Option {
+	return func(o *Options) {
+		o.Root = root
+	}
+}
+
+func PollInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.PollInterval = d
+	}
+}
+
+// New 基于store.Store实现的分布式锁,store需要实现store.Transactional
+// 获取锁: 创建租约,使用事务判断key不存在时写入并绑定租约,事务的revision作为fencing token
+// 持有锁: 后台定时续约,续约失败或者租约过期时关闭Done,Err返回lock.ErrLockLost
+// 释放锁: Unlock时撤销租约,key被自动删除,进程异常退出时租约过期后自动释放
+// 等待锁: Watch锁的删除事件,同时定时轮询
+func New(s store.Store, opts ...Option) lock.Locking {
+	o := &Options{Root: DefaultRoot, PollInterval: DefaultPollInterval}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	return &kvLocking{opts: o, store: s}
+}
+
+type kvLocking struct {
+	opts  *Options
+	store store.Store
+}
+
+func (l *kvLocking) Name() string {
+	return "kv"
+}
+
+func (l *kvLocking) Acquire(key string, opts *lock.Options) (lock.Locker, error) {
+	txn, ok := l.store.(store.Transactional)
+	if !ok {
+		return nil, lock.ErrNotSupport
+	}
+
+	// 复制一份,TTL<=0时使用默认值,避免ticker的间隔为0
+	o := lock.Options{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Build()
+
+	locker := &kvLocker{
+		opts: &o,
+		key: &lease.Key{
+			Store:        l.store,
+			Txn:          txn,
+			Key:          l.opts.Root + "/" + key,
+			Value:        []byte(xid.New().String()), // 持有者标识,便于排查
+			TTL:          o.TTL,
+			PollInterval: l.opts.PollInterval,
+		},
+		done: make(chan struct{}),
+	}
+	close(locker.done)
+	return locker, nil
+}
+
+type kvLocker struct {
+	opts   *lock.Options
+	key    *lease.Key
+	lmux   sync.Mutex // 串行化Lock,等待期间不持有mux,保证Token,Done,Unlock不会被阻塞
+	mux    sync.Mutex
+	held   bool
+	err    error // 锁丢失的原因
+	lease  store.LeaseID
+	token  int64
+	done   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (l *kvLocker) Token() int64 {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.token
+}
+
+func (l *kvLocker) Done() <-chan struct{} {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.done
+}
+
+func (l *kvLocker) Err() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.err
+}
+
+func (l *kvLocker) Lock() error {
+	l.lmux.Lock()
+	defer l.lmux.Unlock()
+
+	l.mux.Lock()
+	held := l.held
+	l.mux.Unlock()
+	if held {
+		return nil
+	}
+
+	if l.opts.Timeout == 0 {
+		id, rev, ok, err := l.key.TryAcquire(context.Background())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return lock.ErrNotLock
+		}
+		l.hold(id, rev)
+		return nil
+	}
+
+	ctx := context.Background()
+	if l.opts.Timeout != lock.TimeoutMax {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.opts.Timeout)
+		defer cancel()
+	}
+
+	id, rev, err := l.key.Acquire(ctx)
+	if err == context.DeadlineExceeded {
+		return lock.ErrNotLock
+	}
+	if err != nil {
+		return err
+	}
+
+	l.hold(id, rev)
+	return nil
+}
+
+// hold 获取锁成功后记录状态,并在后台续约,续约失败时关闭Done
+func (l *kvLocker) hold(id store.LeaseID, token int64) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	l.mux.Lock()
+	l.held = true
+	l.err = nil
+	l.lease = id
+	l.token = token
+	l.done = done
+	l.cancel = cancel
+	l.wg.Add(1)
+	l.mux.Unlock()
+
+	go func() {
+		defer l.wg.Done()
+		if err := l.key.KeepAlive(ctx, id); err == nil {
+			return
+		}
+
+		// Unlock可能同时在执行,只有修改状态的一方关闭done
+		l.mux.Lock()
+		lost := l.lease == id
+		if lost {
+			l.held = false
+			l.lease = 0
+			l.err = lock.ErrLockLost
+		}
+		l.mux.Unlock()
+		if lost {
+			close(done)
+		}
+	}()
+}
+
+func (l *kvLocker) Unlock() {
+	l.mux.Lock()
+	cancel, lease, held, done := l.cancel, l.lease, l.held, l.done
+	l.cancel = nil
+	l.lease = 0
+	l.held = false
+	l.mux.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	l.wg.Wait()
+
+	if held {
+		_ = l.key.Txn.Revoke(context.Background(), lease)
+		close(done)
+	}
+}
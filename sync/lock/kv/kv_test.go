@@ -0,0 +1,151 @@
+package kv
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/store/memory"
+	"github.com/jeckbjy/gsk/sync/lock"
+)
+
+func acquire(t *testing.T, l lock.Locking, key string, opts ...lock.Option) lock.Locker {
+	o := &lock.Options{}
+	o.Build(opts...)
+	locker, err := l.Acquire(key, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return locker
+}
+
+func TestLock(t *testing.T) {
+	s := memory.New()
+	defer s.Close()
+
+	// 两个Locking模拟两个进程
+	l1 := New(s, PollInterval(time.Millisecond*50))
+	l2 := New(s, PollInterval(time.Millisecond*50))
+
+	a := acquire(t, l1, "job", lock.TTL(time.Millisecond*300))
+	if err := a.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	token1 := a.(lock.Fenced).Token()
+
+	// 不等待
+	b := acquire(t, l2, "job", lock.TTL(time.Millisecond*300))
+	if err := b.Lock(); err != lock.ErrNotLock {
+		t.Fatalf("should not lock, %+v", err)
+	}
+
+	// 持有期间自动续约,不会过期
+	time.Sleep(time.Millisecond * 500)
+	select {
+	case <-a.(lock.Fenced).Done():
+		t.Fatal("lock lost while held")
+	default:
+	}
+
+	// 等待释放
+	b = acquire(t, l2, "job", lock.TTL(time.Millisecond*300), lock.Blocking())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	locked := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		if err := b.Lock(); err != nil {
+			t.Error(err)
+		}
+		close(locked)
+	}()
+
+	// 等待期间不能阻塞Token和Done
+	time.Sleep(time.Millisecond * 50)
+	if b.(lock.Fenced).Token() != 0 || a.(lock.Fenced).Err() != nil {
+		t.Fatal("bad state while waiting")
+	}
+	a.Unlock()
+	select {
+	case <-a.(lock.Fenced).Done():
+	default:
+		t.Fatal("done not closed after unlock")
+	}
+
+	select {
+	case <-locked:
+	case <-time.After(time.Second * 2):
+		t.Fatal("wait lock timeout")
+	}
+	wg.Wait()
+
+	token2 := b.(lock.Fenced).Token()
+	if token2 <= token1 {
+		t.Fatalf("token not increase, %d, %d", token1, token2)
+	}
+
+	fence := &lock.Fence{}
+	if !fence.Check(token2) || fence.Check(token1) {
+		t.Fatal("bad fence")
+	}
+
+	// 模拟进程卡死,不再续约,锁过期后被其他人获取
+	b.(*kvLocker).cancel()
+	c := acquire(t, l1, "job", lock.Timeout(time.Second*2))
+	if err := c.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Unlock()
+
+	if token3 := c.(lock.Fenced).Token(); token3 <= token2 || !fence.Check(token3) || fence.Check(token2) {
+		t.Fatalf("bad token after expire, %d", token3)
+	}
+
+	b.Unlock()
+}
+
+func TestDefaultTTL(t *testing.T) {
+	s := memory.New()
+	defer s.Close()
+
+	// 未调用Build的Options,TTL为0时使用默认值
+	l := New(s)
+	for _, o := range []*lock.Options{nil, {}} {
+		locker, err := l.Acquire("ttl", o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := locker.Lock(); err != nil {
+			t.Fatal(err)
+		}
+		locker.Unlock()
+	}
+}
+
+func TestLost(t *testing.T) {
+	s := memory.New()
+	defer s.Close()
+
+	l := New(s)
+	a := acquire(t, l, "job", lock.TTL(time.Millisecond*300))
+	if err := a.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 租约被外部撤销(比如运维手动清理),续约失败后通知持有者
+	lease := a.(*kvLocker).lease
+	_ = s.(store.Transactional).Revoke(context.Background(), lease)
+	select {
+	case <-a.(lock.Fenced).Done():
+	case <-time.After(time.Second):
+		t.Fatal("lost not notified")
+	}
+	if err := a.(lock.Fenced).Err(); err != lock.ErrLockLost {
+		t.Fatalf("expect lock lost, %v", err)
+	}
+
+	a.Unlock()
+}
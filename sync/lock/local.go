@@ -2,20 +2,34 @@ package lock
 
 import (
 	"sync"
-	"sync/atomic"
 	"time"
-	"unsafe"
 )
 
 func newLocal() Locking {
-	return &localLocking{locks: make(map[string]*sync.Mutex)}
+	return &localLocking{entries: make(map[string]*localEntry)}
 }
 
 // 本地调试使用
 // 不能用于分布式环境,不关心ttl过期,需要外边主动Unlock,才能被释放
+// 支持互斥锁,读写锁,信号量以及可重入锁,同一个key的互斥锁和读写锁中的写锁是互斥的
 type localLocking struct {
-	mux   sync.Mutex
-	locks map[string]*sync.Mutex
+	mux     sync.Mutex
+	entries map[string]*localEntry
+}
+
+// localEntry 每个key的状态,由localLocking.mux保护
+type localEntry struct {
+	writer   interface{}   // 写锁持有者
+	writes   int           // 写锁重入次数
+	readers  int           // 读者数量
+	waiting  int           // 正在等待的写者数量
+	permits  int           // 信号量已经占用的名额
+	limit    int           // 信号量名额
+	released chan struct{} // 有释放时关闭,用于唤醒等待者
+}
+
+func (e *localEntry) idle() bool {
+	return e.writer == nil && e.readers == 0 && e.waiting == 0 && e.permits == 0
 }
 
 func (l *localLocking) Name() string {
@@ -23,8 +37,102 @@ func (l *localLocking) Name() string {
 }
 
 func (l *localLocking) Acquire(key string, opts *Options) (Locker, error) {
-	locker := &localLocker{owner: l, key: key, opts: opts}
-	return locker, nil
+	return &localLocker{owner: l, key: key, opts: fixOptions(opts)}, nil
+}
+
+func (l *localLocking) AcquireRW(key string, opts *Options) (RWLocker, error) {
+	return &localLocker{owner: l, key: key, opts: fixOptions(opts)}, nil
+}
+
+func (l *localLocking) AcquireSemaphore(key string, n int, opts *Options) (Semaphore, error) {
+	if n <= 0 {
+		n = 1
+	}
+
+	// 与锁使用不同的命名空间
+	return &localSemaphore{owner: l, key: "semaphore:" + key, n: n, opts: fixOptions(opts)}, nil
+}
+
+func fixOptions(opts *Options) *Options {
+	if opts == nil {
+		opts = &Options{}
+		opts.Build()
+	}
+
+	return opts
+}
+
+// wait 循环调用try直到成功,等待时间由timeout决定,onWait用于记录等待中的写者
+func (l *localLocking) wait(key string, timeout time.Duration, try func(e *localEntry) bool, onWait func(e *localEntry, waiting bool)) error {
+	var deadline <-chan time.Time
+	waiting := false
+	defer func() {
+		if waiting {
+			l.mux.Lock()
+			onWait(l.entries[key], false)
+			l.release(key, l.entries[key])
+			l.mux.Unlock()
+		}
+	}()
+
+	for {
+		l.mux.Lock()
+		e := l.entries[key]
+		if e == nil {
+			e = &localEntry{released: make(chan struct{})}
+			l.entries[key] = e
+		}
+
+		if try(e) {
+			if waiting {
+				onWait(e, false)
+				waiting = false
+			}
+			l.mux.Unlock()
+			return nil
+		}
+
+		if timeout == 0 {
+			if e.idle() {
+				delete(l.entries, key)
+			}
+			l.mux.Unlock()
+			return ErrNotLock
+		}
+
+		if !waiting && onWait != nil {
+			onWait(e, true)
+			waiting = true
+		}
+
+		released := e.released
+		l.mux.Unlock()
+
+		if deadline == nil && timeout != TimeoutMax {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
+		select {
+		case <-released:
+		case <-deadline:
+			return ErrNotLock
+		}
+	}
+}
+
+// release 唤醒所有等待者,没有使用者时删除
+func (l *localLocking) release(key string, e *localEntry) {
+	if e == nil {
+		return
+	}
+
+	close(e.released)
+	e.released = make(chan struct{})
+	if e.idle() {
+		delete(l.entries, key)
+	}
 }
 
 type localLocker struct {
@@ -33,63 +141,142 @@ type localLocker struct {
 	opts  *Options
 }
 
-func (l *localLocker) Lock() error {
-	mux, locked := l.tryLock()
-	if locked {
-		return nil
-	}
-
-	timeout := time.Duration(0)
-	if l.opts != nil {
-		timeout = l.opts.Timeout
-	}
-
-	switch timeout {
-	case 0:
-		return ErrNotLock
-	case TimeoutMax:
-		mux.Lock()
-		return nil
-	default:
-		// 轮询检测是否获得到锁,直到过期
-		expired := time.Now().Add(timeout)
-		for {
-			time.Sleep(time.Millisecond)
-			if _, locked := l.tryLock(); locked {
-				return nil
-			}
-			if time.Now().After(expired) {
-				return ErrNotLock
-			}
-		}
+// holder 持有者,设置了Owner时可重入
+func (l *localLocker) holder() interface{} {
+	if l.opts.Owner != "" {
+		return l.opts.Owner
 	}
+
+	return l
+}
+
+func (l *localLocker) tryWrite(e *localEntry) bool {
+	if e.writer == nil && e.readers == 0 {
+		e.writer = l.holder()
+		e.writes = 1
+		return true
+	}
+
+	if l.opts.Owner != "" && e.writer == l.holder() {
+		e.writes++
+		return true
+	}
+
+	return false
+}
+
+func (l *localLocker) tryRead(e *localEntry) bool {
+	if e.writer == nil && e.waiting == 0 {
+		e.readers++
+		return true
+	}
+
+	return false
+}
+
+func onWaitWrite(e *localEntry, waiting bool) {
+	if waiting {
+		e.waiting++
+	} else {
+		e.waiting--
+	}
+}
+
+func (l *localLocker) Lock() error {
+	return l.owner.wait(l.key, l.opts.Timeout, l.tryWrite, onWaitWrite)
+}
+
+func (l *localLocker) TryLock() bool {
+	return l.owner.wait(l.key, 0, l.tryWrite, nil) == nil
 }
 
 func (l *localLocker) Unlock() {
 	p := l.owner
 	p.mux.Lock()
-	if mux, ok := p.locks[l.key]; ok {
-		mux.Unlock()
-		delete(p.locks, l.key)
+	if e := p.entries[l.key]; e != nil && e.writer == l.holder() {
+		e.writes--
+		if e.writes <= 0 {
+			e.writer = nil
+			e.writes = 0
+			p.release(l.key, e)
+		}
 	}
 	p.mux.Unlock()
 }
 
-func (l *localLocker) tryLock() (*sync.Mutex, bool) {
+func (l *localLocker) RLock() error {
+	return l.owner.wait(l.key, l.opts.Timeout, l.tryRead, nil)
+}
+
+func (l *localLocker) TryRLock() bool {
+	return l.owner.wait(l.key, 0, l.tryRead, nil) == nil
+}
+
+func (l *localLocker) RUnlock() {
 	p := l.owner
 	p.mux.Lock()
-	mux, ok := p.locks[l.key]
-	if !ok {
-		mux = &sync.Mutex{}
-		p.locks[l.key] = mux
+	if e := p.entries[l.key]; e != nil && e.readers > 0 {
+		e.readers--
+		p.release(l.key, e)
 	}
-	locked := tryLock(mux)
 	p.mux.Unlock()
-	return mux, locked
 }
 
-const mutexLocked = 1
+type localSemaphore struct {
+	owner *localLocking
+	key   string
+	n     int
+	opts  *Options
+	mux   sync.Mutex
+	held  int // 当前对象持有的名额,防止多次Release
+}
+
+func (s *localSemaphore) try(e *localEntry) bool {
+	if e.permits == 0 {
+		e.limit = s.n
+	}
+
+	if e.permits < e.limit {
+		e.permits++
+		return true
+	}
+
+	return false
+}
+
+func (s *localSemaphore) acquire(timeout time.Duration) error {
+	if err := s.owner.wait(s.key, timeout, s.try, nil); err != nil {
+		return err
+	}
 
-func tryLock(mux *sync.Mutex) bool {
-	return atomic.CompareAndSwapInt32((*int32)(unsafe.Pointer(mux)), 0, mutexLocked)
+	s.mux.Lock()
+	s.held++
+	s.mux.Unlock()
+	return nil
+}
+
+func (s *localSemaphore) Acquire() error {
+	return s.acquire(s.opts.Timeout)
+}
+
+func (s *localSemaphore) TryAcquire() bool {
+	return s.acquire(0) == nil
+}
+
+func (s *localSemaphore) Release() {
+	s.mux.Lock()
+	if s.held == 0 {
+		s.mux.Unlock()
+		return
+	}
+	s.held--
+	s.mux.Unlock()
+
+	p := s.owner
+	p.mux.Lock()
+	if e := p.entries[s.key]; e != nil && e.permits > 0 {
+		e.permits--
+		p.release(s.key, e)
+	}
+	p.mux.Unlock()
 }
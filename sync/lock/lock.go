@@ -5,7 +5,13 @@ import (
 	"sync/atomic"
 )
 
-var ErrNotLock = errors.New("not lock")
+// ErrLockLost 锁已经丢失,比如续约失败导致过期
+var ErrLockLost = errors.New("lock lost")
+
+var (
+	ErrNotLock    = errors.New("not lock")
+	ErrNotSupport = errors.New("not support")
+)
 
 var locking atomic.Value
 
@@ -47,14 +53,38 @@ func Lock(key string, opts ...Option) (Locker, error) {
 	return l, nil
 }
 
+// NewRW 使用默认的locking创建读写锁,不支持时返回ErrNotSupport
+func NewRW(key string, opts ...Option) (RWLocker, error) {
+	l, ok := GetDefault().(RWLocking)
+	if !ok {
+		return nil, ErrNotSupport
+	}
+
+	o := Options{}
+	o.Build(opts...)
+	return l.AcquireRW(key, &o)
+}
+
+// NewSemaphore 使用默认的locking创建信号量,最多允许n个持有者,不支持时返回ErrNotSupport
+func NewSemaphore(key string, n int, opts ...Option) (Semaphore, error) {
+	l, ok := GetDefault().(SemaphoreLocking)
+	if !ok {
+		return nil, ErrNotSupport
+	}
+
+	o := Options{}
+	o.Build(opts...)
+	return l.AcquireSemaphore(key, n, &o)
+}
+
 // Locking 分布式锁系统,distributed locking system
-// 可以基于redis实现,也可以基于consul,etcd等实现
+// 可以基于redis实现,也可以基于consul,etcd等实现,sync/lock/kv基于store.Store实现
 // 可选参数:
 // TTL:通常不需要设置,用于指示业务过期时间,防止服务器宕机永远无法释放锁
 // Timeout:用于指示Lock等待超时时间,0立即返回,-1表示永久等待,直到获取到锁
 //
 // 注意:因为目标是分布式锁,为了防止永久死锁,必须要设置一个过期,插件库来保证自动续期
-// TODO:如何抽象乐观锁?
+// 乐观锁见sync/optimistic,基于store的Version或者orm的版本列实现
 //
 // github.com/go-redsync/redsync
 type Locking interface {
@@ -67,3 +97,66 @@ type Locker interface {
 	Lock() error
 	Unlock()
 }
+
+// TryLocker 可选接口,尝试获取锁,不会等待,忽略Timeout
+type TryLocker interface {
+	TryLock() bool
+}
+
+// RWLocking 可选接口,读写锁,同一个key可以同时有多个读者,或者一个写者
+type RWLocking interface {
+	AcquireRW(key string, opts *Options) (RWLocker, error)
+}
+
+// RWLocker 读写锁,Lock和Unlock用于写锁,RLock和RUnlock用于读锁,等待时间与Locker一致,由Options.Timeout决定
+// 有写者等待时,新的读者需要等待,防止写者饥饿
+type RWLocker interface {
+	Locker
+	TryLocker
+	RLock() error
+	RUnlock()
+	TryRLock() bool
+}
+
+// SemaphoreLocking 可选接口,计数信号量,用于限制同时执行的数量,比如集群中最多N个worker
+type SemaphoreLocking interface {
+	AcquireSemaphore(key string, n int, opts *Options) (Semaphore, error)
+}
+
+// Semaphore 计数信号量,每次Acquire占用一个名额,Release释放一个名额
+// 同一个key的n以第一次创建时为准,所有名额释放后可以重新指定
+type Semaphore interface {
+	Acquire() error
+	TryAcquire() bool
+	Release()
+}
+
+// Fenced 可选接口,分布式锁获取成功后提供fencing token
+// Token: 每次获取锁时单调递增,下游写入时携带token,拒绝比已经见过的token更小的请求,
+// 这样即使旧的持有者因为GC停顿等原因在锁过期后继续写入,也会被拒绝,见Fence
+// Done: 锁丢失(续约失败)或者Unlock后关闭,持有锁期间需要监听,关闭后应该立即停止操作
+// Err: 锁因为续约失败丢失时返回ErrLockLost,否则返回nil,重新获取锁后重置
+type Fenced interface {
+	Token() int64
+	Done() <-chan struct{}
+	Err() error
+}
+
+// Fence 下游用于校验fencing token,只接受不小于已经见过的最大token的请求
+type Fence struct {
+	max int64
+}
+
+// Check 校验token,通过时记录为最大值
+func (f *Fence) Check(token int64) bool {
+	for {
+		max := atomic.LoadInt64(&f.max)
+		if token < max {
+			return false
+		}
+
+		if token == max || atomic.CompareAndSwapInt64(&f.max, max, token) {
+			return true
+		}
+	}
+}
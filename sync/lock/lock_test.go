@@ -23,3 +23,109 @@ func Foo(t *testing.T, index int) {
 	l.Unlock()
 	t.Log("free lock")
 }
+
+func TestRWLock(t *testing.T) {
+	r1, _ := NewRW("rw_key")
+	r2, _ := NewRW("rw_key")
+	w, _ := NewRW("rw_key", Timeout(time.Millisecond*200))
+
+	// 多个读者可以同时持有
+	if err := r1.RLock(); err != nil {
+		t.Fatal(err)
+	}
+	if !r2.TryRLock() {
+		t.Fatal("shared lock failed")
+	}
+
+	if w.TryLock() {
+		t.Fatal("exclusive lock while reading")
+	}
+
+	locked := make(chan error, 1)
+	go func() { locked <- w.Lock() }()
+	time.Sleep(time.Millisecond * 20)
+
+	// 有写者等待时,新的读者需要等待
+	if r1.TryRLock() {
+		t.Fatal("reader should wait for writer")
+	}
+
+	r1.RUnlock()
+	r2.RUnlock()
+	if err := <-locked; err != nil {
+		t.Fatal(err)
+	}
+
+	if r1.TryRLock() {
+		t.Fatal("shared lock while writing")
+	}
+
+	w.Unlock()
+	if !r1.TryRLock() {
+		t.Fatal("shared lock after unlock failed")
+	}
+	r1.RUnlock()
+}
+
+func TestReentrant(t *testing.T) {
+	a, _ := Lock("reentrant_key", Owner("job-1"))
+	// 相同Owner可以重复获取
+	b, err := Lock("reentrant_key", Owner("job-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := Lock("reentrant_key", Timeout(time.Millisecond*200))
+	if c != nil {
+		t.Fatal("lock acquired by other owner")
+	}
+
+	a.Unlock()
+	if c, _ := Lock("reentrant_key"); c != nil {
+		t.Fatal("lock released before all unlock")
+	}
+
+	b.Unlock()
+	c, err = Lock("reentrant_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Unlock()
+}
+
+func TestSemaphore(t *testing.T) {
+	const n = 3
+	var sems []Semaphore
+	for i := 0; i < n; i++ {
+		s, _ := NewSemaphore("sem_key", n, Blocking())
+		if !s.TryAcquire() {
+			t.Fatal("acquire failed", i)
+		}
+		sems = append(sems, s)
+	}
+	// 默认的locking是全局的,结束时释放所有名额,否则-count=2时会失败,重复Release不会多释放名额
+	defer func() {
+		for _, s := range sems {
+			s.Release()
+			s.Release()
+		}
+	}()
+
+	s, _ := NewSemaphore("sem_key", n, Timeout(time.Millisecond*50))
+	if err := s.Acquire(); err != ErrNotLock {
+		t.Fatal("should not acquire", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- sems[0].Acquire() }()
+	time.Sleep(time.Millisecond * 20)
+	sems[1].Release()
+	// 重复Release不会多释放名额
+	sems[1].Release()
+	if err := <-acquired; err != nil {
+		t.Fatal(err)
+	}
+
+	if s.TryAcquire() {
+		t.Fatal("acquire over limit")
+	}
+}
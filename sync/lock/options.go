@@ -13,6 +13,7 @@ type Option func(o *Options)
 type Options struct {
 	TTL     time.Duration // 表示宕机后最长TTL时间后可以重新获得锁,<=0则使用默认值
 	Timeout time.Duration // Lock等待超时时间,0则不等待立即返回,TimeoutMax则表示永不超时,直到获取到锁
+	Owner   string        // 持有者标识,非空时为可重入锁,相同Owner可以重复获取写锁,需要调用相同次数的Unlock
 }
 
 func (o *Options) Build(opts ...Option) {
@@ -45,3 +46,10 @@ func Blocking() Option {
 		o.Timeout = TimeoutMax
 	}
 }
+
+// Owner 设置持有者,相同持有者可以重复获取写锁
+func Owner(owner string) Option {
+	return func(o *Options) {
+		o.Owner = owner
+	}
+}
@@ -0,0 +1,109 @@
+// Package optimistic 乐观锁辅助函数
+// 读取数据和版本号,执行修改,写回时检查版本号是否变化,冲突则按照backoff等待后重试
+// 适用于冲突较少的场景,冲突频繁时应使用sync/lock
+package optimistic
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jeckbjy/gsk/orm"
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/util/backoff"
+)
+
+// ErrConflict 版本冲突,fn返回此错误时也会重试,超过重试次数后返回
+var ErrConflict = errors.New("optimistic conflict")
+
+// IsConflict 判断是否是版本冲突
+func IsConflict(err error) bool {
+	return err == ErrConflict || err == orm.ErrVersionConflict
+}
+
+// Retry 执行fn,返回冲突时重试,其他错误直接返回
+func Retry(fn func() error, opts ...Option) error {
+	o := Options{}
+	o.Build(opts...)
+	o.Backoff.Reset()
+
+	for i := 0; ; i++ {
+		err := fn()
+		if !IsConflict(err) {
+			return err
+		}
+
+		if o.MaxRetries >= 0 && i >= o.MaxRetries {
+			return err
+		}
+
+		d := o.Backoff.Next()
+		if d == backoff.Stop {
+			return err
+		}
+
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-o.Context.Done():
+			timer.Stop()
+			return o.Context.Err()
+		}
+	}
+}
+
+// Update 读取key并调用fn修改,key不存在时kv为nil,只有Version不变时才会写入fn返回的数据
+// store需要实现store.Transactional
+func Update(s store.Store, key string, fn func(kv *store.KV) ([]byte, error), opts ...Option) error {
+	o := Options{}
+	o.Build(opts...)
+
+	return Retry(func() error {
+		var version int64
+		kv, err := s.Get(o.Context, key)
+		switch {
+		case err == store.ErrNotFound:
+			kv = nil
+		case err != nil:
+			return err
+		default:
+			version = kv.Version
+		}
+
+		value, err := fn(kv)
+		if err != nil {
+			return err
+		}
+
+		ok, err := store.CompareAndSwap(o.Context, s, key, version, value)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrConflict
+		}
+
+		return nil
+	}, opts...)
+}
+
+// UpdateModel 查询一条记录解析到model中,调用fn修改model后写回,model需要是含有版本列(orm:"version")的struct指针
+// 版本检查由orm.Database.Update完成
+func UpdateModel(db *orm.Database, table string, filter orm.Cond, model interface{}, fn func() error, opts ...Option) error {
+	return Retry(func() error {
+		res, err := db.QueryOne(table, filter)
+		if err != nil {
+			return err
+		}
+
+		if err := res.Decode(model); err != nil {
+			return err
+		}
+
+		if err := fn(); err != nil {
+			return err
+		}
+
+		_, err = db.UpdateOne(table, filter, model)
+		return err
+	}, opts...)
+}
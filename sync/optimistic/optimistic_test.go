@@ -0,0 +1,161 @@
+package optimistic
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/gsk/orm"
+	"github.com/jeckbjy/gsk/orm/driver"
+	"github.com/jeckbjy/gsk/store"
+	"github.com/jeckbjy/gsk/store/memory"
+	"github.com/jeckbjy/gsk/util/backoff"
+)
+
+func TestUpdate(t *testing.T) {
+	s := memory.New()
+	defer s.Close()
+
+	// 多个协程模拟多个进程并发累加
+	const n = 4
+	const count = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < count; j++ {
+				err := Update(s, "counter", func(kv *store.KV) ([]byte, error) {
+					v := 0
+					if kv != nil {
+						v, _ = strconv.Atoi(string(kv.Value))
+					}
+					return []byte(strconv.Itoa(v + 1)), nil
+				}, MaxRetries(-1), Backoff(backoff.NewConstant(time.Millisecond)))
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	kv, err := s.Get(context.Background(), "counter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(kv.Value) != strconv.Itoa(n*count) {
+		t.Fatalf("bad counter, %s", kv.Value)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	calls := 0
+	err := Retry(func() error {
+		calls++
+		return ErrConflict
+	}, MaxRetries(2), Backoff(&backoff.ZeroBackOff{}))
+	if err != ErrConflict || calls != 3 {
+		t.Fatalf("bad retry, %v, %d", err, calls)
+	}
+}
+
+type account struct {
+	ID      int64 `db:"id"`
+	Balance int64 `db:"balance"`
+	Version int64 `db:"version" orm:"version"`
+}
+
+// memDB 只保存一条记录,用于测试版本检查
+type memDB struct {
+	driver.Database
+	mux      sync.Mutex
+	record   account
+	conflict int // 模拟其他人修改的次数
+}
+
+// memDriver 测试用驱动,所有数据库共享同一个memDB
+type memDriver struct {
+	db *memDB
+}
+
+func (d *memDriver) Name() string                                  { return "optimistic_mem" }
+func (d *memDriver) Open(opts *driver.OpenOptions) error           { return nil }
+func (d *memDriver) Close() error                                  { return nil }
+func (d *memDriver) Ping() error                                   { return nil }
+func (d *memDriver) Drop(name string) error                        { return nil }
+func (d *memDriver) Database(name string) (driver.Database, error) { return d.db, nil }
+
+func (d *memDB) Query(table string, filter driver.Cond, opts *driver.QueryOptions) (driver.QueryResult, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	r := &memResult{record: d.record}
+	if d.conflict > 0 {
+		d.conflict--
+		d.record.Version++
+	}
+	return r, nil
+}
+
+func (d *memDB) Update(table string, filter driver.Cond, update interface{}, opts *driver.UpdateOptions) (*driver.UpdateResult, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	a := update.(*account)
+	for _, c := range filter.(driver.ListCond).List() {
+		if e, ok := c.(driver.ExprCond); ok && e.Key() == "version" && e.Value().(int64) != d.record.Version {
+			return &driver.UpdateResult{}, nil
+		}
+	}
+	d.record = *a
+	return &driver.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+type memResult struct {
+	record account
+}
+
+func (r *memResult) Cursor() driver.Cursor { return nil }
+func (r *memResult) Decode(result interface{}) error {
+	*result.(*account) = r.record
+	return nil
+}
+
+// 驱动只能注册一次,放在init中,否则-count=2时会panic
+var gMemDriver = &memDriver{}
+
+func init() {
+	driver.Register("optimistic_mem", gMemDriver)
+}
+
+func TestUpdateModel(t *testing.T) {
+	m := &memDB{record: account{ID: 1, Balance: 100, Version: 1}, conflict: 2}
+	gMemDriver.db = m
+	c, err := orm.New("optimistic_mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, _ := c.Database("test")
+
+	calls := 0
+	a := &account{}
+	err = UpdateModel(db, "account", orm.Eq("id", 1), a, func() error {
+		calls++
+		a.Balance -= 10
+		return nil
+	}, Backoff(&backoff.ZeroBackOff{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 || m.record.Balance != 90 || m.record.Version != 4 || a.Version != 4 {
+		t.Fatalf("bad update, calls=%d, %+v", calls, m.record)
+	}
+
+	// 版本号不一致时不会修改
+	stale := &account{ID: 1, Balance: 0, Version: 1}
+	if _, err := db.UpdateOne("account", orm.Eq("id", 1), stale); err != orm.ErrVersionConflict || stale.Version != 1 {
+		t.Fatalf("should conflict, %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package optimistic
+
+import (
+	"context"
+	"time"
+
+	"github.com/jeckbjy/gsk/util/backoff"
+)
+
+const DefaultMaxRetries = 10
+
+type Option func(o *Options)
+
+type Options struct {
+	Context    context.Context
+	Backoff    backoff.BackOff // 冲突后的等待时间,默认指数退避,10ms-1s
+	MaxRetries int             // 最大重试次数,默认10,小于0表示不限制
+}
+
+func (o *Options) Build(opts ...Option) {
+	o.MaxRetries = DefaultMaxRetries
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	if o.Context == nil {
+		o.Context = context.Background()
+	}
+
+	if o.Backoff == nil {
+		o.Backoff = backoff.NewExponential(
+			backoff.WithMin(time.Millisecond*10),
+			backoff.WithMax(time.Second),
+			backoff.WithJitter(true),
+		)
+	}
+}
+
+func Context(ctx context.Context) Option {
+	return func(o *Options) {
+		o.Context = ctx
+	}
+}
+
+func Backoff(b backoff.BackOff) Option {
+	return func(o *Options) {
+		o.Backoff = b
+	}
+}
+
+func MaxRetries(n int) Option {
+	return func(o *Options) {
+		o.MaxRetries = n
+	}
+}
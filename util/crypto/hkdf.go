@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+var ErrKeyTooLong = errors.New("hkdf: key too long")
+
+// HKDF 基于HMAC-SHA256的密钥派生函数,用于从协商的共享密钥中派生出多个独立的密钥
+// salt可以为空,info用于区分不同用途的密钥
+//
+// https://tools.ietf.org/html/rfc5869
+func HKDF(secret, salt, info []byte, length int) ([]byte, error) {
+	return HKDFExpand(HKDFExtract(secret, salt), info, length)
+}
+
+// HKDFExtract 从secret中提取固定长度的伪随机密钥
+func HKDFExtract(secret, salt []byte) []byte {
+	if len(salt) == 0 {
+		salt = make([]byte, sha256.Size)
+	}
+
+	h := hmac.New(sha256.New, salt)
+	h.Write(secret)
+	return h.Sum(nil)
+}
+
+// HKDFExpand 将伪随机密钥扩展成指定长度
+func HKDFExpand(prk, info []byte, length int) ([]byte, error) {
+	if length > 255*sha256.Size {
+		return nil, ErrKeyTooLong
+	}
+
+	result := make([]byte, 0, length)
+	h := hmac.New(sha256.New, prk)
+	var prev []byte
+	for counter := byte(1); len(result) < length; counter++ {
+		h.Reset()
+		h.Write(prev)
+		h.Write(info)
+		h.Write([]byte{counter})
+		prev = h.Sum(nil)
+		result = append(result, prev...)
+	}
+
+	return result[:length], nil
+}
@@ -66,7 +66,8 @@ func (s *SummarySignature) Verify(sign string, src string) error {
 		return err
 	}
 
-	if signRaw != sign {
+	// 使用常量时间比较,防止时序攻击
+	if !hmac.Equal([]byte(signRaw), []byte(sign)) {
 		return ErrVerifyFail
 	}
 
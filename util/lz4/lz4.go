@@ -0,0 +1,228 @@
+// Package lz4 实现了lz4的block格式压缩与解压,不包含frame格式
+// 压缩使用简单的贪心匹配,速度优先,压缩率略低于官方实现,但输出与官方block格式兼容
+//
+// block格式:由多个sequence组成
+//	token[1byte] + literal length[n byte] + literals + offset[2byte] + match length[n byte]
+//	token高4位为literal长度,低4位为match长度-4,等于15时表示后边还有额外长度,每个字节累加,直到不等于255
+//	最后一个sequence只有literals,没有offset和match
+//
+// https://github.com/lz4/lz4/blob/dev/doc/lz4_Block_format.md
+package lz4
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrShortBuffer = errors.New("lz4: short buffer")
+	ErrCorrupt     = errors.New("lz4: corrupt input")
+)
+
+const (
+	minMatch     = 4
+	lastLiterals = 5     // 最后5个字节必须是literal
+	mfLimit      = 12    // 最后一个match必须在结尾12个字节之前开始
+	maxOffset    = 65535 // 最大回溯距离
+	hashLog      = 14
+	hashShift    = 32 - hashLog
+	skipTrigger  = 6 // 连续未匹配时加快步进,用于跳过不可压缩数据
+)
+
+var gTablePool = sync.Pool{
+	New: func() interface{} {
+		return new([1 << hashLog]int32)
+	},
+}
+
+// CompressBound 返回压缩后数据的最大长度
+func CompressBound(size int) int {
+	return size + size/255 + 16
+}
+
+// Compress 压缩src到dst中,返回压缩后长度,dst长度需要不小于CompressBound(len(src))
+func Compress(dst, src []byte) (int, error) {
+	if len(dst) < CompressBound(len(src)) {
+		return 0, ErrShortBuffer
+	}
+
+	table := gTablePool.Get().(*[1 << hashLog]int32)
+	defer func() {
+		*table = [1 << hashLog]int32{}
+		gTablePool.Put(table)
+	}()
+
+	si, di, anchor := 0, 0, 0
+	limit := len(src) - mfLimit
+	for si < limit {
+		seq := binary.LittleEndian.Uint32(src[si:])
+		h := hash(seq)
+		// 存储位置+1,0表示空
+		ref := int(table[h]) - 1
+		table[h] = int32(si + 1)
+		if ref < 0 || si-ref > maxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+			si += 1 + (si-anchor)>>skipTrigger
+			continue
+		}
+
+		// 向前扩展
+		for si > anchor && ref > 0 && src[si-1] == src[ref-1] {
+			si--
+			ref--
+		}
+
+		// 向后扩展
+		length := minMatch
+		for si+length < len(src)-lastLiterals && src[si+length] == src[ref+length] {
+			length++
+		}
+
+		di = writeSequence(dst, di, src[anchor:si], si-ref, length)
+		si += length
+		anchor = si
+	}
+
+	di = writeLiterals(dst, di, src[anchor:])
+	return di, nil
+}
+
+// Decompress 解压src到dst中,返回解压后长度,dst需要预先分配足够空间
+func Decompress(dst, src []byte) (int, error) {
+	si, di := 0, 0
+	for si < len(src) {
+		token := src[si]
+		si++
+
+		// literals
+		literals := int(token >> 4)
+		if literals == 0xF {
+			n, err := readLength(src, &si)
+			if err != nil {
+				return 0, err
+			}
+			literals += n
+		}
+
+		if si+literals > len(src) {
+			return 0, ErrCorrupt
+		}
+		if di+literals > len(dst) {
+			return 0, ErrShortBuffer
+		}
+		copy(dst[di:], src[si:si+literals])
+		si += literals
+		di += literals
+
+		// 最后一个sequence
+		if si == len(src) {
+			break
+		}
+
+		// match
+		if si+2 > len(src) {
+			return 0, ErrCorrupt
+		}
+		offset := int(binary.LittleEndian.Uint16(src[si:]))
+		si += 2
+		if offset == 0 || offset > di {
+			return 0, ErrCorrupt
+		}
+
+		length := int(token & 0xF)
+		if length == 0xF {
+			n, err := readLength(src, &si)
+			if err != nil {
+				return 0, err
+			}
+			length += n
+		}
+		length += minMatch
+
+		if di+length > len(dst) {
+			return 0, ErrShortBuffer
+		}
+
+		// 可能存在重叠,不能直接使用copy
+		pos := di - offset
+		if offset >= length {
+			copy(dst[di:di+length], dst[pos:pos+length])
+		} else {
+			for i := 0; i < length; i++ {
+				dst[di+i] = dst[pos+i]
+			}
+		}
+		di += length
+	}
+
+	return di, nil
+}
+
+func hash(seq uint32) uint32 {
+	return (seq * 2654435761) >> hashShift
+}
+
+func readLength(src []byte, si *int) (int, error) {
+	length := 0
+	for {
+		if *si >= len(src) {
+			return 0, ErrCorrupt
+		}
+		b := src[*si]
+		*si++
+		length += int(b)
+		if b != 0xFF {
+			return length, nil
+		}
+	}
+}
+
+func writeLength(dst []byte, di int, length int) int {
+	for length >= 0xFF {
+		dst[di] = 0xFF
+		di++
+		length -= 0xFF
+	}
+	dst[di] = byte(length)
+	return di + 1
+}
+
+func writeSequence(dst []byte, di int, literals []byte, offset int, length int) int {
+	token := di
+	di++
+
+	var tag byte
+	if len(literals) >= 0xF {
+		tag = 0xF << 4
+		di = writeLength(dst, di, len(literals)-0xF)
+	} else {
+		tag = byte(len(literals)) << 4
+	}
+	di += copy(dst[di:], literals)
+
+	binary.LittleEndian.PutUint16(dst[di:], uint16(offset))
+	di += 2
+
+	length -= minMatch
+	if length >= 0xF {
+		tag |= 0xF
+		di = writeLength(dst, di, length-0xF)
+	} else {
+		tag |= byte(length)
+	}
+
+	dst[token] = tag
+	return di
+}
+
+func writeLiterals(dst []byte, di int, literals []byte) int {
+	if len(literals) >= 0xF {
+		dst[di] = 0xF << 4
+		di = writeLength(dst, di+1, len(literals)-0xF)
+	} else {
+		dst[di] = byte(len(literals)) << 4
+		di++
+	}
+
+	return di + copy(dst[di:], literals)
+}
@@ -0,0 +1,76 @@
+package lz4
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func roundTrip(t *testing.T, src []byte) int {
+	dst := make([]byte, CompressBound(len(src)))
+	n, err := Compress(dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := make([]byte, len(src))
+	m, err := Decompress(out, dst[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m != len(src) || !bytes.Equal(out, src) {
+		t.Fatalf("data not equal, len=%+v", len(src))
+	}
+
+	return n
+}
+
+func TestRoundTrip(t *testing.T) {
+	roundTrip(t, nil)
+	roundTrip(t, []byte("a"))
+	roundTrip(t, []byte("hello world"))
+
+	text := bytes.Repeat([]byte("inventory:item=1001,count=20;"), 1000)
+	n := roundTrip(t, text)
+	if n >= len(text)/4 {
+		t.Fatalf("bad ratio, %+v/%+v", n, len(text))
+	}
+	t.Logf("compress %+v => %+v", len(text), n)
+
+	// 重叠复制
+	roundTrip(t, bytes.Repeat([]byte{'x'}, 100000))
+
+	// 不可压缩数据
+	random := make([]byte, 100000)
+	rand.Read(random)
+	roundTrip(t, random)
+
+	// 混合数据
+	mixed := append(random[:5000:5000], text...)
+	mixed = append(mixed, random[5000:6000]...)
+	roundTrip(t, mixed)
+}
+
+func TestCorrupt(t *testing.T) {
+	src := bytes.Repeat([]byte("corrupt data test,"), 100)
+	dst := make([]byte, CompressBound(len(src)))
+	n, _ := Compress(dst, src)
+
+	if _, err := Decompress(make([]byte, len(src)/2), dst[:n]); err == nil {
+		t.Fatal("expect short buffer")
+	}
+
+	if _, err := Decompress(make([]byte, len(src)), dst[:n/2]); err == nil {
+		t.Fatal("expect corrupt")
+	}
+}
+
+func BenchmarkCompress(b *testing.B) {
+	src := bytes.Repeat([]byte("inventory:item=1001,count=20;"), 1000)
+	dst := make([]byte, CompressBound(len(src)))
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		_, _ = Compress(dst, src)
+	}
+}
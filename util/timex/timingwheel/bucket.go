@@ -30,6 +30,8 @@ func (b *bucket) Front() *Timer {
 }
 
 func (b *bucket) Push(t *Timer) {
+	t.prev = b.tail
+	t.next = nil
 	if b.tail != nil {
 		b.tail.next = t
 		b.tail = t
@@ -44,9 +46,13 @@ func (b *bucket) Push(t *Timer) {
 func (b *bucket) Remove(t *Timer) {
 	if t.prev != nil {
 		t.prev.next = t.next
+	} else if b.head == t {
+		b.head = t.next
 	}
 	if t.next != nil {
 		t.next.prev = t.prev
+	} else if b.tail == t {
+		b.tail = t.prev
 	}
 
 	t.list = nil
@@ -57,8 +63,13 @@ func (b *bucket) Remove(t *Timer) {
 
 // 注意:为了减少遍历,这里timer所关联的list并没有同步修改,在外部特定个地方一次性处理
 func (b *bucket) merge(other *bucket) {
+	if other.head == nil {
+		return
+	}
+
 	if b.tail != nil {
 		b.tail.next = other.head
+		other.head.prev = b.tail
 		b.tail = other.tail
 		b.size += other.size
 	} else {
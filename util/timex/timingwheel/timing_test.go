@@ -2,9 +2,27 @@ package timingwheel
 
 import (
 	"testing"
+	"time"
 )
 
 func TestTimer(t *testing.T) {
 	//time.NewTimer()
 	//time.NewTicker()
 }
+
+// 执行过的timer从bucket中删除后,bucket需要能够继续使用
+func TestSequential(t *testing.T) {
+	tw := New()
+	defer tw.Stop()
+
+	for _, d := range []int64{5, 50, 3, 100, 1} {
+		done := make(chan struct{}, 1)
+		now := time.Now().UnixNano() / int64(time.Millisecond)
+		tw.NewTimer(now+d, func() { done <- struct{}{} })
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("timer not fired, %d", d)
+		}
+	}
+}
@@ -54,4 +54,5 @@ type Service interface {
 	Register(callback interface{}, opts ...arpc.MiscOption) error
 	Send(service string, req interface{}, opts ...arpc.MiscOption) error
 	Call(service string, req interface{}, rsp interface{}, opts ...arpc.MiscOption) error
+	Broadcast(service string, req interface{}, rsp interface{}, opts ...arpc.MiscOption) (map[string]*arpc.Reply, error)
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/jeckbjy/gsk/arpc/server"
 	"github.com/jeckbjy/gsk/broker"
 	"github.com/jeckbjy/gsk/exec"
+	"github.com/jeckbjy/gsk/exec/actor"
 	"github.com/jeckbjy/gsk/registry"
 	"github.com/jeckbjy/gsk/selector"
 	rselector "github.com/jeckbjy/gsk/selector/registry"
@@ -35,6 +36,7 @@ func newOptions(name string, opts ...Option) *Options {
 		ef := fexec.New(
 			fexec.Router(o.Router),
 			fexec.Executor(o.Exec),
+			fexec.Actors(o.Actors),
 		)
 		tran.AddFilters(fframe.New(), logging.New(), ef)
 	} else {
@@ -52,6 +54,7 @@ func newOptions(name string, opts ...Option) *Options {
 			server.ID(o.Id),
 			server.Address(o.Address),
 			server.Advertise(o.Advertise),
+			server.Router(o.Router),
 		)
 	}
 
@@ -61,6 +64,7 @@ func newOptions(name string, opts ...Option) *Options {
 			client.Transport(tran),
 			client.Proxy(o.Proxy),
 			client.Selector(o.Selector),
+			client.Interceptor(o.Interceptors...),
 		)
 	}
 
@@ -76,23 +80,25 @@ type Option func(o *Options)
 //	1:每个使用到的组件都提供了SetDefault选项用于全局替换组件,只需要初始化的地方设置一下即可,比如registry,selector,anet等
 // 	2:有一些会经常用到的参数可直接在Options中设置,用于初始化Server和Client,如Proxy
 type Options struct {
-	Context     context.Context
-	BeforeStart []Callback
-	AfterStart  []Callback
-	BeforeStop  []Callback
-	AfterStop   []Callback
-	Broker      broker.Broker
-	Server      arpc.Server
-	Client      arpc.Client
-	Router      arpc.Router
-	Selector    selector.Selector
-	Exec        exec.Executor
-	Filters     []anet.Filter
-	Name        string
-	Id          string
-	Address     string
-	Advertise   string
-	Proxy       string
+	Context      context.Context
+	BeforeStart  []Callback
+	AfterStart   []Callback
+	BeforeStop   []Callback
+	AfterStop    []Callback
+	Broker       broker.Broker
+	Server       arpc.Server
+	Client       arpc.Client
+	Router       arpc.Router
+	Selector     selector.Selector
+	Exec         exec.Executor
+	Actors       *actor.System
+	Filters      []anet.Filter
+	Interceptors []arpc.Interceptor
+	Name         string
+	Id           string
+	Address      string
+	Advertise    string
+	Proxy        string
 }
 
 func Context(c context.Context) Option {
@@ -143,6 +149,13 @@ func Executor(e exec.Executor) Option {
 	}
 }
 
+// Actors 带有UserID的消息投递到对应用户的actor中串行执行
+func Actors(s *actor.System) Option {
+	return func(o *Options) {
+		o.Actors = s
+	}
+}
+
 func Filter(f ...anet.Filter) Option {
 	return func(o *Options) {
 		o.Filters = f
@@ -172,3 +185,10 @@ func Proxy(proxy string) Option {
 		o.Proxy = proxy
 	}
 }
+
+// Interceptor 添加客户端拦截器,作用于Send和Call
+func Interceptor(i ...arpc.Interceptor) Option {
+	return func(o *Options) {
+		o.Interceptors = append(o.Interceptors, i...)
+	}
+}
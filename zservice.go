@@ -122,3 +122,7 @@ func (s *service) Send(service string, req interface{}, opts ...arpc.MiscOption)
 func (s *service) Call(service string, req interface{}, rsp interface{}, opts ...arpc.MiscOption) error {
 	return s.client.Call(service, req, rsp, opts...)
 }
+
+func (s *service) Broadcast(service string, req interface{}, rsp interface{}, opts ...arpc.MiscOption) (map[string]*arpc.Reply, error) {
+	return s.client.Broadcast(service, req, rsp, opts...)
+}